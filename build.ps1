@@ -150,7 +150,7 @@ Write-Info "Building components..."
 Write-Info ""
 
 # Build CLI Generator
-Write-Info "[1/2] Building patch generator (CLI)..."
+Write-Info "[1/4] Building patch generator (CLI)..."
 $generatorPath = Join-Path $versionDir "patch-gen.exe"
 & go build @buildFlags $generatorPath ./cmd/generator
 if ($LASTEXITCODE -eq 0) {
@@ -161,7 +161,7 @@ if ($LASTEXITCODE -eq 0) {
 }
 
 # Build CLI Applier
-Write-Info "[2/2] Building patch applier (CLI)..."
+Write-Info "[2/4] Building patch applier (CLI)..."
 $applierPath = Join-Path $versionDir "patch-apply.exe"
 & go build @buildFlags $applierPath ./cmd/applier
 if ($LASTEXITCODE -eq 0) {
@@ -171,6 +171,50 @@ if ($LASTEXITCODE -eq 0) {
     exit 1
 }
 
+# Build CLI Updater
+Write-Info "[3/4] Building updater (CLI)..."
+$updaterPath = Join-Path $versionDir "patch-update.exe"
+& go build @buildFlags $updaterPath ./cmd/updater
+if ($LASTEXITCODE -eq 0) {
+    Write-Success "  [OK] patch-update.exe"
+} else {
+    Write-Error "  [FAIL] Failed to build patch-update.exe"
+    exit 1
+}
+
+# Build CLI Patch Server
+Write-Info "[4/4] Building patch server (CLI)..."
+$serverPath = Join-Path $versionDir "patch-serve.exe"
+& go build @buildFlags $serverPath ./cmd/server
+if ($LASTEXITCODE -eq 0) {
+    Write-Success "  [OK] patch-serve.exe"
+} else {
+    Write-Error "  [FAIL] Failed to build patch-serve.exe"
+    exit 1
+}
+
+# Build applier stubs for self-contained executables on other platforms (patch-gen --exe-target)
+Write-Info "Building applier stubs..."
+$stubsDir = Join-Path $versionDir "stubs"
+if (-not (Test-Path $stubsDir)) {
+    New-Item -ItemType Directory -Path $stubsDir | Out-Null
+}
+foreach ($target in @("linux/amd64", "linux/arm64")) {
+    $goos, $goarch = $target.Split("/")
+    $stubPath = Join-Path $stubsDir "patch-apply-$goos-$goarch"
+    $env:GOOS = $goos
+    $env:GOARCH = $goarch
+    & go build @buildFlags $stubPath ./cmd/applier
+    $stubExit = $LASTEXITCODE
+    Remove-Item Env:GOOS, Env:GOARCH
+    if ($stubExit -eq 0) {
+        Write-Success "  [OK] stubs/patch-apply-$goos-$goarch"
+    } else {
+        Write-Error "  [FAIL] Failed to build stub for $target"
+        exit 1
+    }
+}
+
 Write-Info ""
 Write-Success "=== Build Complete ==="
 Write-Info ""
@@ -185,4 +229,6 @@ Write-Info ""
 Write-Info "To run:"
 Write-Info "  CLI Generator:      .\dist\$version\patch-gen.exe --help"
 Write-Info "  CLI Applier:        .\dist\$version\patch-apply.exe --help"
+Write-Info "  CLI Updater:        .\dist\$version\patch-update.exe --help"
+Write-Info "  CLI Patch Server:   .\dist\$version\patch-serve.exe --help"
 Write-Info ""
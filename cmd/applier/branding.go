@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// defaultBannerTitle is shown by self-contained patches built without branding
+const defaultBannerTitle = "CyberPatchMaker - Self-Contained Patch"
+
+// brandingBanner returns the banner shown by self-contained patches, using the patch's branding if it has any
+func brandingBanner(patch *utils.Patch) string {
+	var b strings.Builder
+	b.WriteString("==============================================\n")
+	branding := patch.Branding
+	if branding == nil {
+		b.WriteString("  " + defaultBannerTitle + "\n")
+		b.WriteString("==============================================\n")
+		return b.String()
+	}
+
+	if patch.FullInstall {
+		fmt.Fprintf(&b, "  %s Setup\n", branding.ProductName)
+	} else {
+		fmt.Fprintf(&b, "  %s Update\n", branding.ProductName)
+	}
+	if branding.Publisher != "" {
+		fmt.Fprintf(&b, "  by %s\n", branding.Publisher)
+	}
+	b.WriteString("==============================================\n")
+	if branding.WelcomeText != "" {
+		fmt.Fprintf(&b, "\n%s\n", strings.TrimRight(branding.WelcomeText, "\n"))
+	}
+	if branding.SupportURL != "" {
+		fmt.Fprintf(&b, "\nSupport: %s\n", branding.SupportURL)
+	}
+	return b.String()
+}
+
+// hasEULA reports whether the patch carries a license agreement that must be accepted
+func hasEULA(patch *utils.Patch) bool {
+	return patch.Branding != nil && patch.Branding.EULA != ""
+}
+
+// eulaChecksum returns a short checksum of the EULA text, recorded with its acceptance
+func eulaChecksum(patch *utils.Patch) string {
+	sum := sha256.Sum256([]byte(patch.Branding.EULA))
+	return fmt.Sprintf("%x", sum[:8])
+}
+
+// promptEULA shows the patch's license agreement and asks the user to accept it.
+// Returns true if the patch has no EULA or the user accepted it.
+func promptEULA(patch *utils.Patch, reader *bufio.Reader, logOutput func(format string, args ...interface{})) bool {
+	if !hasEULA(patch) {
+		return true
+	}
+
+	logOutput("\n==============================================\n")
+	logOutput("License Agreement\n")
+	logOutput("==============================================\n")
+	logOutput("%s\n", patch.Branding.EULA)
+	logOutput("==============================================\n")
+	// The prompt and the typed answer are only shown on the console; the log records the outcome
+	fmt.Print("Do you accept the license agreement? (yes/no): ")
+	answer, _ := reader.ReadString('\n')
+	answer = strings.TrimSpace(strings.ToLower(answer))
+
+	if answer != "yes" && answer != "y" {
+		logOutput("License agreement declined at %s\n", time.Now().UTC().Format("2006-01-02 15:04:05 UTC"))
+		return false
+	}
+	logOutput("License agreement accepted at %s (EULA %s)\n", time.Now().UTC().Format("2006-01-02 15:04:05 UTC"), eulaChecksum(patch))
+	return true
+}
+
+// checkEULAFlag enforces --accept-eula for non-interactive runs.
+// Returns an error if the patch has a EULA and it was not accepted on the command line.
+func checkEULAFlag(patch *utils.Patch, acceptEULA bool) error {
+	if !hasEULA(patch) {
+		return nil
+	}
+	if !acceptEULA {
+		return fmt.Errorf("%s requires accepting its license agreement; review it and rerun with --accept-eula", patch.Branding.ProductName)
+	}
+	return nil
+}
+
+// eulaAcceptedNote describes an acceptance given with --accept-eula, for logs
+func eulaAcceptedNote(patch *utils.Patch) string {
+	return fmt.Sprintf("License agreement accepted via --accept-eula at %s (EULA %s)",
+		time.Now().UTC().Format("2006-01-02 15:04:05 UTC"), eulaChecksum(patch))
+}
+
+// supportHint returns a line pointing at the product's support URL, or "" if the patch has none
+func supportHint(patch *utils.Patch) string {
+	if patch.Branding == nil || patch.Branding.SupportURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("Need help? Visit %s\n", patch.Branding.SupportURL)
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/config"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/manifest"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/patcher"
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// runCommand dispatches "patch-apply <command> [options]" invocations.
+// Returns the exit code and true if args starts with a known command.
+func runCommand(args []string) (int, bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+
+	switch args[0] {
+	case "rollback":
+		return runRollbackCommand(args[1:]), true
+	case "repair":
+		return runRepairCommand(args[1:]), true
+	case "verify":
+		return runVerifyCommand(args[1:]), true
+	}
+	return 0, false
+}
+
+// runRollbackCommand restores an install to the patch's source version from backup.cyberpatcher
+func runRollbackCommand(args []string) int {
+	fs := flag.NewFlagSet("rollback", flag.ExitOnError)
+	patchFile := fs.String("patch", "", "Path to the patch that was applied")
+	currentDir := fs.String("current-dir", "", "Directory containing the patched installation")
+	fs.Usage = func() {
+		fmt.Println("Usage: patch-apply rollback --patch <file> --current-dir <directory>")
+		fmt.Println("\nRestores the installation to the patch's source version using the backup")
+		fmt.Println("(backup.cyberpatcher) created when the patch was applied.")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *patchFile == "" || *currentDir == "" {
+		fmt.Println("Error: --patch and --current-dir are required")
+		fs.Usage()
+		return 1
+	}
+
+	if !utils.FileExists(*patchFile) {
+		fmt.Printf("Error: patch file not found: %s\n", *patchFile)
+		return 1
+	}
+
+	patch, err := loadPatch(*patchFile)
+	if err != nil {
+		fmt.Printf("Error: failed to load patch: %v\n", err)
+		return 1
+	}
+
+	if err := patcher.NewApplier().RollbackPatch(patch, *currentDir); err != nil {
+		fmt.Printf("Error: rollback failed: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// runRepairCommand rewrites missing or corrupted files using the target manifest and file data in a patch
+func runRepairCommand(args []string) int {
+	fs := flag.NewFlagSet("repair", flag.ExitOnError)
+	patchFile := fs.String("patch", "", "Path to a patch generated with --embed-manifest")
+	currentDir := fs.String("current-dir", "", "Directory containing the installation to repair")
+	dryRun := fs.Bool("dry-run", false, "Report damaged files without changing anything")
+	jobs := fs.Int("jobs", 0, "Number of parallel workers for hashing (0 = auto-detect CPU cores)")
+	fs.Usage = func() {
+		fmt.Println("Usage: patch-apply repair --patch <file> --current-dir <directory> [--dry-run]")
+		fmt.Println("\nCompares the installation with the target manifest embedded in the patch and")
+		fmt.Println("rewrites missing or mismatched files from the file data the patch carries.")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *patchFile == "" || *currentDir == "" {
+		fmt.Println("Error: --patch and --current-dir are required")
+		fs.Usage()
+		return 1
+	}
+
+	if !utils.FileExists(*patchFile) {
+		fmt.Printf("Error: patch file not found: %s\n", *patchFile)
+		return 1
+	}
+
+	patch, err := loadPatch(*patchFile)
+	if err != nil {
+		fmt.Printf("Error: failed to load patch: %v\n", err)
+		return 1
+	}
+
+	applier := patcher.NewApplier()
+	applier.SetWorkerThreads(config.ResolveWorkerCount(*jobs))
+
+	result, err := applier.Repair(patch, *currentDir, *dryRun)
+	if err != nil {
+		fmt.Printf("Error: repair failed: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("\nChecked %d files: %d missing, %d mismatched\n", result.Checked, len(result.Missing), len(result.Mismatched))
+	if result.DirsCreated > 0 {
+		if *dryRun {
+			fmt.Printf("Would create %d missing directories\n", result.DirsCreated)
+		} else {
+			fmt.Printf("Created %d missing directories\n", result.DirsCreated)
+		}
+	}
+	if *dryRun {
+		for _, path := range result.Repaired {
+			fmt.Printf("  Would repair: %s\n", path)
+		}
+	} else if len(result.Repaired) > 0 {
+		fmt.Printf("Repaired %d files\n", len(result.Repaired))
+	}
+
+	if len(result.Unrepairable) > 0 {
+		fmt.Printf("\n%d files could not be repaired (the patch carries no data for them):\n", len(result.Unrepairable))
+		for _, path := range result.Unrepairable {
+			fmt.Printf("  - %s\n", path)
+		}
+		return 1
+	}
+
+	if result.Damaged() == 0 {
+		fmt.Println("Installation matches the manifest, nothing to repair")
+	} else if !*dryRun {
+		fmt.Printf("Installation repaired to version %s\n", patch.ToVersion)
+	}
+	return 0
+}
+
+// Exit codes of the verify command, by severity
+const (
+	verifyExitOK      = 0 // Install matches the manifest
+	verifyExitError   = 1 // Verification could not be performed
+	verifyExitMinor   = 2 // Only extra files or permission differences
+	verifyExitDamaged = 3 // Missing or mismatched files
+)
+
+// runVerifyCommand checks an install against a manifest file or the target manifest embedded in a patch
+func runVerifyCommand(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	manifestFile := fs.String("manifest", "", "Manifest file to verify against (saved with patch-gen --save-manifest, or the "+utils.PackageInfoFileName+" of a full-install package)")
+	patchFile := fs.String("patch", "", "Patch with an embedded target manifest (alternative to --manifest)")
+	currentDir := fs.String("current-dir", "", "Directory containing the installation to verify")
+	jsonOutput := fs.Bool("json", false, "Print the report as JSON")
+	jobs := fs.Int("jobs", 0, "Number of parallel workers for hashing (0 = auto-detect CPU cores)")
+	trustKey := fs.String("trust-key", "", "Public key file(s) the package info must be signed with (comma-separated)")
+	fs.Usage = func() {
+		fmt.Println("Usage: patch-apply verify (--manifest <file> | --patch <file>) --current-dir <directory> [--json]")
+		fmt.Println("\nReports missing, mismatched, extra and permission-differing files.")
+		fmt.Printf("An install extracted from a full-install package is verified against its %s\n", utils.PackageInfoFileName)
+		fmt.Println("when neither --manifest nor --patch is given.")
+		fmt.Println("\nExit codes:")
+		fmt.Println("  0  Installation matches the manifest")
+		fmt.Println("  1  Verification could not be performed")
+		fmt.Println("  2  Only extra files or permission differences")
+		fmt.Println("  3  Missing or mismatched files")
+		fmt.Println("\nOptions:")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	// An install extracted from a full-install package carries its own manifest
+	if *currentDir != "" && *manifestFile == "" && *patchFile == "" {
+		if infoFile := filepath.Join(*currentDir, utils.PackageInfoFileName); utils.FileExists(infoFile) {
+			*manifestFile = infoFile
+		}
+	}
+	if *currentDir == "" || (*manifestFile == "") == (*patchFile == "") {
+		fmt.Fprintln(os.Stderr, "Error: --current-dir and exactly one of --manifest or --patch are required")
+		fs.Usage()
+		return verifyExitError
+	}
+
+	var trustedKeys []ed25519.PublicKey
+	if *trustKey != "" {
+		for _, keyPath := range strings.Split(*trustKey, ",") {
+			key, err := utils.LoadPublicKey(strings.TrimSpace(keyPath))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return verifyExitError
+			}
+			trustedKeys = append(trustedKeys, key)
+		}
+	}
+
+	target, err := loadVerifyManifest(*manifestFile, *patchFile, trustedKeys)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return verifyExitError
+	}
+
+	report, err := manifest.NewManager().VerifyInstall(target, *currentDir, config.ResolveWorkerCount(*jobs))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: verification failed: %v\n", err)
+		return verifyExitError
+	}
+
+	if *jsonOutput {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to encode report: %v\n", err)
+			return verifyExitError
+		}
+		fmt.Println(string(data))
+	} else {
+		printVerifyReport(report)
+	}
+
+	switch report.Severity() {
+	case manifest.SeverityDamaged:
+		return verifyExitDamaged
+	case manifest.SeverityMinor:
+		return verifyExitMinor
+	}
+	return verifyExitOK
+}
+
+// loadVerifyManifest loads the manifest to verify against from a manifest file, the package info
+// of a full-install package or a patch. Package info must be signed by one of trustedKeys, if any.
+func loadVerifyManifest(manifestFile, patchFile string, trustedKeys []ed25519.PublicKey) (*utils.Manifest, error) {
+	if manifestFile != "" && filepath.Base(manifestFile) == utils.PackageInfoFileName {
+		info, err := loadPackageInfo(manifestFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(trustedKeys) > 0 {
+			keyID, err := utils.VerifyPackageSignature(info, trustedKeys)
+			if err != nil {
+				return nil, fmt.Errorf("package signature verification failed: %w", err)
+			}
+			fmt.Fprintf(os.Stderr, "✓ Package info signed by trusted key %s\n", keyID)
+		}
+		return info.Manifest, nil
+	}
+	if manifestFile != "" {
+		return manifest.NewManager().LoadManifest(manifestFile)
+	}
+
+	if !utils.FileExists(patchFile) {
+		return nil, fmt.Errorf("patch file not found: %s", patchFile)
+	}
+	patch, err := loadPatch(patchFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load patch: %w", err)
+	}
+	if patch.TargetManifest == nil {
+		return nil, fmt.Errorf("patch does not contain a target manifest (generate it with --embed-manifest)")
+	}
+	return patch.TargetManifest, nil
+}
+
+// printVerifyReport prints a human-readable verification report
+func printVerifyReport(report *manifest.VerifyReport) {
+	fmt.Printf("Verified %s against version %s manifest (%d files)\n", report.Directory, report.Version, report.Checked)
+
+	if len(report.Missing) > 0 {
+		fmt.Printf("\nMissing (%d):\n", len(report.Missing))
+		for _, path := range report.Missing {
+			fmt.Printf("  - %s\n", path)
+		}
+	}
+	if len(report.Mismatched) > 0 {
+		fmt.Printf("\nMismatched (%d):\n", len(report.Mismatched))
+		for _, m := range report.Mismatched {
+			if m.Error != "" {
+				fmt.Printf("  - %s: %s\n", m.Path, m.Error)
+			} else {
+				fmt.Printf("  - %s: expected %s, got %s\n", m.Path, patcher.ShortChecksum(m.Expected), patcher.ShortChecksum(m.Actual))
+			}
+		}
+	}
+	if len(report.Extra) > 0 {
+		fmt.Printf("\nExtra (%d):\n", len(report.Extra))
+		for _, path := range report.Extra {
+			fmt.Printf("  - %s\n", path)
+		}
+	}
+	if len(report.ModeDiffers) > 0 {
+		fmt.Printf("\nMode differs (%d):\n", len(report.ModeDiffers))
+		for _, m := range report.ModeDiffers {
+			fmt.Printf("  - %s: expected executable=%t, got executable=%t\n", m.Path, m.ExpectedExecutable, m.ActualExecutable)
+		}
+	}
+
+	switch report.Severity() {
+	case manifest.SeverityDamaged:
+		fmt.Println("\nResult: installation is damaged")
+	case manifest.SeverityMinor:
+		fmt.Println("\nResult: installation content is intact, with minor differences")
+	default:
+		fmt.Println("\nResult: installation matches the manifest")
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/patcher"
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// checkInstallDir is the dry-run check of a full install's directory, in place of the source
+// version checks of an update: the directory must be empty or not exist yet.
+// Returns false if the install cannot go there.
+func checkInstallDir(targetDir string, logOutput func(format string, args ...interface{})) bool {
+	logOutput("\nChecking install directory: %s\n", targetDir)
+	existing, err := patcher.InstallDirContents(targetDir)
+	if err != nil {
+		logOutput("✗ %v\n", err)
+		return false
+	}
+	if len(existing) > 0 {
+		logOutput("✗ Install directory is not empty: %s\n", strings.Join(existing, ", "))
+		logOutput("  A full install only goes into an empty directory; use an update patch for an existing install\n")
+		return false
+	}
+	if !utils.FileExists(targetDir) {
+		logOutput("✓ Install directory will be created\n")
+	} else {
+		logOutput("✓ Install directory is empty\n")
+	}
+	return true
+}
+
+// versionChange describes what applying the patch does to the install, for result messages
+func versionChange(patch *utils.Patch) string {
+	if patch.FullInstall {
+		return fmt.Sprintf("version %s installed", patch.ToVersion)
+	}
+	return fmt.Sprintf("%s → %s", patch.FromVersion, patch.ToVersion)
+}
+
+// loadPackageInfo reads the package info of a full-install package
+func loadPackageInfo(path string) (*utils.PackageInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package info: %w", err)
+	}
+	var info utils.PackageInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse package info: %w", err)
+	}
+	if info.Manifest == nil {
+		return nil, fmt.Errorf("package info %s has no manifest", path)
+	}
+	return &info, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/config"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/patcher"
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// locateInstalls searches the working directory, this executable's location, the patch's search roots
+// and the install history for installs of the patch's source version
+func locateInstalls(patch *utils.Patch, workingDir string) []patcher.InstallMatch {
+	opts := patcher.LocateOptions{WorkingDir: workingDir}
+	if exePath, err := os.Executable(); err == nil {
+		opts.ExeDir = filepath.Dir(exePath)
+	}
+	if history, err := patcher.LoadInstallHistory(config.GetDefaultHistoryPath()); err == nil {
+		opts.History = history.Paths()
+	}
+	return patcher.LocateInstalls(patch, opts)
+}
+
+// preferredInstall returns the install to use without asking: the working directory if it matched,
+// otherwise the only match. Returns false if there is no match or the choice is ambiguous.
+func preferredInstall(matches []patcher.InstallMatch) (patcher.InstallMatch, bool) {
+	for _, match := range matches {
+		if match.Source == patcher.SourceWorkingDir {
+			return match, true
+		}
+	}
+	if len(matches) == 1 {
+		return matches[0], true
+	}
+	return patcher.InstallMatch{}, false
+}
+
+// formatInstallMatches lists located installs, numbered from 1
+func formatInstallMatches(matches []patcher.InstallMatch) string {
+	var b strings.Builder
+	for i, match := range matches {
+		fmt.Fprintf(&b, "  %d. %s (%s)\n", i+1, match.Dir, match.Source)
+	}
+	return b.String()
+}
+
+// selectInstallMatch returns the match numbered by input (1-based), if input is such a number
+func selectInstallMatch(matches []patcher.InstallMatch, input string) (string, bool) {
+	n, err := strconv.Atoi(input)
+	if err != nil || n < 1 || n > len(matches) {
+		return "", false
+	}
+	return matches[n-1].Dir, true
+}
@@ -3,9 +3,9 @@ package main
 import (
 	"bufio"
 	"bytes"
-	"crypto/sha256"
-	"encoding/binary"
+	"crypto/ed25519"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -14,29 +14,19 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/config"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/embedded"
 	"github.com/cyberofficial/cyberpatchmaker/internal/core/patcher"
 	"github.com/cyberofficial/cyberpatchmaker/internal/core/version"
 	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
 )
 
-const (
-	MAGIC_BYTES = "CPMPATCH"
-	HEADER_SIZE = 128
-)
-
-type EmbeddedPatchHeader struct {
-	Magic       [8]byte
-	Version     uint32
-	StubSize    uint64
-	DataOffset  uint64
-	DataSize    uint64
-	Compression [16]byte
-	Checksum    [32]byte
-	Flags       byte
-	Reserved    [43]byte
-}
-
 func main() {
+	// Commands (patch-apply <command> [options]) have their own flag sets
+	if code, ok := runCommand(os.Args[1:]); ok {
+		os.Exit(code)
+	}
+
 	// Define flags
 	patchFile := flag.String("patch", "", "Path to patch file")
 	currentDir := flag.String("current-dir", "", "Directory containing current version")
@@ -46,6 +36,13 @@ func main() {
 	backup := flag.Bool("backup", true, "Create backup before patching")
 	ignore1GB := flag.Bool("ignore1gb", false, "Bypass 1GB patch size limit (use with caution)")
 	silent := flag.Bool("silent", false, "Silent mode: apply patch automatically without prompts (for automation)")
+	jobs := flag.Int("jobs", 0, "Number of parallel workers for hashing and applying (0 = auto-detect CPU cores, 1 = single-threaded)")
+	allowHooks := flag.Bool("allow-hooks", false, "Run hook scripts declared in the patch even if it is not signed by a trusted key")
+	trustKey := flag.String("trust-key", "", "Public key file(s) used to verify patch signatures (comma-separated)")
+	verifyTree := flag.Bool("verify-tree", false, "After patching, verify the whole tree against the patch's embedded target manifest")
+	verifySelf := flag.Bool("verify-self", false, "Check this self-contained executable (or --patch <exe>) without applying it")
+	extractDir := flag.String("extract", "", "Unpack the patch from this self-contained executable (or --patch <exe>) into a directory")
+	acceptEULA := flag.Bool("accept-eula", false, "Accept the patch's license agreement without prompting (required by --silent and --patch for patches with a EULA)")
 	versionFlag := flag.Bool("version", false, "Show version information")
 	help := flag.Bool("help", false, "Show help message")
 
@@ -62,21 +59,52 @@ func main() {
 		return
 	}
 
+	// Resolve worker count (same semantics as the generator's --jobs flag)
+	opts := &applyOptions{
+		workerCount: config.ResolveWorkerCount(*jobs),
+		allowHooks:  *allowHooks,
+		verifyTree:  *verifyTree,
+		acceptEULA:  *acceptEULA,
+	}
+
+	// Load trusted public keys for signature verification
+	if *trustKey != "" {
+		for _, keyPath := range strings.Split(*trustKey, ",") {
+			key, err := utils.LoadPublicKey(strings.TrimSpace(keyPath))
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			opts.trustedKeys = append(opts.trustedKeys, key)
+		}
+	}
+
+	// Inspect or unpack a self-contained executable instead of applying it
+	if *verifySelf || *extractDir != "" {
+		target, err := selfCheckTarget(*patchFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if *verifySelf {
+			os.Exit(runVerifySelf(target, opts.trustedKeys))
+		}
+		os.Exit(runExtract(target, *extractDir))
+	}
+
 	// Check if patch data is embedded in this executable
-	patch, targetDir, isEmbedded, embeddedSilent := checkEmbeddedPatch(*ignore1GB)
+	patch, targetDir, isEmbedded, embeddedSilent := checkEmbeddedPatch(*ignore1GB, opts)
 
 	if isEmbedded && patch != nil {
 		// Use embedded silent flag if set, otherwise check command-line flag
 		if embeddedSilent || *silent {
 			// Silent mode: apply patch automatically
-			runSilentMode(patch, targetDir, *currentDir, *keyFile)
+			runSilentMode(patch, targetDir, *currentDir, *keyFile, opts)
 			return
 		}
 		// Interactive console mode for embedded patch
-		fmt.Println("==============================================")
-		fmt.Println("  CyberPatchMaker - Self-Contained Patch")
-		fmt.Println("==============================================")
-		runInteractiveMode(patch, targetDir, *ignore1GB)
+		fmt.Print(brandingBanner(patch))
+		runInteractiveMode(patch, targetDir, *ignore1GB, opts)
 		return
 	}
 
@@ -93,12 +121,6 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Check if current directory exists
-	if !utils.FileExists(*currentDir) {
-		fmt.Printf("Error: current directory not found: %s\n", *currentDir)
-		os.Exit(1)
-	}
-
 	// Load patch
 	patch, err := loadPatch(*patchFile)
 	if err != nil {
@@ -106,6 +128,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Check if current directory exists (a full install creates it)
+	if !patch.FullInstall && !utils.FileExists(*currentDir) {
+		fmt.Printf("Error: current directory not found: %s\n", *currentDir)
+		os.Exit(1)
+	}
+
 	// Display patch information
 	displayPatchInfo(patch)
 
@@ -118,11 +146,22 @@ func main() {
 	if *dryRun {
 		fmt.Println("\n=== DRY RUN MODE ===")
 		fmt.Println("No changes will be made")
-		performDryRun(patch, *currentDir, *keyFile)
+		performDryRun(patch, *currentDir, *keyFile, *backup)
 		return
 	}
 
-	applier := patcher.NewApplier()
+	if err := checkEULAFlag(patch, opts.acceptEULA); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if hasEULA(patch) {
+		fmt.Printf("\n✓ %s\n", eulaAcceptedNote(patch))
+	}
+
+	applier := opts.newApplier()
+	if opts.workerCount > 1 {
+		fmt.Printf("\n✓ Using %d worker threads for verification and patching\n", opts.workerCount)
+	}
 	if err := applier.ApplyPatchWithPath(patch, *currentDir, *patchFile, *verify, *verify, *backup); err != nil {
 		fmt.Printf("Error: patch application failed: %v\n", err)
 		if *backup {
@@ -131,8 +170,35 @@ func main() {
 		os.Exit(1)
 	}
 
+	patcher.RecordInstall(config.GetDefaultHistoryPath(), *currentDir, patch)
+
 	fmt.Println("\n=== Patch Applied Successfully ===")
-	fmt.Printf("Version updated from %s to %s\n", patch.FromVersion, patch.ToVersion)
+	if patch.FullInstall {
+		fmt.Printf("Version %s installed in %s\n", patch.ToVersion, *currentDir)
+	} else {
+		fmt.Printf("Version updated from %s to %s\n", patch.FromVersion, patch.ToVersion)
+	}
+}
+
+// applyOptions holds the command-line settings applied to every patch applier
+type applyOptions struct {
+	workerCount int                 // Worker threads for hashing and applying operations
+	allowHooks  bool                // Run hooks from patches that are not signed by a trusted key
+	trustedKeys []ed25519.PublicKey // Public keys used to verify patch signatures
+	verifyTree  bool                // Verify the whole tree against the target manifest after patching
+	acceptEULA  bool                // The patch's license agreement was accepted with --accept-eula
+}
+
+// newApplier creates a patch applier configured with these options
+func (o *applyOptions) newApplier() *patcher.Applier {
+	applier := patcher.NewApplier()
+	applier.SetWorkerThreads(o.workerCount)
+	applier.SetAllowHooks(o.allowHooks)
+	applier.SetVerifyTree(o.verifyTree)
+	for _, key := range o.trustedKeys {
+		applier.AddTrustedKey(key)
+	}
+	return applier
 }
 
 func loadPatch(filename string) (*utils.Patch, error) {
@@ -253,10 +319,24 @@ func parsePatchDataStreaming(reader io.Reader) (*utils.Patch, error) {
 
 func displayPatchInfo(patch *utils.Patch) {
 	fmt.Println("\n=== Patch Information ===")
-	fmt.Printf("From Version:     %s\n", patch.FromVersion)
-	fmt.Printf("To Version:       %s\n", patch.ToVersion)
-	fmt.Printf("Key File:         %s\n", patch.FromKeyFile.Path)
-	fmt.Printf("Required Hash:    %s\n", patch.FromKeyFile.Checksum)
+	if patch.Branding != nil {
+		fmt.Printf("Product:          %s\n", patch.Branding.ProductName)
+		if patch.Branding.Publisher != "" {
+			fmt.Printf("Publisher:        %s\n", patch.Branding.Publisher)
+		}
+	}
+	if patch.FullInstall {
+		fmt.Printf("Type:             full install (into an empty directory)\n")
+		fmt.Printf("Version:          %s\n", patch.ToVersion)
+	} else {
+		fmt.Printf("From Version:     %s\n", patch.FromVersion)
+		fmt.Printf("To Version:       %s\n", patch.ToVersion)
+		fmt.Printf("Key File:         %s\n", patch.FromKeyFile.Path)
+		fmt.Printf("Required Hash:    %s\n", patch.FromKeyFile.Checksum)
+		if patch.FromIdentity != nil {
+			fmt.Printf("Identity:         %s\n", patcher.IdentitySummary(patch.FromKeyFile, patch.FromIdentity))
+		}
+	}
 	fmt.Printf("Patch Size:       %d bytes\n", patch.Header.PatchSize)
 	fmt.Printf("Compression:      %s\n", patch.Header.Compression)
 	fmt.Printf("Created:          %s\n", patch.Header.CreatedAt.Format("2006-01-02 15:04:05"))
@@ -289,6 +369,18 @@ func displayPatchInfo(patch *utils.Patch) {
 	fmt.Printf("Dirs Added:       %d\n", addDirCount)
 	fmt.Printf("Dirs Deleted:     %d\n", deleteDirCount)
 	fmt.Printf("Required Files:   %d (must match exact hashes)\n", len(patch.RequiredFiles))
+	fmt.Printf("Verification:     %s\n", patcher.VerificationSummary(patch))
+	if utils.IsSigned(patch) {
+		fmt.Printf("Signature:        signed (key %s)\n", patch.Header.SignerKeyID)
+	} else {
+		fmt.Printf("Signature:        unsigned\n")
+	}
+	if len(patch.Hooks) > 0 {
+		fmt.Printf("Hooks:            %d (run only if signed by a trusted key or --allow-hooks is set)\n", len(patch.Hooks))
+	}
+	if patch.TargetManifest != nil {
+		fmt.Printf("Target Manifest:  embedded (%d files, usable for repair)\n", len(patch.TargetManifest.Files))
+	}
 }
 
 // resolveKeyFilePath resolves the actual key file path, using custom path if provided
@@ -306,37 +398,83 @@ func resolveKeyFilePath(patch *utils.Patch, currentDir string, customKeyFile str
 	return currentDir + string(os.PathSeparator) + patch.FromKeyFile.Path
 }
 
-func performDryRun(patch *utils.Patch, currentDir string, customKeyFile string) {
+func performDryRun(patch *utils.Patch, currentDir string, customKeyFile string, createBackup bool) {
 	fmt.Println("\nSimulating patch application...")
 
-	// Verify key file
-	if customKeyFile != "" {
-		fmt.Printf("\nVerifying custom key file: %s\n", customKeyFile)
-	} else {
-		fmt.Printf("\nVerifying key file: %s\n", patch.FromKeyFile.Path)
-	}
-	keyFilePath := resolveKeyFilePath(patch, currentDir, customKeyFile)
-	if !utils.FileExists(keyFilePath) {
-		fmt.Printf("✗ Key file not found: %s\n", keyFilePath)
+	// A full install has no source version to verify, only a directory to install into
+	if patch.FullInstall {
+		if !checkInstallDir(currentDir, func(format string, args ...interface{}) { fmt.Printf(format, args...) }) {
+			return
+		}
+	} else if !verifySourceVersion(patch, currentDir, customKeyFile) {
 		return
 	}
 
-	checksum, err := utils.CalculateFileChecksum(keyFilePath)
+	// Run the same preflight checks the applier performs before making changes
+	fmt.Println("\nRunning preflight checks...")
+	report, err := patcher.NewApplier().Preflight(patch, currentDir, createBackup)
 	if err != nil {
-		fmt.Printf("✗ Failed to calculate key file checksum: %v\n", err)
+		fmt.Printf("✗ Preflight check failed: %v\n", err)
 		return
 	}
-
-	if checksum != patch.FromKeyFile.Checksum {
-		fmt.Printf("✗ Key file hash mismatch\n")
-		fmt.Printf("  Expected: %s\n", patch.FromKeyFile.Checksum[:16]+"...")
-		fmt.Printf("  Got:      %s\n", checksum[:16]+"...")
+	fmt.Print(report.Format())
+	if !report.OK() {
+		fmt.Println("\n✗ Preflight checks failed - patch cannot be applied")
 		return
 	}
-	fmt.Println("✓ Key file verified")
+
+	// Show hooks that would run around the operations
+	if len(patch.Hooks) > 0 {
+		fmt.Println("\nHooks declared by the patch (not run during dry run):")
+		for _, hook := range patch.Hooks {
+			fmt.Printf("  %s: %s %s\n", strings.ToUpper(hook.Phase), hook.Command, strings.Join(hook.Args, " "))
+		}
+	}
+
+	// Show operations that would be performed
+	fmt.Println("\nOperations that would be performed:")
+	for _, op := range patch.Operations {
+		switch op.Type {
+		case utils.OpAdd:
+			fmt.Printf("  ADD: %s\n", op.FilePath)
+		case utils.OpModify:
+			fmt.Printf("  MODIFY: %s\n", op.FilePath)
+		case utils.OpDelete:
+			fmt.Printf("  DELETE: %s\n", op.FilePath)
+		case utils.OpAddDir:
+			fmt.Printf("  ADD DIR: %s\n", op.FilePath)
+		case utils.OpDeleteDir:
+			fmt.Printf("  DELETE DIR: %s\n", op.FilePath)
+		}
+	}
+
+	fmt.Println("\n✓ Dry run completed - patch can be applied safely")
+}
+
+// verifySourceVersion is the dry-run check of the key file and required files of an update.
+// Returns false if the install is not at the patch's source version.
+func verifySourceVersion(patch *utils.Patch, currentDir string, customKeyFile string) bool {
+	// Verify key file and any further identity checks
+	keyFile := patch.FromKeyFile
+	if customKeyFile != "" {
+		fmt.Printf("\nVerifying custom key file: %s\n", customKeyFile)
+		if abs, err := filepath.Abs(resolveKeyFilePath(patch, currentDir, customKeyFile)); err == nil {
+			keyFile.Path = abs
+		}
+	} else {
+		fmt.Printf("\nVerifying version identity (%s)\n", patcher.IdentitySummary(patch.FromKeyFile, patch.FromIdentity))
+	}
+	identity := patcher.CheckIdentity(currentDir, keyFile, patch.FromIdentity)
+	fmt.Print(identity.Format())
+	if !identity.OK() {
+		if explanation := identity.Explain(); explanation != "" {
+			fmt.Printf("  %s\n", explanation)
+		}
+		return false
+	}
 
 	// Verify required files
-	fmt.Printf("\nVerifying %d required files...\n", len(patch.RequiredFiles))
+	fmt.Printf("\nVerifying %d required files (%s)...\n", len(patch.RequiredFiles), patcher.VerificationSummary(patch))
 	mismatches := 0
 	for i, req := range patch.RequiredFiles {
 		if i < 5 || mismatches > 0 { // Show first 5 or any mismatches
@@ -363,262 +501,105 @@ func performDryRun(patch *utils.Patch, currentDir string, customKeyFile string)
 
 	if mismatches > 0 {
 		fmt.Printf("\n✗ %d file(s) have mismatches - patch cannot be applied\n", mismatches)
-		return
+		return false
 	}
 
 	fmt.Println("✓ All required files verified")
+	return true
+}
 
-	// Show operations that would be performed
-	fmt.Println("\nOperations that would be performed:")
-	for _, op := range patch.Operations {
-		switch op.Type {
-		case utils.OpAdd:
-			fmt.Printf("  ADD: %s\n", op.FilePath)
-		case utils.OpModify:
-			fmt.Printf("  MODIFY: %s\n", op.FilePath)
-		case utils.OpDelete:
-			fmt.Printf("  DELETE: %s\n", op.FilePath)
-		case utils.OpAddDir:
-			fmt.Printf("  ADD DIR: %s\n", op.FilePath)
-		case utils.OpDeleteDir:
-			fmt.Printf("  DELETE DIR: %s\n", op.FilePath)
+// embeddedBaseName returns the name that external parts of a self-contained executable share
+// Example: "1.0.0-to-1.0.1.exe" (Windows) and "1.0.0-to-1.0.1" (Linux/macOS) -> "1.0.0-to-1.0.1"
+func embeddedBaseName(exePath string) string {
+	name := filepath.Base(exePath)
+	if strings.EqualFold(filepath.Ext(name), ".exe") {
+		name = name[:len(name)-len(".exe")]
+	}
+	return name
+}
+
+// embeddedPartsBase returns the base name the parts of an embedded multi-part patch were generated with,
+// taken from the names of files embedded in the exe (fallback if none identify it), and whether every part is embedded
+func embeddedPartsBase(names []string, totalParts int, fallback string) (string, bool) {
+	present := make(map[string]bool, len(names))
+	for _, name := range names {
+		present[name] = true
+	}
+
+	baseName := fallback
+	for _, name := range names {
+		if base, ok := strings.CutSuffix(name, ".part2.chunks.json"); ok {
+			baseName = base
+			break
+		}
+		if base, ok := strings.CutSuffix(name, ".02.patch"); ok {
+			baseName = base
+			break
 		}
 	}
 
-	fmt.Println("\n✓ Dry run completed - patch can be applied safely")
+	// A part is embedded as a whole file or, if it was chunked, as its chunk files
+	for i := 2; i <= totalParts; i++ {
+		if !present[fmt.Sprintf("%s.%02d.patch", baseName, i)] && !present[fmt.Sprintf("%s.part%d.1.patch", baseName, i)] {
+			return baseName, false
+		}
+	}
+	return baseName, true
 }
 
 // checkEmbeddedPatch checks if this executable contains an embedded patch
 // Returns: patch, targetDir, isEmbedded, embeddedSilent
-func checkEmbeddedPatch(ignore1GB bool) (*utils.Patch, string, bool, bool) {
+// A damaged embedded patch or a signature that does not verify is a fatal error rather than a reason
+// to run as a plain applier.
+func checkEmbeddedPatch(ignore1GB bool, opts *applyOptions) (*utils.Patch, string, bool, bool) {
 	// Get path to this executable
 	exePath, err := os.Executable()
 	if err != nil {
 		return nil, "", false, false
 	}
 
-	// Open executable for reading
-	file, err := os.Open(exePath)
-	if err != nil {
+	exe, err := embedded.Open(exePath)
+	if errors.Is(err, embedded.ErrNotEmbedded) {
 		return nil, "", false, false
 	}
-	defer file.Close()
-
-	// Get file size
-	stat, err := file.Stat()
 	if err != nil {
-		return nil, "", false, false
-	}
-	fileSize := stat.Size()
-
-	// Check if file is large enough for header
-	if fileSize < HEADER_SIZE {
-		return nil, "", false, false
-	}
-
-	// Read header from end of file
-	headerOffset := fileSize - HEADER_SIZE
-	if _, err := file.Seek(headerOffset, io.SeekStart); err != nil {
-		return nil, "", false, false
-	}
-
-	headerBytes := make([]byte, HEADER_SIZE)
-	if _, err := io.ReadFull(file, headerBytes); err != nil {
-		return nil, "", false, false
-	}
-
-	// Parse header
-	var header EmbeddedPatchHeader
-	buf := bytes.NewReader(headerBytes)
-	if err := binary.Read(buf, binary.LittleEndian, &header); err != nil {
-		return nil, "", false, false
-	}
-
-	// Validate magic bytes
-	magic := string(bytes.TrimRight(header.Magic[:], "\x00"))
-	if magic != MAGIC_BYTES {
-		return nil, "", false, false
-	}
-
-	// Validate version
-	if header.Version != 1 {
-		return nil, "", false, false
+		embeddedLoadFailed(err)
 	}
+	defer exe.Close()
 
-	// Validate structure: data must start immediately after stub
-	if header.DataOffset != header.StubSize {
-		return nil, "", false, false
+	if err := checkExecutable(exe, opts.trustedKeys); err != nil {
+		embeddedLoadFailed(err)
 	}
 
-	// Allow optional sidecar blob between patch data and header (we may embed chunk JSONs)
-	minExpectedSize := header.StubSize + header.DataSize + HEADER_SIZE
-	if uint64(fileSize) < minExpectedSize {
-		return nil, "", false, false
-	}
-
-	// Extract silent flag from Flags byte (bit 0)
-	embeddedSilent := (header.Flags & 0x01) != 0
-
-	// Check size limit (1GB)
+	// The decoded patch is held in memory, so keep the size limit unless bypassed
 	const maxPatchSize = 1 << 30 // 1 GB
-	if !ignore1GB && header.DataSize > maxPatchSize {
-		fmt.Printf("Warning: Patch size (%d bytes) exceeds 1GB limit\n", header.DataSize)
+	if !ignore1GB && exe.Header.DataSize > maxPatchSize {
+		fmt.Printf("Warning: Patch size (%d bytes) exceeds 1GB limit\n", exe.Header.DataSize)
 		fmt.Println("Use --ignore1gb flag if you want to proceed anyway")
 		return nil, "", false, false
 	}
 
-
-	// Read patch data
-	if _, err := file.Seek(int64(header.DataOffset), io.SeekStart); err != nil {
-		return nil, "", false, false
-	}
-
-	patchData := make([]byte, header.DataSize)
-	if _, err := io.ReadFull(file, patchData); err != nil {
-		return nil, "", false, false
-	}
-
-	// If there are extra bytes between patch data and header, treat them as sidecar blob
-	extraBytes := int64(fileSize) - int64(minExpectedSize)
-	var writtenSidecars []string
-	if extraBytes > 0 {
-		// Read sidecar blob
-		sidecarOffset := int64(header.DataOffset + header.DataSize)
-		if _, err := file.Seek(sidecarOffset, io.SeekStart); err == nil {
-			sidecarData := make([]byte, extraBytes)
-			if _, err := io.ReadFull(file, sidecarData); err == nil {
-				// Parse sidecar format: uint32 count, then for each: uint16 nameLen, name bytes, uint64 dataLen, data bytes
-				r := bytes.NewReader(sidecarData)
-				var count uint32
-				if err := binary.Read(r, binary.LittleEndian, &count); err == nil {
-					for i := uint32(0); i < count; i++ {
-						var nameLen uint16
-						if err := binary.Read(r, binary.LittleEndian, &nameLen); err != nil {
-							break
-						}
-						nameBytes := make([]byte, nameLen)
-						if _, err := io.ReadFull(r, nameBytes); err != nil {
-							break
-						}
-						var dataLen uint64
-						if err := binary.Read(r, binary.LittleEndian, &dataLen); err != nil {
-							break
-						}
-						dataBytes := make([]byte, dataLen)
-						if _, err := io.ReadFull(r, dataBytes); err != nil {
-							break
-						}
-						// Write sidecar file into exe directory
-						exeDir := filepath.Dir(exePath)
-						sidePath := filepath.Join(exeDir, string(nameBytes))
-						if err := os.WriteFile(sidePath, dataBytes, 0644); err == nil {
-							writtenSidecars = append(writtenSidecars, sidePath)
-						}
-					}
-				}
-			}
-		}
-	}
-
-	// Verify checksum
-	actualChecksum := sha256.Sum256(patchData)
-	if !bytes.Equal(actualChecksum[:], header.Checksum[:]) {
-		return nil, "", false, false
+	// Parse the embedded data (part 01 if multi-part) straight from the executable
+	patch, err := loadEmbeddedPatch(exe)
+	if err != nil {
+		embeddedLoadFailed(err)
 	}
 
-	// The embedded patch data is the raw .patch file content (part 01 if multi-part)
-	// Check if there are additional parts (.02, .03, etc.) in the same directory as the exe
-	exeDir := filepath.Dir(exePath)
-	exeBaseName := strings.TrimSuffix(filepath.Base(exePath), ".exe")
-
-	// Check if part 02 exists to determine if this is multi-part
-	part02Path := filepath.Join(exeDir, exeBaseName+".02.patch")
-	isMultiPart := utils.FileExists(part02Path)
-
-	var patch *utils.Patch
-	if isMultiPart {
-		// Multi-part patch: Save embedded part 01 temporarily and load all parts
-		// Save in exe directory with the correct base name so LoadMultiPartPatch finds the parts
-		tempPart01 := filepath.Join(exeDir, exeBaseName+".01.patch")
-
-		// Before writing part 01 temp, verify that any extracted sidecars reference existing chunk files.
-		// If any listed chunk file is missing, fail early to avoid silently falling back to a full part.
-		exeDir := filepath.Dir(exePath)
-		for _, sc := range writtenSidecars {
-			// Read sidecar JSON
-			sb, err := os.ReadFile(sc)
-			if err != nil {
-				// cleanup and fail
-				for _, p := range writtenSidecars {
-					_ = os.Remove(p)
-				}
-				return nil, "", false, false
-			}
-			var parsed struct {
-				PartNumber int                 `json:"part_number"`
-				Chunks     []utils.PartChunk   `json:"chunks"`
-			}
-			if err := json.Unmarshal(sb, &parsed); err != nil {
-				for _, p := range writtenSidecars {
-					_ = os.Remove(p)
-				}
-				return nil, "", false, false
-			}
-			// Verify each chunk file exists
-			for _, ch := range parsed.Chunks {
-				chunkPath := filepath.Join(exeDir, ch.FileName)
-				if !utils.FileExists(chunkPath) {
-					// cleanup and fail with clear message
-					for _, p := range writtenSidecars {
-						_ = os.Remove(p)
-					}
-					fmt.Fprintf(os.Stderr, "Error: missing chunk file required by embedded sidecar: %s\n", chunkPath)
-					return nil, "", false, false
-				}
-			}
-		}
-
-		// Write part 01 data to temporary file
-		if err := os.WriteFile(tempPart01, patchData, 0644); err != nil {
-			// cleanup any written sidecars
-			for _, p := range writtenSidecars {
-				_ = os.Remove(p)
-			}
-			return nil, "", false, false
-		}
-		// Clean up temporary file and any written sidecars after loading
-		defer func() {
-			_ = os.Remove(tempPart01)
-			for _, p := range writtenSidecars {
-				_ = os.Remove(p)
-			}
-		}()
-
-		// Load all parts using multi-part loader
-		patch, err = patcher.LoadMultiPartPatch(tempPart01)
-		if err != nil {
-			return nil, "", false, false
-		}
-
-		fmt.Printf("✓ Loaded multi-part patch from embedded part 01 + external parts\n")
-	} else {
-		// Single-part patch: Parse embedded data directly
-		patch, err = parsePatchDataStreaming(bytes.NewReader(patchData))
-		if err != nil {
-			// cleanup any written sidecars
-			for _, p := range writtenSidecars {
-				_ = os.Remove(p)
-			}
-			return nil, "", false, false
-		}
-	} // Get current directory as default target
+	// Get current directory as default target
 	targetDir, _ := os.Getwd()
 
-	return patch, targetDir, true, embeddedSilent
+	return patch, targetDir, true, exe.Header.Silent()
+}
+
+// embeddedLoadFailed reports a damaged self-contained executable and exits
+func embeddedLoadFailed(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	fmt.Fprintln(os.Stderr, "The embedded patch could not be loaded. Run with --verify-self for details.")
+	os.Exit(1)
 }
 
 // runSilentMode applies the patch automatically without user interaction (for automation)
-func runSilentMode(patch *utils.Patch, defaultTargetDir string, customTargetDir string, customKeyFile string) {
+func runSilentMode(patch *utils.Patch, defaultTargetDir string, customTargetDir string, customKeyFile string, opts *applyOptions) {
 	// Use custom target directory if provided, otherwise use default (current directory)
 	targetDir := defaultTargetDir
 	if customTargetDir != "" {
@@ -654,8 +635,34 @@ func runSilentMode(patch *utils.Patch, defaultTargetDir string, customTargetDir
 	logOutput("Started: %s\n", timestamp)
 	logOutput("========================================\n\n")
 
-	// Check if directory exists
-	if !utils.FileExists(targetDir) {
+	// Without --current-dir, look for the install rather than assuming the working directory.
+	// A full install goes to the default install directory instead.
+	if customTargetDir == "" && patch.FullInstall {
+		targetDir = patcher.DefaultInstallDir(patch, defaultTargetDir)
+		logOutput("Installing into: %s\n\n", targetDir)
+	} else if customTargetDir == "" {
+		matches := locateInstalls(patch, defaultTargetDir)
+		if match, ok := preferredInstall(matches); ok {
+			targetDir = match.Dir
+			logOutput("Install located: %s (%s)\n\n", match.Dir, match.Source)
+		} else if len(matches) > 1 {
+			logOutput("Error: Found %d installs of version %s; choose one with --current-dir:\n", len(matches), patch.FromVersion)
+			logOutput("%s", formatInstallMatches(matches))
+			logOutput("\n========================================\n")
+			logOutput("Status: FAILED\n")
+			logOutput("Completed: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+			logOutput("========================================\n")
+			if logFile != nil {
+				logOutput("\nLog saved to: %s\n", logFileName)
+			}
+			os.Exit(1)
+		} else {
+			logOutput("No install of version %s found; using the current directory\n\n", patch.FromVersion)
+		}
+	}
+
+	// Check if directory exists (a full install creates it)
+	if !patch.FullInstall && !utils.FileExists(targetDir) {
 		logOutput("Error: Target directory not found: %s\n", targetDir)
 		logOutput("\n========================================\n")
 		logOutput("Status: FAILED\n")
@@ -675,20 +682,48 @@ func runSilentMode(patch *utils.Patch, defaultTargetDir string, customTargetDir
 
 	// Log patch details
 	logOutput("Patch Information:\n")
-	logOutput("  From Version: %s\n", patch.FromVersion)
-	logOutput("  To Version:   %s\n", patch.ToVersion)
-	logOutput("  Key File:     %s\n", patch.FromKeyFile.Path)
+	if patch.FullInstall {
+		logOutput("  Type:         full install\n")
+		logOutput("  Version:      %s\n", patch.ToVersion)
+	} else {
+		logOutput("  From Version: %s\n", patch.FromVersion)
+		logOutput("  To Version:   %s\n", patch.ToVersion)
+		logOutput("  Key File:     %s\n", patch.FromKeyFile.Path)
+	}
 	logOutput("  Target Dir:   %s\n", targetDir)
 	logOutput("  Compression:  %s\n", patch.Header.Compression)
+	if patch.Branding != nil {
+		logOutput("  Product:      %s\n", patch.Branding.ProductName)
+	}
 	logOutput("\n")
 
+	// Silent mode cannot prompt; the license agreement must be accepted on the command line
+	if err := checkEULAFlag(patch, opts.acceptEULA); err != nil {
+		logOutput("Error: %v\n", err)
+		logOutput("\n========================================\n")
+		logOutput("Status: FAILED\n")
+		logOutput("Completed: %s\n", time.Now().Format("2006-01-02 15:04:05"))
+		logOutput("========================================\n")
+		if logFile != nil {
+			logOutput("\nLog saved to: %s\n", logFileName)
+		}
+		os.Exit(1)
+	}
+	if hasEULA(patch) {
+		logOutput("%s\n\n", eulaAcceptedNote(patch))
+	}
+
 	// Display simple startup message
 	logOutput("Applying patch...\n\n")
 
 	// Apply patch with default settings (verify=true, backup=true)
-	applier := patcher.NewApplier()
+	applier := opts.newApplier()
+	if logFile != nil {
+		applier.SetLogWriter(logFile)
+	}
 	if err := applier.ApplyPatchWithPath(patch, targetDir, "", true, true, true); err != nil {
 		logOutput("\nError: Patch application failed: %v\n", err)
+		logOutput("%s", supportHint(patch))
 		logOutput("\n========================================\n")
 		logOutput("Status: FAILED\n")
 		logOutput("Completed: %s\n", time.Now().Format("2006-01-02 15:04:05"))
@@ -699,9 +734,11 @@ func runSilentMode(patch *utils.Patch, defaultTargetDir string, customTargetDir
 		os.Exit(1)
 	}
 
+	patcher.RecordInstall(config.GetDefaultHistoryPath(), targetDir, patch)
+
 	// Success - output minimal message
 	logOutput("\n")
-	logOutput("Patch applied successfully: %s → %s\n", patch.FromVersion, patch.ToVersion)
+	logOutput("Patch applied successfully: %s\n", versionChange(patch))
 	logOutput("\n========================================\n")
 	logOutput("Status: SUCCESS\n")
 	logOutput("Completed: %s\n", time.Now().Format("2006-01-02 15:04:05"))
@@ -719,21 +756,14 @@ func runSilentMode(patch *utils.Patch, defaultTargetDir string, customTargetDir
 // - Runs dry-run first to verify
 // - Applies patch if dry-run succeeds
 // - Logs everything to <patchname>_<utctime>_log.txt
-func runSimpleMode(patch *utils.Patch, defaultTargetDir string) {
+func runSimpleMode(patch *utils.Patch, defaultTargetDir string, opts *applyOptions) {
 	// Use current directory as target
 	targetDir := defaultTargetDir
 
 	// Create log file with patch name and UTC timestamp
-	exePath, _ := os.Executable()
-	exeBaseName := strings.TrimSuffix(exePath, ".exe")
-	if exeBaseName == exePath {
-		exeBaseName = "patch"
-	} else {
-		// Extract just the filename without directory
-		parts := strings.Split(exeBaseName, string(os.PathSeparator))
-		if len(parts) > 0 {
-			exeBaseName = parts[len(parts)-1]
-		}
+	exeBaseName := "patch"
+	if exePath, err := os.Executable(); err == nil {
+		exeBaseName = embeddedBaseName(exePath)
 	}
 	logFileName := fmt.Sprintf("%s_%d_log.txt", exeBaseName, time.Now().UTC().Unix())
 	logFile, err := os.Create(logFileName)
@@ -758,31 +788,81 @@ func runSimpleMode(patch *utils.Patch, defaultTargetDir string) {
 
 	// Header
 	logOutput("\n")
-	logOutput("==============================================\n")
-	logOutput("  CyberPatchMaker - Self-Contained Patch\n")
-	logOutput("==============================================\n")
+	logOutput("%s", brandingBanner(patch))
 	logOutput("\n")
 	logOutput("==============================================\n")
 	logOutput("          Simple Patch Application\n")
 	logOutput("==============================================\n")
 	logOutput("\n")
-	logOutput("Automated patching from \"%s\" to \"%s\"\n", patch.FromVersion, patch.ToVersion)
+	if patch.FullInstall {
+		logOutput("Automated install of \"%s\"\n", patch.ToVersion)
+	} else {
+		logOutput("Automated patching from \"%s\" to \"%s\"\n", patch.FromVersion, patch.ToVersion)
+	}
 	logOutput("\n")
 
+	// Look for the install instead of assuming the updater was started in it.
+	// A full install asks where to go, suggesting the default install directory.
+	reader := bufio.NewReader(os.Stdin)
+	var matches []patcher.InstallMatch
+	if patch.FullInstall {
+		targetDir = patcher.DefaultInstallDir(patch, defaultTargetDir)
+		fmt.Printf("Install directory [%s]: ", targetDir)
+		if input, _ := reader.ReadString('\n'); strings.TrimSpace(input) != "" {
+			targetDir = strings.TrimSpace(input)
+		}
+		logOutput("Install directory: %s\n\n", targetDir)
+	} else {
+		matches = locateInstalls(patch, defaultTargetDir)
+	}
+	if match, ok := preferredInstall(matches); ok {
+		targetDir = match.Dir
+		logOutput("Install located: %s (%s)\n\n", match.Dir, match.Source)
+	} else if len(matches) > 1 {
+		logOutput("Found %d installs of version %s:\n", len(matches), patch.FromVersion)
+		logOutput("%s", formatInstallMatches(matches))
+		for {
+			fmt.Printf("Select the install to update [1-%d]: ", len(matches))
+			input, err := reader.ReadString('\n')
+			if dir, ok := selectInstallMatch(matches, strings.TrimSpace(input)); ok {
+				targetDir = dir
+				logOutput("Selected install: %s\n\n", targetDir)
+				break
+			}
+			if err != nil {
+				logOutput("\nNo install selected.\n")
+				logOutput("\n========================================\n")
+				logOutput("Status: CANCELLED\n")
+				logOutput("Completed: %s\n", time.Now().UTC().Format("2006-01-02 15:04:05 UTC"))
+				logOutput("========================================\n")
+				if logFile != nil {
+					logOutput("\nLog saved to: %s\n", logFileName)
+				}
+				os.Exit(1)
+			}
+			fmt.Printf("Invalid selection. Please enter a number from 1 to %d.\n", len(matches))
+		}
+	}
+
 	// Log details
 	timestamp := time.Now().UTC().Format("2006-01-02 15:04:05 UTC")
 	logOutput("Patch Information:\n")
 	logOutput("  Started:      %s\n", timestamp)
-	logOutput("  From Version: %s\n", patch.FromVersion)
-	logOutput("  To Version:   %s\n", patch.ToVersion)
-	logOutput("  Key File:     %s\n", patch.FromKeyFile.Path)
+	if patch.FullInstall {
+		logOutput("  Type:         full install\n")
+		logOutput("  Version:      %s\n", patch.ToVersion)
+	} else {
+		logOutput("  From Version: %s\n", patch.FromVersion)
+		logOutput("  To Version:   %s\n", patch.ToVersion)
+		logOutput("  Key File:     %s\n", patch.FromKeyFile.Path)
+	}
 	logOutput("  Target Dir:   %s\n", targetDir)
 	logOutput("  Backup:       Enabled\n")
 	logOutput("  Compression:  %s\n", patch.Header.Compression)
 	logOutput("\n")
 
-	// Check if directory exists
-	if !utils.FileExists(targetDir) {
+	// Check if directory exists (a full install creates it)
+	if !patch.FullInstall && !utils.FileExists(targetDir) {
 		logOutput("Error: Directory not found: %s\n", targetDir)
 		logOutput("\n========================================\n")
 		logOutput("Status: FAILED\n")
@@ -794,6 +874,19 @@ func runSimpleMode(patch *utils.Patch, defaultTargetDir string) {
 		os.Exit(1)
 	}
 
+	// The license agreement must be accepted before anything is checked or changed
+	if !promptEULA(patch, reader, logOutput) {
+		logOutput("\nNothing was changed.\n")
+		logOutput("\n========================================\n")
+		logOutput("Status: CANCELLED\n")
+		logOutput("Completed: %s\n", time.Now().UTC().Format("2006-01-02 15:04:05 UTC"))
+		logOutput("========================================\n")
+		if logFile != nil {
+			logOutput("\nLog saved to: %s\n", logFileName)
+		}
+		os.Exit(1)
+	}
+
 	// Step 1: Dry run
 	logOutput("==============================================\n")
 	logOutput("Step 1: Dry Run (Validation)\n")
@@ -805,30 +898,25 @@ func runSimpleMode(patch *utils.Patch, defaultTargetDir string) {
 	// Perform dry run validation
 	dryRunSuccess := true
 
-	// Verify key file
-	logOutput("Verifying key file: %s\n", patch.FromKeyFile.Path)
-	keyFilePath := targetDir + string(os.PathSeparator) + patch.FromKeyFile.Path
-	if !utils.FileExists(keyFilePath) {
-		logOutput("✗ Key file not found: %s\n", keyFilePath)
-		dryRunSuccess = false
+	// A full install has no source version to verify, only a directory to install into
+	if patch.FullInstall {
+		dryRunSuccess = checkInstallDir(targetDir, logOutput)
 	} else {
-		checksum, err := utils.CalculateFileChecksum(keyFilePath)
-		if err != nil {
-			logOutput("✗ Failed to calculate key file checksum: %v\n", err)
-			dryRunSuccess = false
-		} else if checksum != patch.FromKeyFile.Checksum {
-			logOutput("✗ Key file hash mismatch\n")
-			logOutput("  Expected: %s\n", patch.FromKeyFile.Checksum[:16]+"...")
-			logOutput("  Got:      %s\n", checksum[:16]+"...")
+		// Verify key file and any further identity checks
+		logOutput("Verifying version identity (%s)\n", patcher.IdentitySummary(patch.FromKeyFile, patch.FromIdentity))
+		identity := patcher.CheckIdentity(targetDir, patch.FromKeyFile, patch.FromIdentity)
+		logOutput("%s", identity.Format())
+		if !identity.OK() {
+			if explanation := identity.Explain(); explanation != "" {
+				logOutput("  %s\n", explanation)
+			}
 			dryRunSuccess = false
-		} else {
-			logOutput("✓ Key file verified\n")
 		}
 	}
 
 	// Verify required files
-	if dryRunSuccess {
-		logOutput("\nVerifying %d required files...\n", len(patch.RequiredFiles))
+	if dryRunSuccess && !patch.FullInstall {
+		logOutput("\nVerifying %d required files (%s)...\n", len(patch.RequiredFiles), patcher.VerificationSummary(patch))
 		mismatches := 0
 		for _, req := range patch.RequiredFiles {
 			filePath := targetDir + string(os.PathSeparator) + req.Path
@@ -859,8 +947,27 @@ func runSimpleMode(patch *utils.Patch, defaultTargetDir string) {
 		}
 	}
 
+	// Preflight disk space and permission checks
+	if dryRunSuccess {
+		logOutput("\nRunning preflight checks...\n")
+		report, err := patcher.NewApplier().Preflight(patch, targetDir, true)
+		if err != nil {
+			logOutput("✗ Preflight check failed: %v\n", err)
+			dryRunSuccess = false
+		} else {
+			logOutput("%s", report.Format())
+			if !report.OK() {
+				dryRunSuccess = false
+			}
+		}
+	}
+
 	if !dryRunSuccess {
 		logOutput("\n✗ Dry run validation failed - patch cannot be applied\n")
+		if !patch.FullInstall && patch.Branding != nil && patch.Branding.InstallDirHint != "" {
+			logOutput("\nRun this updater from the %s folder (usually %s).\n", patch.Branding.ProductName, patch.Branding.InstallDirHint)
+		}
+		logOutput("%s", supportHint(patch))
 		logOutput("\n========================================\n")
 		logOutput("Status: FAILED\n")
 		logOutput("Completed: %s\n", time.Now().UTC().Format("2006-01-02 15:04:05 UTC"))
@@ -882,10 +989,14 @@ func runSimpleMode(patch *utils.Patch, defaultTargetDir string) {
 	logOutput("Applying patch with backup enabled...\n")
 	logOutput("\n")
 
-	applier := patcher.NewApplier()
+	applier := opts.newApplier()
+	if logFile != nil {
+		applier.SetLogWriter(logFile)
+	}
 	if err := applier.ApplyPatchWithPath(patch, targetDir, "", true, true, true); err != nil {
 		logOutput("\nError: Patch application failed: %v\n", err)
 		logOutput("\nNote: Automatic rollback may have been performed to restore original files.\n")
+		logOutput("%s", supportHint(patch))
 		logOutput("\n========================================\n")
 		logOutput("Status: FAILED\n")
 		logOutput("Completed: %s\n", time.Now().UTC().Format("2006-01-02 15:04:05 UTC"))
@@ -896,13 +1007,19 @@ func runSimpleMode(patch *utils.Patch, defaultTargetDir string) {
 		os.Exit(1)
 	}
 
+	patcher.RecordInstall(config.GetDefaultHistoryPath(), targetDir, patch)
+
 	// Success
 	logOutput("\n")
 	logOutput("==============================================\n")
 	logOutput("          Patch Applied Successfully\n")
 	logOutput("==============================================\n")
 	logOutput("\n")
-	logOutput("Version updated: %s → %s\n", patch.FromVersion, patch.ToVersion)
+	if patch.FullInstall {
+		logOutput("Version installed: %s\n", patch.ToVersion)
+	} else {
+		logOutput("Version updated: %s → %s\n", patch.FromVersion, patch.ToVersion)
+	}
 	logOutput("\n========================================\n")
 	logOutput("Status: SUCCESS\n")
 	logOutput("Completed: %s\n", time.Now().UTC().Format("2006-01-02 15:04:05 UTC"))
@@ -915,13 +1032,13 @@ func runSimpleMode(patch *utils.Patch, defaultTargetDir string) {
 }
 
 // runInteractiveMode runs the interactive console interface for embedded patches
-func runInteractiveMode(patch *utils.Patch, defaultTargetDir string, ignore1GB bool) {
+func runInteractiveMode(patch *utils.Patch, defaultTargetDir string, ignore1GB bool, opts *applyOptions) {
 	reader := bufio.NewReader(os.Stdin)
 	customKeyFile := "" // Track custom key file path
 
 	// Check if patch creator enabled simple mode for end users
 	if patch.SimpleMode {
-		runSimpleMode(patch, defaultTargetDir)
+		runSimpleMode(patch, defaultTargetDir, opts)
 		return
 	}
 
@@ -929,17 +1046,50 @@ func runInteractiveMode(patch *utils.Patch, defaultTargetDir string, ignore1GB b
 	fmt.Println()
 	displayPatchInfo(patch)
 
-	// Ask for target directory
-	fmt.Printf("\nTarget directory [%s]: ", defaultTargetDir)
+	// The license agreement must be accepted before anything else
+	if !promptEULA(patch, reader, func(format string, args ...interface{}) { fmt.Printf(format, args...) }) {
+		fmt.Println("\nNothing was changed.")
+		fmt.Println("\nPress Enter to exit...")
+		reader.ReadString('\n')
+		os.Exit(1)
+	}
+
+	// Suggest installs of this version; the working directory is only the fallback.
+	// A full install suggests the default install directory instead.
+	targetDir := defaultTargetDir
+	var matches []patcher.InstallMatch
+	if patch.FullInstall {
+		targetDir = patcher.DefaultInstallDir(patch, defaultTargetDir)
+	} else {
+		matches = locateInstalls(patch, defaultTargetDir)
+	}
+	if len(matches) > 0 {
+		fmt.Printf("\nFound %d install(s) of version %s:\n", len(matches), patch.FromVersion)
+		fmt.Print(formatInstallMatches(matches))
+		targetDir = matches[0].Dir
+		if match, ok := preferredInstall(matches); ok {
+			targetDir = match.Dir
+		}
+	} else if !patch.FullInstall && patch.Branding != nil && patch.Branding.InstallDirHint != "" {
+		fmt.Printf("\n%s is usually installed in: %s\n", patch.Branding.ProductName, patch.Branding.InstallDirHint)
+	}
+
+	// Ask for target directory (a path, or the number of a found install)
+	if len(matches) > 0 {
+		fmt.Printf("\nTarget directory (number or path) [%s]: ", targetDir)
+	} else {
+		fmt.Printf("\nTarget directory [%s]: ", targetDir)
+	}
 	input, _ := reader.ReadString('\n')
 	input = strings.TrimSpace(input)
-	targetDir := defaultTargetDir
-	if input != "" {
+	if dir, ok := selectInstallMatch(matches, input); ok {
+		targetDir = dir
+	} else if input != "" {
 		targetDir = input
 	}
 
-	// Check if directory exists
-	if !utils.FileExists(targetDir) {
+	// Check if directory exists (a full install creates it)
+	if !patch.FullInstall && !utils.FileExists(targetDir) {
 		fmt.Printf("Error: Directory not found: %s\n", targetDir)
 		fmt.Println("\nPress Enter to exit...")
 		reader.ReadString('\n')
@@ -972,7 +1122,7 @@ func runInteractiveMode(patch *utils.Patch, defaultTargetDir string, ignore1GB b
 			// Dry run
 			fmt.Println("\n=== DRY RUN MODE ===")
 			fmt.Println("Simulating patch application...")
-			performDryRun(patch, targetDir, customKeyFile)
+			performDryRun(patch, targetDir, customKeyFile, true)
 			fmt.Println("\nPress Enter to continue...")
 			reader.ReadString('\n')
 
@@ -993,18 +1143,25 @@ func runInteractiveMode(patch *utils.Patch, defaultTargetDir string, ignore1GB b
 
 			if confirm == "yes" || confirm == "y" {
 				fmt.Println("\nApplying patch...")
-				applier := patcher.NewApplier()
+				applier := opts.newApplier()
 				if err := applier.ApplyPatchWithPath(patch, targetDir, "", true, true, true); err != nil {
 					fmt.Printf("\nError: Patch application failed: %v\n", err)
 					fmt.Println("\nNote: Automatic rollback may have been performed to restore original files.")
+					fmt.Print(supportHint(patch))
 					fmt.Println("\nPress Enter to exit...")
 					reader.ReadString('\n')
 					os.Exit(1)
 				}
 
+				patcher.RecordInstall(config.GetDefaultHistoryPath(), targetDir, patch)
+
 				fmt.Println("\n=== SUCCESS ===")
 				fmt.Printf("Patch applied successfully!\n")
-				fmt.Printf("Version updated from %s to %s\n", patch.FromVersion, patch.ToVersion)
+				if patch.FullInstall {
+					fmt.Printf("Version %s installed in %s\n", patch.ToVersion, targetDir)
+				} else {
+					fmt.Printf("Version updated from %s to %s\n", patch.FromVersion, patch.ToVersion)
+				}
 				fmt.Println("\nPress Enter to exit...")
 				reader.ReadString('\n')
 				return
@@ -1022,11 +1179,19 @@ func runInteractiveMode(patch *utils.Patch, defaultTargetDir string, ignore1GB b
 
 		case "4":
 			// Change target directory
-			fmt.Print("\nEnter new target directory: ")
+			if len(matches) > 0 {
+				fmt.Print(formatInstallMatches(matches))
+				fmt.Print("\nEnter new target directory (number or path): ")
+			} else {
+				fmt.Print("\nEnter new target directory: ")
+			}
 			input, _ := reader.ReadString('\n')
 			input = strings.TrimSpace(input)
-			if input != "" {
-				if !utils.FileExists(input) {
+			if dir, ok := selectInstallMatch(matches, input); ok {
+				targetDir = dir
+				fmt.Printf("Target directory changed to: %s\n", targetDir)
+			} else if input != "" {
+				if !patch.FullInstall && !utils.FileExists(input) {
 					fmt.Printf("Error: Directory not found: %s\n", input)
 				} else {
 					targetDir = input
@@ -1089,11 +1254,27 @@ func printHelp() {
 	fmt.Println("  --backup        Create backup before patching (default: true)")
 	fmt.Println("  --ignore1gb     Bypass 1GB patch size limit (use with caution)")
 	fmt.Println("  --silent        Silent mode: apply patch automatically without prompts")
+	fmt.Println("  --jobs          Number of parallel workers (0=auto-detect CPU cores, 1=single-threaded, default: 0)")
+	fmt.Println("  --allow-hooks   Run hook scripts from the patch even if it is not signed by a trusted key")
+	fmt.Println("  --trust-key     Public key file(s) for signature verification (comma-separated)")
+	fmt.Println("  --verify-tree   Verify the whole tree against the embedded target manifest after patching (rolls back on failure)")
+	fmt.Println("  --verify-self   Check a self-contained executable (this one, or --patch <exe>) without applying it")
+	fmt.Println("  --extract       Unpack the patch from a self-contained executable (this one, or --patch <exe>) into a directory")
+	fmt.Println("  --accept-eula   Accept the patch's license agreement without prompting (needed with --silent or --patch)")
 	fmt.Println("  --version       Show version information")
 	fmt.Println("  --help          Show this help message")
+	fmt.Println("\nCommands:")
+	fmt.Println("  rollback        Restore the install from backup.cyberpatcher after a patch (patch-apply rollback --help)")
+	fmt.Println("  repair          Rewrite missing or corrupted files using the patch's embedded manifest (patch-apply repair --help)")
+	fmt.Println("  verify          Check an install against a manifest file or a patch's embedded manifest (patch-apply verify --help)")
 	fmt.Println("\nSelf-Contained Executable Mode:")
 	fmt.Println("  When run as a self-contained executable, an interactive console")
 	fmt.Println("  interface will guide you through the patch application process.")
+	fmt.Println("  The install is located automatically: the current directory, the executable's")
+	fmt.Println("  folder and its parents, search roots set by the patch author, and installs")
+	fmt.Println("  updated before (recorded in the install history file).")
+	fmt.Println("  Full-install patches (<version>-full) install into an empty directory instead;")
+	fmt.Println("  --current-dir is created if it does not exist.")
 	fmt.Println("  Use --silent flag for automated patching without user interaction.")
 	fmt.Println("\nExamples:")
 	fmt.Println("  # Apply patch")
@@ -1106,4 +1287,9 @@ func printHelp() {
 	fmt.Println("  1.2.4-to-1.2.5.exe --silent")
 	fmt.Println("\n  # Silent mode with custom target directory")
 	fmt.Println("  1.2.4-to-1.2.5.exe --silent --current-dir C:\\MyApp")
+	fmt.Println("\n  # Silent mode for a branded patch with a license agreement")
+	fmt.Println("  1.2.4-to-1.2.5.exe --silent --accept-eula")
+	fmt.Println("\n  # Check a downloaded updater and unpack it without running it")
+	fmt.Println("  patch-apply --verify-self --patch 1.2.4-to-1.2.5.exe --trust-key release.pub")
+	fmt.Println("  patch-apply --extract unpacked --patch 1.2.4-to-1.2.5.exe")
 }
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/embedded"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/patcher"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/version"
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// selfCheckTarget returns the executable inspected by --verify-self and --extract:
+// the file given with --patch, or this executable
+func selfCheckTarget(patchFile string) (string, error) {
+	if patchFile != "" {
+		return patchFile, nil
+	}
+	exePath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to get executable path: %w", err)
+	}
+	return exePath, nil
+}
+
+// openSelfContained opens a self-contained executable, reporting a plain applier as an error
+func openSelfContained(path string) (*embedded.Executable, error) {
+	exe, err := embedded.Open(path)
+	if errors.Is(err, embedded.ErrNotEmbedded) {
+		return nil, fmt.Errorf("%s is not a self-contained executable (no embedded patch)", path)
+	}
+	return exe, err
+}
+
+// checkExecutable runs the checks made on every launch of a self-contained executable: the checksum
+// of the embedded files and the metadata signature, against the trusted keys or, without them, the
+// key pinned in the executable. The pinned key only shows the executable is intact; it trusts
+// nothing, since anyone can pin their own key. The patch data checksum is verified while the patch
+// is loaded.
+func checkExecutable(exe *embedded.Executable, trustedKeys []ed25519.PublicKey) error {
+	if err := exe.VerifySidecars(); err != nil {
+		return err
+	}
+	if len(trustedKeys) == 0 {
+		key := exe.PinnedKey()
+		if key == nil {
+			return nil
+		}
+		if _, err := exe.VerifySignature([]ed25519.PublicKey{key}); err != nil {
+			return fmt.Errorf("executable signature verification failed: %w", err)
+		}
+		return nil
+	}
+	keyID, err := exe.VerifySignature(trustedKeys)
+	if err != nil {
+		return fmt.Errorf("executable signature verification failed: %w", err)
+	}
+	fmt.Printf("✓ Executable signature verified (key %s)\n", keyID)
+	return nil
+}
+
+// runVerifySelf checks a self-contained executable without applying it.
+// Returns the process exit code: 0 if every check passed, 1 otherwise.
+func runVerifySelf(path string, trustedKeys []ed25519.PublicKey) int {
+	exe, err := openSelfContained(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	defer exe.Close()
+
+	failed := false
+	fail := func(format string, args ...interface{}) {
+		fmt.Printf("  ✗ "+format+"\n", args...)
+		failed = true
+	}
+
+	fmt.Printf("Checking self-contained executable: %s\n\n", path)
+	fmt.Printf("Format:          v%d\n", exe.Header.Version)
+	if meta := exe.Metadata; meta != nil {
+		fmt.Printf("Versions:        %s → %s\n", meta.FromVersion, meta.ToVersion)
+		fmt.Printf("Built with:      applier v%s (this applier: v%s)\n", meta.StubVersion, version.GetVersion())
+		switch {
+		case meta.HasFlag(embedded.MetadataAllParts):
+			fmt.Println("Parts:           multi-part, all parts embedded")
+		case meta.HasFlag(embedded.MetadataMultiPart):
+			fmt.Println("Parts:           multi-part, remaining parts must be next to the executable")
+		default:
+			fmt.Println("Parts:           single part")
+		}
+	}
+	fmt.Printf("Compression:     %s\n", exe.Header.CompressionName())
+	if exe.Header.Silent() {
+		fmt.Println("Mode:            silent (applies without prompts)")
+	} else {
+		fmt.Println("Mode:            interactive")
+	}
+	fmt.Printf("Patch data:      %d bytes\n\n", exe.Header.DataSize)
+
+	// Integrity of the embedded data
+	if err := exe.VerifyChecksum(); err != nil {
+		fail("Patch data: %v", err)
+	} else {
+		fmt.Println("  ✓ Patch data checksum verified")
+	}
+	if exe.Metadata != nil {
+		if err := exe.VerifySidecars(); err != nil {
+			fail("Embedded files: %v", err)
+		} else if exe.Metadata.SidecarChecksum != "" {
+			fmt.Println("  ✓ Embedded files checksum verified")
+		}
+	}
+
+	// Signature over the patch checksum and metadata
+	switch {
+	case !exe.IsSigned():
+		fmt.Println("  - Executable is not signed")
+	case len(trustedKeys) == 0 && exe.PinnedKey() != nil:
+		if keyID, err := exe.VerifySignature([]ed25519.PublicKey{exe.PinnedKey()}); err != nil {
+			fail("Signature: %v", err)
+		} else {
+			fmt.Printf("  ✓ Signature matches the pinned key %s (not trusted: pass --trust-key to check it against a key you trust)\n", keyID)
+		}
+	case len(trustedKeys) == 0:
+		fmt.Printf("  - Signed by key %s (not verified: pass --trust-key to check it)\n", exe.Metadata.SignerKeyID)
+	default:
+		if keyID, err := exe.VerifySignature(trustedKeys); err != nil {
+			fail("Signature: %v", err)
+		} else {
+			fmt.Printf("  ✓ Signature verified (key %s)\n", keyID)
+		}
+	}
+
+	// Decode the patch and every part, as applying it would
+	if !failed {
+		if patch, err := loadEmbeddedPatch(exe); err != nil {
+			fail("Patch: %v", err)
+		} else {
+			fmt.Printf("  ✓ Patch decoded: %s → %s, %d operations\n", patch.FromVersion, patch.ToVersion, len(patch.Operations))
+		}
+	}
+
+	if failed {
+		fmt.Println("\nResult: FAILED - do not run this executable; download it again")
+		return 1
+	}
+	fmt.Println("\nResult: OK")
+	return 0
+}
+
+// runExtract unpacks the patch and embedded files of a self-contained executable into dir
+func runExtract(path, dir string) int {
+	exe, err := openSelfContained(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	defer exe.Close()
+
+	sidecars, err := exe.Sidecars()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	names := make([]string, len(sidecars))
+	for i, sc := range sidecars {
+		names[i] = sc.Name
+	}
+	baseName, _ := embeddedPartsBase(names, 0, embeddedBaseName(path))
+
+	// Part 01 of a multi-part patch must keep its .01 suffix so the other parts are loaded with it
+	multiPart := false
+	if exe.Metadata != nil {
+		multiPart = exe.Metadata.HasFlag(embedded.MetadataMultiPart)
+	} else {
+		// Version 1 executables do not say; decode the patch to find out
+		patch, err := exe.LoadPatch()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return 1
+		}
+		multiPart = patch.MultiPart != nil && patch.MultiPart.IsMultiPart
+	}
+	patchName := baseName + ".patch"
+	if multiPart {
+		patchName = baseName + ".01.patch"
+	}
+
+	written, err := exe.Extract(dir, patchName)
+	for _, file := range written {
+		fmt.Printf("  %s\n", file)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("\n✓ Extracted %d file(s) to %s\n", len(written), dir)
+	fmt.Println("Apply with:")
+	fmt.Printf("  patch-apply --patch %s --current-dir <directory>\n", written[0])
+	return 0
+}
+
+// loadEmbeddedPatch decodes the patch embedded in exe, loading the remaining parts of a multi-part patch
+// from the executable or from files next to it
+func loadEmbeddedPatch(exe *embedded.Executable) (*utils.Patch, error) {
+	patch, err := exe.LoadPatch()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embedded patch: %w", err)
+	}
+	if patch.MultiPart == nil || !patch.MultiPart.IsMultiPart {
+		return patch, nil
+	}
+
+	sidecars, err := exe.Sidecars()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(sidecars))
+	for i, sc := range sidecars {
+		names[i] = sc.Name
+	}
+	baseName, allEmbedded := embeddedPartsBase(names, patch.MultiPart.TotalParts, embeddedBaseName(exe.Path))
+
+	source := patcher.NewDirPartSource(filepath.Dir(exe.Path), baseName)
+	for _, sc := range sidecars {
+		source.AddFile(sc.Name, sc.Data)
+	}
+
+	patch, err = patcher.LoadMultiPartPatchFrom(patch, source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load multi-part patch: %w", err)
+	}
+
+	if allEmbedded {
+		fmt.Printf("✓ Loaded multi-part patch from embedded parts\n")
+	} else {
+		fmt.Printf("✓ Loaded multi-part patch from embedded part 01 + external parts\n")
+	}
+	return patch, nil
+}
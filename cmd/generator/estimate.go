@@ -0,0 +1,302 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/patcher"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/version"
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// estimateDirLimit is the most directories listed for one patch; the rest are summed up
+const estimateDirLimit = 15
+
+// estimateRequest selects the versions to estimate with the flags of the generation modes
+type estimateRequest struct {
+	versionsDir, newVersion string
+	from, to                string
+	fromDir, toDir          string
+	filter                  version.Filter
+}
+
+// estimateJob is a patch to estimate: its description, its output file, and the estimate
+type estimateJob struct {
+	label    string
+	file     string
+	allParts bool // Its executable embeds every part (--embed-all-parts)
+	estimate func() (*patcher.PatchEstimate, error)
+}
+
+// estimatePatches scans the versions of the selected mode (using the scan cache with --savescans),
+// predicts the size of every patch the mode would build and the disk space they take, and prints
+// the estimates. No patch, executable or package is written.
+func estimatePatches(versionMgr *version.Manager, request estimateRequest, opts patcher.EstimateOptions, settings *genSettings) {
+	fmt.Println("Estimating patch sizes (nothing will be written)")
+
+	sources, toVer, err := request.register(versionMgr, settings)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	options := settings.patchOptions()
+	generator := patcher.NewGenerator()
+	var jobs []estimateJob
+	for _, fromVer := range sources {
+		if settings.crp {
+			forwardFile, reverseFile := settings.crpPatchFiles(fromVer.Number, toVer.Number)
+			jobs = append(jobs,
+				estimateJob{label: fromVer.Number + " → " + toVer.Number, file: forwardFile, estimate: func() (*patcher.PatchEstimate, error) {
+					return generator.EstimatePatch(fromVer, toVer, options, opts)
+				}},
+				estimateJob{label: toVer.Number + " → " + fromVer.Number + " (reverse)", file: reverseFile, estimate: func() (*patcher.PatchEstimate, error) {
+					return generator.EstimatePatch(toVer, fromVer, options, opts)
+				}})
+			continue
+		}
+		jobs = append(jobs, estimateJob{
+			label:    fromVer.Number + " → " + toVer.Number,
+			file:     settings.patchFile(fromVer.Number, toVer.Number),
+			allParts: settings.embedAllParts,
+			estimate: func() (*patcher.PatchEstimate, error) {
+				return generator.EstimatePatch(fromVer, toVer, options, opts)
+			},
+		})
+	}
+	if settings.full {
+		jobs = append(jobs, estimateJob{
+			label:    toVer.Number + " full install",
+			file:     settings.fullPatchFile(toVer.Number),
+			allParts: settings.embedAllParts,
+			estimate: func() (*patcher.PatchEstimate, error) {
+				return generator.EstimateFullPatch(toVer, options, opts)
+			},
+		})
+	}
+
+	var stubSize int64
+	if settings.createExe {
+		if info, err := os.Stat(settings.stubPath); err == nil {
+			stubSize = info.Size()
+		}
+	}
+
+	var total, exeTotal, extraPeak, dataTotal int64
+	patches, files, exes := 0, 0, 0
+	for _, job := range jobs {
+		fmt.Printf("\nEstimating %s...\n", job.label)
+		estimate, err := job.estimate()
+		if err != nil {
+			fmt.Printf("Warning: failed to estimate %s: %v\n", job.label, err)
+			continue
+		}
+		printPatchEstimate(job, estimate, settings)
+
+		patches++
+		files += estimate.Files()
+		total += estimate.Size
+		dataTotal += estimate.DataSize
+		extraPeak = max(extraPeak, estimate.PeakSize-estimate.Size)
+		if exe := settings.estimateExeSize(estimate, stubSize, job.allParts); exe > 0 {
+			exes++
+			exeTotal += exe
+		}
+	}
+
+	// The package stores the version compressed; its uncompressed size bounds it
+	var packageSize int64
+	if settings.fullPackage != "" {
+		for _, file := range toVer.Manifest.Files {
+			packageSize += file.Size
+		}
+	}
+
+	required := total + exeTotal + packageSize
+	fmt.Println("\n=== Estimate Summary ===")
+	fmt.Printf("Patches:       %d, ~%s in %d file(s) (%s of file data)\n", patches, utils.FormatBytes(total), files, utils.FormatBytes(dataTotal))
+	if settings.createExe {
+		fmt.Printf("Executables:   %d, ~%s\n", exes, utils.FormatBytes(exeTotal))
+	}
+	if settings.fullPackage != "" {
+		fmt.Printf("Full package:  at most %s (%s, not estimated: the uncompressed size of %s)\n",
+			utils.FormatBytes(packageSize), filepath.Base(settings.fullPackageFile(toVer.Number)), toVer.Number)
+	}
+	fmt.Printf("Disk space:    ~%s in %s", utils.FormatBytes(required), settings.outputDir)
+	if extraPeak > 0 {
+		fmt.Printf(" (~%s at the peak, while parts are cut into chunks)", utils.FormatBytes(required+extraPeak))
+	}
+	fmt.Println()
+	fmt.Println("\nEstimate only: no files were written")
+}
+
+// register detects the key files of the versions selected by the request and registers them.
+// Returns the source versions and the target version; there are no sources when only the full
+// install of the target is built.
+func (r estimateRequest) register(versionMgr *version.Manager, settings *genSettings) ([]*utils.Version, *utils.Version, error) {
+	switch {
+	case r.newVersion != "" && r.versionsDir != "":
+		locations, err := versionEntries(r.versionsDir)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read versions directory: %w", err)
+		}
+		location, ok := locations[r.newVersion]
+		if !ok {
+			return nil, nil, fmt.Errorf("new version directory not found: %s", filepath.Join(r.versionsDir, r.newVersion))
+		}
+		toVer, err := registerVersionDir(versionMgr, r.newVersion, location, settings)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var names []string
+		for name := range locations {
+			if name != r.newVersion {
+				names = append(names, name)
+			}
+		}
+		var sources []*utils.Version
+		for _, name := range selectSourceVersions(names, r.newVersion, r.filter) {
+			fromVer, err := registerVersionDir(versionMgr, name, locations[name], settings)
+			if err != nil {
+				fmt.Printf("Warning: skipping %s - %v\n", name, err)
+				continue
+			}
+			sources = append(sources, fromVer)
+		}
+		return sources, toVer, nil
+
+	case r.fromDir != "" && r.toDir != "":
+		fromName, toName := extractVersionFromPath(r.fromDir), extractVersionFromPath(r.toDir)
+		fromVer, err := registerVersionDir(versionMgr, fromName, r.fromDir, settings)
+		if err != nil {
+			return nil, nil, err
+		}
+		if fromName == toName {
+			versionMgr.UnregisterVersion(fromName)
+		}
+		toVer, err := registerVersionDir(versionMgr, toName, r.toDir, settings)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []*utils.Version{fromVer}, toVer, nil
+
+	case r.from != "" && r.to != "" && r.versionsDir != "":
+		var versions []*utils.Version
+		for _, name := range []string{r.from, r.to} {
+			location, err := versionLocation(r.versionsDir, name)
+			if err != nil {
+				return nil, nil, err
+			}
+			ver, err := registerVersionDir(versionMgr, name, location, settings)
+			if err != nil {
+				return nil, nil, err
+			}
+			versions = append(versions, ver)
+		}
+		return versions[:1], versions[1], nil
+
+	case (settings.full || settings.fullPackage != "") && (r.toDir != "" || r.to != "" && r.versionsDir != ""):
+		name, location := r.to, r.toDir
+		if location != "" {
+			name = extractVersionFromPath(location)
+		} else {
+			var err error
+			if location, err = versionLocation(r.versionsDir, name); err != nil {
+				return nil, nil, err
+			}
+		}
+		toVer, err := registerVersionDir(versionMgr, name, location, settings)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, toVer, nil
+	}
+	return nil, nil, fmt.Errorf("--estimate needs the versions of a patch: --versions-dir with --new-version or --from/--to, --from-dir/--to-dir, or --full with --to-dir")
+}
+
+// printPatchEstimate prints the predicted size, parts and per-directory contributions of a patch
+func printPatchEstimate(job estimateJob, estimate *patcher.PatchEstimate, settings *genSettings) {
+	fmt.Printf("\n=== %s: %s ===\n", job.label, job.file)
+	fmt.Printf("Changes:     %d added, %d modified, %d deleted files\n", estimate.Added, estimate.Modified, estimate.Deleted)
+	fmt.Printf("File data:   %s (%s sampled)\n", utils.FormatBytes(estimate.DataSize), utils.FormatBytes(estimate.SampledSize))
+
+	compression := fmt.Sprintf("%s level %d", settings.compression, settings.level)
+	if settings.compression == "none" {
+		compression = "uncompressed"
+	}
+	fmt.Printf("Patch size:  ~%s (%s; file data %.0f%%, metadata %s)\n", utils.FormatBytes(estimate.Size), compression,
+		estimate.Ratio()*100, utils.FormatBytes(estimate.MetadataSize))
+
+	maxPartSize := settings.customMaxPartSize
+	if maxPartSize <= 0 {
+		maxPartSize = utils.DefaultMaxPartSize
+	}
+	if estimate.Parts == nil {
+		fmt.Printf("Parts:       1 file, not split (split limit %s)\n", utils.FormatBytes(maxPartSize))
+	} else {
+		fmt.Printf("Parts:       %d parts in %d files (split limit %s)\n", len(estimate.Parts), estimate.Files(), utils.FormatBytes(maxPartSize))
+		for i, part := range estimate.Parts {
+			chunks := ""
+			if part.Chunks > 0 {
+				chunks = fmt.Sprintf(", %d chunks", part.Chunks)
+			}
+			fmt.Printf("  Part %02d    %6d operations  %10s → ~%s%s\n", i+1, part.Operations,
+				utils.FormatBytes(part.DataSize), utils.FormatBytes(part.Size), chunks)
+		}
+	}
+
+	if len(estimate.Directories) == 0 {
+		return
+	}
+	width := len("Directory")
+	for _, dir := range estimate.Directories[:min(len(estimate.Directories), estimateDirLimit)] {
+		width = max(width, len(dir.Path))
+	}
+	fmt.Println("Contributions by directory:")
+	fmt.Printf("  %-*s  %6s  %10s  %11s  %6s\n", width, "Directory", "Files", "Data", "In patch", "Share")
+	var restFiles int
+	var restData, restSize int64
+	for i, dir := range estimate.Directories {
+		if i >= estimateDirLimit {
+			restFiles += dir.Files
+			restData += dir.DataSize
+			restSize += dir.Size
+			continue
+		}
+		fmt.Printf("  %-*s  %6d  %10s  %11s  %5.1f%%\n", width, dir.Path, dir.Files, utils.FormatBytes(dir.DataSize),
+			"~"+utils.FormatBytes(dir.Size), share(dir.Size, estimate.Size))
+	}
+	if rest := len(estimate.Directories) - estimateDirLimit; rest > 0 {
+		fmt.Printf("  %-*s  %6d  %10s  %11s  %5.1f%%\n", width, fmt.Sprintf("(%d more)", rest), restFiles, utils.FormatBytes(restData),
+			"~"+utils.FormatBytes(restSize), share(restSize, estimate.Size))
+	}
+}
+
+// share returns part as a percentage of whole
+func share(part, whole int64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(whole)
+}
+
+// estimateExeSize predicts the size of the self-contained executable built for a patch, or 0 if
+// none is built: the stub with the patch, part 01, or every part with allParts, within the
+// executable size limit as in writePatch
+func (s *genSettings) estimateExeSize(estimate *patcher.PatchEstimate, stubSize int64, allParts bool) int64 {
+	if !s.createExe {
+		return 0
+	}
+	if estimate.Parts == nil {
+		return stubSize + estimate.Size
+	}
+	if allParts && estimate.Size < maxExeSize {
+		return stubSize + estimate.Size
+	}
+	if estimate.FirstFileSize() < maxExeSize {
+		return stubSize + estimate.FirstFileSize()
+	}
+	return 0
+}
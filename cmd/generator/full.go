@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/archive"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/patcher"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/version"
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// packageInfoFormatVersion is the version of the PackageInfo written into full-install packages
+const packageInfoFormatVersion = 1
+
+// fullBase returns the output path of the full install of a version without extension, named by --full-name
+func (s *genSettings) fullBase(ver string) string {
+	return filepath.Join(s.outputDir, strings.ReplaceAll(s.fullName, "{version}", ver))
+}
+
+// fullPatchFile returns the output file of the full-install patch for a version
+func (s *genSettings) fullPatchFile(ver string) string {
+	return s.fullBase(ver) + ".patch"
+}
+
+// fullPackageFile returns the output file of the full-install package for a version
+func (s *genSettings) fullPackageFile(ver string) string {
+	return s.fullBase(ver) + "." + s.fullPackage
+}
+
+// buildFullInstall writes the full-install patch (--full) and package (--full-package) of the target
+// version. Failures are reported as warnings, since the update patches are still usable.
+func (s *genSettings) buildFullInstall(toVer *utils.Version) {
+	if s.full {
+		fmt.Printf("\nGenerating full-install patch for %s...\n", toVer.Number)
+		if err := s.generateFullPatch(toVer); err != nil {
+			fmt.Printf("Warning: failed to generate full-install patch: %v\n", err)
+		}
+	}
+	if s.fullPackage != "" {
+		packageFile := s.fullPackageFile(toVer.Number)
+		fmt.Printf("\nWriting full-install package %s...\n", packageFile)
+		if err := s.writeFullPackage(toVer, packageFile); err != nil {
+			fmt.Printf("Warning: failed to write full-install package: %v\n", err)
+		} else {
+			fmt.Printf("✓ Full-install package: %s\n", packageFile)
+		}
+	}
+}
+
+// generateFullPatch generates, signs and saves the full-install patch of a version, with its
+// self-contained executable if --create-exe is set
+func (s *genSettings) generateFullPatch(toVer *utils.Version) error {
+	generator := patcher.NewGenerator()
+	patch, err := generator.GenerateFullPatch(toVer, s.patchOptions())
+	if err != nil {
+		return err
+	}
+	if err := s.finalizePatch(generator, patch); err != nil {
+		return err
+	}
+	return writePatch(generator, patch, "", toVer.Number, s.fullPatchFile(toVer.Number), s)
+}
+
+// writeFullPackage writes the files of a version to an archive, with the signed package info at
+// its root
+func (s *genSettings) writeFullPackage(toVer *utils.Version, packageFile string) error {
+	info := &utils.PackageInfo{
+		FormatVersion: packageInfoFormatVersion,
+		Version:       toVer.Number,
+		KeyFile:       toVer.KeyFile,
+		Manifest:      toVer.Manifest,
+		Branding:      s.branding,
+		Hooks:         s.hooks,
+		SearchRoots:   s.searchRoots,
+		CreatedAt:     time.Now().UTC(),
+	}
+	if s.signingKey != nil {
+		if err := utils.SignPackage(info, s.signingKey); err != nil {
+			return fmt.Errorf("failed to sign package: %w", err)
+		}
+	}
+	infoData, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode package info: %w", err)
+	}
+
+	open, err := versionFileOpener(toVer)
+	if err != nil {
+		return err
+	}
+	files := make([]archive.Entry, 0, len(toVer.Manifest.Files)+1)
+	for _, file := range toVer.Manifest.Files {
+		mode := file.Mode
+		if mode == 0 && file.IsExecutable {
+			mode = 0755
+		}
+		files = append(files, archive.Entry{
+			Path:    file.Path,
+			Size:    file.Size,
+			Mode:    mode,
+			ModTime: file.ModTime,
+			Open:    func() (io.ReadCloser, error) { return open(file.Path) },
+		})
+	}
+	files = append(files, archive.Entry{
+		Path:    utils.PackageInfoFileName,
+		Size:    int64(len(infoData)),
+		ModTime: info.CreatedAt,
+		Open:    func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(infoData)), nil },
+	})
+
+	return archive.Write(packageFile, toVer.Manifest.Directories, files)
+}
+
+// versionFileOpener returns a function that opens a file of a version by its manifest path.
+// Files of archive versions are read into memory in one pass first, as for patch generation.
+func versionFileOpener(ver *utils.Version) (func(path string) (io.ReadCloser, error), error) {
+	if !archive.IsArchive(ver.Location) {
+		return func(path string) (io.ReadCloser, error) {
+			return os.Open(filepath.Join(ver.Location, filepath.FromSlash(path)))
+		}, nil
+	}
+
+	paths := make([]string, 0, len(ver.Manifest.Files))
+	for _, file := range ver.Manifest.Files {
+		paths = append(paths, file.Path)
+	}
+	fmt.Printf("Reading %d files from %s...\n", len(paths), filepath.Base(ver.Location))
+	contents, err := archive.ReadFiles(ver.Location, paths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read version files: %w", err)
+	}
+	return func(path string) (io.ReadCloser, error) {
+		data, ok := contents[path]
+		if !ok {
+			return nil, fmt.Errorf("%s was not read from the archive", path)
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}, nil
+}
+
+// generateFullInstallOnly builds only the full install of a version, given as --to-dir or as
+// --to with --versions-dir
+func generateFullInstallOnly(versionMgr *version.Manager, versionsDir, to, toDir string, settings *genSettings) {
+	location := toDir
+	if location != "" {
+		to = extractVersionFromPath(toDir)
+	} else {
+		var err error
+		if location, err = versionLocation(versionsDir, to); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	fmt.Printf("Building full install of %s (%s)\n", to, location)
+
+	keyFile, err := settings.detectKeyFile(location)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Using key file: %s\n", keyFile)
+
+	toVer, err := versionMgr.RegisterVersion(to, location, keyFile)
+	if err != nil {
+		fmt.Printf("Error: failed to register version: %v\n", err)
+		os.Exit(1)
+	}
+	settings.saveTargetManifest(toVer)
+
+	// Failures are fatal here, since nothing else is built
+	if settings.full {
+		fmt.Printf("\nGenerating full-install patch for %s...\n", toVer.Number)
+		if err := settings.generateFullPatch(toVer); err != nil {
+			fmt.Printf("Error: failed to generate full-install patch: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if settings.fullPackage != "" {
+		packageFile := settings.fullPackageFile(toVer.Number)
+		fmt.Printf("\nWriting full-install package %s...\n", packageFile)
+		if err := settings.writeFullPackage(toVer, packageFile); err != nil {
+			fmt.Printf("Error: failed to write full-install package: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Full-install package: %s\n", packageFile)
+	}
+	fmt.Println("Full install built successfully")
+}
@@ -1,46 +1,92 @@
 package main
 
 import (
-	"crypto/sha256"
-	"encoding/binary"
+	"crypto/ed25519"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/archive"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/catalog"
 	"github.com/cyberofficial/cyberpatchmaker/internal/core/config"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/embedded"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/manifest"
 	"github.com/cyberofficial/cyberpatchmaker/internal/core/patcher"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/planner"
 	"github.com/cyberofficial/cyberpatchmaker/internal/core/version"
 	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
 )
 
 func main() {
 	// Define flags
-	versionsDir := flag.String("versions-dir", "", "Directory containing version folders")
+	versionsDir := flag.String("versions-dir", "", "Directory containing version folders or archives (zip, tar, tar.gz, tar.zst)")
 	newVersion := flag.String("new-version", "", "New version number to generate patches for")
 	from := flag.String("from", "", "Source version number (for single patch)")
 	to := flag.String("to", "", "Target version number (for single patch)")
-	fromDir := flag.String("from-dir", "", "Full path to source version directory (overrides --versions-dir/--from)")
-	toDir := flag.String("to-dir", "", "Full path to target version directory (overrides --versions-dir/--to)")
-	output := flag.String("output", "", "Output directory for patches")
+	fromDir := flag.String("from-dir", "", "Full path to source version directory or archive (overrides --versions-dir/--from)")
+	toDir := flag.String("to-dir", "", "Full path to target version directory or archive (overrides --versions-dir/--to)")
+	output := flag.String("output", "", "Output directory for patches ({version} = target version)")
+	patchName := flag.String("patch-name", "{from}-to-{to}", "Name of patch files without extension ({from} and {to} = versions)")
+	fullName := flag.String("full-name", "{version}-full", "Name of full-install patches and packages without extension ({version} = target version)")
 	keyFile := flag.String("key-file", "", "Specific key file to use (e.g., app.exe, game.exe)")
+	keyCandidates := flag.String("key-candidates", "", "Key file names or glob patterns tried in order when --key-file is not set (comma-separated; default: program.exe,game.exe,app.exe,main.exe)")
+	identityFiles := flag.String("identity-files", "", "Further files that identify each version together with the key file (comma-separated)")
+	identitySample := flag.Int("identity-sample", 0, "Percentage of files in a sampled manifest fingerprint that identifies each version (1-100, 0 = none)")
 	compression := flag.String("compression", "zstd", "Compression algorithm (zstd, gzip, none)")
 	level := flag.Int("level", 3, "Compression level (1-4 for zstd, 1-3 for gzip)")
 	verify := flag.Bool("verify", true, "Verify patches after creation")
 	createExe := flag.Bool("create-exe", false, "Create self-contained CLI executable")
+	exeTargetFlag := flag.String("exe-target", "", "Target platform for --create-exe as os/arch (e.g. linux/amd64, windows/amd64)")
+	stubsDir := flag.String("stubs-dir", "", "Directory with applier stubs named patch-apply-<os>-<arch>[.exe] (default: stubs next to patch-gen)")
+	embedAllParts := flag.Bool("embed-all-parts", false, "Embed every part of a multi-part patch in the --create-exe executable")
 	silent := flag.Bool("silent", false, "Enable silent mode in generated executable (auto-apply without prompts)")
 	crp := flag.Bool("crp", false, "Create reverse patch (for downgrades)")
+	lastN := flag.Int("last", 0, "With --new-version: only generate patches from the newest N older versions (0 = all)")
+	versionRange := flag.String("range", "", "With --new-version: only generate patches from source versions in this range (e.g. '>=1.4.0, <2.0.0')")
+	skipPreRelease := flag.Bool("skip-prerelease", false, "With --new-version: skip pre-release source versions (e.g. 1.2.0-beta.1)")
+	planFlag := flag.Bool("plan", false, "With --new-version: plan incremental and cumulative patches across the version history instead of patching from every older version")
+	maxChain := flag.Int("max-chain", 0, "With --plan: most patches any version applies to reach the new version (0 = no limit)")
+	storageBudget := flag.String("storage-budget", "", "With --plan: most space all patches may take together (e.g. '20GB', '500MB')")
+	planOnly := flag.Bool("plan-only", false, "With --plan: print the plan without building patches")
+	prune := flag.Bool("prune", false, "With --plan: delete patches in the output directory that are not in the plan")
 	saveScans := flag.Bool("savescans", false, "Save directory scans to cache for faster subsequent patches")
 	rescan := flag.Bool("rescan", false, "Force rescan of cached versions")
 	scanData := flag.String("scandata", "", "Custom directory for scan cache (default: .data)")
 	jobs := flag.Int("jobs", 0, "Number of parallel workers (0 = auto-detect CPU cores, 1 = single-threaded)")
 	splitSize := flag.String("splitsize", "", "Custom multi-part split size (e.g., '2G', '2GB', '500M', '500MB'). Default: 4GB")
 	bypassSplitLimit := flag.Bool("bypasssplitlimit", false, "Bypass 100MB minimum split size check")
+	hooksFile := flag.String("hooks", "", "JSON file with hook scripts to embed in the patch")
+	brandingFile := flag.String("branding", "", "JSON file with product branding (name, publisher, welcome text, EULA, support URL) for the applier UI")
+	searchRoots := flag.String("search-roots", "", "Directories self-contained updaters search for the install (comma-separated; environment variables allowed)")
+	signKey := flag.String("sign-key", "", "Private key file used to sign patches (default: signing_key_path from config)")
+	genKey := flag.String("gen-key", "", "Generate a signing key pair (<name>.key and <name>.pub) and exit")
+	embedManifest := flag.Bool("embed-manifest", false, "Embed the complete target manifest in the patch (enables repair and full-tree verification)")
+	verification := flag.String("verification", "full", "Source files the applier verifies before patching: full, touched or sampled")
+	samplePercent := flag.Int("sample-percent", 10, "Percentage of untouched files to verify with --verification sampled (1-99)")
+	full := flag.Bool("full", false, "Also generate a full-install patch of the target version (<version>-full.patch) that installs into an empty directory")
+	fullPackage := flag.String("full-package", "", "Also write the target version as a full-install package: tar.zst or zip (<version>-full.<format>)")
+	saveManifest := flag.Bool("save-manifest", false, "Save the target version manifest to <output>/<version>.manifest.json (for patch-apply verify)")
+	writeIndex := flag.Bool("index", false, "Write or update <output>/index.json, a machine-readable catalog of versions and patches")
+	releaseNotes := flag.String("release-notes", "", "Text or Markdown file with release notes for the new version, stored in index.json (requires --index)")
+	estimate := flag.Bool("estimate", false, "Predict the size, parts and disk space of the patches without writing them")
+	estimateDepth := flag.Int("estimate-depth", 1, "With --estimate: directory levels in the per-directory contributions")
+	estimateSample := flag.String("estimate-sample", "64MB", "With --estimate: most file data compressed to measure compression (e.g. '64MB', '1G')")
 	versionFlag := flag.Bool("version", false, "Show version information")
 	help := flag.Bool("help", false, "Show help message")
 
-	flag.Parse()
+	// "patch-gen build" runs a project file, with the given flags overriding its settings
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "build" {
+		var err error
+		if args, err = buildArgs(args[1:]); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	flag.CommandLine.Parse(args)
 
 	// Show version if requested
 	if *versionFlag {
@@ -53,6 +99,19 @@ func main() {
 		return
 	}
 
+	// Generate a signing key pair if requested
+	if *genKey != "" {
+		keyID, err := utils.GenerateSigningKeyPair(*genKey+".key", *genKey+".pub")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✓ Signing key pair generated (key ID %s)\n", keyID)
+		fmt.Printf("  Private key: %s (keep secret, use with --sign-key)\n", *genKey+".key")
+		fmt.Printf("  Public key:  %s (distribute, use with patch-apply --trust-key)\n", *genKey+".pub")
+		return
+	}
+
 	// Load configuration
 	cfg := config.NewManager()
 	configPath := config.GetDefaultConfigPath()
@@ -124,23 +183,254 @@ func main() {
 	if outputDir == "" {
 		outputDir = "patches"
 	}
+	if strings.Contains(outputDir, "{version}") {
+		target := *newVersion
+		if target == "" {
+			target = *to
+		}
+		if target == "" && *toDir != "" {
+			target = extractVersionFromPath(*toDir)
+		}
+		if target == "" {
+			fmt.Println("Error: --output uses {version}, but no target version is given")
+			os.Exit(1)
+		}
+		outputDir = strings.ReplaceAll(outputDir, "{version}", target)
+	}
+	if err := validateNameTemplate("--patch-name", *patchName, "{from}", "{to}"); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := validateNameTemplate("--full-name", *fullName, "{version}"); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Ensure output directory exists; an estimate writes nothing
+	if !*estimate {
+		if err := utils.EnsureDir(outputDir); err != nil {
+			fmt.Printf("Error: failed to create output directory: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	settings := &genSettings{
+		outputDir:         outputDir,
+		customKeyFile:     *keyFile,
+		compression:       *compression,
+		level:             *level,
+		verify:            *verify,
+		createExe:         *createExe,
+		embedAllParts:     *embedAllParts,
+		silent:            *silent,
+		crp:               *crp,
+		customMaxPartSize: customMaxPartSize,
+		embedManifest:     *embedManifest,
+		saveManifest:      *saveManifest,
+		full:              *full,
+		fullPackage:       *fullPackage,
+		verification:      utils.VerificationLevel(*verification),
+		samplePercent:     *samplePercent,
+		patchName:         *patchName,
+		fullName:          *fullName,
+		keyCandidates:     splitList(*keyCandidates),
+		identityFiles:     splitList(*identityFiles),
+		identitySample:    *identitySample,
+	}
+	if len(settings.keyCandidates) == 0 {
+		settings.keyCandidates = cfg.GetConfig().KeyFileCandidates
+	}
+	if len(settings.keyCandidates) == 0 {
+		settings.keyCandidates = defaultKeyCandidates
+	}
+	if *identitySample < 0 || *identitySample > 100 {
+		fmt.Println("Error: --identity-sample must be between 0 and 100")
+		os.Exit(1)
+	}
+	if err := patcher.ValidateVerification(settings.patchOptions()); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *fullPackage != "" && *fullPackage != archive.FormatTarZst && *fullPackage != archive.FormatZip {
+		fmt.Printf("Error: invalid --full-package format %q (use tar.zst or zip)\n", *fullPackage)
+		os.Exit(1)
+	}
+
+	// Resolve the applier stub up front so a missing stub fails before any scanning
+	target, err := parseExeTarget(*exeTargetFlag)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	settings.exeTarget = target
+	if *createExe {
+		stubPath, err := resolveApplierStub(target, *stubsDir)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		settings.stubPath = stubPath
+		if !target.isLegacy() {
+			fmt.Printf("✓ Building %s executables from stub %s\n", target, stubPath)
+		}
+	}
+
+	// Load hook scripts to embed in the patch
+	if *hooksFile != "" {
+		hooks, err := patcher.LoadHooksFile(*hooksFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		settings.hooks = hooks
+		fmt.Printf("✓ Embedding %d hook(s) from %s\n", len(hooks), *hooksFile)
+	}
+
+	// Load product branding to embed in the patch
+	if *brandingFile != "" {
+		branding, err := patcher.LoadBrandingFile(*brandingFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		settings.branding = branding
+		fmt.Printf("✓ Embedding branding for %s\n", branding.ProductName)
+		if branding.EULA != "" {
+			fmt.Println("  Users must accept the EULA before the patch is applied (silent mode: --accept-eula)")
+		}
+	}
+
+	// Install search roots embedded for self-contained updaters
+	if *searchRoots != "" {
+		settings.searchRoots = splitList(*searchRoots)
+		fmt.Printf("✓ Updaters will search for the install in: %s\n", strings.Join(settings.searchRoots, ", "))
+	}
+
+	// Open the update index so every generated patch is recorded in it
+	if *writeIndex && !*estimate {
+		index, err := catalog.Load(filepath.Join(outputDir, catalog.FileName))
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		settings.index = index
+		fmt.Printf("✓ Recording patches in %s\n", filepath.Join(outputDir, catalog.FileName))
+	}
+	if *releaseNotes != "" {
+		if !*writeIndex {
+			fmt.Println("Error: --release-notes requires --index")
+			os.Exit(1)
+		}
+		notes, err := catalog.LoadReleaseNotes(*releaseNotes)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		settings.releaseNotes = notes
+	}
+
+	// Load signing key (flag takes precedence over config)
+	signKeyPath := *signKey
+	if signKeyPath == "" {
+		signKeyPath = cfg.GetConfig().SigningKeyPath
+	}
+	if signKeyPath != "" {
+		key, err := utils.LoadSigningKey(signKeyPath)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		settings.signingKey = key
+		fmt.Printf("✓ Signing patches with key %s\n", utils.KeyID(key.Public().(ed25519.PublicKey)))
+	} else if len(settings.hooks) > 0 {
+		fmt.Println("Note: patch is unsigned; its hooks will only run if the user passes --allow-hooks")
+	}
 
-	// Ensure output directory exists
-	if err := utils.EnsureDir(outputDir); err != nil {
-		fmt.Printf("Error: failed to create output directory: %v\n", err)
+	// Source version selection for batch mode
+	filter := version.Filter{SkipPreRelease: *skipPreRelease, Last: *lastN}
+	if *lastN < 0 {
+		fmt.Println("Error: --last must be 0 or more")
+		os.Exit(1)
+	}
+	if *versionRange != "" {
+		constraint, err := version.ParseConstraint(*versionRange)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		filter.Constraint = constraint
+	}
+	if !filter.IsEmpty() && *newVersion == "" {
+		fmt.Println("Error: --last, --range and --skip-prerelease select source versions for --new-version")
+		os.Exit(1)
+	}
+
+	// Patch set planning for batch mode
+	plan := planSettings{limits: planner.Options{MaxChain: *maxChain}, only: *planOnly, prune: *prune}
+	if *maxChain < 0 {
+		fmt.Println("Error: --max-chain must be 0 or more")
+		os.Exit(1)
+	}
+	if *storageBudget != "" {
+		budget, err := parseSplitSize(*storageBudget)
+		if err != nil {
+			fmt.Printf("Error: invalid storage budget: %v\n", err)
+			os.Exit(1)
+		}
+		plan.limits.Budget = budget
+	}
+	if !*planFlag && (*maxChain != 0 || *storageBudget != "" || *planOnly || *prune) {
+		fmt.Println("Error: --max-chain, --storage-budget, --plan-only and --prune require --plan")
+		os.Exit(1)
+	}
+	if *planFlag && (*newVersion == "" || *versionsDir == "") {
+		fmt.Println("Error: --plan requires --versions-dir and --new-version")
+		os.Exit(1)
+	}
+	if *planFlag && *crp {
+		fmt.Println("Error: --plan plans upgrade patches only and cannot be combined with --crp")
 		os.Exit(1)
 	}
 
+	// Size estimates take the versions of any generation mode
+	estimateOpts := patcher.EstimateOptions{MaxPartSize: customMaxPartSize, ChunkSize: customMaxPartSize, Depth: *estimateDepth}
+	if *estimate {
+		if *planFlag {
+			fmt.Println("Error: --estimate cannot be combined with --plan (use --plan-only to see the planned patches)")
+			os.Exit(1)
+		}
+		if *estimateDepth < 1 {
+			fmt.Println("Error: --estimate-depth must be 1 or more")
+			os.Exit(1)
+		}
+		sampleSize, err := parseSplitSize(*estimateSample)
+		if err != nil {
+			fmt.Printf("Error: invalid --estimate-sample: %v\n", err)
+			os.Exit(1)
+		}
+		estimateOpts.SampleSize = sampleSize
+	}
+
 	// Handle different modes
-	if *newVersion != "" && *versionsDir != "" {
+	if *estimate {
+		// Predict the patches of the mode selected by the other flags without building them
+		request := estimateRequest{versionsDir: *versionsDir, newVersion: *newVersion, from: *from, to: *to, fromDir: *fromDir, toDir: *toDir, filter: filter}
+		estimatePatches(versionMgr, request, estimateOpts, settings)
+	} else if *planFlag {
+		// Plan the patch set for the version history and build it
+		generatePlannedPatches(versionMgr, *versionsDir, *newVersion, filter, plan, settings)
+	} else if *newVersion != "" && *versionsDir != "" {
 		// Generate patches from all existing versions to new version
-		generateAllPatches(versionMgr, *versionsDir, *newVersion, outputDir, *keyFile, *compression, *level, *verify, *createExe, *silent, *crp, customMaxPartSize)
+		generateAllPatches(versionMgr, *versionsDir, *newVersion, filter, settings)
 	} else if *fromDir != "" && *toDir != "" {
 		// Generate single patch using custom directory paths
-		generateSinglePatchCustomPaths(versionMgr, *fromDir, *toDir, outputDir, *keyFile, *compression, *level, *verify, *createExe, *silent, *crp, customMaxPartSize)
+		generateSinglePatchCustomPaths(versionMgr, *fromDir, *toDir, settings)
 	} else if *from != "" && *to != "" && *versionsDir != "" {
 		// Generate single patch using versions-dir
-		generateSinglePatch(versionMgr, *versionsDir, *from, *to, outputDir, *keyFile, *compression, *level, *verify, *createExe, *silent, *crp, customMaxPartSize)
+		generateSinglePatch(versionMgr, *versionsDir, *from, *to, settings)
+	} else if (*full || *fullPackage != "") && (*toDir != "" || *to != "" && *versionsDir != "") {
+		// Only build the full install of the target version
+		generateFullInstallOnly(versionMgr, *versionsDir, *to, *toDir, settings)
 	} else {
 		fmt.Println("Error: insufficient arguments")
 		printHelp()
@@ -148,25 +438,193 @@ func main() {
 	}
 }
 
-// detectKeyFile resolves the key file for a version directory.
-// If customKeyFile is non-empty, validates it exists. Otherwise auto-detects
-// from standard names: program.exe, game.exe, app.exe, main.exe.
-// Returns the key file name, or empty string with nil error if none auto-detected.
-func detectKeyFile(dirPath, customKeyFile string) (string, error) {
-	if customKeyFile != "" {
-		if utils.FileExists(filepath.Join(dirPath, customKeyFile)) {
-			return customKeyFile, nil
+// genSettings holds the options shared by all patch generation modes
+type genSettings struct {
+	outputDir         string
+	customKeyFile     string
+	compression       string
+	level             int
+	verify            bool
+	createExe         bool
+	exeTarget         exeTarget // Platform of self-contained executables
+	stubPath          string    // Applier executable used as the stub for self-contained executables
+	embedAllParts     bool      // Embed parts 02+ of multi-part patches in the executable
+	silent            bool
+	crp               bool
+	customMaxPartSize int64
+	embedManifest     bool
+	saveManifest      bool
+	full              bool   // Also generate a full-install patch of the target version
+	fullPackage       string // Archive format of the full-install package of the target version ("" = none)
+	verification      utils.VerificationLevel
+	samplePercent     int
+	patchName         string             // Name template of patch files, with {from} and {to}
+	fullName          string             // Name template of full installs, with {version}
+	keyCandidates     []string           // Key file names or glob patterns tried when --key-file is not set
+	identityFiles     []string           // Files identifying each version besides its key file
+	identitySample    int                // Percentage of files in each version's identity fingerprint (0 = none)
+	hooks             []utils.Hook       // Hook scripts embedded in every generated patch
+	branding          *utils.Branding    // Product branding embedded in every generated patch
+	searchRoots       []string           // Install search roots embedded in every generated patch
+	signingKey        ed25519.PrivateKey // Key used to sign generated patches (nil = unsigned)
+	index             *catalog.Index     // Update index recording every generated patch (nil = --index not set)
+	releaseNotes      string             // Release notes for upgrade patches in the index
+}
+
+// patchOptions returns the generator options for these settings
+func (s *genSettings) patchOptions() *utils.PatchOptions {
+	return &utils.PatchOptions{
+		Compression:       s.compression,
+		CompressionLevel:  s.level,
+		GenerateSignature: s.signingKey != nil,
+		SkipIdentical:     true,
+		Hooks:             s.hooks,
+		Branding:          s.branding,
+		SearchRoots:       s.searchRoots,
+		EmbedManifest:     s.embedManifest,
+		VerificationLevel: s.verification,
+		SamplePercent:     s.samplePercent,
+		IdentityFiles:     s.identityFiles,
+		IdentitySample:    s.identitySample,
+	}
+}
+
+// finalizePatch validates a generated patch and signs it if a signing key is configured
+func (s *genSettings) finalizePatch(generator *patcher.Generator, patch *utils.Patch) error {
+	if err := generator.ValidatePatch(patch); err != nil {
+		return fmt.Errorf("patch validation failed: %w", err)
+	}
+	if s.signingKey != nil {
+		if err := utils.SignPatch(patch, s.signingKey); err != nil {
+			return fmt.Errorf("failed to sign patch: %w", err)
 		}
-		return "", fmt.Errorf("custom key file not found: %s", customKeyFile)
 	}
+	return nil
+}
 
-	candidates := []string{"program.exe", "game.exe", "app.exe", "main.exe"}
-	for _, kf := range candidates {
-		if utils.FileExists(filepath.Join(dirPath, kf)) {
-			return kf, nil
+// recordInIndex adds a generated patch, its executable and both versions to the update index (--index)
+func (s *genSettings) recordInIndex(fromVer, toVer *utils.Version, patchFile string) {
+	if s.index == nil {
+		return
+	}
+
+	patchPath := resolvePatchFile(patchFile)
+	parts, totalSize, err := s.index.DescribeParts(patchPath)
+	if err != nil {
+		fmt.Printf("Warning: failed to add %s to the update index: %v\n", patchPath, err)
+		return
+	}
+
+	entry := catalog.PatchEntry{
+		From:        fromVer.Number,
+		To:          toVer.Number,
+		Compression: s.compression,
+		CreatedAt:   time.Now().UTC(),
+		TotalSize:   totalSize,
+		Parts:       parts,
+	}
+	if cmp, err := version.Compare(fromVer.Number, toVer.Number); err == nil && cmp > 0 {
+		entry.Downgrade = true
+	} else {
+		// Release notes describe the new version, so downgrades do not get them
+		entry.ReleaseNotes = s.releaseNotes
+	}
+	if s.signingKey != nil {
+		entry.SignerKeyID = utils.KeyID(s.signingKey.Public().(ed25519.PublicKey))
+	}
+	if exePath := s.exeTarget.exePathFor(patchFile); s.createExe && utils.FileExists(exePath) {
+		file, err := s.index.DescribeFile(exePath)
+		if err != nil {
+			fmt.Printf("Warning: failed to add %s to the update index: %v\n", exePath, err)
+		} else {
+			entry.Executables = append(entry.Executables, catalog.Executable{Platform: s.exeTarget.platform(), File: file})
 		}
 	}
-	return "", nil
+
+	s.index.SetVersion(fromVer)
+	s.index.SetVersion(toVer)
+	s.index.SetPatch(entry)
+	if err := s.index.Save(version.GetVersion()); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+		return
+	}
+	fmt.Printf("✓ Update index: %s → %s recorded\n", fromVer.Number, toVer.Number)
+}
+
+// saveTargetManifest writes the target version manifest to the output directory if --save-manifest is set
+func (s *genSettings) saveTargetManifest(toVer *utils.Version) {
+	if !s.saveManifest {
+		return
+	}
+	manifestFile := filepath.Join(s.outputDir, fmt.Sprintf("%s.manifest.json", toVer.Number))
+	if err := manifest.NewManager().SaveManifest(toVer.Manifest, manifestFile); err != nil {
+		fmt.Printf("Warning: failed to save manifest: %v\n", err)
+		return
+	}
+	fmt.Printf("✓ Saved manifest: %s\n", manifestFile)
+}
+
+// defaultKeyCandidates are the key file names tried when neither --key-file, --key-candidates
+// nor the KeyFileCandidates config setting is given
+var defaultKeyCandidates = []string{"program.exe", "game.exe", "app.exe", "main.exe"}
+
+// detectKeyFile resolves the key file for a version directory or archive.
+// If --key-file is set, validates it exists. Otherwise tries the key file candidates in order:
+// plain names are looked up as relative paths, and glob patterns (e.g. "bin/*.x86_64") must
+// match exactly one file, so the key file never depends on which of several matches comes first.
+func (s *genSettings) detectKeyFile(dirPath string) (string, error) {
+	files, err := newVersionFiles(dirPath)
+	if err != nil {
+		return "", err
+	}
+
+	if s.customKeyFile != "" {
+		if files.exists(s.customKeyFile) {
+			return s.customKeyFile, nil
+		}
+		return "", fmt.Errorf("custom key file not found in %s: %s", dirPath, s.customKeyFile)
+	}
+
+	for _, candidate := range s.keyCandidates {
+		if !strings.ContainsAny(candidate, "*?[") {
+			if files.exists(candidate) {
+				return candidate, nil
+			}
+			continue
+		}
+		matches, err := files.match(candidate)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case len(matches) == 1:
+			return matches[0], nil
+		case len(matches) > 1:
+			return "", fmt.Errorf("key file pattern %q matches %d files in %s (%s); use a more specific pattern or --key-file",
+				candidate, len(matches), dirPath, strings.Join(firstN(matches, 5), ", "))
+		}
+	}
+	return "", fmt.Errorf("no key file found in %s (tried: %s); use --key-file or --key-candidates",
+		dirPath, strings.Join(s.keyCandidates, ", "))
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// firstN returns at most the first n items
+func firstN(items []string, n int) []string {
+	if len(items) > n {
+		return items[:n]
+	}
+	return items
 }
 
 // parseSplitSize parses a size string like "2G", "2GB", "500M", "500MB" into bytes
@@ -224,34 +682,29 @@ func parseSplitSize(sizeStr string) (int64, error) {
 	return result, nil
 }
 
-func generateAllPatches(versionMgr *version.Manager, versionsDir, newVersion, outputDir, customKeyFile, compression string, level int, verify, createExe, silent, crp bool, customMaxPartSize int64) {
+func generateAllPatches(versionMgr *version.Manager, versionsDir, newVersion string, filter version.Filter, settings *genSettings) {
 	fmt.Printf("Generating patches for new version %s\n", newVersion)
 
-	// Scan for existing versions
-	entries, err := os.ReadDir(versionsDir)
+	// Scan for existing versions (folders and archives)
+	locations, err := versionEntries(versionsDir)
 	if err != nil {
 		fmt.Printf("Error: failed to read versions directory: %v\n", err)
 		os.Exit(1)
 	}
 
 	// Register new version
-	newVersionPath := filepath.Join(versionsDir, newVersion)
-	if !utils.FileExists(newVersionPath) {
-		fmt.Printf("Error: new version directory not found: %s\n", newVersionPath)
+	newVersionPath, ok := locations[newVersion]
+	if !ok {
+		fmt.Printf("Error: new version directory not found: %s\n", filepath.Join(versionsDir, newVersion))
 		os.Exit(1)
 	}
 
 	// Determine key file to use
-	keyFile, err := detectKeyFile(newVersionPath, customKeyFile)
+	keyFile, err := settings.detectKeyFile(newVersionPath)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-	if keyFile == "" {
-		fmt.Println("Error: could not find key file (program.exe, game.exe, app.exe, or main.exe)")
-		fmt.Println("Hint: Use --key-file to specify a custom key file")
-		os.Exit(1)
-	}
 	fmt.Printf("Using key file: %s\n", keyFile)
 
 	toVer, err := versionMgr.RegisterVersion(newVersion, newVersionPath, keyFile)
@@ -259,29 +712,36 @@ func generateAllPatches(versionMgr *version.Manager, versionsDir, newVersion, ou
 		fmt.Printf("Error: failed to register new version: %v\n", err)
 		os.Exit(1)
 	}
+	settings.saveTargetManifest(toVer)
+	settings.buildFullInstall(toVer)
 
-	// Generate patches from each existing version
-	patchCount := 0
-	for _, entry := range entries {
-		if !entry.IsDir() || entry.Name() == newVersion {
-			continue
+	// Order the existing versions and select the sources
+	var names []string
+	for name := range locations {
+		if name != newVersion {
+			names = append(names, name)
 		}
+	}
+	sources := selectSourceVersions(names, newVersion, filter)
+	if len(sources) == 0 {
+		fmt.Println("No source versions selected")
+	} else {
+		fmt.Printf("Source versions (oldest first): %s\n", strings.Join(sources, ", "))
+	}
 
-		fromVersion := entry.Name()
-		fromPath := filepath.Join(versionsDir, fromVersion)
+	// Generate patches from each selected version
+	patchCount := 0
+	for _, fromVersion := range sources {
+		fromPath := locations[fromVersion]
 
 		fmt.Printf("\nProcessing version %s...\n", fromVersion)
 
 		// Auto-detect key file for this source version (may differ from target)
-		fromKeyFile, err := detectKeyFile(fromPath, customKeyFile)
+		fromKeyFile, err := settings.detectKeyFile(fromPath)
 		if err != nil {
 			fmt.Printf("Warning: skipping %s - %v\n", fromVersion, err)
 			continue
 		}
-		if fromKeyFile == "" {
-			fmt.Printf("Warning: skipping %s - no key file found (tried: program.exe, game.exe, app.exe, main.exe)\n", fromVersion)
-			continue
-		}
 
 		fromVer, err := versionMgr.RegisterVersion(fromVersion, fromPath, fromKeyFile)
 		if err != nil {
@@ -290,41 +750,46 @@ func generateAllPatches(versionMgr *version.Manager, versionsDir, newVersion, ou
 		}
 
 		// Generate patch (with reverse if requested)
-		patchFile := filepath.Join(outputDir, fmt.Sprintf("%s-to-%s.patch", fromVersion, newVersion))
+		patchFile := settings.patchFile(fromVersion, newVersion)
 
-		if crp {
+		if settings.crp {
 			// Generate both patches efficiently using the same scan data
-			reversePatchFile := filepath.Join(outputDir, fmt.Sprintf("%s-to-%s_rev.patch", newVersion, fromVersion))
-			if err := generatePatchWithReverse(fromVer, toVer, patchFile, reversePatchFile, compression, level, verify, customMaxPartSize); err != nil {
+			var reversePatchFile string
+			patchFile, reversePatchFile = settings.crpPatchFiles(fromVersion, newVersion)
+			forwardParts, reverseParts, err := generatePatchWithReverse(fromVer, toVer, patchFile, reversePatchFile, settings)
+			if err != nil {
 				fmt.Printf("Error: failed to generate patches from %s: %v\n", fromVersion, err)
 				continue
 			}
 
 			// Create forward exe if requested
-			if createExe {
-				exePath := filepath.Join(outputDir, fmt.Sprintf("%s-to-%s.exe", fromVersion, newVersion))
-				if err := createStandaloneCLIExe(resolvePatchFile(patchFile), exePath, compression, silent); err != nil {
+			if settings.createExe {
+				exePath := settings.exeTarget.exePathFor(patchFile)
+				if err := createStandaloneCLIExe(patchFile, forwardParts, exePath, fromVer.Number, toVer.Number, false, settings); err != nil {
 					fmt.Printf("Warning: failed to create forward executable for %s: %v\n", fromVersion, err)
 				} else {
 					fmt.Printf("✓ Forward executable: %s\n", exePath)
 				}
 
 				// Create reverse exe
-				reverseExePath := filepath.Join(outputDir, fmt.Sprintf("%s-to-%s_rev.exe", newVersion, fromVersion))
-				if err := createStandaloneCLIExe(resolvePatchFile(reversePatchFile), reverseExePath, compression, silent); err != nil {
+				reverseExePath := settings.exeTarget.exePathFor(reversePatchFile)
+				if err := createStandaloneCLIExe(reversePatchFile, reverseParts, reverseExePath, toVer.Number, fromVer.Number, false, settings); err != nil {
 					fmt.Printf("Warning: failed to create reverse executable to %s: %v\n", fromVersion, err)
 				} else {
 					fmt.Printf("✓ Reverse executable: %s\n", reverseExePath)
 				}
 			}
+			settings.recordInIndex(fromVer, toVer, patchFile)
+			settings.recordInIndex(toVer, fromVer, reversePatchFile)
 
 			patchCount += 2 // Count both patches
 		} else {
 			// Generate only forward patch
-			if err := generatePatch(fromVer, toVer, patchFile, compression, level, createExe, silent, customMaxPartSize); err != nil {
+			if err := generatePatch(fromVer, toVer, patchFile, settings); err != nil {
 				fmt.Printf("Error: failed to generate patch from %s: %v\n", fromVersion, err)
 				continue
 			}
+			settings.recordInIndex(fromVer, toVer, patchFile)
 
 			patchCount++
 		}
@@ -333,19 +798,18 @@ func generateAllPatches(versionMgr *version.Manager, versionsDir, newVersion, ou
 	fmt.Printf("\nSuccessfully generated %d patches\n", patchCount)
 }
 
-func generateSinglePatch(versionMgr *version.Manager, versionsDir, from, to, outputDir, customKeyFile, compression string, level int, verify, createExe, silent, crp bool, customMaxPartSize int64) {
+func generateSinglePatch(versionMgr *version.Manager, versionsDir, from, to string, settings *genSettings) {
 	fmt.Printf("Generating patch from %s to %s\n", from, to)
 
 	// Determine key file for FROM version
-	fromPath := filepath.Join(versionsDir, from)
-	fromKeyFile, err := detectKeyFile(fromPath, customKeyFile)
+	fromPath, err := versionLocation(versionsDir, from)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-	if fromKeyFile == "" {
-		fmt.Println("Error: could not find key file in source version (program.exe, game.exe, app.exe, or main.exe)")
-		fmt.Println("Hint: Use --key-file to specify a custom key file")
+	fromKeyFile, err := settings.detectKeyFile(fromPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 	fmt.Printf("Auto-detected source key file: %s\n", fromKeyFile)
@@ -358,15 +822,14 @@ func generateSinglePatch(versionMgr *version.Manager, versionsDir, from, to, out
 	}
 
 	// Determine key file for TO version (may differ from source)
-	toPath := filepath.Join(versionsDir, to)
-	toKeyFile, err := detectKeyFile(toPath, customKeyFile)
+	toPath, err := versionLocation(versionsDir, to)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-	if toKeyFile == "" {
-		fmt.Println("Error: could not find key file in target version (program.exe, game.exe, app.exe, or main.exe)")
-		fmt.Println("Hint: Use --key-file to specify a custom key file")
+	toKeyFile, err := settings.detectKeyFile(toPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 	fmt.Printf("Auto-detected target key file: %s\n", toKeyFile)
@@ -376,49 +839,56 @@ func generateSinglePatch(versionMgr *version.Manager, versionsDir, from, to, out
 		fmt.Printf("Error: failed to register target version: %v\n", err)
 		os.Exit(1)
 	}
+	settings.saveTargetManifest(toVer)
+	settings.buildFullInstall(toVer)
 
 	// Generate patch (with reverse if requested)
-	patchFile := filepath.Join(outputDir, fmt.Sprintf("%s-to-%s.patch", from, to))
+	patchFile := settings.patchFile(from, to)
 
-	if crp {
+	if settings.crp {
 		// Generate both patches efficiently using the same scan data
 		fmt.Printf("\nGenerating forward and reverse patches...\n")
-		reversePatchFile := filepath.Join(outputDir, fmt.Sprintf("%s-to-%s_rev.patch", to, from))
-		if err := generatePatchWithReverse(fromVer, toVer, patchFile, reversePatchFile, compression, level, verify, customMaxPartSize); err != nil {
+		var reversePatchFile string
+		patchFile, reversePatchFile = settings.crpPatchFiles(from, to)
+		forwardParts, reverseParts, err := generatePatchWithReverse(fromVer, toVer, patchFile, reversePatchFile, settings)
+		if err != nil {
 			fmt.Printf("Error: failed to generate patches: %v\n", err)
 			os.Exit(1)
 		}
 		fmt.Println("\n✓ Forward and reverse patches generated successfully")
 
 		// Create executables if requested
-		if createExe {
-			exePath := filepath.Join(outputDir, fmt.Sprintf("%s-to-%s.exe", from, to))
-			if err := createStandaloneCLIExe(resolvePatchFile(patchFile), exePath, compression, silent); err != nil {
+		if settings.createExe {
+			exePath := settings.exeTarget.exePathFor(patchFile)
+			if err := createStandaloneCLIExe(patchFile, forwardParts, exePath, fromVer.Number, toVer.Number, false, settings); err != nil {
 				fmt.Printf("Error: failed to create forward executable: %v\n", err)
 				os.Exit(1)
 			}
 			fmt.Printf("✓ Created forward executable: %s\n", exePath)
 
-			reverseExePath := filepath.Join(outputDir, fmt.Sprintf("%s-to-%s_rev.exe", to, from))
-			if err := createStandaloneCLIExe(resolvePatchFile(reversePatchFile), reverseExePath, compression, silent); err != nil {
+			reverseExePath := settings.exeTarget.exePathFor(reversePatchFile)
+			if err := createStandaloneCLIExe(reversePatchFile, reverseParts, reverseExePath, toVer.Number, fromVer.Number, false, settings); err != nil {
 				fmt.Printf("Error: failed to create reverse executable: %v\n", err)
 				os.Exit(1)
 			}
 			fmt.Printf("✓ Created reverse executable: %s\n", reverseExePath)
 		}
+		settings.recordInIndex(fromVer, toVer, patchFile)
+		settings.recordInIndex(toVer, fromVer, reversePatchFile)
 	} else {
 		// Generate only forward patch
-		if err := generatePatch(fromVer, toVer, patchFile, compression, level, createExe, silent, customMaxPartSize); err != nil {
+		if err := generatePatch(fromVer, toVer, patchFile, settings); err != nil {
 			fmt.Printf("Error: failed to generate patch: %v\n", err)
 			os.Exit(1)
 		}
+		settings.recordInIndex(fromVer, toVer, patchFile)
 		fmt.Println("Patch generated successfully")
 	}
 }
 
 // generateSinglePatchCustomPaths generates a patch using custom directory paths
 // This allows versions to be on different drives or network locations
-func generateSinglePatchCustomPaths(versionMgr *version.Manager, fromPath, toPath, outputDir, customKeyFile, compression string, level int, verify, createExe, silent, crp bool, customMaxPartSize int64) {
+func generateSinglePatchCustomPaths(versionMgr *version.Manager, fromPath, toPath string, settings *genSettings) {
 	// Extract version numbers from directory names
 	fromVersion := extractVersionFromPath(fromPath)
 	toVersion := extractVersionFromPath(toPath)
@@ -426,16 +896,11 @@ func generateSinglePatchCustomPaths(versionMgr *version.Manager, fromPath, toPat
 	fmt.Printf("Generating patch from %s (%s) to %s (%s)...\n", fromVersion, fromPath, toVersion, toPath)
 
 	// Determine key file for FROM version
-	fromKeyFile, err := detectKeyFile(fromPath, customKeyFile)
+	fromKeyFile, err := settings.detectKeyFile(fromPath)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-	if fromKeyFile == "" {
-		fmt.Println("Error: could not find key file in source directory (program.exe, game.exe, app.exe, or main.exe)")
-		fmt.Println("Hint: Use --key-file to specify a custom key file")
-		os.Exit(1)
-	}
 	fmt.Printf("Auto-detected source key file: %s\n", fromKeyFile)
 
 	// Register source version
@@ -447,16 +912,11 @@ func generateSinglePatchCustomPaths(versionMgr *version.Manager, fromPath, toPat
 	}
 
 	// Determine key file for TO version (may differ from source)
-	toKeyFile, err := detectKeyFile(toPath, customKeyFile)
+	toKeyFile, err := settings.detectKeyFile(toPath)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
-	if toKeyFile == "" {
-		fmt.Println("Error: could not find key file in target directory (program.exe, game.exe, app.exe, or main.exe)")
-		fmt.Println("Hint: Use --key-file to specify a custom key file")
-		os.Exit(1)
-	}
 	fmt.Printf("Auto-detected target key file: %s\n", toKeyFile)
 
 	// Register target version
@@ -470,61 +930,148 @@ func generateSinglePatchCustomPaths(versionMgr *version.Manager, fromPath, toPat
 		fmt.Printf("Error: failed to register target version: %v\n", err)
 		os.Exit(1)
 	}
+	settings.saveTargetManifest(toVer)
+	settings.buildFullInstall(toVer)
 
 	// Generate patch (with reverse if requested)
-	patchFile := filepath.Join(outputDir, fmt.Sprintf("%s-to-%s.patch", fromVersion, toVersion))
+	patchFile := settings.patchFile(fromVersion, toVersion)
 
-	if crp {
+	if settings.crp {
 		// Generate both patches efficiently using the same scan data
 		fmt.Printf("\nGenerating forward and reverse patches...\n")
-		reversePatchFile := filepath.Join(outputDir, fmt.Sprintf("%s-to-%s_rev.patch", toVersion, fromVersion))
-		if err := generatePatchWithReverse(fromVer, toVer, patchFile, reversePatchFile, compression, level, verify, customMaxPartSize); err != nil {
+		var reversePatchFile string
+		patchFile, reversePatchFile = settings.crpPatchFiles(fromVersion, toVersion)
+		forwardParts, reverseParts, err := generatePatchWithReverse(fromVer, toVer, patchFile, reversePatchFile, settings)
+		if err != nil {
 			fmt.Printf("Error: failed to generate patches: %v\n", err)
 			os.Exit(1)
 		}
 		fmt.Println("\n✓ Forward and reverse patches generated successfully")
 
 		// Create executables if requested
-		if createExe {
-			exePath := filepath.Join(outputDir, fmt.Sprintf("%s-to-%s.exe", fromVersion, toVersion))
-			if err := createStandaloneCLIExe(resolvePatchFile(patchFile), exePath, compression, silent); err != nil {
+		if settings.createExe {
+			exePath := settings.exeTarget.exePathFor(patchFile)
+			if err := createStandaloneCLIExe(patchFile, forwardParts, exePath, fromVer.Number, toVer.Number, false, settings); err != nil {
 				fmt.Printf("Error: failed to create forward executable: %v\n", err)
 				os.Exit(1)
 			}
 			fmt.Printf("✓ Created forward executable: %s\n", exePath)
 
-			reverseExePath := filepath.Join(outputDir, fmt.Sprintf("%s-to-%s_rev.exe", toVersion, fromVersion))
-			if err := createStandaloneCLIExe(resolvePatchFile(reversePatchFile), reverseExePath, compression, silent); err != nil {
+			reverseExePath := settings.exeTarget.exePathFor(reversePatchFile)
+			if err := createStandaloneCLIExe(reversePatchFile, reverseParts, reverseExePath, toVer.Number, fromVer.Number, false, settings); err != nil {
 				fmt.Printf("Error: failed to create reverse executable: %v\n", err)
 				os.Exit(1)
 			}
 			fmt.Printf("✓ Created reverse executable: %s\n", reverseExePath)
 		}
+		settings.recordInIndex(fromVer, toVer, patchFile)
+		settings.recordInIndex(toVer, fromVer, reversePatchFile)
 	} else {
 		// Generate only forward patch
-		if err := generatePatch(fromVer, toVer, patchFile, compression, level, createExe, silent, customMaxPartSize); err != nil {
+		if err := generatePatch(fromVer, toVer, patchFile, settings); err != nil {
 			fmt.Printf("Error: failed to generate patch: %v\n", err)
 			os.Exit(1)
 		}
+		settings.recordInIndex(fromVer, toVer, patchFile)
 		fmt.Printf("✓ Patch generated successfully: %s\n", patchFile)
 	}
 }
 
+// selectSourceVersions orders the existing versions oldest first and applies the batch filter.
+// Versions that are not older than newVersion are skipped. Names that are not version numbers
+// cannot be ordered: they are generated last when no filter is set, and skipped otherwise.
+func selectSourceVersions(names []string, newVersion string, filter version.Filter) []string {
+	versions, invalid := version.SortNames(names)
+
+	if target, err := version.Parse(newVersion); err == nil {
+		older := versions[:0]
+		for _, v := range versions {
+			if v.Compare(target) >= 0 {
+				fmt.Printf("Skipping %s: not older than the new version %s\n", v, newVersion)
+				continue
+			}
+			older = append(older, v)
+		}
+		versions = older
+	} else {
+		fmt.Printf("Warning: new version %q is not a version number; newer source versions are not skipped\n", newVersion)
+	}
+
+	var sources []string
+	for _, v := range filter.Apply(versions) {
+		sources = append(sources, v.String())
+	}
+
+	for _, name := range invalid {
+		if filter.IsEmpty() {
+			fmt.Printf("Warning: %s is not a version number; it is processed last\n", name)
+			sources = append(sources, name)
+		} else {
+			fmt.Printf("Skipping %s: not a version number (required by --last, --range and --skip-prerelease)\n", name)
+		}
+	}
+	return sources
+}
+
+// crpPatchFiles returns the output files for the patch from → to and its reverse with --crp.
+// The downgrade gets the _rev suffix, so names stay right when the source is the newer version.
+func (s *genSettings) crpPatchFiles(from, to string) (string, string) {
+	forward := s.patchBase(from, to) + ".patch"
+	reverse := s.patchBase(to, from) + "_rev.patch"
+	if cmp, err := version.Compare(from, to); err == nil && cmp > 0 {
+		forward = s.patchBase(from, to) + "_rev.patch"
+		reverse = s.patchBase(to, from) + ".patch"
+	}
+	return forward, reverse
+}
+
+// patchBase returns the output path of the patch from → to without extension, named by --patch-name
+func (s *genSettings) patchBase(from, to string) string {
+	name := strings.NewReplacer("{from}", from, "{to}", to).Replace(s.patchName)
+	return filepath.Join(s.outputDir, name)
+}
+
+// patchFile returns the output file of the patch from → to
+func (s *genSettings) patchFile(from, to string) string {
+	return s.patchBase(from, to) + ".patch"
+}
+
+// validateNameTemplate checks an output name template: only the given placeholders, all of them
+// required, and no path separators or extension, since those are added by the generator
+func validateNameTemplate(flagName, template string, placeholders ...string) error {
+	rest := template
+	for _, placeholder := range placeholders {
+		if !strings.Contains(template, placeholder) {
+			return fmt.Errorf("%s %q must contain %s", flagName, template, placeholder)
+		}
+		rest = strings.ReplaceAll(rest, placeholder, "")
+	}
+	if strings.ContainsAny(rest, "{}") {
+		return fmt.Errorf("%s %q has an unknown placeholder (use %s)", flagName, template, strings.Join(placeholders, ", "))
+	}
+	if strings.ContainsAny(rest, `/\`) {
+		return fmt.Errorf("%s %q must be a file name, not a path (set the directory with --output)", flagName, template)
+	}
+	if strings.HasSuffix(rest, ".patch") {
+		return fmt.Errorf("%s %q must not end in .patch; the extension is added", flagName, template)
+	}
+	return nil
+}
+
 // extractVersionFromPath extracts the version number from a directory path
 // Example: "C:\\releases\\1.0.0" -> "1.0.0"
 // Example: "/mnt/versions/v2.1.5" -> "v2.1.5"
 func extractVersionFromPath(path string) string {
+	if archive.IsArchive(path) {
+		return archiveVersion(filepath.Base(path))
+	}
 	// Get the directory name (last component of the path)
 	return filepath.Base(path)
 }
 
-func generatePatch(fromVer, toVer *utils.Version, outputFile, compression string, level int, createExe, silent bool, customMaxPartSize int64) error {
+func generatePatch(fromVer, toVer *utils.Version, outputFile string, settings *genSettings) error {
 	// Create patch options
-	options := &utils.PatchOptions{
-		Compression:      compression,
-		CompressionLevel: level,
-		SkipIdentical:    true,
-	}
+	options := settings.patchOptions()
 
 	// Generate patch
 	generator := patcher.NewGenerator()
@@ -533,16 +1080,27 @@ func generatePatch(fromVer, toVer *utils.Version, outputFile, compression string
 		return err
 	}
 
-	// Validate patch
-	if err := generator.ValidatePatch(patch); err != nil {
-		return fmt.Errorf("patch validation failed: %w", err)
+	// Validate and sign patch
+	if err := settings.finalizePatch(generator, patch); err != nil {
+		return err
 	}
 
+	return writePatch(generator, patch, fromVer.Number, toVer.Number, outputFile, settings)
+}
+
+// maxExeSize is the largest self-contained executable built: Windows does not run larger ones
+const maxExeSize int64 = 3*1024*1024*1024 + 768*1024*1024 // 3.75 GB
+
+// writePatch saves a finalized patch, splitting it into multiple parts if needed, and creates its
+// self-contained executable if --create-exe is set
+func writePatch(generator *patcher.Generator, patch *utils.Patch, fromVersion, toVersion, outputFile string, settings *genSettings) error {
+	options := settings.patchOptions()
+
 	// Check if patch needs to be split into multiple parts
 	totalSize := generator.CalculatePatchSize(patch)
 	var maxPartSize int64 = utils.DefaultMaxPartSize
-	if customMaxPartSize > 0 {
-		maxPartSize = customMaxPartSize
+	if settings.customMaxPartSize > 0 {
+		maxPartSize = settings.customMaxPartSize
 	}
 
 	if totalSize > maxPartSize {
@@ -556,33 +1114,43 @@ func generatePatch(fromVer, toVer *utils.Version, outputFile, compression string
 		}
 
 		// Save multi-part patch (pass chunk size for additional per-part chunking)
-		if err := generator.SaveMultiPartPatch(parts, outputFile, compression, customMaxPartSize, level); err != nil {
+		partFiles, err := generator.SaveMultiPartPatch(parts, outputFile, settings.compression, settings.customMaxPartSize, settings.level)
+		if err != nil {
 			return fmt.Errorf("failed to save multi-part patch: %w", err)
 		}
 
 		fmt.Printf("✓ Multi-part patch saved: %d parts\n", len(parts))
 
-		// If create-exe flag is set, check if part 01 can be turned into an exe
-		if createExe {
-			// Construct part 01 filename
-			part01File := strings.TrimSuffix(outputFile, ".patch") + ".01.patch"
-
-			// Check if part 01 exists and get its size
-			if fileInfo, err := os.Stat(part01File); err == nil {
+		// If create-exe flag is set, check if part 01 (or every part) can be turned into an exe
+		if settings.createExe {
+			// Check part 01's size
+			if fileInfo, err := os.Stat(partFiles.Part01); err == nil {
 				part01Size := fileInfo.Size()
-				const maxExeSize int64 = 3*1024*1024*1024 + 768*1024*1024 // 3.75 GB
+				embedAll := settings.embedAllParts
+				if embedAll {
+					if totalSize := part01Size + sumFileSizes(partFiles.Parts); totalSize >= maxExeSize {
+						fmt.Printf("\nℹ All parts together (%.2f GB) exceed the 3.75 GB Windows exe limit, embedding part 01 only\n",
+							float64(totalSize)/(1024*1024*1024))
+						embedAll = false
+					}
+				}
 
 				if part01Size < maxExeSize {
-					fmt.Printf("\n✓ Part 01 size (%.2f GB) is under 3.75 GB limit, creating self-contained executable...\n",
-						float64(part01Size)/(1024*1024*1024))
+					if embedAll {
+						fmt.Printf("\n✓ Embedding all %d parts in a self-contained executable...\n", len(parts))
+					} else {
+						fmt.Printf("\n✓ Part 01 size (%.2f GB) is under 3.75 GB limit, creating self-contained executable...\n",
+							float64(part01Size)/(1024*1024*1024))
+					}
 
 					// Extract version info from output filename
-					baseName := filepath.Base(outputFile)
-					exeName := strings.TrimSuffix(baseName, ".patch") + ".exe"
-					exePath := filepath.Join(filepath.Dir(outputFile), exeName)
+					exePath := settings.exeTarget.exePathFor(outputFile)
 
-					if err := createStandaloneCLIExe(part01File, exePath, compression, silent); err != nil {
+					if err := createStandaloneCLIExe(outputFile, partFiles, exePath, fromVersion, toVersion, embedAll, settings); err != nil {
 						fmt.Printf("Warning: failed to create executable from part 01: %v\n", err)
+					} else if embedAll {
+						fmt.Printf("✓ Created self-contained executable with all parts: %s\n", exePath)
+						fmt.Printf("  Note: The exe alone is enough to apply the patch\n")
 					} else {
 						fmt.Printf("✓ Created self-contained executable from part 01: %s\n", exePath)
 						fmt.Printf("  Note: This exe will automatically detect and use remaining parts (.02, .03, etc.)\n")
@@ -604,12 +1172,10 @@ func generatePatch(fromVer, toVer *utils.Version, outputFile, compression string
 		fmt.Printf("Patch saved to: %s\n", outputFile)
 
 		// Create self-contained executable if requested
-		if createExe {
-			baseName := filepath.Base(outputFile)
-			exeName := strings.TrimSuffix(baseName, ".patch") + ".exe"
-			exePath := filepath.Join(filepath.Dir(outputFile), exeName)
+		if settings.createExe {
+			exePath := settings.exeTarget.exePathFor(outputFile)
 
-			if err := createStandaloneCLIExe(outputFile, exePath, compression, silent); err != nil {
+			if err := createStandaloneCLIExe(outputFile, nil, exePath, fromVersion, toVersion, false, settings); err != nil {
 				return fmt.Errorf("failed to create executable: %w", err)
 			}
 			fmt.Printf("✓ Created executable: %s\n", exePath)
@@ -621,29 +1187,27 @@ func generatePatch(fromVer, toVer *utils.Version, outputFile, compression string
 
 // generatePatchWithReverse generates both forward and reverse patches efficiently
 // by reusing the same generator and scan data (no need to rescan directories)
-func generatePatchWithReverse(fromVer, toVer *utils.Version, forwardFile, reverseFile, compression string, level int, verify bool, customMaxPartSize int64) error {
+// Returns the files of each patch that was split into multiple parts (nil if saved as a single file).
+func generatePatchWithReverse(fromVer, toVer *utils.Version, forwardFile, reverseFile string, settings *genSettings) (*patcher.MultiPartFiles, *patcher.MultiPartFiles, error) {
 	// Create patch options
-	options := &utils.PatchOptions{
-		Compression:      compression,
-		CompressionLevel: level,
-		SkipIdentical:    true,
-	}
+	options := settings.patchOptions()
 
 	// Generate forward patch (from → to)
 	generator := patcher.NewGenerator()
 	forwardPatch, err := generator.GeneratePatch(fromVer, toVer, options)
 	if err != nil {
-		return fmt.Errorf("forward patch generation failed: %w", err)
+		return nil, nil, fmt.Errorf("forward patch generation failed: %w", err)
 	}
 
-	// Validate forward patch
-	if err := generator.ValidatePatch(forwardPatch); err != nil {
-		return fmt.Errorf("forward patch validation failed: %w", err)
+	// Validate and sign forward patch
+	if err := settings.finalizePatch(generator, forwardPatch); err != nil {
+		return nil, nil, fmt.Errorf("forward %w", err)
 	}
 
 	// Save forward patch (auto-splits into multi-part if needed)
-	if err := savePatchWithSplitting(generator, forwardPatch, forwardFile, compression, level, customMaxPartSize); err != nil {
-		return fmt.Errorf("failed to save forward patch: %w", err)
+	forwardParts, err := savePatchWithSplitting(generator, forwardPatch, forwardFile, settings.compression, settings.level, settings.customMaxPartSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to save forward patch: %w", err)
 	}
 
 	// Generate reverse patch (to → from) - REUSES SAME SCAN DATA!
@@ -651,20 +1215,21 @@ func generatePatchWithReverse(fromVer, toVer *utils.Version, forwardFile, revers
 	fmt.Printf("Generating reverse patch (reusing scan data)...\n")
 	reversePatch, err := generator.GeneratePatch(toVer, fromVer, options)
 	if err != nil {
-		return fmt.Errorf("reverse patch generation failed: %w", err)
+		return nil, nil, fmt.Errorf("reverse patch generation failed: %w", err)
 	}
 
-	// Validate reverse patch
-	if err := generator.ValidatePatch(reversePatch); err != nil {
-		return fmt.Errorf("reverse patch validation failed: %w", err)
+	// Validate and sign reverse patch
+	if err := settings.finalizePatch(generator, reversePatch); err != nil {
+		return nil, nil, fmt.Errorf("reverse %w", err)
 	}
 
 	// Save reverse patch (auto-splits into multi-part if needed)
-	if err := savePatchWithSplitting(generator, reversePatch, reverseFile, compression, level, customMaxPartSize); err != nil {
-		return fmt.Errorf("failed to save reverse patch: %w", err)
+	reverseParts, err := savePatchWithSplitting(generator, reversePatch, reverseFile, settings.compression, settings.level, settings.customMaxPartSize)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to save reverse patch: %w", err)
 	}
 
-	return nil
+	return forwardParts, reverseParts, nil
 }
 
 func savePatch(patch *utils.Patch, filename string, options *utils.PatchOptions) error {
@@ -689,7 +1254,8 @@ func savePatchWithCustomSize(patch *utils.Patch, filename string, options *utils
 }
 
 // savePatchWithSplitting saves a patch, splitting into multi-part if total size exceeds maxPartSize.
-func savePatchWithSplitting(generator *patcher.Generator, patch *utils.Patch, outputFile, compression string, level int, customMaxPartSize int64) error {
+// Returns the files of a multi-part patch, or nil if it was saved as a single file.
+func savePatchWithSplitting(generator *patcher.Generator, patch *utils.Patch, outputFile, compression string, level int, customMaxPartSize int64) (*patcher.MultiPartFiles, error) {
 	var maxPartSize int64 = utils.DefaultMaxPartSize
 	if customMaxPartSize > 0 {
 		maxPartSize = customMaxPartSize
@@ -702,168 +1268,68 @@ func savePatchWithSplitting(generator *patcher.Generator, patch *utils.Patch, ou
 
 		parts, err := generator.SplitPatchIntoParts(patch, maxPartSize)
 		if err != nil {
-			return fmt.Errorf("failed to split patch: %w", err)
+			return nil, fmt.Errorf("failed to split patch: %w", err)
 		}
 
-		if err := generator.SaveMultiPartPatch(parts, outputFile, compression, customMaxPartSize, level); err != nil {
-			return fmt.Errorf("failed to save multi-part patch: %w", err)
+		files, err := generator.SaveMultiPartPatch(parts, outputFile, compression, customMaxPartSize, level)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save multi-part patch: %w", err)
 		}
 		fmt.Printf("✓ Multi-part patch saved: %d parts\n", len(parts))
+		return files, nil
 	} else {
 		if err := utils.SavePatch(patch, outputFile, compression, level); err != nil {
-			return fmt.Errorf("failed to save patch: %w", err)
+			return nil, fmt.Errorf("failed to save patch: %w", err)
 		}
 		fmt.Printf("Patch saved to: %s\n", outputFile)
 	}
-	return nil
+	return nil, nil
 }
 
-// createStandaloneCLIExe creates a self-contained CLI executable by appending patch data to CLI applier
-func createStandaloneCLIExe(patchPath, exePath, compression string, silent bool) error {
-	// Get path to patch-apply.exe (CLI applier)
-	genExe, err := os.Executable()
-	if err != nil {
-		return fmt.Errorf("failed to get executable path: %w", err)
-	}
-
-	// Look for patch-apply.exe in the same directory
-	applierPath := filepath.Join(filepath.Dir(genExe), "patch-apply.exe")
-	if !utils.FileExists(applierPath) {
-		return fmt.Errorf("CLI applier not found: %s", applierPath)
-	}
-
-	// Read the CLI applier executable
-	applierData, err := os.ReadFile(applierPath)
-	if err != nil {
-		return fmt.Errorf("failed to read applier executable: %w", err)
-	}
-
-	// Read the patch file
-	patchData, err := os.ReadFile(patchPath)
-	if err != nil {
-		return fmt.Errorf("failed to read patch file: %w", err)
-	}
-
-	// Look for any chunk sidecar JSON files in the same directory and build a sidecar blob.
-	// Sidecar filename pattern: <base>.part<N>.chunks.json
-	sidecarBlob := []byte{}
-	sidecarFiles := []string{}
-	base := filepath.Base(patchPath)
-	if strings.HasSuffix(base, ".01.patch") {
-		base = strings.TrimSuffix(base, ".01.patch")
-	} else {
-		base = strings.TrimSuffix(base, ".patch")
-	}
-	// Glob for sidecars
-	globPattern := filepath.Join(filepath.Dir(patchPath), base+".part*.chunks.json")
-	matches, _ := filepath.Glob(globPattern)
-	if len(matches) > 0 {
-		// We'll encode an index followed by pairs of (nameLen, name, size, data)
-		var idxBuf []byte
-		// Use a bytes buffer-like composition without extra import
-		for _, f := range matches {
-			data, err := os.ReadFile(f)
-			if err != nil {
-				return fmt.Errorf("failed to read sidecar %s: %w", f, err)
-			}
-			relName := filepath.Base(f)
-			nameBytes := []byte(relName)
-			// name length (uint16) + name + size (uint64) + data
-			nb := make([]byte, 2)
-			binary.LittleEndian.PutUint16(nb, uint16(len(nameBytes)))
-			idxBuf = append(idxBuf, nb...)
-			idxBuf = append(idxBuf, nameBytes...)
-			sb := make([]byte, 8)
-			binary.LittleEndian.PutUint64(sb, uint64(len(data)))
-			idxBuf = append(idxBuf, sb...)
-			idxBuf = append(idxBuf, data...)
-			sidecarFiles = append(sidecarFiles, relName)
+// createStandaloneCLIExe creates a self-contained CLI executable by appending patch data to an applier stub.
+// parts lists the files of a multi-part patch that was just saved (nil for a single-file patch); its part 01
+// is embedded, and with allParts the remaining parts (and their chunks) as well. Only the files of that
+// patch are embedded, never leftovers of an earlier run with the same name.
+// The executable's metadata is signed with the patch signing key, if one is configured.
+func createStandaloneCLIExe(patchPath string, parts *patcher.MultiPartFiles, exePath, fromVersion, toVersion string, allParts bool, settings *genSettings) error {
+	var sidecars []string
+	meta := embedded.Metadata{
+		FromVersion: fromVersion,
+		ToVersion:   toVersion,
+		StubVersion: version.GetVersion(), // Stubs are built alongside the generator
+	}
+	if parts != nil {
+		patchPath = parts.Part01
+		meta.Flags |= embedded.MetadataMultiPart
+
+		// Chunk sidecar JSON files for the remaining parts always travel inside the executable
+		sidecars = append(sidecars, parts.Sidecars...)
+		if allParts {
+			meta.Flags |= embedded.MetadataAllParts
+			sidecars = append(sidecars, parts.Parts...)
 		}
-		// Prepend total count (uint32)
-		cnt := make([]byte, 4)
-		binary.LittleEndian.PutUint32(cnt, uint32(len(matches)))
-		sidecarBlob = append(cnt, idxBuf...)
-	}
-
-	// Calculate checksum of patch data (as bytes, not hex string)
-	checksum := sha256.Sum256(patchData)
-
-	// Create 128-byte header
-	header := make([]byte, 128)
-
-	// Magic bytes "CPMPATCH" (8 bytes)
-	copy(header[0:8], []byte("CPMPATCH"))
-
-	// Version (4 bytes, uint32)
-	var version uint32 = 1
-	header[8] = byte(version)
-	header[9] = byte(version >> 8)
-	header[10] = byte(version >> 16)
-	header[11] = byte(version >> 24)
-
-	// Stub size (8 bytes, uint64) - size of applier exe
-	stubSize := uint64(len(applierData))
-	for i := 0; i < 8; i++ {
-		header[12+i] = byte(stubSize >> (i * 8))
-	}
-
-	// Data offset (8 bytes, uint64) - right after stub
-	dataOffset := uint64(len(applierData))
-	for i := 0; i < 8; i++ {
-		header[20+i] = byte(dataOffset >> (i * 8))
-	}
-
-	// Data size (8 bytes, uint64)
-	dataSize := uint64(len(patchData))
-	for i := 0; i < 8; i++ {
-		header[28+i] = byte(dataSize >> (i * 8))
 	}
 
-	// Compression type (16 bytes)
-	compressionBytes := make([]byte, 16)
-	copy(compressionBytes, []byte(compression))
-	copy(header[36:52], compressionBytes)
-
-	// Checksum (32 bytes, SHA-256)
-	copy(header[52:84], checksum[:])
-
-	// Flags (1 byte) - bit 0: silent mode
-	var flags byte = 0
-	if silent {
-		flags |= 0x01 // Set bit 0 for silent mode
-	}
-	header[84] = flags
-
-	// Reserved (43 bytes) - already zeroed
-
-	// Create output file
-	outFile, err := os.Create(exePath)
-	if err != nil {
-		return fmt.Errorf("failed to create output file: %w", err)
-	}
-	defer outFile.Close()
-
-	// Write: applier.exe + patch data + optional sidecar blob + header
-	if _, err := outFile.Write(applierData); err != nil {
-		return fmt.Errorf("failed to write applier data: %w", err)
-	}
-
-	if _, err := outFile.Write(patchData); err != nil {
-		return fmt.Errorf("failed to write patch data: %w", err)
-	}
+	return embedded.Build(exePath, embedded.BuildOptions{
+		StubPath:    settings.stubPath,
+		PatchPath:   patchPath,
+		Sidecars:    sidecars,
+		Compression: settings.compression,
+		Silent:      settings.silent,
+		Metadata:    meta,
+		SigningKey:  settings.signingKey,
+	})
+}
 
-	// Write sidecar blob (may be empty)
-	if len(sidecarBlob) > 0 {
-		if _, err := outFile.Write(sidecarBlob); err != nil {
-			return fmt.Errorf("failed to write sidecar blob: %w", err)
+// sumFileSizes returns the total size of the given files
+func sumFileSizes(paths []string) int64 {
+	var total int64
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			total += info.Size()
 		}
 	}
-
-	if _, err := outFile.Write(header); err != nil {
-		return fmt.Errorf("failed to write header: %w", err)
-	}
-
-	return nil
+	return total
 }
 
 // encodePatchStreaming writes the patch as JSON in a streaming fashion to avoid memory exhaustion
@@ -876,34 +1342,74 @@ func printHelp() {
 	fmt.Println("    patch-gen --versions-dir <dir> --from <version> --to <version>")
 	fmt.Println("\n  Generate single patch (custom paths, different drives/locations):")
 	fmt.Println("    patch-gen --from-dir <path> --to-dir <path>")
+	fmt.Println("\n  Build only the full install of a version:")
+	fmt.Println("    patch-gen --to-dir <path> --full [--full-package tar.zst|zip]")
+	fmt.Println("\n  Run a project file (default: patch-project.json); flags override its settings:")
+	fmt.Println("    patch-gen build [project-file] [options]")
 	fmt.Println("\nOptions:")
-	fmt.Println("  --versions-dir    Directory containing version folders")
+	fmt.Println("  --versions-dir    Directory containing version folders or archives (e.g. 1.0.0/, build-1.0.1.tar.zst)")
 	fmt.Println("  --new-version     New version number to generate patches for")
 	fmt.Println("  --from            Source version number (with --versions-dir)")
 	fmt.Println("  --to              Target version number (with --versions-dir)")
-	fmt.Println("  --from-dir        Full path to source version directory")
-	fmt.Println("  --to-dir          Full path to target version directory")
-	fmt.Println("  --output          Output directory for patches (default: patches)")
+	fmt.Println("  --from-dir        Full path to source version directory or archive (.zip, .tar, .tar.gz, .tar.zst)")
+	fmt.Println("  --to-dir          Full path to target version directory or archive (.zip, .tar, .tar.gz, .tar.zst)")
+	fmt.Println("  --output          Output directory for patches; {version} = target version (default: patches)")
+	fmt.Println("  --patch-name      Name of patch files without extension (default: {from}-to-{to})")
+	fmt.Println("  --full-name       Name of full-install patches and packages without extension (default: {version}-full)")
 	fmt.Println("  --key-file        Specific key file to use (e.g., app_name.exe)")
+	fmt.Println("  --key-candidates  Key file names or glob patterns tried in order (comma-separated, e.g. 'bin/server,*.x86_64')")
+	fmt.Println("  --identity-files  Further files identifying each version with the key file (comma-separated)")
+	fmt.Println("  --identity-sample Percentage of files in a sampled fingerprint identifying each version (0 = none)")
 	fmt.Println("  --compression     Compression algorithm: zstd, gzip, none (default: zstd)")
 	fmt.Println("  --level           Compression level (default: 3)")
 	fmt.Println("  --verify          Verify patches after creation (default: true)")
 	fmt.Println("  --create-exe      Create self-contained CLI executable")
+	fmt.Println("  --exe-target      Target platform for --create-exe as os/arch (e.g. linux/amd64; default: Windows patch-apply.exe)")
+	fmt.Println("  --stubs-dir       Directory with applier stubs patch-apply-<os>-<arch>[.exe] (default: stubs next to patch-gen)")
+	fmt.Println("  --embed-all-parts Embed every part of a multi-part patch in the executable (single-file distribution)")
 	fmt.Println("  --silent          Enable silent mode in generated executable (auto-apply without prompts)")
 	fmt.Println("  --crp             Create reverse patch (for downgrades)")
+	fmt.Println("  --last            With --new-version: only patch from the newest N older versions (0 = all)")
+	fmt.Println("  --range           With --new-version: only patch from source versions in a range (e.g. '>=1.4.0, <2.0.0')")
+	fmt.Println("  --skip-prerelease With --new-version: skip pre-release source versions (e.g. 1.2.0-beta.1)")
+	fmt.Println("  --plan            With --new-version: plan incremental and cumulative patches for the version history")
+	fmt.Println("  --max-chain       With --plan: most patches any version applies to reach the new version (0 = no limit)")
+	fmt.Println("  --storage-budget  With --plan: most space all patches may take together (e.g. '20GB', '500MB')")
+	fmt.Println("  --plan-only       With --plan: print the plan without building patches")
+	fmt.Println("  --prune           With --plan: delete patches in the output directory that are not in the plan")
 	fmt.Println("  --savescans       Save directory scans to cache for faster subsequent patches")
 	fmt.Println("  --rescan          Force rescan of cached versions (use with --savescans)")
 	fmt.Println("  --scandata        Custom directory for scan cache (default: .data)")
 	fmt.Println("  --jobs            Number of parallel workers (0=auto-detect CPU cores, 1=single-threaded, default: 0)")
 	fmt.Println("  --splitsize       Custom multi-part split size (e.g., '2G', '2GB', '500M', '500MB', default: 4GB)")
 	fmt.Println("  --bypasssplitlimit Bypass 100MB minimum split size confirmation")
+	fmt.Println("  --hooks           JSON file with hook scripts to embed (pre-verify, pre-apply, post-apply, on-rollback)")
+	fmt.Println("  --branding        JSON file with product branding for the applier UI (product name, EULA, support URL, ...)")
+	fmt.Println("  --search-roots    Directories self-contained updaters search for the install (comma-separated, e.g. '%ProgramFiles%\\Acme')")
+	fmt.Println("  --sign-key        Private key file used to sign patches (default: signing_key_path from config)")
+	fmt.Println("  --gen-key         Generate a signing key pair (<name>.key and <name>.pub) and exit")
+	fmt.Println("  --embed-manifest  Embed the complete target manifest (enables patch-apply repair)")
+	fmt.Println("  --verification    Source files verified before patching: full, touched, sampled (default: full)")
+	fmt.Println("  --sample-percent  Percentage of untouched files verified with --verification sampled (default: 10)")
+	fmt.Println("  --index           Write or update <output>/index.json, a catalog of versions and patches for launchers")
+	fmt.Println("  --release-notes   Text or Markdown file with release notes for the new version (stored in index.json)")
+	fmt.Println("  --full            Also generate <version>-full.patch, which installs the target version into an empty directory")
+	fmt.Println("  --full-package    Also write the target version as <version>-full.tar.zst or .zip with its signed manifest")
+	fmt.Println("  --save-manifest   Save the target version manifest to <output>/<version>.manifest.json (for patch-apply verify)")
+	fmt.Println("  --estimate        Predict the size, parts and disk space of the patches without writing them")
+	fmt.Println("  --estimate-depth  With --estimate: directory levels in the per-directory contributions (default: 1)")
+	fmt.Println("  --estimate-sample With --estimate: most file data compressed to measure compression (default: 64MB)")
 	fmt.Println("  --version         Show version information")
 	fmt.Println("  --help            Show this help message")
 	fmt.Println("\nExamples:")
 	fmt.Println("  # Versions on different drives")
 	fmt.Println("  patch-gen --from-dir C:\\releases\\1.0.0 --to-dir D:\\builds\\1.0.1 --output patches")
+	fmt.Println("\n  # Build artifacts, read without extracting them")
+	fmt.Println("  patch-gen --from-dir artifacts/build-1.4.1.tar.zst --to-dir artifacts/build-1.4.2.tar.zst --output patches")
 	fmt.Println("\n  # Create self-contained executable")
 	fmt.Println("  patch-gen --from-dir C:\\\\v1 --to-dir C:\\\\v2 --output patches --create-exe")
+	fmt.Println("\n  # Create a self-contained Linux executable (uses stubs/patch-apply-linux-amd64)")
+	fmt.Println("  patch-gen --from-dir /srv/v1 --to-dir /srv/v2 --output patches --create-exe --exe-target linux/amd64")
 	fmt.Println("\n  # Create forward and reverse patches with executables")
 	fmt.Println("  patch-gen --from-dir C:\\\\v1.0.0 --to-dir C:\\\\v1.0.1 --output patches --crp --create-exe")
 	fmt.Println("\\n  # Use scan caching for faster subsequent patches")
@@ -919,6 +1425,19 @@ func printHelp() {
 	fmt.Println("  patch-gen --from-dir C:\\\\v1 --to-dir C:\\\\v2 --output patches --splitsize 500MB")
 	fmt.Println("\\n  # Small split size (below 100MB) with bypass")
 	fmt.Println("  patch-gen --from-dir C:\\\\v1 --to-dir C:\\\\v2 --output patches --splitsize 50M --bypasssplitlimit")
+	fmt.Println("\n  # Sign a patch that stops and restarts a service around the update")
+	fmt.Println("  patch-gen --gen-key release")
+	fmt.Println("  patch-gen --from-dir C:\\\\v1 --to-dir C:\\\\v2 --output patches --hooks hooks.json --sign-key release.key")
+	fmt.Println("\n  # Plan patches so every version updates in at most 3 patches, within 20 GB")
+	fmt.Println("  patch-gen --versions-dir C:\\\\versions --new-version 1.5.0 --output patches --plan --max-chain 3 --storage-budget 20GB --plan-only")
+	fmt.Println("\n  # Patches for existing users plus a full install for new users")
+	fmt.Println("  patch-gen --versions-dir C:\\\\versions --new-version 1.5.0 --output patches --full --full-package zip --sign-key release.key")
+	fmt.Println("\n  # Linux server whose binary name changes, identified by two more files and a 5% fingerprint")
+	fmt.Println("  patch-gen --versions-dir versions --new-version 1.5.0 --output patches --key-candidates 'bin/*.x86_64' --identity-files data/core.pak,version.txt --identity-sample 5")
+	fmt.Println("\n  # Release build from a project file, with the new version given on the command line")
+	fmt.Println("  patch-gen build release.json --new-version 1.5.0")
+	fmt.Println("\n  # Predict patch sizes, parts and disk space before building")
+	fmt.Println("  patch-gen --versions-dir C:\\\\versions --new-version 1.5.0 --output patches --splitsize 2G --estimate")
 	fmt.Println("\n  # Versions on different network locations")
 	fmt.Println("  patch-gen --from-dir \\\\\\\\server1\\\\app\\\\v1 --to-dir \\\\\\\\server2\\\\app\\\\v2 --output .")
 }
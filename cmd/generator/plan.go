@@ -0,0 +1,362 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/catalog"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/patcher"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/planner"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/version"
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// planSettings holds the --plan options
+type planSettings struct {
+	limits planner.Options
+	only   bool // Print the plan without building patches (--plan-only)
+	prune  bool // Delete patches that are not in the plan (--prune)
+}
+
+// patchPair identifies a patch by its source and target version
+type patchPair struct {
+	from, to string
+}
+
+// planEstimateSample is the file data compressed to estimate each patch that is not built yet.
+// The planner sizes every pair of versions, so each gets a smaller sample than --estimate takes.
+const planEstimateSample = 8 * 1024 * 1024
+
+// generatePlannedPatches plans the patch set for the whole version history up to newVersion,
+// prints the plan, and builds the planned patches that are not in the output directory yet
+func generatePlannedPatches(versionMgr *version.Manager, versionsDir, newVersion string, filter version.Filter, opts planSettings, settings *genSettings) {
+	fmt.Printf("Planning patches for new version %s\n", newVersion)
+
+	if _, err := version.Parse(newVersion); err != nil {
+		fmt.Printf("Error: --plan requires version numbers: %v\n", err)
+		os.Exit(1)
+	}
+	locations, err := versionEntries(versionsDir)
+	if err != nil {
+		fmt.Printf("Error: failed to read versions directory: %v\n", err)
+		os.Exit(1)
+	}
+	if _, ok := locations[newVersion]; !ok {
+		fmt.Printf("Error: new version directory not found: %s\n", filepath.Join(versionsDir, newVersion))
+		os.Exit(1)
+	}
+
+	// The planner orders versions by number, so names that are not version numbers are left out
+	var all, names []string
+	for name := range locations {
+		all = append(all, name)
+	}
+	sort.Strings(all)
+	for _, name := range all {
+		if name == newVersion {
+			continue
+		}
+		if _, err := version.Parse(name); err != nil {
+			fmt.Printf("Skipping %s: not a version number (required by --plan)\n", filepath.Base(locations[name]))
+			continue
+		}
+		names = append(names, name)
+	}
+	sources := selectSourceVersions(names, newVersion, filter)
+
+	// Scan every version: sizes of patches that don't exist yet are estimated from the versions
+	versions := make(map[string]*utils.Version)
+	var history []string
+	for _, name := range append(sources, newVersion) {
+		fmt.Printf("\nScanning version %s...\n", name)
+		ver, err := registerVersionDir(versionMgr, name, locations[name], settings)
+		if err != nil {
+			if name == newVersion {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Warning: skipping %s - %v\n", name, err)
+			continue
+		}
+		versions[name] = ver
+		history = append(history, name)
+	}
+	settings.saveTargetManifest(versions[newVersion])
+
+	// Patches already in the output directory are measured, so they count with their real size.
+	// The others are estimated with the compression and split settings they will be built with,
+	// so both are compared as compressed sizes.
+	existing := make(map[patchPair]int64)
+	generator := patcher.NewGenerator()
+	options := settings.patchOptions()
+	estimate := patcher.EstimateOptions{MaxPartSize: settings.customMaxPartSize, ChunkSize: settings.customMaxPartSize, SampleSize: planEstimateSample}
+	size := func(from, to string) int64 {
+		if size, ok := existingPatchSize(settings.patchFile(from, to)); ok {
+			existing[patchPair{from, to}] = size
+			return size
+		}
+		predicted, err := generator.EstimatePatch(versions[from], versions[to], options, estimate)
+		if err != nil {
+			fmt.Printf("Warning: failed to estimate %s → %s, using its uncompressed size: %v\n", from, to, err)
+			return planner.EstimateSize(versions[from].Manifest, versions[to].Manifest)
+		}
+		return predicted.Size
+	}
+	plan, err := planner.PlanPatches(history, size, opts.limits)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	planned := make(map[patchPair]bool)
+	for _, patch := range plan.Patches {
+		planned[patchPair{patch.From, patch.To}] = true
+	}
+	var obsolete []patchPair
+	for _, from := range history {
+		for _, to := range history {
+			pair := patchPair{from, to}
+			if _, ok := existing[pair]; ok && !planned[pair] {
+				obsolete = append(obsolete, pair)
+			}
+		}
+	}
+
+	printPatchPlan(plan, existing, obsolete, opts)
+	if opts.only {
+		fmt.Println("\nPlan only: no patches were built")
+		return
+	}
+
+	// Build the planned patches that don't exist yet, oldest source first
+	built := 0
+	for _, patch := range plan.Patches {
+		fromVer, toVer := versions[patch.From], versions[patch.To]
+		patchFile := settings.patchFile(patch.From, patch.To)
+
+		// Release notes describe the new version; patches to older versions keep the notes they have
+		patchSettings := settings
+		if patch.To != newVersion {
+			copied := *settings
+			copied.releaseNotes = ""
+			patchSettings = &copied
+		}
+
+		if _, ok := existing[patchPair{patch.From, patch.To}]; ok {
+			if settings.index != nil && settings.index.FindPatch(patch.From, patch.To) == nil {
+				patchSettings.recordInIndex(fromVer, toVer, patchFile)
+			}
+			continue
+		}
+
+		fmt.Printf("\nBuilding patch %s → %s...\n", patch.From, patch.To)
+		if err := generatePatch(fromVer, toVer, patchFile, patchSettings); err != nil {
+			fmt.Printf("Error: failed to generate patch %s → %s: %v\n", patch.From, patch.To, err)
+			os.Exit(1)
+		}
+		patchSettings.recordInIndex(fromVer, toVer, patchFile)
+		built++
+	}
+	fmt.Printf("\n✓ Built %d patches (%d already existed)\n", built, len(plan.Patches)-built)
+	settings.buildFullInstall(versions[newVersion])
+
+	if opts.prune && len(obsolete) > 0 {
+		prunePatches(obsolete, settings)
+	}
+}
+
+// registerVersionDir detects the key file of a version directory or archive and registers the version
+func registerVersionDir(versionMgr *version.Manager, name, path string, settings *genSettings) (*utils.Version, error) {
+	keyFile, err := settings.detectKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ver, err := versionMgr.RegisterVersion(name, path, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register version %s: %w", name, err)
+	}
+	return ver, nil
+}
+
+// existingPatchSize returns the size of the patch from → to in the output directory, counting
+// every part and chunk that part 1 of a multi-part patch records, and whether the patch exists.
+// A multi-part patch whose part 1 cannot be read is treated as missing, so it is built again.
+func existingPatchSize(patchFile string) (int64, bool) {
+	patchPath := resolvePatchFile(patchFile)
+	info, err := os.Stat(patchPath)
+	if err != nil {
+		return 0, false
+	}
+	size := info.Size()
+	if strings.HasSuffix(patchPath, ".01.patch") {
+		files, err := patcher.ListMultiPartFiles(patchPath)
+		if err != nil {
+			fmt.Printf("Warning: %v (the patch will be built again)\n", err)
+			return 0, false
+		}
+		size += sumFileSizes(files.Parts)
+	}
+	return size, true
+}
+
+// printPatchPlan prints the planned patches, the chain each version takes and the storage they use
+func printPatchPlan(plan *planner.Plan, existing map[patchPair]int64, obsolete []patchPair, opts planSettings) {
+	newest := plan.Versions[len(plan.Versions)-1]
+
+	fmt.Println("\n=== Patch Plan ===")
+	fmt.Printf("Versions:        %s\n", strings.Join(plan.Versions, ", "))
+	if opts.limits.MaxChain > 0 {
+		fmt.Printf("Chain limit:     %d patches\n", opts.limits.MaxChain)
+	} else {
+		fmt.Println("Chain limit:     none")
+	}
+	if opts.limits.Budget > 0 {
+		fmt.Printf("Storage budget:  %s\n", utils.FormatBytes(opts.limits.Budget))
+	} else {
+		fmt.Println("Storage budget:  none")
+	}
+
+	if len(plan.Patches) == 0 {
+		fmt.Println("\nNo older versions: nothing to build")
+		return
+	}
+
+	width := 0
+	for _, patch := range plan.Patches {
+		width = max(width, len(patch.From)+len(" → ")+len(patch.To))
+	}
+
+	fmt.Println("\nPatches (~ = estimated, not built yet):")
+	var toBuild int64
+	builds := 0
+	for _, patch := range plan.Patches {
+		kind := "incremental"
+		if patch.Span > 1 {
+			kind = fmt.Sprintf("cumulative (%d releases)", patch.Span)
+		}
+		status := "exists"
+		if _, ok := existing[patchPair{patch.From, patch.To}]; !ok {
+			status = "to build"
+			toBuild += patch.Size
+			builds++
+		}
+		fmt.Printf("  %-*s  %12s  %-26s %s\n", width, patch.From+" → "+patch.To,
+			formatPlanSize(patch.Size, existing, patchPair{patch.From, patch.To}), kind, status)
+	}
+
+	versionWidth := 0
+	for _, v := range plan.Versions {
+		versionWidth = max(versionWidth, len(v))
+	}
+	fmt.Printf("\nUpdate chains to %s:\n", newest)
+	for _, v := range plan.Versions[:len(plan.Versions)-1] {
+		chain := plan.Chain(v)
+		var size int64
+		estimated := false
+		steps := []string{v}
+		for _, patch := range chain {
+			size += patch.Size
+			steps = append(steps, patch.To)
+			if _, ok := existing[patchPair{patch.From, patch.To}]; !ok {
+				estimated = true
+			}
+		}
+		sizeText := utils.FormatBytes(size)
+		if estimated {
+			sizeText = "~" + sizeText
+		}
+		fmt.Printf("  %-*s  %d patch(es), %s: %s\n", versionWidth, v, len(chain), sizeText, strings.Join(steps, " → "))
+	}
+
+	fmt.Printf("\nTotal: %d patches, %s", len(plan.Patches), utils.FormatBytes(plan.TotalSize))
+	if plan.TotalSize > plan.MinimumSize {
+		fmt.Printf(" (planned set %s; the rest of the budget adds direct patches to %s)", utils.FormatBytes(plan.MinimumSize), newest)
+	}
+	fmt.Printf("\nTo build: %d patches, ~%s\n", builds, utils.FormatBytes(toBuild))
+	fmt.Printf("Longest chain: %d patches\n", plan.LongestChain())
+
+	if len(obsolete) > 0 {
+		var size int64
+		fmt.Println("\nPatches in the output directory that are not in the plan:")
+		for _, pair := range obsolete {
+			size += existing[pair]
+			fmt.Printf("  %s → %s (%s)\n", pair.from, pair.to, utils.FormatBytes(existing[pair]))
+		}
+		if opts.prune {
+			fmt.Printf("These will be deleted after the build (%s)\n", utils.FormatBytes(size))
+		} else {
+			fmt.Printf("Use --prune to delete them (%s)\n", utils.FormatBytes(size))
+		}
+	}
+}
+
+// formatPlanSize formats a planned patch size, marking estimates with ~
+func formatPlanSize(size int64, existing map[patchPair]int64, pair patchPair) string {
+	if _, ok := existing[pair]; ok {
+		return utils.FormatBytes(size)
+	}
+	return "~" + utils.FormatBytes(size)
+}
+
+// prunePatches deletes the files of patches that are not in the plan and removes them from index.json
+func prunePatches(obsolete []patchPair, settings *genSettings) {
+	index := settings.index
+	indexPath := filepath.Join(settings.outputDir, catalog.FileName)
+	if index == nil && utils.FileExists(indexPath) {
+		// Keep an index written by earlier runs in step with the files, even without --index
+		loaded, err := catalog.Load(indexPath)
+		if err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		} else {
+			index = loaded
+		}
+	}
+
+	fmt.Println("\nPruning patches that are not in the plan...")
+	for _, pair := range obsolete {
+		base := settings.patchBase(pair.from, pair.to)
+		removed := 0
+		for _, file := range patchFiles(base) {
+			if err := os.Remove(file); err != nil {
+				fmt.Printf("Warning: failed to delete %s: %v\n", file, err)
+				continue
+			}
+			removed++
+		}
+		if index != nil && index.RemovePatch(pair.from, pair.to) {
+			fmt.Printf("  Deleted %s → %s (%d files, removed from %s)\n", pair.from, pair.to, removed, catalog.FileName)
+		} else {
+			fmt.Printf("  Deleted %s → %s (%d files)\n", pair.from, pair.to, removed)
+		}
+	}
+	if index != nil {
+		if err := index.Save(version.GetVersion()); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}
+}
+
+// patchFiles returns the existing files of the patch with the given base path: the patch or the
+// parts, chunks and chunk sidecars its part 1 records, and self-contained executables built from it
+func patchFiles(base string) []string {
+	candidates := []string{base + ".patch", base + ".01.patch", base + ".exe", base}
+	if part01 := base + ".01.patch"; utils.FileExists(part01) {
+		if parts, err := patcher.ListMultiPartFiles(part01); err != nil {
+			fmt.Printf("Warning: %v (its other parts are not deleted)\n", err)
+		} else {
+			candidates = append(candidates, parts.Parts...)
+			candidates = append(candidates, parts.Sidecars...)
+		}
+	}
+
+	var files []string
+	for _, file := range candidates {
+		if info, err := os.Stat(file); err == nil && info.Mode().IsRegular() {
+			files = append(files, file)
+		}
+	}
+	return files
+}
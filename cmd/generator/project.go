@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/archive"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/version"
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// defaultProjectFile is the project file "patch-gen build" runs when none is given
+const defaultProjectFile = "patch-project.json"
+
+// projectFile is the on-disk format of a generator project, run with "patch-gen build".
+// Every setting maps to a command-line flag; unset settings keep the flag's default.
+// Relative paths are resolved against the directory of the project file.
+type projectFile struct {
+	VersionsDir    string              `json:"versions_dir"`
+	NewVersion     string              `json:"new_version"`
+	From           string              `json:"from"`
+	To             string              `json:"to"`
+	FromDir        string              `json:"from_dir"`
+	ToDir          string              `json:"to_dir"`
+	Versions       projectVersions     `json:"versions"`
+	Plan           projectPlan         `json:"plan"`
+	Output         projectOutput       `json:"output"`
+	KeyFile        projectKeyFile      `json:"key_file"`
+	Identity       projectIdentity     `json:"identity"`
+	Compression    projectCompression  `json:"compression"`
+	Split          projectSplit        `json:"split"`
+	ReversePatches *bool               `json:"reverse_patches"`
+	Full           projectFull         `json:"full"`
+	Exe            projectExe          `json:"exe"`
+	Verification   projectVerification `json:"verification"`
+	EmbedManifest  *bool               `json:"embed_manifest"`
+	Verify         *bool               `json:"verify"`
+	SigningKey     string              `json:"signing_key"`
+	Branding       string              `json:"branding"`
+	Hooks          string              `json:"hooks"`
+	SearchRoots    []string            `json:"search_roots"`
+	ScanCache      projectScanCache    `json:"scan_cache"`
+	Jobs           *int                `json:"jobs"`
+}
+
+// projectVersions selects the source versions patched to new_version
+type projectVersions struct {
+	Last           *int   `json:"last"`
+	Range          string `json:"range"`
+	SkipPreRelease *bool  `json:"skip_prerelease"`
+}
+
+// projectPlan configures patch-set planning
+type projectPlan struct {
+	Enabled       *bool  `json:"enabled"`
+	MaxChain      *int   `json:"max_chain"`
+	StorageBudget string `json:"storage_budget"`
+	Prune         *bool  `json:"prune"`
+}
+
+// projectOutput configures where generated files go and how they are named
+type projectOutput struct {
+	Dir          string `json:"dir"`
+	PatchName    string `json:"patch_name"`
+	FullName     string `json:"full_name"`
+	SaveManifest *bool  `json:"save_manifest"`
+	Index        *bool  `json:"index"`
+	ReleaseNotes string `json:"release_notes"`
+}
+
+// projectKeyFile configures key file detection
+type projectKeyFile struct {
+	Path       string   `json:"path"`
+	Candidates []string `json:"candidates"`
+}
+
+// projectIdentity configures the composite version identity
+type projectIdentity struct {
+	Files         []string `json:"files"`
+	SamplePercent *int     `json:"sample_percent"`
+}
+
+// projectCompression configures patch compression
+type projectCompression struct {
+	Algorithm string `json:"algorithm"`
+	Level     *int   `json:"level"`
+}
+
+// projectSplit configures multi-part patches
+type projectSplit struct {
+	Size        string `json:"size"`
+	BypassLimit *bool  `json:"bypass_limit"`
+}
+
+// projectFull configures full-install patches and packages
+type projectFull struct {
+	Patch   *bool  `json:"patch"`
+	Package string `json:"package"`
+}
+
+// projectExe configures self-contained executables
+type projectExe struct {
+	Create        *bool  `json:"create"`
+	Target        string `json:"target"`
+	StubsDir      string `json:"stubs_dir"`
+	EmbedAllParts *bool  `json:"embed_all_parts"`
+	Silent        *bool  `json:"silent"`
+}
+
+// projectVerification configures the source files the applier verifies
+type projectVerification struct {
+	Level         string `json:"level"`
+	SamplePercent *int   `json:"sample_percent"`
+}
+
+// projectScanCache configures the scan cache
+type projectScanCache struct {
+	Enabled *bool  `json:"enabled"`
+	Dir     string `json:"dir"`
+	Rescan  *bool  `json:"rescan"`
+}
+
+// buildArgs returns the command-line arguments of "patch-gen build [project-file] [flags]": the
+// project's settings as flags, followed by the given flags, so the given flags override the project
+func buildArgs(args []string) ([]string, error) {
+	projectPath := defaultProjectFile
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		projectPath, args = args[0], args[1:]
+	}
+
+	project, err := loadProjectFile(projectPath)
+	if err != nil {
+		return nil, err
+	}
+	projectArgs := project.args(filepath.Dir(projectPath))
+
+	fmt.Printf("✓ Project %s\n", projectPath)
+	fmt.Printf("  Equivalent to: patch-gen %s\n", quoteArgs(projectArgs))
+	if len(args) > 0 {
+		fmt.Printf("  Overridden by: %s\n", quoteArgs(args))
+	}
+	return append(projectArgs, args...), nil
+}
+
+// loadProjectFile reads a project file and validates it against the schema, reporting every
+// problem at once
+func loadProjectFile(path string) (*projectFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project file: %w", err)
+	}
+
+	var project projectFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&project); err != nil {
+		return nil, fmt.Errorf("failed to parse project file %s: %w", path, err)
+	}
+
+	if problems := project.validate(filepath.Dir(path)); len(problems) > 0 {
+		return nil, fmt.Errorf("invalid project file %s:\n  - %s", path, strings.Join(problems, "\n  - "))
+	}
+	return &project, nil
+}
+
+// validate checks the values of a project file, returning one message per problem
+func (p *projectFile) validate(baseDir string) []string {
+	var problems []string
+	add := func(field, format string, args ...interface{}) {
+		problems = append(problems, field+": "+fmt.Sprintf(format, args...))
+	}
+	nonNegative := func(field string, value *int) {
+		if value != nil && *value < 0 {
+			add(field, "must be 0 or more, got %d", *value)
+		}
+	}
+	exists := func(field, path string) {
+		if path != "" && !utils.FileExists(resolveProjectPath(baseDir, path)) {
+			add(field, "%s not found", path)
+		}
+	}
+
+	if p.NewVersion != "" && (p.From != "" || p.FromDir != "") {
+		add("new_version", "cannot be combined with from or from_dir")
+	}
+	if p.Versions.Range != "" {
+		if _, err := version.ParseConstraint(p.Versions.Range); err != nil {
+			add("versions.range", "%v", err)
+		}
+	}
+	nonNegative("versions.last", p.Versions.Last)
+	nonNegative("plan.max_chain", p.Plan.MaxChain)
+	if p.Plan.StorageBudget != "" {
+		if _, err := parseSplitSize(p.Plan.StorageBudget); err != nil {
+			add("plan.storage_budget", "%v", err)
+		}
+	}
+	if p.Plan.Enabled != nil && *p.Plan.Enabled && p.ReversePatches != nil && *p.ReversePatches {
+		add("plan.enabled", "plans upgrade patches only and cannot be combined with reverse_patches")
+	}
+
+	if p.Output.Dir != "" && strings.ContainsAny(strings.ReplaceAll(p.Output.Dir, "{version}", ""), "{}") {
+		add("output.dir", "unknown placeholder in %q (use {version})", p.Output.Dir)
+	}
+	if p.Output.PatchName != "" {
+		if err := validateNameTemplate("patch_name", p.Output.PatchName, "{from}", "{to}"); err != nil {
+			add("output.patch_name", "%v", err)
+		}
+	}
+	if p.Output.FullName != "" {
+		if err := validateNameTemplate("full_name", p.Output.FullName, "{version}"); err != nil {
+			add("output.full_name", "%v", err)
+		}
+	}
+	if p.Output.ReleaseNotes != "" && (p.Output.Index == nil || !*p.Output.Index) {
+		add("output.release_notes", "requires output.index")
+	}
+	exists("output.release_notes", p.Output.ReleaseNotes)
+
+	if p.Identity.SamplePercent != nil && (*p.Identity.SamplePercent < 0 || *p.Identity.SamplePercent > 100) {
+		add("identity.sample_percent", "must be between 0 and 100, got %d", *p.Identity.SamplePercent)
+	}
+
+	switch p.Compression.Algorithm {
+	case "", "zstd", "gzip", "none":
+	default:
+		add("compression.algorithm", "must be zstd, gzip or none, got %q", p.Compression.Algorithm)
+	}
+	if level := p.Compression.Level; level != nil {
+		maxLevel := 4
+		if p.Compression.Algorithm == "gzip" {
+			maxLevel = 3
+		}
+		if *level < 1 || *level > maxLevel {
+			add("compression.level", "must be between 1 and %d, got %d", maxLevel, *level)
+		}
+	}
+	if p.Split.Size != "" {
+		if _, err := parseSplitSize(p.Split.Size); err != nil {
+			add("split.size", "%v", err)
+		}
+	}
+
+	if p.Full.Package != "" && p.Full.Package != archive.FormatTarZst && p.Full.Package != archive.FormatZip {
+		add("full.package", "must be tar.zst or zip, got %q", p.Full.Package)
+	}
+	if p.Exe.Target != "" {
+		if _, err := parseExeTarget(p.Exe.Target); err != nil {
+			add("exe.target", "%v", err)
+		}
+	}
+
+	switch utils.VerificationLevel(p.Verification.Level) {
+	case "", utils.VerificationFull, utils.VerificationTouched, utils.VerificationSampled:
+	default:
+		add("verification.level", "must be full, touched or sampled, got %q", p.Verification.Level)
+	}
+	if percent := p.Verification.SamplePercent; percent != nil && (*percent < 1 || *percent > 99) {
+		add("verification.sample_percent", "must be between 1 and 99, got %d", *percent)
+	}
+
+	exists("signing_key", p.SigningKey)
+	exists("branding", p.Branding)
+	exists("hooks", p.Hooks)
+	nonNegative("jobs", p.Jobs)
+	return problems
+}
+
+// args returns the project's settings as command-line flags
+func (p *projectFile) args(baseDir string) []string {
+	var args []string
+	str := func(name, value string) {
+		if value != "" {
+			args = append(args, "--"+name, value)
+		}
+	}
+	path := func(name, value string) {
+		if value != "" {
+			str(name, resolveProjectPath(baseDir, value))
+		}
+	}
+	list := func(name string, values []string) {
+		if len(values) > 0 {
+			str(name, strings.Join(values, ","))
+		}
+	}
+	boolean := func(name string, value *bool) {
+		if value != nil {
+			args = append(args, fmt.Sprintf("--%s=%t", name, *value))
+		}
+	}
+	number := func(name string, value *int) {
+		if value != nil {
+			str(name, strconv.Itoa(*value))
+		}
+	}
+
+	path("versions-dir", p.VersionsDir)
+	str("new-version", p.NewVersion)
+	str("from", p.From)
+	str("to", p.To)
+	path("from-dir", p.FromDir)
+	path("to-dir", p.ToDir)
+
+	number("last", p.Versions.Last)
+	str("range", p.Versions.Range)
+	boolean("skip-prerelease", p.Versions.SkipPreRelease)
+
+	boolean("plan", p.Plan.Enabled)
+	number("max-chain", p.Plan.MaxChain)
+	str("storage-budget", p.Plan.StorageBudget)
+	boolean("prune", p.Plan.Prune)
+
+	path("output", p.Output.Dir)
+	str("patch-name", p.Output.PatchName)
+	str("full-name", p.Output.FullName)
+	boolean("save-manifest", p.Output.SaveManifest)
+	boolean("index", p.Output.Index)
+	path("release-notes", p.Output.ReleaseNotes)
+
+	str("key-file", p.KeyFile.Path)
+	list("key-candidates", p.KeyFile.Candidates)
+	list("identity-files", p.Identity.Files)
+	number("identity-sample", p.Identity.SamplePercent)
+
+	str("compression", p.Compression.Algorithm)
+	number("level", p.Compression.Level)
+	str("splitsize", p.Split.Size)
+	boolean("bypasssplitlimit", p.Split.BypassLimit)
+	boolean("crp", p.ReversePatches)
+
+	boolean("full", p.Full.Patch)
+	str("full-package", p.Full.Package)
+
+	boolean("create-exe", p.Exe.Create)
+	str("exe-target", p.Exe.Target)
+	path("stubs-dir", p.Exe.StubsDir)
+	boolean("embed-all-parts", p.Exe.EmbedAllParts)
+	boolean("silent", p.Exe.Silent)
+
+	str("verification", p.Verification.Level)
+	number("sample-percent", p.Verification.SamplePercent)
+	boolean("embed-manifest", p.EmbedManifest)
+	boolean("verify", p.Verify)
+
+	path("sign-key", p.SigningKey)
+	path("branding", p.Branding)
+	path("hooks", p.Hooks)
+	list("search-roots", p.SearchRoots)
+
+	boolean("savescans", p.ScanCache.Enabled)
+	path("scandata", p.ScanCache.Dir)
+	boolean("rescan", p.ScanCache.Rescan)
+	number("jobs", p.Jobs)
+	return args
+}
+
+// resolveProjectPath resolves a path in a project file against the project's directory
+func resolveProjectPath(baseDir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
+
+// quoteArgs formats arguments for display, quoting those with spaces
+func quoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \t'\"") {
+			arg = strconv.Quote(arg)
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
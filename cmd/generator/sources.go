@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/archive"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/scanner"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/version"
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// versionEntries lists the versions in a versions directory, mapping version names to their
+// location: subdirectories named after their version, and archives named after their version
+// with an optional prefix, such as build-1.4.2.tar.zst. A directory takes precedence over an
+// archive of the same version.
+func versionEntries(versionsDir string) (map[string]string, error) {
+	entries, err := os.ReadDir(versionsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	locations := make(map[string]string)
+	for _, entry := range entries {
+		path := filepath.Join(versionsDir, entry.Name())
+		if entry.IsDir() {
+			locations[entry.Name()] = path
+		}
+	}
+	for _, entry := range entries {
+		path := filepath.Join(versionsDir, entry.Name())
+		if entry.IsDir() || !archive.IsArchive(path) {
+			continue
+		}
+		name := archiveVersion(entry.Name())
+		if existing, ok := locations[name]; ok {
+			fmt.Printf("Warning: ignoring %s: version %s is already in %s\n", entry.Name(), name, filepath.Base(existing))
+			continue
+		}
+		locations[name] = path
+	}
+	return locations, nil
+}
+
+// versionLocation returns the directory or archive of a version in a versions directory
+func versionLocation(versionsDir, name string) (string, error) {
+	locations, err := versionEntries(versionsDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read versions directory: %w", err)
+	}
+	location, ok := locations[name]
+	if !ok {
+		return "", fmt.Errorf("version %s not found in %s (no folder named %s or archive such as %s.zip)", name, versionsDir, name, name)
+	}
+	return location, nil
+}
+
+// archiveVersion returns the version an archive stands for: its file name without the archive
+// extension and without a prefix ending in "-" or "_" before a version number
+// ("build-1.4.2.tar.zst" → "1.4.2"). Names without a version number are returned without the extension.
+func archiveVersion(fileName string) string {
+	name := archive.TrimExtension(fileName)
+	if _, err := version.Parse(name); err == nil {
+		return name
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] != '-' && name[i] != '_' {
+			continue
+		}
+		if _, err := version.Parse(name[i+1:]); err == nil {
+			return name[i+1:]
+		}
+	}
+	return name
+}
+
+// versionFiles finds files by name or glob pattern in a version directory or archive
+type versionFiles struct {
+	location string
+	archived []string // Files of an archive version (nil for a directory)
+}
+
+// newVersionFiles lists the files of an archive version; directories are looked up on demand
+func newVersionFiles(location string) (*versionFiles, error) {
+	if !archive.IsArchive(location) {
+		return &versionFiles{location: location}, nil
+	}
+	files, err := archive.ListFiles(location)
+	if err != nil {
+		return nil, err
+	}
+	return &versionFiles{location: location, archived: files}, nil
+}
+
+// exists reports whether the version has a file at the given relative path
+func (v *versionFiles) exists(name string) bool {
+	if v.archived == nil {
+		return utils.FileExists(filepath.Join(v.location, name))
+	}
+	name = filepath.ToSlash(name)
+	for _, file := range v.archived {
+		if file == name {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the files whose relative path matches a glob pattern, sorted. As with
+// filepath.Match, "*" does not cross directories, so "bin/*.exe" only matches files in bin.
+func (v *versionFiles) match(pattern string) ([]string, error) {
+	pattern = filepath.ToSlash(pattern)
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid key file pattern %q: %w", pattern, err)
+	}
+
+	var matches []string
+	if v.archived == nil {
+		found, _ := filepath.Glob(filepath.Join(v.location, filepath.FromSlash(pattern)))
+		for _, file := range found {
+			rel, err := filepath.Rel(v.location, file)
+			if err != nil || isDir(file) {
+				continue
+			}
+			matches = append(matches, filepath.ToSlash(rel))
+		}
+	} else {
+		for _, file := range v.archived {
+			if ok, _ := path.Match(pattern, file); ok {
+				matches = append(matches, file)
+			}
+		}
+	}
+
+	// The patcher's own files never identify a version
+	kept := matches[:0]
+	for _, file := range matches {
+		if !scanner.IsPatcherPath(file) {
+			kept = append(kept, file)
+		}
+	}
+	sort.Strings(kept)
+	return kept, nil
+}
+
+// isDir reports whether path is a directory
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// exeTarget identifies the platform a self-contained executable is built for
+type exeTarget struct {
+	goos   string
+	goarch string
+}
+
+// parseExeTarget parses an "os/arch" target such as "linux/amd64".
+// An empty value selects the legacy Windows target (patch-apply.exe next to the generator).
+func parseExeTarget(value string) (exeTarget, error) {
+	if value == "" {
+		return exeTarget{}, nil
+	}
+	goos, goarch, ok := strings.Cut(value, "/")
+	if !ok || goos == "" || goarch == "" {
+		return exeTarget{}, fmt.Errorf("invalid executable target %q (expected os/arch, e.g. linux/amd64)", value)
+	}
+	switch goos {
+	case "windows", "linux", "darwin":
+	default:
+		return exeTarget{}, fmt.Errorf("unsupported executable target OS %q (use windows, linux or darwin)", goos)
+	}
+	return exeTarget{goos: goos, goarch: goarch}, nil
+}
+
+// isLegacy reports whether no target was given
+func (t exeTarget) isLegacy() bool {
+	return t.goos == ""
+}
+
+// isWindows reports whether executables for this target need the .exe extension
+func (t exeTarget) isWindows() bool {
+	return t.isLegacy() || t.goos == "windows"
+}
+
+// String returns the target as "os/arch"
+func (t exeTarget) String() string {
+	if t.isLegacy() {
+		return "windows (patch-apply.exe)"
+	}
+	return t.goos + "/" + t.goarch
+}
+
+// platform returns the target as "os/arch", or "windows" for the legacy target
+func (t exeTarget) platform() string {
+	if t.isLegacy() {
+		return "windows"
+	}
+	return t.goos + "/" + t.goarch
+}
+
+// exeExtension returns the file extension for executables on this target
+func (t exeTarget) exeExtension() string {
+	if t.isWindows() {
+		return ".exe"
+	}
+	return ""
+}
+
+// exePathFor returns the self-contained executable path for a patch file
+// Example: "1.0.0-to-1.0.1.patch" -> "1.0.0-to-1.0.1.exe" (Windows) or "1.0.0-to-1.0.1" (Linux/macOS)
+func (t exeTarget) exePathFor(patchFile string) string {
+	return strings.TrimSuffix(patchFile, ".patch") + t.exeExtension()
+}
+
+// resolveApplierStub finds the applier executable used as the stub for self-contained executables.
+// Stubs are named patch-apply-<os>-<arch>[.exe] in stubsDir (default: "stubs" next to the generator);
+// for the generator's own platform, patch-apply next to the generator is used as a fallback.
+func resolveApplierStub(target exeTarget, stubsDir string) (string, error) {
+	genExe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to get executable path: %w", err)
+	}
+	genDir := filepath.Dir(genExe)
+
+	if target.isLegacy() {
+		applierPath := filepath.Join(genDir, "patch-apply.exe")
+		if _, err := os.Stat(applierPath); err != nil {
+			return "", fmt.Errorf("CLI applier not found: %s", applierPath)
+		}
+		return applierPath, nil
+	}
+
+	if stubsDir == "" {
+		stubsDir = filepath.Join(genDir, "stubs")
+	}
+	candidates := []string{
+		filepath.Join(stubsDir, fmt.Sprintf("patch-apply-%s-%s%s", target.goos, target.goarch, target.exeExtension())),
+	}
+	if target.goos == runtime.GOOS && target.goarch == runtime.GOARCH {
+		candidates = append(candidates, filepath.Join(genDir, "patch-apply"+target.exeExtension()))
+	}
+
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err != nil {
+			continue
+		}
+		if err := checkStubFormat(candidate, target); err != nil {
+			return "", err
+		}
+		return candidate, nil
+	}
+	return "", fmt.Errorf("no applier stub for %s (tried: %s)", target, strings.Join(candidates, ", "))
+}
+
+// checkStubFormat verifies that a stub is an executable for the target OS and architecture
+func checkStubFormat(path string, target exeTarget) error {
+	switch target.goos {
+	case "linux":
+		f, err := elf.Open(path)
+		if err != nil {
+			return fmt.Errorf("applier stub %s is not a Linux ELF executable: %w", path, err)
+		}
+		defer f.Close()
+		if want, ok := elfMachines[target.goarch]; ok && f.Machine != want {
+			return fmt.Errorf("applier stub %s is built for %s, not %s", path, f.Machine, target.goarch)
+		}
+	case "windows":
+		f, err := pe.Open(path)
+		if err != nil {
+			return fmt.Errorf("applier stub %s is not a Windows PE executable: %w", path, err)
+		}
+		defer f.Close()
+		if want, ok := peMachines[target.goarch]; ok && f.Machine != want {
+			return fmt.Errorf("applier stub %s is built for machine 0x%x, not %s", path, f.Machine, target.goarch)
+		}
+	case "darwin":
+		f, err := macho.Open(path)
+		if err != nil {
+			return fmt.Errorf("applier stub %s is not a macOS Mach-O executable: %w", path, err)
+		}
+		defer f.Close()
+		if want, ok := machoCPUs[target.goarch]; ok && f.Cpu != want {
+			return fmt.Errorf("applier stub %s is built for %s, not %s", path, f.Cpu, target.goarch)
+		}
+	}
+	return nil
+}
+
+// Machine types expected for each GOARCH (architectures not listed are not checked)
+var (
+	elfMachines = map[string]elf.Machine{
+		"amd64": elf.EM_X86_64,
+		"arm64": elf.EM_AARCH64,
+		"386":   elf.EM_386,
+		"arm":   elf.EM_ARM,
+	}
+	peMachines = map[string]uint16{
+		"amd64": pe.IMAGE_FILE_MACHINE_AMD64,
+		"arm64": pe.IMAGE_FILE_MACHINE_ARM64,
+		"386":   pe.IMAGE_FILE_MACHINE_I386,
+	}
+	machoCPUs = map[string]macho.Cpu{
+		"amd64": macho.CpuAmd64,
+		"arm64": macho.CpuArm64,
+	}
+)
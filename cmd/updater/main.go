@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/catalog"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/config"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/download"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/patcher"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/update"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/version"
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// indexTimeout bounds fetching index.json; patch downloads are not time-limited
+const indexTimeout = 60 * time.Second
+
+// updateOptions holds the command-line settings shared by index and single-patch updates
+type updateOptions struct {
+	currentDir    string
+	keyFile       string
+	downloadDir   string // Where patches are downloaded; kept after a failure so the next run can resume
+	keepDownloads bool
+	acceptEULA    bool
+	backup        bool
+	applier       *patcher.Applier
+}
+
+func main() {
+	baseURL := flag.String("url", "", "URL of the patch directory containing index.json")
+	patchURL := flag.String("patch-url", "", "URL of a single patch (.patch or part 1 .01.patch) to download and apply without an index")
+	currentDir := flag.String("current-dir", "", "Directory containing the installation to update")
+	toVersion := flag.String("to", "", "Version to update to (default: newest release in the index)")
+	fromVersion := flag.String("from", "", "Installed version, if it can't be identified from the key file")
+	keyFile := flag.String("key-file", "", "Custom key file path relative to --current-dir (if renamed)")
+	downloadDir := flag.String("download-dir", "", "Directory for downloaded patches (default: a per-URL directory in the system temp folder)")
+	keepDownloads := flag.Bool("keep-downloads", false, "Keep downloaded patches after updating")
+	check := flag.Bool("check", false, "Only show the installed version and the update plan; download nothing")
+	preRelease := flag.Bool("prerelease", false, "Allow updating to pre-release versions when --to is not given")
+	retries := flag.Int("retries", 5, "Times a failed download is retried, with increasing delays")
+	parallel := flag.Int("parallel", 4, "Number of files downloaded at the same time")
+	backup := flag.Bool("backup", true, "Create backup before each patch")
+	jobs := flag.Int("jobs", 0, "Number of parallel workers for hashing and applying (0 = auto-detect CPU cores, 1 = single-threaded)")
+	trustKey := flag.String("trust-key", "", "Public key file(s); every patch must be signed by one of them (comma-separated)")
+	allowHooks := flag.Bool("allow-hooks", false, "Run hook scripts declared in patches even if they are not signed by a trusted key")
+	acceptEULA := flag.Bool("accept-eula", false, "Accept the license agreements of the patches being applied")
+	versionFlag := flag.Bool("version", false, "Show version information")
+	help := flag.Bool("help", false, "Show help message")
+
+	flag.Parse()
+
+	if *versionFlag {
+		fmt.Printf("CyberPatchMaker Updater v%s\n", version.GetVersion())
+		return
+	}
+	if *help {
+		printHelp()
+		return
+	}
+
+	if (*baseURL == "") == (*patchURL == "") || *currentDir == "" {
+		fmt.Println("Error: --current-dir and one of --url or --patch-url are required")
+		printHelp()
+		os.Exit(1)
+	}
+	if !utils.FileExists(*currentDir) {
+		fmt.Printf("Error: current directory not found: %s\n", *currentDir)
+		os.Exit(1)
+	}
+
+	opts := &updateOptions{
+		currentDir:    *currentDir,
+		keyFile:       *keyFile,
+		downloadDir:   *downloadDir,
+		keepDownloads: *keepDownloads,
+		acceptEULA:    *acceptEULA,
+		backup:        *backup,
+		applier:       patcher.NewApplier(),
+	}
+	if opts.downloadDir == "" {
+		opts.downloadDir = defaultDownloadDir(*baseURL + *patchURL)
+	}
+	opts.applier.SetWorkerThreads(config.ResolveWorkerCount(*jobs))
+	opts.applier.SetAllowHooks(*allowHooks)
+	if *trustKey != "" {
+		for _, keyPath := range strings.Split(*trustKey, ",") {
+			key, err := utils.LoadPublicKey(strings.TrimSpace(keyPath))
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			opts.applier.AddTrustedKey(key)
+		}
+	}
+
+	// Ctrl+C cancels downloads; a patch that is already being applied finishes or rolls back on its own
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	fmt.Printf("CyberPatchMaker Updater v%s\n", version.GetVersion())
+
+	if *patchURL != "" {
+		downloader := download.NewDownloader(update.NewHTTPClient())
+		downloader.SetUserAgent(update.UserAgent())
+		configureDownloader(downloader, *retries, *parallel)
+		os.Exit(runPatchURL(ctx, downloader, *patchURL, opts))
+	}
+
+	client, err := update.NewClient(*baseURL)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	configureDownloader(client.Downloader(), *retries, *parallel)
+	os.Exit(runIndexUpdate(ctx, client, *fromVersion, *toVersion, *preRelease, *check, opts))
+}
+
+// configureDownloader applies the --retries and --parallel flags and prints progress to the console
+func configureDownloader(downloader *download.Downloader, retries, parallel int) {
+	downloader.SetRetries(retries)
+	downloader.SetParallel(parallel)
+	downloader.SetProgress(download.NewConsoleProgress(os.Stdout))
+}
+
+// runIndexUpdate identifies the install, plans the patch chain from the index and applies it
+func runIndexUpdate(ctx context.Context, client *update.Client, fromVersion, toVersion string, preRelease, check bool, opts *updateOptions) int {
+	fmt.Printf("\nFetching update index from %s\n", client.BaseURL())
+	indexCtx, cancel := context.WithTimeout(ctx, indexTimeout)
+	index, err := client.FetchIndex(indexCtx)
+	cancel()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	fmt.Printf("✓ Index lists %d versions and %d patches\n", len(index.Versions), len(index.Patches))
+
+	installed := fromVersion
+	if installed == "" {
+		entry, err := update.IdentifyInstall(index, opts.currentDir, opts.keyFile)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return 1
+		}
+		installed = entry.Version
+		fmt.Printf("✓ Installed version: %s (identified by %s)\n", installed, entry.KeyFile.Path)
+	} else {
+		fmt.Printf("Installed version: %s (from --from)\n", installed)
+	}
+
+	target := toVersion
+	if target == "" {
+		if target, err = update.LatestVersion(index, preRelease); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return 1
+		}
+	}
+
+	chain, err := update.PlanChain(index, installed, target)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+	if len(chain) == 0 {
+		fmt.Printf("\n✓ Already up to date (%s)\n", installed)
+		return 0
+	}
+	printPlan(chain)
+	if check {
+		return 0
+	}
+
+	for i, entry := range chain {
+		fmt.Printf("\n=== Patch %d of %d: %s → %s ===\n", i+1, len(chain), entry.From, entry.To)
+		fmt.Printf("Downloading %s to %s\n", utils.FormatBytes(entry.TotalSize), opts.downloadDir)
+		patchFile, err := client.DownloadPatch(ctx, entry, opts.downloadDir)
+		if err != nil {
+			return updateFailed(fmt.Errorf("download failed: %w", err), i, chain)
+		}
+		fmt.Println("✓ Download verified (SHA-256)")
+
+		patch, err := loadDownloadedPatch(patchFile)
+		if err == nil && (patch.FromVersion != entry.From || patch.ToVersion != entry.To) {
+			err = fmt.Errorf("downloaded patch is %s → %s, but the index lists it as %s → %s",
+				patch.FromVersion, patch.ToVersion, entry.From, entry.To)
+		}
+		if err == nil {
+			// --key-file only applies to the installed version; later patches use the key file they wrote
+			err = opts.apply(patch, patchFile, i == 0)
+		}
+		if err != nil {
+			return updateFailed(err, i, chain)
+		}
+
+		if !opts.keepDownloads {
+			for _, file := range update.PatchFiles(entry) {
+				os.Remove(filepath.Join(opts.downloadDir, filepath.FromSlash(file.Name)))
+			}
+		}
+	}
+
+	opts.finishDownloads()
+	fmt.Println("\n=== Update Complete ===")
+	fmt.Printf("Version updated from %s to %s\n", installed, target)
+	return 0
+}
+
+// runPatchURL downloads a single patch, with its parts and chunks, and applies it
+func runPatchURL(ctx context.Context, downloader *download.Downloader, patchURL string, opts *updateOptions) int {
+	fmt.Printf("\nDownloading %s to %s\n", download.Redact(patchURL), opts.downloadDir)
+	patchFile, err := downloader.FetchPatch(ctx, patchURL, opts.downloadDir)
+	if err != nil {
+		fmt.Printf("Error: download failed: %v\n", err)
+		fmt.Println("Run the same command again to resume the download.")
+		return 1
+	}
+
+	patch, err := loadDownloadedPatch(patchFile)
+	if err == nil {
+		fmt.Printf("\nPatch: %s → %s\n", patch.FromVersion, patch.ToVersion)
+		err = opts.apply(patch, patchFile, true)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return 1
+	}
+
+	if !opts.keepDownloads {
+		// Remove everything the download may have created, including parts and chunks
+		base := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(patchFile), ".patch"), ".01")
+		if matches, err := filepath.Glob(filepath.Join(opts.downloadDir, base+".*")); err == nil {
+			for _, match := range matches {
+				os.Remove(match)
+			}
+		}
+	}
+	opts.finishDownloads()
+	fmt.Println("\n=== Update Complete ===")
+	fmt.Printf("Version updated from %s to %s\n", patch.FromVersion, patch.ToVersion)
+	return 0
+}
+
+// printPlan shows the patches that will be applied and the total download
+func printPlan(chain []catalog.PatchEntry) {
+	fmt.Printf("\nUpdate plan: %s → %s (%d patch(es), %s to download)\n",
+		chain[0].From, chain[len(chain)-1].To, len(chain), utils.FormatBytes(update.ChainSize(chain)))
+	for i, entry := range chain {
+		signed := ""
+		if entry.SignerKeyID != "" {
+			signed = fmt.Sprintf(", signed by %s", entry.SignerKeyID)
+		}
+		fmt.Printf("  %d. %s → %s (%s%s)\n", i+1, entry.From, entry.To, utils.FormatBytes(entry.TotalSize), signed)
+	}
+
+	last := chain[len(chain)-1]
+	if last.ReleaseNotes != "" {
+		fmt.Printf("\nRelease notes for %s:\n%s\n", last.To, last.ReleaseNotes)
+	}
+}
+
+// loadDownloadedPatch loads a downloaded patch, with all parts if it is multi-part
+func loadDownloadedPatch(patchFile string) (*utils.Patch, error) {
+	var patch *utils.Patch
+	var err error
+	if strings.HasSuffix(patchFile, ".01.patch") {
+		patch, err = patcher.LoadMultiPartPatch(patchFile)
+	} else {
+		patch, err = utils.LoadPatch(patchFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load patch: %w", err)
+	}
+	return patch, nil
+}
+
+// apply checks the patch's license agreement and applies it to the install.
+// useKeyFile applies --key-file, which only describes the version installed before the update.
+func (o *updateOptions) apply(patch *utils.Patch, patchFile string, useKeyFile bool) error {
+	if patch.Branding != nil && patch.Branding.EULA != "" && !o.acceptEULA {
+		return fmt.Errorf("patch %s → %s has a license agreement; review it and run again with --accept-eula",
+			patch.FromVersion, patch.ToVersion)
+	}
+	if useKeyFile && o.keyFile != "" {
+		patch.FromKeyFile.Path = o.keyFile
+	}
+	if err := o.applier.ApplyPatchWithPath(patch, o.currentDir, patchFile, true, true, o.backup); err != nil {
+		return fmt.Errorf("patch application failed: %w", err)
+	}
+	patcher.RecordInstall(config.GetDefaultHistoryPath(), o.currentDir, patch)
+	return nil
+}
+
+// finishDownloads removes the download directory if it is empty, or reports where kept files are
+func (o *updateOptions) finishDownloads() {
+	if o.keepDownloads {
+		fmt.Printf("\nDownloaded patches kept in: %s\n", o.downloadDir)
+		return
+	}
+	// Only succeeds if nothing else is left in the directory
+	os.Remove(o.downloadDir)
+}
+
+// updateFailed reports a failed update. Patches before step were applied, so the install is left
+// at the source version of the failed patch. Downloaded files are kept so the next run can resume.
+func updateFailed(err error, step int, chain []catalog.PatchEntry) int {
+	fmt.Printf("Error: %v\n", err)
+	if step > 0 {
+		fmt.Printf("\nThe install was updated to %s before the failure.\n", chain[step].From)
+	}
+	fmt.Println("Run the updater again to continue; completed and partial downloads are reused.")
+	return 1
+}
+
+// defaultDownloadDir returns a download directory in the system temp folder that is the same for
+// every run against the same URL, so an interrupted download can be resumed
+func defaultDownloadDir(sourceURL string) string {
+	sum := sha256.Sum256([]byte(sourceURL))
+	return filepath.Join(os.TempDir(), "patch-update", fmt.Sprintf("%x", sum[:8]))
+}
+
+func printHelp() {
+	fmt.Printf("CyberPatchMaker - Updater v%s\n", version.GetVersion())
+	fmt.Println("\nUsage:")
+	fmt.Println("  patch-update --url <patch directory URL> --current-dir <directory>")
+	fmt.Println("  patch-update --patch-url <patch URL> --current-dir <directory>")
+	fmt.Println("\nWith --url, downloads index.json, identifies the installed version by its key file,")
+	fmt.Println("and downloads and applies the patches that lead to the newest version.")
+	fmt.Println("With --patch-url, downloads one patch (and its other parts) and applies it.")
+	fmt.Println("Interrupted downloads are resumed on the next run.")
+	fmt.Println("\nOptions:")
+	fmt.Println("  --url             URL of the patch directory containing index.json")
+	fmt.Println("  --patch-url       URL of a single patch (.patch or part 1 .01.patch) to apply without an index")
+	fmt.Println("  --current-dir     Directory containing the installation to update (required)")
+	fmt.Println("  --to              Version to update to (default: newest release in the index)")
+	fmt.Println("  --from            Installed version, if it can't be identified from the key file")
+	fmt.Println("  --key-file        Custom key file path relative to --current-dir (if renamed)")
+	fmt.Println("  --download-dir    Directory for downloaded patches (default: a per-URL temp directory)")
+	fmt.Println("  --keep-downloads  Keep downloaded patches after updating")
+	fmt.Println("  --check           Only show the installed version and the update plan")
+	fmt.Println("  --prerelease      Allow updating to pre-release versions when --to is not given")
+	fmt.Println("  --retries         Times a failed download is retried, with increasing delays (default: 5)")
+	fmt.Println("  --parallel        Number of files downloaded at the same time (default: 4)")
+	fmt.Println("  --backup          Create backup before each patch (default: true)")
+	fmt.Println("  --jobs            Number of parallel workers (0=auto-detect CPU cores, 1=single-threaded, default: 0)")
+	fmt.Println("  --trust-key       Public key file(s); every patch must be signed by one of them (comma-separated)")
+	fmt.Println("  --allow-hooks     Run hook scripts from patches even if they are not signed by a trusted key")
+	fmt.Println("  --accept-eula     Accept the license agreements of the patches being applied")
+	fmt.Println("  --version         Show version information")
+	fmt.Println("  --help            Show this help message")
+	fmt.Println("\nProxies:")
+	fmt.Println("  HTTP_PROXY, HTTPS_PROXY and NO_PROXY are used when set.")
+	fmt.Println("\nExamples:")
+	fmt.Println("  # Show what would be downloaded")
+	fmt.Println("  patch-update --url https://updates.example.com/myapp/ --current-dir C:\\MyApp --check")
+	fmt.Println("\n  # Update to the newest version, requiring signed patches")
+	fmt.Println("  patch-update --url https://updates.example.com/myapp/ --current-dir C:\\MyApp --trust-key release.pub")
+	fmt.Println("\n  # Update to a specific version")
+	fmt.Println("  patch-update --url https://updates.example.com/myapp/ --current-dir C:\\MyApp --to 1.2.0")
+	fmt.Println("\n  # Apply one multi-part patch without an index")
+	fmt.Println("  patch-update --patch-url https://updates.example.com/myapp/1.1.0-to-1.2.0.01.patch --current-dir C:\\MyApp")
+}
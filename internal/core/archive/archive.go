@@ -0,0 +1,262 @@
+// Package archive reads version trees straight from zip, tar, tar.gz and tar.zst archives, so
+// build artifacts can be scanned and patched without extracting them to disk. Archives are read
+// as streams: tar members in order, zip members one at a time.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported archive formats
+const (
+	FormatZip    = "zip"
+	FormatTar    = "tar"
+	FormatTarGz  = "tar.gz"
+	FormatTarZst = "tar.zst"
+)
+
+// extensions maps file name extensions to archive formats; compound extensions come first
+var extensions = []struct {
+	suffix string
+	format string
+}{
+	{".tar.gz", FormatTarGz},
+	{".tgz", FormatTarGz},
+	{".tar.zst", FormatTarZst},
+	{".tzst", FormatTarZst},
+	{".tar", FormatTar},
+	{".zip", FormatZip},
+}
+
+// zipCreatorUnix is the "version made by" host of zip members that record Unix mode bits
+const zipCreatorUnix = 3
+
+// Format returns the archive format of path from its extension, or "" if it is not a supported archive
+func Format(path string) string {
+	lower := strings.ToLower(path)
+	for _, ext := range extensions {
+		if strings.HasSuffix(lower, ext.suffix) {
+			return ext.format
+		}
+	}
+	return ""
+}
+
+// IsArchive reports whether path is a regular file with a supported archive extension
+func IsArchive(path string) bool {
+	if Format(path) == "" {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// TrimExtension returns a file name without its archive extension ("build-1.4.2.tar.zst" → "build-1.4.2")
+func TrimExtension(name string) string {
+	lower := strings.ToLower(name)
+	for _, ext := range extensions {
+		if strings.HasSuffix(lower, ext.suffix) {
+			return name[:len(name)-len(ext.suffix)]
+		}
+	}
+	return name
+}
+
+// memberKind is the type of an archive member
+type memberKind int
+
+const (
+	kindFile memberKind = iota
+	kindDir
+	kindSymlink
+	kindHardlink
+)
+
+// member is a file, directory or link in an archive
+type member struct {
+	name    string // Slash-separated path relative to the archive root
+	kind    memberKind
+	mode    uint32 // Unix permission bits (0 = not recorded)
+	modTime time.Time
+	link    string                        // Link target as stored in the archive
+	open    func() (io.ReadCloser, error) // Contents of a regular file; only valid during the walk callback
+}
+
+// walk calls fn for every file, directory and link in the archive at path, in archive order.
+// Other member types, such as devices and FIFOs, are skipped.
+func walk(path string, fn func(m *member) error) error {
+	switch Format(path) {
+	case FormatZip:
+		return walkZip(path, fn)
+	case FormatTar, FormatTarGz, FormatTarZst:
+		return walkTar(path, fn)
+	}
+	return fmt.Errorf("%s is not a supported archive (use .zip, .tar, .tar.gz, .tgz, .tar.zst or .tzst)", path)
+}
+
+// walkTar walks a tar archive, decompressing it on the fly
+func walkTar(path string, fn func(m *member) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = bufio.NewReaderSize(f, 1024*1024)
+	switch Format(path) {
+	case FormatTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	case FormatTarZst:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+
+		name, err := cleanName(hdr.Name)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			continue // The archive root ("./")
+		}
+
+		m := &member{name: name, mode: uint32(hdr.Mode) & 0777, modTime: hdr.ModTime, link: hdr.Linkname}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			m.kind = kindFile
+			m.open = func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }
+		case tar.TypeDir:
+			m.kind = kindDir
+		case tar.TypeSymlink:
+			m.kind = kindSymlink
+		case tar.TypeLink:
+			m.kind = kindHardlink
+		default:
+			continue
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+}
+
+// walkZip walks a zip archive; member contents are only decompressed when opened
+func walkZip(path string, fn func(m *member) error) error {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		name, err := cleanName(f.Name)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			continue
+		}
+
+		m := &member{name: name, modTime: f.Modified}
+		if f.CreatorVersion>>8 == zipCreatorUnix {
+			m.mode = uint32(f.Mode().Perm())
+		}
+		switch mode := f.Mode(); {
+		case mode.IsDir():
+			m.kind = kindDir
+		case mode&fs.ModeSymlink != 0:
+			// Zip stores the symlink target as the member's contents
+			m.kind = kindSymlink
+			target, err := readZipFile(f)
+			if err != nil {
+				return fmt.Errorf("failed to read symlink %s: %w", name, err)
+			}
+			m.link = string(target)
+		case mode.IsRegular():
+			m.kind = kindFile
+			m.open = f.Open
+		default:
+			continue
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readZipFile reads the contents of a zip member
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// readMember reads the contents of a regular file member, at most limit bytes of it (0 = all)
+func readMember(m *member, limit int64) ([]byte, error) {
+	rc, err := m.open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", m.name, err)
+	}
+	defer rc.Close()
+	var r io.Reader = rc
+	if limit > 0 {
+		r = io.LimitReader(rc, limit)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", m.name, err)
+	}
+	return data, nil
+}
+
+// cleanName turns a member name into a slash-separated path relative to the archive root ("" for
+// the root itself). Absolute names and names leading out of the root are rejected: extracting
+// such an archive would write outside the install, so it cannot describe a version.
+func cleanName(name string) (string, error) {
+	slashed := strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(slashed, "/") || len(slashed) >= 2 && slashed[1] == ':' {
+		return "", fmt.Errorf("archive member %s has an absolute path", name)
+	}
+	cleaned := path.Clean(slashed)
+	if cleaned == "." {
+		return "", nil
+	}
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("archive member %s points outside the archive", name)
+	}
+	return cleaned, nil
+}
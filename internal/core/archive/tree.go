@@ -0,0 +1,345 @@
+package archive
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/scanner"
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// maxLinkHops limits how many links are followed to resolve one link, so link cycles end
+const maxLinkHops = 40
+
+// node is what a walk learned about one member
+type node struct {
+	kind     memberKind
+	mode     uint32
+	modTime  time.Time
+	link     string
+	size     int64  // Regular files, after Scan hashed them
+	checksum string // Regular files, after Scan hashed them
+}
+
+// tree indexes the members of an archive by name
+type tree struct {
+	nodes map[string]*node
+	names []string // Member names in archive order, including directories that are only implied by their contents
+}
+
+func newTree() *tree {
+	return &tree{nodes: make(map[string]*node)}
+}
+
+// add records a member and its parent directories. A member that appears twice replaces the earlier one,
+// as it would when the archive is extracted.
+func (t *tree) add(m *member) *node {
+	for dir := path.Dir(m.name); dir != "."; dir = path.Dir(dir) {
+		if _, ok := t.nodes[dir]; ok {
+			break
+		}
+		t.nodes[dir] = &node{kind: kindDir}
+		t.names = append(t.names, dir)
+	}
+
+	n := &node{kind: m.kind, mode: m.mode, modTime: m.modTime, link: m.link}
+	if old, ok := t.nodes[m.name]; !ok {
+		t.names = append(t.names, m.name)
+	} else if old.kind == kindDir && m.kind == kindDir {
+		return old
+	}
+	t.nodes[m.name] = n
+	return n
+}
+
+// root returns the prefix of member names that is the version root: when every member is inside
+// a single top-level directory (build-1.4.2/...), that directory is the root; otherwise the
+// archive root is, and the prefix is "".
+func (t *tree) root() string {
+	top := ""
+	nested := false
+	for _, name := range t.names {
+		first, _, found := strings.Cut(name, "/")
+		if top == "" {
+			top = first
+		} else if first != top {
+			return ""
+		}
+		nested = nested || found
+	}
+	if !nested || t.nodes[top].kind != kindDir {
+		return ""
+	}
+	return top + "/"
+}
+
+// relative returns the path of a member relative to the version root, or false for the root itself
+func relative(name, prefix string) (string, bool) {
+	rel := strings.TrimPrefix(name, prefix)
+	return rel, rel != "" && name+"/" != prefix
+}
+
+// resolve follows links from the member name to the regular file they lead to and returns its name.
+// Links to directories, links leaving the version root and dangling links are errors.
+func (t *tree) resolve(name, prefix string) (string, error) {
+	for hops := 0; hops <= maxLinkHops; hops++ {
+		n := t.nodes[name]
+		switch n.kind {
+		case kindFile:
+			return name, nil
+		case kindDir:
+			return "", fmt.Errorf("%s is a directory (links to directories are not supported)", strings.TrimPrefix(name, prefix))
+		}
+
+		// Symlink targets are relative to the link's directory; hard link targets are member names
+		target := strings.ReplaceAll(n.link, "\\", "/")
+		if n.kind == kindSymlink {
+			if strings.HasPrefix(target, "/") {
+				return "", fmt.Errorf("target %s is outside the archive", n.link)
+			}
+			target = path.Join(path.Dir(name), target)
+		}
+		cleaned, err := cleanName(target)
+		if err != nil || cleaned == "" || !strings.HasPrefix(cleaned, prefix) {
+			return "", fmt.Errorf("target %s is outside the version", n.link)
+		}
+		if _, ok := t.nodes[cleaned]; !ok {
+			return "", fmt.Errorf("target %s is not in the archive", n.link)
+		}
+		name = cleaned
+	}
+	return "", fmt.Errorf("too many levels of links")
+}
+
+// ignored reports whether a path, or a directory containing it, is excluded from the version
+func ignored(patterns *scanner.IgnorePatterns, rel string) bool {
+	for p := rel; p != "."; p = path.Dir(p) {
+		if scanner.IsPatcherPath(p) || patterns.ShouldIgnore(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Scan hashes every file in the archive at path and returns the files and directories of the
+// version it contains, like scanner.Scanner.ScanDirectory does for a directory. File entries carry
+// the members' mode bits and modification times. Symlinks and hard links to files are listed with
+// their target's contents and LinkTarget set; links to directories and dangling links are skipped
+// with a warning. A .cyberignore file at the version root is applied. progress, if not nil, is
+// called after each hashed file.
+func Scan(path string, progress func(files int, bytes int64)) ([]utils.FileEntry, []string, error) {
+	t := newTree()
+	ignoreFiles := make(map[string][]byte) // .cyberignore candidates; the version root is known after the walk
+	hashedFiles := 0
+	var hashedBytes int64
+
+	err := walk(path, func(m *member) error {
+		n := t.add(m)
+		if m.kind != kindFile {
+			return nil
+		}
+
+		rc, err := m.open()
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %w", m.name, err)
+		}
+		defer rc.Close()
+
+		hasher := sha256.New()
+		var w io.Writer = hasher
+		var ignoreData bytes.Buffer
+		if m.name == ".cyberignore" || strings.Count(m.name, "/") == 1 && strings.HasSuffix(m.name, "/.cyberignore") {
+			w = io.MultiWriter(hasher, &ignoreData)
+		}
+		size, err := io.Copy(w, rc)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", m.name, err)
+		}
+		if ignoreData.Len() > 0 {
+			ignoreFiles[m.name] = ignoreData.Bytes()
+		}
+
+		n.size = size
+		n.checksum = hex.EncodeToString(hasher.Sum(nil))
+		hashedFiles++
+		hashedBytes += size
+		if progress != nil {
+			progress(hashedFiles, hashedBytes)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scan archive: %w", err)
+	}
+
+	prefix := t.root()
+	patterns := scanner.NewIgnorePatterns()
+	if data, ok := ignoreFiles[prefix+".cyberignore"]; ok {
+		if err := patterns.LoadFromReader(bytes.NewReader(data)); err != nil {
+			return nil, nil, fmt.Errorf("failed to read .cyberignore: %w", err)
+		}
+	}
+
+	var files []utils.FileEntry
+	var directories []string
+	for _, name := range t.names {
+		rel, ok := relative(name, prefix)
+		if !ok || ignored(patterns, rel) {
+			continue
+		}
+
+		n := t.nodes[name]
+		switch n.kind {
+		case kindDir:
+			directories = append(directories, rel)
+		case kindFile:
+			files = append(files, fileEntry(rel, n))
+		default:
+			target, err := t.resolve(name, prefix)
+			if err != nil {
+				fmt.Printf("Warning: skipping link %s: %v\n", rel, err)
+				continue
+			}
+			entry := fileEntry(rel, t.nodes[target])
+			entry.LinkTarget = n.link
+			if n.kind == kindHardlink {
+				entry.LinkTarget = strings.TrimPrefix(target, prefix)
+			}
+			files = append(files, entry)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	sort.Strings(directories)
+	return files, directories, nil
+}
+
+// fileEntry describes the regular file n at rel
+func fileEntry(rel string, n *node) utils.FileEntry {
+	return utils.FileEntry{
+		Path:         rel,
+		Size:         n.size,
+		Checksum:     n.checksum,
+		ModTime:      n.modTime,
+		IsExecutable: n.mode&0111 != 0,
+		Mode:         n.mode,
+	}
+}
+
+// ListFiles returns the paths of the files and links in the archive relative to the version root,
+// without reading their contents. Unlike Scan, it does not apply .cyberignore.
+func ListFiles(path string) ([]string, error) {
+	t := newTree()
+	if err := walk(path, func(m *member) error {
+		t.add(m)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to list archive: %w", err)
+	}
+
+	prefix := t.root()
+	var files []string
+	for _, name := range t.names {
+		rel, ok := relative(name, prefix)
+		if ok && t.nodes[name].kind != kindDir && !scanner.IsPatcherPath(rel) {
+			files = append(files, rel)
+		}
+	}
+	return files, nil
+}
+
+// ReadFiles reads the contents of files, given as paths relative to the version root like Scan
+// returns them, in a single pass over the archive at path. Links return their target's contents;
+// a link whose target was passed before the link was seen takes a second pass.
+func ReadFiles(path string, files []string) (map[string][]byte, error) {
+	return readFiles(path, files, 0)
+}
+
+// ReadFilePrefixes reads at most limit bytes from the start of each of files, like ReadFiles.
+// It is used to sample file contents without holding large files in memory.
+func ReadFilePrefixes(path string, files []string, limit int64) (map[string][]byte, error) {
+	return readFiles(path, files, limit)
+}
+
+// readFiles reads files from the archive at path, at most limit bytes of each (0 = all)
+func readFiles(path string, files []string, limit int64) (map[string][]byte, error) {
+	wanted := make(map[string]bool, len(files))
+	for _, file := range files {
+		wanted[file] = true
+	}
+
+	// The version root is only known after the walk, so contents are kept for members wanted
+	// either at the archive root or inside a single top-level directory
+	t := newTree()
+	data := make(map[string][]byte)
+	err := walk(path, func(m *member) error {
+		t.add(m)
+		if m.kind != kindFile {
+			return nil
+		}
+		_, inTop, _ := strings.Cut(m.name, "/")
+		if !wanted[m.name] && !wanted[inTop] {
+			return nil
+		}
+		content, err := readMember(m, limit)
+		if err != nil {
+			return err
+		}
+		data[m.name] = content
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	prefix := t.root()
+	contents := make(map[string][]byte, len(files))
+	missing := make(map[string][]string) // Link targets still to read, with the paths linking to them
+	for _, file := range files {
+		name := prefix + file
+		if n, ok := t.nodes[name]; !ok || n.kind == kindDir {
+			return nil, fmt.Errorf("%s is not a file in %s", file, path)
+		}
+		target, err := t.resolve(name, prefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve link %s: %w", file, err)
+		}
+		if content, ok := data[target]; ok {
+			contents[file] = content
+		} else {
+			missing[target] = append(missing[target], file)
+		}
+	}
+
+	if len(missing) > 0 {
+		err := walk(path, func(m *member) error {
+			links, ok := missing[m.name]
+			if !ok || m.kind != kindFile {
+				return nil
+			}
+			content, err := readMember(m, limit)
+			if err != nil {
+				return err
+			}
+			for _, file := range links {
+				contents[file] = content
+			}
+			delete(missing, m.name)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		for target := range missing {
+			return nil, fmt.Errorf("%s changed while it was read: %s is missing", path, target)
+		}
+	}
+	return contents, nil
+}
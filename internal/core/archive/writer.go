@@ -0,0 +1,197 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Entry is a file written to an archive by Write
+type Entry struct {
+	Path    string                        // Path relative to the archive root, with forward slashes
+	Size    int64                         // Size of the contents
+	Mode    uint32                        // Unix permission bits (0 = 0644)
+	ModTime time.Time                     // Modification time (zero = now)
+	Open    func() (io.ReadCloser, error) // Opens the contents
+}
+
+// Write creates the archive at path, in the format of its extension, with the given directories
+// and files at its root. Every file is written as a regular file, so links in the source version
+// extract as copies of their targets everywhere. The archive is written to a temporary file first and
+// only renamed into place once complete.
+func Write(archivePath string, directories []string, files []Entry) error {
+	format := Format(archivePath)
+	if format == "" {
+		return fmt.Errorf("%s is not a supported archive (use .zip, .tar, .tar.gz, .tgz, .tar.zst or .tzst)", archivePath)
+	}
+
+	tmpPath := archivePath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			f.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	bw := bufio.NewWriterSize(f, 1024*1024)
+	if format == FormatZip {
+		err = writeZip(bw, directories, files)
+	} else {
+		err = writeTar(bw, format, directories, files)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := os.Rename(tmpPath, archivePath); err != nil {
+		return fmt.Errorf("failed to finalize archive: %w", err)
+	}
+	committed = true
+	return nil
+}
+
+// writeTar writes a tar archive, compressed according to format
+func writeTar(w io.Writer, format string, directories []string, files []Entry) error {
+	switch format {
+	case FormatTarGz:
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		if err := writeTarEntries(gz, directories, files); err != nil {
+			return err
+		}
+		return gz.Close()
+	case FormatTarZst:
+		zw, err := zstd.NewWriter(w)
+		if err != nil {
+			return fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+		defer zw.Close()
+		if err := writeTarEntries(zw, directories, files); err != nil {
+			return err
+		}
+		return zw.Close()
+	}
+	return writeTarEntries(w, directories, files)
+}
+
+// writeTarEntries writes the tar stream itself
+func writeTarEntries(w io.Writer, directories []string, files []Entry) error {
+	tw := tar.NewWriter(w)
+	now := time.Now()
+
+	for _, dir := range directories {
+		hdr := &tar.Header{Typeflag: tar.TypeDir, Name: dir + "/", Mode: 0755, ModTime: now, Format: tar.FormatPAX}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write %s: %w", dir, err)
+		}
+	}
+
+	for _, file := range files {
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     file.Path,
+			Size:     file.Size,
+			Mode:     int64(entryMode(file)),
+			ModTime:  entryModTime(file, now),
+			Format:   tar.FormatPAX,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.Path, err)
+		}
+		if err := copyEntry(tw, file); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// writeZip writes a zip archive; members record Unix mode bits so executables keep them
+func writeZip(w io.Writer, directories []string, files []Entry) error {
+	zw := zip.NewWriter(w)
+	now := time.Now()
+
+	for _, dir := range directories {
+		hdr := &zip.FileHeader{Name: dir + "/", Modified: now}
+		hdr.SetMode(os.ModeDir | 0755)
+		if _, err := zw.CreateHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write %s: %w", dir, err)
+		}
+	}
+
+	for _, file := range files {
+		hdr := &zip.FileHeader{
+			Name:     file.Path,
+			Method:   zip.Deflate,
+			Modified: entryModTime(file, now),
+		}
+		hdr.SetMode(os.FileMode(entryMode(file)))
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.Path, err)
+		}
+		if err := copyEntry(fw, file); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// copyEntry copies the contents of file to w, checking that the size matches the one recorded
+func copyEntry(w io.Writer, file Entry) error {
+	rc, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", file.Path, err)
+	}
+	defer rc.Close()
+
+	n, err := io.Copy(w, rc)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", file.Path, err)
+	}
+	if n != file.Size {
+		return fmt.Errorf("%s changed while it was being archived (%d bytes, expected %d)", file.Path, n, file.Size)
+	}
+	return nil
+}
+
+// entryMode returns the permission bits a file is written with
+func entryMode(file Entry) uint32 {
+	if file.Mode == 0 {
+		return 0644
+	}
+	return file.Mode & 0777
+}
+
+// entryModTime returns the modification time a file is written with
+func entryModTime(file Entry, now time.Time) time.Time {
+	if file.ModTime.IsZero() {
+		return now
+	}
+	return file.ModTime
+}
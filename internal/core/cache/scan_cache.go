@@ -66,6 +66,9 @@ func (sc *ScanCache) SaveScan(version *utils.Version) error {
 		Manifest:     version.Manifest,
 		CachedAt:     version.LastScanned,
 		LocationHash: sc.hashLocation(version.Location),
+
+		ArchiveSize:    version.ArchiveSize,
+		ArchiveModTime: version.ArchiveModTime,
 	}
 
 	// Marshal to JSON
@@ -117,6 +120,9 @@ func (sc *ScanCache) LoadScan(versionNumber, location string) (*utils.Version, e
 		Manifest:     cacheEntry.Manifest,
 		RegisteredAt: cacheEntry.CachedAt,
 		LastScanned:  cacheEntry.CachedAt,
+
+		ArchiveSize:    cacheEntry.ArchiveSize,
+		ArchiveModTime: cacheEntry.ArchiveModTime,
 	}
 
 	return version, nil
@@ -246,6 +252,9 @@ type CachedScan struct {
 	Manifest     *utils.Manifest   `json:"manifest"`
 	CachedAt     time.Time         `json:"cached_at"`
 	LocationHash string            `json:"location_hash"`
+
+	ArchiveSize    int64     `json:"archive_size,omitempty"`     // Size of a version archive when it was scanned
+	ArchiveModTime time.Time `json:"archive_mod_time,omitempty"` // Modification time of a version archive when it was scanned
 }
 
 // CachedScanInfo provides summary information about a cached scan
@@ -0,0 +1,353 @@
+// Package catalog maintains index.json, a machine-readable list of the versions and patches
+// in a patch output directory, for launchers that need to find the right patch to download.
+package catalog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/version"
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// FormatVersion is the index format written by this generator.
+// Readers must reject indexes with a newer format version.
+const FormatVersion = 1
+
+// FileName is the name of the index in the patch output directory
+const FileName = "index.json"
+
+// Index lists every version and patch in a patch output directory
+type Index struct {
+	FormatVersion int            `json:"format_version"`
+	Generator     string         `json:"generator"` // patch-gen version that last wrote the index
+	UpdatedAt     time.Time      `json:"updated_at"`
+	Versions      []VersionEntry `json:"versions"` // Oldest first
+	Patches       []PatchEntry   `json:"patches"`  // By target version, then source version
+	path          string
+}
+
+// VersionEntry identifies a version by its key file and manifest
+type VersionEntry struct {
+	Version          string  `json:"version"`
+	KeyFile          KeyFile `json:"key_file"`
+	ManifestChecksum string  `json:"manifest_checksum"`
+	TotalFiles       int     `json:"total_files"`
+	TotalSize        int64   `json:"total_size"`
+}
+
+// KeyFile is the file an install is identified by
+type KeyFile struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// PatchEntry describes the files of one patch
+type PatchEntry struct {
+	From         string       `json:"from"`
+	To           string       `json:"to"`
+	Downgrade    bool         `json:"downgrade"`
+	Compression  string       `json:"compression"`
+	SignerKeyID  string       `json:"signer_key_id,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	TotalSize    int64        `json:"total_size"` // Size of all part and chunk files
+	Parts        []Part       `json:"parts"`
+	Executables  []Executable `json:"executables,omitempty"`
+	ReleaseNotes string       `json:"release_notes,omitempty"`
+}
+
+// Part is one part of a patch: a single file, or chunk files listed in a chunk sidecar
+type Part struct {
+	Number    int    `json:"part"`
+	File      *File  `json:"file,omitempty"`
+	ChunkList *File  `json:"chunk_list,omitempty"`
+	Chunks    []File `json:"chunks,omitempty"`
+}
+
+// Executable is a self-contained executable built from a patch
+type Executable struct {
+	Platform string `json:"platform"`
+	File     File   `json:"file"`
+}
+
+// File is a file in the output directory
+type File struct {
+	Name   string `json:"name"` // Relative to the index, with forward slashes
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Load reads the index at path. A missing file is an empty index.
+func Load(path string) (*Index, error) {
+	index := &Index{FormatVersion: FormatVersion, path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read update index: %w", err)
+	}
+	index, err = Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	index.path = path
+	index.FormatVersion = FormatVersion
+	return index, nil
+}
+
+// Parse decodes an index, rejecting format versions newer than FormatVersion
+func Parse(data []byte) (*Index, error) {
+	index := &Index{}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("failed to parse update index: %w", err)
+	}
+	if index.FormatVersion > FormatVersion {
+		return nil, fmt.Errorf("update index has format version %d; version %d is the newest supported",
+			index.FormatVersion, FormatVersion)
+	}
+	return index, nil
+}
+
+// FindVersion returns the entry for a version, or nil if the index does not list it
+func (i *Index) FindVersion(number string) *VersionEntry {
+	for n := range i.Versions {
+		if i.Versions[n].Version == number {
+			return &i.Versions[n]
+		}
+	}
+	return nil
+}
+
+// FindPatch returns the entry for the patch from → to, or nil if the index does not list it
+func (i *Index) FindPatch(from, to string) *PatchEntry {
+	for n := range i.Patches {
+		if i.Patches[n].From == from && i.Patches[n].To == to {
+			return &i.Patches[n]
+		}
+	}
+	return nil
+}
+
+// RemovePatch removes the patch from → to and reports whether the index listed it
+func (i *Index) RemovePatch(from, to string) bool {
+	for n := range i.Patches {
+		if i.Patches[n].From == from && i.Patches[n].To == to {
+			i.Patches = append(i.Patches[:n], i.Patches[n+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Dir returns the directory file names in the index are relative to
+func (i *Index) Dir() string {
+	return filepath.Dir(i.path)
+}
+
+// SetVersion adds a version, replacing an earlier entry for the same version
+func (i *Index) SetVersion(v *utils.Version) {
+	entry := VersionEntry{
+		Version: v.Number,
+		KeyFile: KeyFile{Path: v.KeyFile.Path, SHA256: v.KeyFile.Checksum, Size: v.KeyFile.Size},
+	}
+	if v.Manifest != nil {
+		entry.ManifestChecksum = v.Manifest.Checksum
+		entry.TotalFiles = v.Manifest.TotalFiles
+		entry.TotalSize = v.Manifest.TotalSize
+	}
+
+	for n := range i.Versions {
+		if i.Versions[n].Version == v.Number {
+			i.Versions[n] = entry
+			return
+		}
+	}
+	i.Versions = append(i.Versions, entry)
+}
+
+// SetPatch adds a patch, replacing an earlier entry with the same source and target.
+// Release notes are kept from the earlier entry unless the new entry has its own; executables
+// are not, since they embed the patch they were built from.
+func (i *Index) SetPatch(entry PatchEntry) {
+	for n := range i.Patches {
+		old := &i.Patches[n]
+		if old.From != entry.From || old.To != entry.To {
+			continue
+		}
+		if entry.ReleaseNotes == "" {
+			entry.ReleaseNotes = old.ReleaseNotes
+		}
+		i.Patches[n] = entry
+		return
+	}
+	i.Patches = append(i.Patches, entry)
+}
+
+// Save sorts the index and writes it, replacing the file atomically
+func (i *Index) Save(generatorVersion string) error {
+	i.Generator = generatorVersion
+	i.UpdatedAt = time.Now().UTC()
+
+	sort.SliceStable(i.Versions, func(a, b int) bool {
+		return versionLess(i.Versions[a].Version, i.Versions[b].Version)
+	})
+	sort.SliceStable(i.Patches, func(a, b int) bool {
+		pa, pb := i.Patches[a], i.Patches[b]
+		if pa.To != pb.To {
+			return versionLess(pa.To, pb.To)
+		}
+		return versionLess(pa.From, pb.From)
+	})
+
+	data, err := json.MarshalIndent(i, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal update index: %w", err)
+	}
+	tmpPath := i.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write update index: %w", err)
+	}
+	if err := os.Rename(tmpPath, i.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write update index: %w", err)
+	}
+	return nil
+}
+
+// versionLess orders version numbers oldest first; names that are not version numbers sort last, by name
+func versionLess(a, b string) bool {
+	va, errA := version.Parse(a)
+	vb, errB := version.Parse(b)
+	switch {
+	case errA == nil && errB == nil:
+		if c := va.Compare(vb); c != 0 {
+			return c < 0
+		}
+		return a < b
+	case errA == nil:
+		return true
+	case errB == nil:
+		return false
+	}
+	return a < b
+}
+
+// DescribeParts lists and hashes the files of a saved patch.
+// patchPath is the .patch file, or part 01 (.01.patch) of a multi-part patch.
+func (i *Index) DescribeParts(patchPath string) ([]Part, int64, error) {
+	if !strings.HasSuffix(patchPath, ".01.patch") {
+		file, err := i.DescribeFile(patchPath)
+		if err != nil {
+			return nil, 0, err
+		}
+		return []Part{{Number: 1, File: &file}}, file.Size, nil
+	}
+
+	base := strings.TrimSuffix(patchPath, ".01.patch")
+	var parts []Part
+	var total int64
+	for n := 1; ; n++ {
+		part := Part{Number: n}
+		if partFile := fmt.Sprintf("%s.%02d.patch", base, n); utils.FileExists(partFile) {
+			file, err := i.DescribeFile(partFile)
+			if err != nil {
+				return nil, 0, err
+			}
+			part.File = &file
+			total += file.Size
+		}
+
+		sidecarPath := fmt.Sprintf("%s.part%d.chunks.json", base, n)
+		if utils.FileExists(sidecarPath) {
+			chunkList, chunks, err := i.describeChunks(sidecarPath)
+			if err != nil {
+				return nil, 0, err
+			}
+			part.ChunkList = &chunkList
+			part.Chunks = chunks
+			for _, chunk := range chunks {
+				total += chunk.Size
+			}
+		}
+
+		if part.File == nil && part.ChunkList == nil {
+			break
+		}
+		parts = append(parts, part)
+	}
+	return parts, total, nil
+}
+
+// describeChunks describes a chunk sidecar and the chunk files it lists, in order
+func (i *Index) describeChunks(sidecarPath string) (File, []File, error) {
+	sidecar, err := i.DescribeFile(sidecarPath)
+	if err != nil {
+		return File{}, nil, err
+	}
+	data, err := os.ReadFile(sidecarPath)
+	if err != nil {
+		return File{}, nil, fmt.Errorf("failed to read chunk sidecar: %w", err)
+	}
+	var parsed struct {
+		Chunks []utils.PartChunk `json:"chunks"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return File{}, nil, fmt.Errorf("failed to parse chunk sidecar %s: %w", sidecarPath, err)
+	}
+	sort.SliceStable(parsed.Chunks, func(a, b int) bool {
+		return parsed.Chunks[a].ChunkNumber < parsed.Chunks[b].ChunkNumber
+	})
+
+	chunks := make([]File, 0, len(parsed.Chunks))
+	for _, chunk := range parsed.Chunks {
+		file, err := i.DescribeFile(filepath.Join(filepath.Dir(sidecarPath), chunk.FileName))
+		if err != nil {
+			return File{}, nil, err
+		}
+		chunks = append(chunks, file)
+	}
+	return sidecar, chunks, nil
+}
+
+// DescribeFile hashes a file and names it relative to the index
+func (i *Index) DescribeFile(path string) (File, error) {
+	name, err := filepath.Rel(i.Dir(), path)
+	if err != nil || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return File{}, fmt.Errorf("%s is outside the index directory %s", path, i.Dir())
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return File{}, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, f)
+	if err != nil {
+		return File{}, fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+	return File{Name: filepath.ToSlash(name), Size: size, SHA256: fmt.Sprintf("%x", hasher.Sum(nil))}, nil
+}
+
+// LoadReleaseNotes reads release notes from a text or Markdown file
+func LoadReleaseNotes(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read release notes: %w", err)
+	}
+	notes := string(bytes.TrimSpace(data))
+	if notes == "" {
+		return "", fmt.Errorf("release notes file %s is empty", path)
+	}
+	return notes, nil
+}
@@ -0,0 +1,222 @@
+package catalog
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// writeFile writes data to name in dir and returns its path
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestVersionLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1.9.0", "1.10.0", true},
+		{"1.10.0", "1.9.0", false},
+		{"1.0.0-rc.1", "1.0.0", true},
+		{"1.0", "1.0.0", true}, // Equal numbers are ordered by name
+		{"1.0.0", "1.0", false},
+		{"2.0.0", "latest", true},
+		{"latest", "2.0.0", false},
+		{"beta", "latest", true},
+		{"1.0.0", "1.0.0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_vs_"+tt.b, func(t *testing.T) {
+			if got := versionLess(tt.a, tt.b); got != tt.want {
+				t.Errorf("versionLess(%q, %q) = %t, want %t", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	index, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() of a missing index error = %v", err)
+	}
+	if index.FormatVersion != FormatVersion || len(index.Versions) != 0 || len(index.Patches) != 0 {
+		t.Fatalf("Load() of a missing index = %+v, want an empty index", index)
+	}
+
+	for _, number := range []string{"1.10.0", "1.2.0", "1.9.0"} {
+		index.SetVersion(&utils.Version{Number: number, KeyFile: utils.KeyFileInfo{Path: "app.exe", Checksum: number}})
+	}
+	index.SetVersion(&utils.Version{Number: "1.2.0", KeyFile: utils.KeyFileInfo{Path: "app.exe", Checksum: "replaced"}})
+
+	index.SetPatch(PatchEntry{From: "1.9.0", To: "1.10.0", ReleaseNotes: "notes"})
+	index.SetPatch(PatchEntry{From: "1.2.0", To: "1.10.0"})
+	index.SetPatch(PatchEntry{From: "1.2.0", To: "1.9.0"})
+	index.SetPatch(PatchEntry{From: "1.9.0", To: "1.10.0", Compression: "zstd"})
+
+	if err := index.Save("test"); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var versions []string
+	for _, v := range loaded.Versions {
+		versions = append(versions, v.Version+"="+v.KeyFile.SHA256)
+	}
+	if want := []string{"1.2.0=replaced", "1.9.0=1.9.0", "1.10.0=1.10.0"}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("versions = %v, want %v", versions, want)
+	}
+
+	var patches []string
+	for _, p := range loaded.Patches {
+		patches = append(patches, p.From+"->"+p.To)
+	}
+	if want := []string{"1.2.0->1.9.0", "1.2.0->1.10.0", "1.9.0->1.10.0"}; !reflect.DeepEqual(patches, want) {
+		t.Errorf("patches = %v, want %v", patches, want)
+	}
+	if last := loaded.Patches[2]; last.Compression != "zstd" || last.ReleaseNotes != "notes" {
+		t.Errorf("replaced patch = %+v, want the new entry with the earlier release notes", last)
+	}
+	if loaded.Generator != "test" {
+		t.Errorf("Generator = %q, want %q", loaded.Generator, "test")
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("Save() left its temporary file behind")
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "newer format version", data: `{"format_version": 99}`},
+		{name: "not JSON", data: `index`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, t.TempDir(), FileName, []byte(tt.data))
+			if _, err := Load(path); err == nil {
+				t.Error("Load() accepted the index")
+			}
+		})
+	}
+}
+
+func TestDescribeParts(t *testing.T) {
+	dir := t.TempDir()
+	index, err := Load(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	single := writeFile(t, dir, "1.0.0-to-1.1.0.patch", []byte("single"))
+
+	// Part 1 is split into chunks listed by its sidecar, part 2 is a plain file
+	writeFile(t, dir, "1.1.0-to-1.2.0.01.patch", []byte("stub"))
+	writeFile(t, dir, "c2.bin", []byte("chunk two"))
+	writeFile(t, dir, "c1.bin", []byte("chunk1"))
+	sidecar, _ := json.Marshal(map[string][]utils.PartChunk{"chunks": {
+		{PartNumber: 1, ChunkNumber: 2, FileName: "c2.bin"},
+		{PartNumber: 1, ChunkNumber: 1, FileName: "c1.bin"},
+	}})
+	writeFile(t, dir, "1.1.0-to-1.2.0.part1.chunks.json", sidecar)
+	writeFile(t, dir, "1.1.0-to-1.2.0.02.patch", []byte("part two"))
+	writeFile(t, dir, "1.1.0-to-1.2.0.04.patch", []byte("after a gap"))
+
+	tests := []struct {
+		name      string
+		patchPath string
+		want      [][]string // File name, chunk list and chunk names of each part
+		wantTotal int64
+	}{
+		{
+			name:      "single file",
+			patchPath: single,
+			want:      [][]string{{"1.0.0-to-1.1.0.patch"}},
+			wantTotal: 6,
+		},
+		{
+			name:      "multi-part with chunks",
+			patchPath: filepath.Join(dir, "1.1.0-to-1.2.0.01.patch"),
+			want: [][]string{
+				{"1.1.0-to-1.2.0.01.patch", "1.1.0-to-1.2.0.part1.chunks.json", "c1.bin", "c2.bin"},
+				{"1.1.0-to-1.2.0.02.patch"},
+			},
+			wantTotal: 4 + 6 + 9 + 8,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts, total, err := index.DescribeParts(tt.patchPath)
+			if err != nil {
+				t.Fatalf("DescribeParts() error = %v", err)
+			}
+
+			var got [][]string
+			for n, part := range parts {
+				if part.Number != n+1 {
+					t.Errorf("part %d has number %d", n+1, part.Number)
+				}
+				var names []string
+				if part.File != nil {
+					names = append(names, part.File.Name)
+				}
+				if part.ChunkList != nil {
+					names = append(names, part.ChunkList.Name)
+				}
+				for _, chunk := range part.Chunks {
+					names = append(names, chunk.Name)
+				}
+				got = append(got, names)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DescribeParts() = %v, want %v", got, tt.want)
+			}
+			if total != tt.wantTotal {
+				t.Errorf("total size = %d, want %d", total, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestDescribeFile(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "patches")
+	if err := os.MkdirAll(filepath.Join(dir, "exe"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	index, err := Load(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := index.DescribeFile(writeFile(t, filepath.Join(dir, "exe"), "app.exe", []byte("abc")))
+	if err != nil {
+		t.Fatalf("DescribeFile() error = %v", err)
+	}
+	want := File{Name: "exe/app.exe", Size: 3, SHA256: "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"}
+	if file != want {
+		t.Errorf("DescribeFile() = %+v, want %+v", file, want)
+	}
+
+	if _, err := index.DescribeFile(writeFile(t, root, "outside.patch", []byte("x"))); err == nil {
+		t.Error("DescribeFile() accepted a file outside the index directory")
+	}
+}
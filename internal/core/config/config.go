@@ -157,6 +157,24 @@ func GetDefaultManifestPath() string {
 	return filepath.Join(filepath.Dir(configPath), "manifests")
 }
 
+// GetDefaultHistoryPath returns the path of the applier's install history file
+func GetDefaultHistoryPath() string {
+	configPath := GetDefaultConfigPath()
+	return filepath.Join(filepath.Dir(configPath), "install_history.json")
+}
+
+// ResolveWorkerCount converts a --jobs flag into a worker count
+// 0 = auto-detect CPU cores, 1 = single-threaded
+func ResolveWorkerCount(jobs int) int {
+	if jobs == 0 {
+		jobs = runtime.NumCPU()
+	}
+	if jobs < 1 {
+		jobs = 1
+	}
+	return jobs
+}
+
 // getDefaultConfig returns the default configuration
 func getDefaultConfig() *utils.Config {
 	var tempDir string
@@ -0,0 +1,465 @@
+// Package download fetches files over HTTP with resume support. Incomplete downloads are kept
+// as <file>.partial and continued with Range requests; failed requests are retried with backoff,
+// and every file is checked against its expected size and SHA-256 before it is renamed into place.
+package download
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PartialSuffix is appended to the name of a file while it is being downloaded
+const PartialSuffix = ".partial"
+
+// progressInterval limits how often progress events are sent for one file
+const progressInterval = 250 * time.Millisecond
+
+// Request describes a file to download
+type Request struct {
+	URL    string // Source URL
+	Path   string // Destination file
+	Name   string // Name shown in progress events (default: the base name of Path)
+	Size   int64  // Expected size in bytes (0 = unknown)
+	SHA256 string // Expected SHA-256 checksum ("" = not verified)
+}
+
+// name returns the display name of the request
+func (r Request) name() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return filepath.Base(r.Path)
+}
+
+// StatusError is returned when the server answers with an unexpected HTTP status
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+// Error implements error
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("failed to download %s: %s", Redact(e.URL), e.Status)
+}
+
+// IsNotFound reports whether err is a 404 Not Found response
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound
+}
+
+// permanentError marks a failure that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+// Error implements error
+func (e *permanentError) Error() string { return e.err.Error() }
+
+// Unwrap returns the underlying error
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Downloader fetches files with resume, retries and verification
+type Downloader struct {
+	client     *http.Client
+	userAgent  string
+	retries    int           // Retries after the first attempt
+	backoff    time.Duration // Delay before the first retry; doubled for each further retry
+	maxBackoff time.Duration // Longest delay between retries
+	parallel   int           // Files fetched at the same time by FetchAll
+	progress   func(Event)
+	progressMu sync.Mutex // Progress callbacks are never called concurrently
+}
+
+// NewDownloader creates a downloader that sends requests with client
+func NewDownloader(client *http.Client) *Downloader {
+	return &Downloader{
+		client:     client,
+		retries:    5,
+		backoff:    time.Second,
+		maxBackoff: 30 * time.Second,
+		parallel:   4,
+	}
+}
+
+// SetHTTPClient replaces the HTTP client
+func (d *Downloader) SetHTTPClient(client *http.Client) {
+	d.client = client
+}
+
+// SetUserAgent sets the User-Agent header sent with every request
+func (d *Downloader) SetUserAgent(userAgent string) {
+	d.userAgent = userAgent
+}
+
+// SetRetries sets how many times a failed download is retried
+func (d *Downloader) SetRetries(retries int) {
+	if retries < 0 {
+		retries = 0
+	}
+	d.retries = retries
+}
+
+// SetBackoff sets the delay before the first retry and the longest delay between retries
+func (d *Downloader) SetBackoff(initial, max time.Duration) {
+	d.backoff = initial
+	d.maxBackoff = max
+}
+
+// SetParallel sets how many files FetchAll downloads at the same time
+func (d *Downloader) SetParallel(parallel int) {
+	if parallel < 1 {
+		parallel = 1
+	}
+	d.parallel = parallel
+}
+
+// SetProgress sets a function that receives progress events
+func (d *Downloader) SetProgress(progress func(Event)) {
+	d.progress = progress
+}
+
+// emit sends a progress event
+func (d *Downloader) emit(event Event) {
+	if d.progress == nil {
+		return
+	}
+	d.progressMu.Lock()
+	defer d.progressMu.Unlock()
+	d.progress(event)
+}
+
+// Fetch downloads a file, resuming an earlier partial download and retrying failed attempts.
+// A file that is already in place with the expected size and checksum is not downloaded again.
+func (d *Downloader) Fetch(ctx context.Context, req Request) error {
+	if req.SHA256 != "" && fileMatches(req.Path, req.Size, req.SHA256) {
+		d.emit(Event{Kind: EventSkipped, Name: req.name(), Downloaded: req.Size, Total: req.Size, Verified: true})
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(req.Path), 0755); err != nil {
+		return fmt.Errorf("failed to create download directory: %w", err)
+	}
+
+	for attempt := 1; ; attempt++ {
+		size, err := d.attempt(ctx, req, attempt)
+		if err == nil {
+			d.emit(Event{Kind: EventCompleted, Name: req.name(), Downloaded: size, Total: req.Size, Attempt: attempt,
+				Verified: req.SHA256 != ""})
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !retryable(err) || attempt > d.retries {
+			d.emit(Event{Kind: EventFailed, Name: req.name(), Total: req.Size, Attempt: attempt, Err: err})
+			return err
+		}
+
+		wait := d.retryDelay(attempt)
+		d.emit(Event{Kind: EventRetrying, Name: req.name(), Total: req.Size, Attempt: attempt, Wait: wait, Err: err})
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// retryDelay returns the exponential backoff before retry number attempt, with up to 20% jitter
+// so parallel downloads don't retry in lockstep
+func (d *Downloader) retryDelay(attempt int) time.Duration {
+	wait := d.backoff
+	for i := 1; i < attempt && wait < d.maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.maxBackoff {
+		wait = d.maxBackoff
+	}
+	if wait > 0 {
+		wait += time.Duration(rand.Int64N(int64(wait)/5 + 1))
+	}
+	return wait
+}
+
+// retryable reports whether a failed attempt may succeed when repeated
+func retryable(err error) bool {
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		code := statusErr.StatusCode
+		return code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests
+	}
+	// Network errors, truncated responses and checksum mismatches
+	return true
+}
+
+// attempt makes one request, continuing the partial file if there is one, and renames the
+// partial file into place once it is complete and verified. Returns the size of the file.
+func (d *Downloader) attempt(ctx context.Context, req Request, attempt int) (int64, error) {
+	partialPath := req.Path + PartialSuffix
+	offset := int64(0)
+	if info, err := os.Stat(partialPath); err == nil && !info.IsDir() {
+		offset = info.Size()
+	}
+	if req.Size > 0 && offset > req.Size {
+		// Longer than the file can be; start over
+		offset = 0
+	}
+
+	// Hash what is already on disk so the final checksum covers the whole file
+	hasher := sha256.New()
+	if offset > 0 {
+		if err := hashPrefix(partialPath, offset, hasher); err != nil {
+			offset = 0
+			hasher.Reset()
+		}
+	}
+
+	if req.Size == 0 || offset < req.Size {
+		var err error
+		offset, err = d.download(ctx, req, partialPath, offset, hasher, attempt)
+		if err != nil {
+			return offset, err
+		}
+	}
+	return offset, finish(req, partialPath, offset, hasher)
+}
+
+// download requests the file from offset and appends the response to the partial file.
+// Returns the size of the partial file afterwards.
+func (d *Downloader) download(ctx context.Context, req Request, partialPath string, offset int64, hasher hash.Hash, attempt int) (int64, error) {
+	displayURL := Redact(req.URL)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL, nil)
+	if err != nil {
+		return 0, &permanentError{fmt.Errorf("failed to create request for %s: %w", displayURL, err)}
+	}
+	if d.userAgent != "" {
+		httpReq.Header.Set("User-Agent", d.userAgent)
+	}
+	if offset > 0 {
+		httpReq.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := d.client.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to download %s: %w", displayURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != offset {
+			// The server sent a different range than requested; discard the partial file
+			os.Remove(partialPath)
+			return 0, fmt.Errorf("failed to resume %s: server returned range %q", displayURL, resp.Header.Get("Content-Range"))
+		}
+	case resp.StatusCode == http.StatusOK:
+		// No range support (or nothing to resume): start from the beginning
+		offset = 0
+		hasher.Reset()
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// The partial file doesn't fit the file on the server (e.g. it was replaced); start over
+		os.Remove(partialPath)
+		return 0, fmt.Errorf("failed to resume %s: %s", displayURL, resp.Status)
+	default:
+		return 0, &StatusError{URL: req.URL, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE
+	if offset == 0 {
+		flags |= os.O_TRUNC
+	}
+	out, err := os.OpenFile(partialPath, flags, 0644)
+	if err != nil {
+		return 0, &permanentError{fmt.Errorf("failed to open %s: %w", partialPath, err)}
+	}
+	if _, err := out.Seek(offset, io.SeekStart); err != nil {
+		out.Close()
+		return 0, &permanentError{fmt.Errorf("failed to open %s: %w", partialPath, err)}
+	}
+	if err := out.Truncate(offset); err != nil {
+		out.Close()
+		return 0, &permanentError{fmt.Errorf("failed to open %s: %w", partialPath, err)}
+	}
+
+	d.emit(Event{Kind: EventStarted, Name: req.name(), Downloaded: offset, Total: req.Size, Resumed: offset, Attempt: attempt})
+
+	body := io.Reader(resp.Body)
+	if req.Size > 0 {
+		// Read one byte past the expected size so an oversized response is detected without reading all of it
+		body = io.LimitReader(resp.Body, req.Size-offset+1)
+	}
+	counter := &progressWriter{downloader: d, name: req.name(), done: offset, total: req.Size}
+	written, copyErr := io.Copy(io.MultiWriter(out, hasher, counter), body)
+	closeErr := out.Close()
+	offset += written
+	if copyErr != nil {
+		return offset, fmt.Errorf("failed to download %s: %w", displayURL, copyErr)
+	}
+	if closeErr != nil {
+		return offset, &permanentError{fmt.Errorf("failed to write %s: %w", partialPath, closeErr)}
+	}
+	return offset, nil
+}
+
+// finish checks the size and checksum of a complete partial file and renames it into place.
+// A partial file that fails the checks is deleted so the next attempt starts over.
+func finish(req Request, partialPath string, size int64, hasher hash.Hash) error {
+	if req.Size > 0 && size != req.Size {
+		if size > req.Size {
+			os.Remove(partialPath)
+		}
+		return fmt.Errorf("download of %s is the wrong size: expected %d bytes, got %d", req.name(), req.Size, size)
+	}
+	if req.SHA256 != "" {
+		if actual := fmt.Sprintf("%x", hasher.Sum(nil)); actual != req.SHA256 {
+			os.Remove(partialPath)
+			return fmt.Errorf("download of %s failed SHA-256 verification: expected %s, got %s",
+				req.name(), shortHash(req.SHA256), shortHash(actual))
+		}
+	}
+	if err := os.Rename(partialPath, req.Path); err != nil {
+		return &permanentError{fmt.Errorf("failed to save %s: %w", req.Path, err)}
+	}
+	return nil
+}
+
+// FetchAll downloads files in parallel (see SetParallel). The first error cancels the
+// remaining downloads and is returned; partial files are kept so a later run can resume.
+func (d *Downloader) FetchAll(ctx context.Context, reqs []Request) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan Request)
+	var wg sync.WaitGroup
+	var errOnce sync.Once
+	var firstErr error
+
+	for w := 0; w < d.parallel && w < len(reqs); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for req := range jobs {
+				if err := d.Fetch(ctx, req); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+	for _, req := range reqs {
+		select {
+		case jobs <- req:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// progressWriter counts downloaded bytes and sends throttled progress events
+type progressWriter struct {
+	downloader *Downloader
+	name       string
+	done       int64
+	total      int64
+	lastEvent  time.Time
+}
+
+// Write implements io.Writer
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.done += int64(len(p))
+	if now := time.Now(); now.Sub(w.lastEvent) >= progressInterval {
+		w.lastEvent = now
+		w.downloader.emit(Event{Kind: EventProgress, Name: w.name, Downloaded: w.done, Total: w.total})
+	}
+	return len(p), nil
+}
+
+// contentRangeStart parses the first byte position of a "bytes start-end/size" Content-Range header
+func contentRangeStart(header string) (int64, bool) {
+	rest, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	startText, _, ok := strings.Cut(rest, "-")
+	if !ok {
+		return 0, false
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(startText), 10, 64)
+	return start, err == nil
+}
+
+// hashPrefix feeds the first n bytes of a file into hasher
+func hashPrefix(path string, n int64, hasher hash.Hash) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.CopyN(hasher, f, n)
+	return err
+}
+
+// fileMatches reports whether path exists with the given size (if known) and SHA-256
+func fileMatches(path string, size int64, checksum string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || (size > 0 && info.Size() != size) {
+		return false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return false
+	}
+	return fmt.Sprintf("%x", hasher.Sum(nil)) == checksum
+}
+
+// Redact returns rawURL with any password replaced by "xxxxx", for messages
+func Redact(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return u.Redacted()
+}
+
+// shortHash abbreviates a hash for error messages
+func shortHash(hash string) string {
+	if len(hash) > 16 {
+		return hash[:16] + "..."
+	}
+	return hash
+}
@@ -0,0 +1,118 @@
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// FetchPatch downloads the patch at patchURL into dir and returns the path of the downloaded file.
+// For a multi-part patch (part 1 ending in .01.patch), the remaining parts are downloaded in parallel
+// from the same directory: each .NN.patch is checked against the part hashes recorded in part 1,
+// and each chunk of a chunked part against the checksum in its .partN.chunks.json sidecar.
+func (d *Downloader) FetchPatch(ctx context.Context, patchURL, dir string) (string, error) {
+	u, err := url.Parse(patchURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid patch URL: %w", err)
+	}
+	name := path.Base(u.Path)
+	if !strings.HasSuffix(name, ".patch") {
+		return "", fmt.Errorf("invalid patch URL %q: the file name must end in .patch", u.Redacted())
+	}
+
+	part1Path := filepath.Join(dir, name)
+	if err := d.Fetch(ctx, Request{URL: u.String(), Path: part1Path}); err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(name, ".01.patch") {
+		return part1Path, nil
+	}
+
+	part1, err := utils.LoadPatch(part1Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to load part 1: %w", err)
+	}
+	if part1.MultiPart == nil || !part1.MultiPart.IsMultiPart {
+		return part1Path, nil
+	}
+	if len(part1.MultiPart.PartHashes) != part1.MultiPart.TotalParts {
+		return "", fmt.Errorf("part 1 missing hash information for all parts")
+	}
+
+	baseName := strings.TrimSuffix(name, ".01.patch")
+	var reqs []Request
+	for n := 2; n <= part1.MultiPart.TotalParts; n++ {
+		partReqs, err := d.partRequests(ctx, u, dir, baseName, n, part1.MultiPart.PartHashes[n-1])
+		if err != nil {
+			return "", err
+		}
+		reqs = append(reqs, partReqs...)
+	}
+	if err := d.FetchAll(ctx, reqs); err != nil {
+		return "", err
+	}
+	return part1Path, nil
+}
+
+// partRequests returns the downloads for part n: its chunks if the server has a chunk sidecar
+// for it, otherwise the .NN.patch file
+func (d *Downloader) partRequests(ctx context.Context, part1URL *url.URL, dir, baseName string, n int, partHash utils.PartHash) ([]Request, error) {
+	sidecarName := fmt.Sprintf("%s.part%d.chunks.json", baseName, n)
+	sidecarPath := filepath.Join(dir, sidecarName)
+	err := d.Fetch(ctx, Request{URL: siblingURL(part1URL, sidecarName), Path: sidecarPath})
+	if IsNotFound(err) {
+		partName := fmt.Sprintf("%s.%02d.patch", baseName, n)
+		return []Request{{
+			URL:    siblingURL(part1URL, partName),
+			Path:   filepath.Join(dir, partName),
+			Size:   partHash.Size,
+			SHA256: partHash.Checksum,
+		}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(sidecarPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk sidecar for part %d: %w", n, err)
+	}
+	var sidecar struct {
+		Chunks []utils.PartChunk `json:"chunks"`
+	}
+	if err := json.Unmarshal(data, &sidecar); err != nil {
+		return nil, fmt.Errorf("failed to parse chunk sidecar for part %d: %w", n, err)
+	}
+
+	var reqs []Request
+	var total int64
+	for _, chunk := range sidecar.Chunks {
+		if chunk.FileName != path.Base(chunk.FileName) || strings.ContainsAny(chunk.FileName, `/\:`) {
+			return nil, fmt.Errorf("chunk sidecar for part %d lists an invalid file name %q", n, chunk.FileName)
+		}
+		reqs = append(reqs, Request{
+			URL:    siblingURL(part1URL, chunk.FileName),
+			Path:   filepath.Join(dir, chunk.FileName),
+			Size:   chunk.Size,
+			SHA256: chunk.Checksum,
+		})
+		total += chunk.Size
+	}
+	// The chunks together must make up the part recorded in part 1
+	if total != partHash.Size {
+		return nil, fmt.Errorf("chunk sidecar for part %d lists %d bytes, but part 1 records %d", n, total, partHash.Size)
+	}
+	return reqs, nil
+}
+
+// siblingURL returns the URL of a file in the same directory as u
+func siblingURL(u *url.URL, name string) string {
+	return u.ResolveReference(&url.URL{Path: name}).String()
+}
@@ -0,0 +1,91 @@
+package download
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// EventKind identifies what happened to a download
+type EventKind int
+
+const (
+	EventStarted   EventKind = iota // A request was sent; Resumed > 0 if it continues a partial file
+	EventProgress                   // More data arrived (sent at most every 250ms per file)
+	EventRetrying                   // An attempt failed and will be retried after Wait
+	EventCompleted                  // The file was downloaded and verified
+	EventSkipped                    // The file was already downloaded and verified
+	EventFailed                     // The download failed and will not be retried
+)
+
+// Event reports the progress of one file. Events are never delivered concurrently.
+type Event struct {
+	Kind       EventKind
+	Name       string        // Display name of the file
+	Downloaded int64         // Bytes of the file on disk so far
+	Total      int64         // Expected size (0 if unknown)
+	Resumed    int64         // Bytes kept from an earlier partial download (EventStarted)
+	Attempt    int           // Attempt number, starting at 1
+	Wait       time.Duration // Delay before the next attempt (EventRetrying)
+	Err        error         // Cause of EventRetrying and EventFailed
+	Verified   bool          // The file's SHA-256 was checked (EventCompleted, EventSkipped)
+}
+
+// Percent returns the downloaded percentage, or -1 if the size is unknown
+func (e Event) Percent() int {
+	if e.Total <= 0 {
+		return -1
+	}
+	return int(e.Downloaded * 100 / e.Total)
+}
+
+// consoleStep is the percentage between progress lines for one file
+const consoleStep = 10
+
+// NewConsoleProgress returns a progress function that prints one line per event to w.
+// Progress lines are printed every 10% per file, so parallel downloads stay readable.
+func NewConsoleProgress(w io.Writer) func(Event) {
+	printed := make(map[string]int) // File → last percentage printed
+	return func(e Event) {
+		switch e.Kind {
+		case EventStarted:
+			printed[e.Name] = 0
+			if e.Resumed > 0 {
+				fmt.Fprintf(w, "  ↓ %s: resuming at %s of %s\n", e.Name, utils.FormatBytes(e.Resumed), formatTotal(e.Total))
+			} else {
+				fmt.Fprintf(w, "  ↓ %s (%s)\n", e.Name, formatTotal(e.Total))
+			}
+		case EventProgress:
+			percent := e.Percent()
+			if percent < 0 || percent-printed[e.Name] < consoleStep || percent >= 100 {
+				return
+			}
+			printed[e.Name] = percent - percent%consoleStep
+			fmt.Fprintf(w, "    %s: %d%% (%s of %s)\n", e.Name, printed[e.Name], utils.FormatBytes(e.Downloaded), utils.FormatBytes(e.Total))
+		case EventRetrying:
+			fmt.Fprintf(w, "  ! %s: %v; retrying in %s (attempt %d)\n", e.Name, e.Err, e.Wait.Round(100*time.Millisecond), e.Attempt+1)
+		case EventCompleted:
+			delete(printed, e.Name)
+			if e.Verified {
+				fmt.Fprintf(w, "  ✓ %s verified\n", e.Name)
+			} else {
+				fmt.Fprintf(w, "  ✓ %s downloaded\n", e.Name)
+			}
+		case EventSkipped:
+			fmt.Fprintf(w, "  ✓ %s already downloaded\n", e.Name)
+		case EventFailed:
+			delete(printed, e.Name)
+			fmt.Fprintf(w, "  ✗ %s: %v\n", e.Name, e.Err)
+		}
+	}
+}
+
+// formatTotal formats an expected size that may be unknown
+func formatTotal(total int64) string {
+	if total <= 0 {
+		return "size unknown"
+	}
+	return utils.FormatBytes(total)
+}
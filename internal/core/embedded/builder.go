@@ -0,0 +1,188 @@
+package embedded
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// BuildOptions describes the contents of a self-contained executable
+type BuildOptions struct {
+	StubPath    string             // Applier executable the patch is appended to
+	PatchPath   string             // Patch file to embed (part 01 for multi-part patches)
+	Sidecars    []string           // Files stored in the sidecar blob, by base name (chunk sidecars, remaining parts)
+	Compression string             // Compression name recorded in the header
+	Silent      bool               // Apply without prompts when the executable runs
+	Metadata    Metadata           // Versions, stub version and flags; checksums and signature are filled in by Build
+	SigningKey  ed25519.PrivateKey // Key used to sign the metadata (nil = unsigned)
+}
+
+// Build writes a self-contained executable to exePath.
+// The stub, patch and sidecars are streamed, so memory use does not depend on the patch size.
+func Build(exePath string, opts BuildOptions) error {
+	stub, err := os.Open(opts.StubPath)
+	if err != nil {
+		return fmt.Errorf("failed to open applier executable: %w", err)
+	}
+	defer stub.Close()
+
+	patchFile, err := os.Open(opts.PatchPath)
+	if err != nil {
+		return fmt.Errorf("failed to open patch file: %w", err)
+	}
+	defer patchFile.Close()
+
+	outFile, err := os.Create(exePath)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer outFile.Close()
+
+	out := bufio.NewWriterSize(outFile, 1024*1024)
+
+	// Write: applier stub + patch data + optional sidecar blob + metadata + header
+	stubSize, err := io.Copy(out, stub)
+	if err != nil {
+		return fmt.Errorf("failed to write applier data: %w", err)
+	}
+
+	hasher := sha256.New()
+	dataSize, err := io.Copy(io.MultiWriter(out, hasher), patchFile)
+	if err != nil {
+		return fmt.Errorf("failed to write patch data: %w", err)
+	}
+
+	meta := opts.Metadata
+	if len(opts.Sidecars) > 0 {
+		sidecarHasher := sha256.New()
+		if err := writeSidecarBlob(io.MultiWriter(out, sidecarHasher), opts.Sidecars); err != nil {
+			return err
+		}
+		meta.SidecarChecksum = fmt.Sprintf("%x", sidecarHasher.Sum(nil))
+	}
+
+	header := Header{
+		Version:    FormatVersion,
+		StubSize:   uint64(stubSize),
+		DataOffset: uint64(stubSize),
+		DataSize:   uint64(dataSize),
+	}
+	copy(header.Magic[:], Magic)
+	copy(header.Compression[:], opts.Compression)
+	copy(header.Checksum[:], hasher.Sum(nil))
+	if opts.Silent {
+		header.Flags |= FlagSilent
+		meta.Flags |= MetadataSilent
+	}
+
+	metaSize, err := writeMetadata(out, &meta, header.Checksum, opts.SigningKey)
+	if err != nil {
+		return err
+	}
+	header.MetadataSize = metaSize
+	if _, err := out.Write(header.encode()); err != nil {
+		return fmt.Errorf("failed to write header: %w", err)
+	}
+
+	if err := out.Flush(); err != nil {
+		return fmt.Errorf("failed to write executable: %w", err)
+	}
+
+	// Keep the stub's permissions so Linux/macOS executables get the exec bit
+	if stubInfo, err := stub.Stat(); err == nil {
+		if err := outFile.Chmod(stubInfo.Mode().Perm() | 0755); err != nil {
+			return fmt.Errorf("failed to set executable permissions: %w", err)
+		}
+	}
+
+	return outFile.Close()
+}
+
+// writeMetadata signs the metadata if a key is given and writes the length-prefixed metadata section.
+// Returns the size of the section.
+func writeMetadata(w io.Writer, meta *Metadata, patchChecksum [32]byte, key ed25519.PrivateKey) (uint32, error) {
+	meta.Signature = nil
+	meta.SignerKeyID = ""
+	meta.SignerKey = nil
+	if key != nil {
+		publicKey := key.Public().(ed25519.PublicKey)
+		meta.SignerKeyID = utils.KeyID(publicKey)
+		meta.SignerKey = publicKey
+		digest, err := meta.Digest(patchChecksum)
+		if err != nil {
+			return 0, err
+		}
+		meta.Signature = ed25519.Sign(key, digest)
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(data))); err != nil {
+		return 0, fmt.Errorf("failed to write metadata: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return 0, fmt.Errorf("failed to write metadata: %w", err)
+	}
+	return uint32(4 + len(data)), nil
+}
+
+// writeSidecarBlob streams the sidecar files as count-prefixed name/length/data records
+func writeSidecarBlob(w io.Writer, paths []string) error {
+	if err := binary.Write(w, binary.LittleEndian, uint32(len(paths))); err != nil {
+		return fmt.Errorf("failed to write sidecar blob: %w", err)
+	}
+
+	for _, path := range paths {
+		if err := writeSidecarRecord(w, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSidecarRecord writes one sidecar file record
+func writeSidecarRecord(w io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read sidecar %s: %w", path, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read sidecar %s: %w", path, err)
+	}
+
+	name := filepath.Base(path)
+	if len(name) > 0xFFFF {
+		return fmt.Errorf("sidecar name too long: %s", name)
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint16(len(name))); err != nil {
+		return fmt.Errorf("failed to write sidecar %s: %w", name, err)
+	}
+	if _, err := io.WriteString(w, name); err != nil {
+		return fmt.Errorf("failed to write sidecar %s: %w", name, err)
+	}
+	if err := binary.Write(w, binary.LittleEndian, uint64(info.Size())); err != nil {
+		return fmt.Errorf("failed to write sidecar %s: %w", name, err)
+	}
+
+	written, err := io.Copy(w, file)
+	if err != nil {
+		return fmt.Errorf("failed to write sidecar %s: %w", name, err)
+	}
+	if written != info.Size() {
+		return fmt.Errorf("sidecar %s changed while it was being embedded", name)
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package embedded
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFile writes data to name in dir and returns its path
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBuildAndOpen(t *testing.T) {
+	tests := []struct {
+		name        string
+		patch       []byte
+		sidecars    map[string][]byte
+		compression string
+		silent      bool
+	}{
+		{name: "patch only", patch: []byte("patch data"), compression: "zstd"},
+		{name: "silent", patch: []byte("patch data"), compression: "none", silent: true},
+		{name: "empty patch", patch: []byte{}, compression: "gzip"},
+		{
+			name:        "with sidecars",
+			patch:       bytes.Repeat([]byte("p"), 5000),
+			sidecars:    map[string][]byte{"a.part1.chunks.json": []byte(`{"chunks":[]}`), "a.02.patch": bytes.Repeat([]byte("x"), 3000)},
+			compression: "zstd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			stub := []byte("#!stub applier executable\n")
+			opts := BuildOptions{
+				StubPath:    writeFile(t, dir, "stub", stub),
+				PatchPath:   writeFile(t, dir, "in.patch", tt.patch),
+				Compression: tt.compression,
+				Silent:      tt.silent,
+			}
+			for name, data := range tt.sidecars {
+				opts.Sidecars = append(opts.Sidecars, writeFile(t, dir, name, data))
+			}
+
+			exePath := filepath.Join(dir, "out.exe")
+			if err := Build(exePath, opts); err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+
+			exe, err := Open(exePath)
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer exe.Close()
+
+			if exe.Header.Version != FormatVersion {
+				t.Errorf("Version = %d, want %d", exe.Header.Version, FormatVersion)
+			}
+			if exe.Header.StubSize != uint64(len(stub)) || exe.Header.DataSize != uint64(len(tt.patch)) {
+				t.Errorf("StubSize, DataSize = %d, %d, want %d, %d", exe.Header.StubSize, exe.Header.DataSize, len(stub), len(tt.patch))
+			}
+			if got := exe.Header.CompressionName(); got != tt.compression {
+				t.Errorf("CompressionName() = %q, want %q", got, tt.compression)
+			}
+			if got := exe.Header.Silent(); got != tt.silent {
+				t.Errorf("Silent() = %t, want %t", got, tt.silent)
+			}
+			if err := exe.VerifyChecksum(); err != nil {
+				t.Errorf("VerifyChecksum() error = %v", err)
+			}
+			if got, _ := io.ReadAll(exe.PatchData()); !bytes.Equal(got, tt.patch) {
+				t.Errorf("PatchData() = %d bytes, want the %d embedded bytes", len(got), len(tt.patch))
+			}
+
+			sidecars, err := exe.Sidecars()
+			if err != nil {
+				t.Fatalf("Sidecars() error = %v", err)
+			}
+			if len(sidecars) != len(tt.sidecars) {
+				t.Fatalf("Sidecars() returned %d files, want %d", len(sidecars), len(tt.sidecars))
+			}
+			for _, sidecar := range sidecars {
+				got, _ := io.ReadAll(sidecar.Data)
+				if want, ok := tt.sidecars[sidecar.Name]; !ok || !bytes.Equal(got, want) {
+					t.Errorf("sidecar %s does not match the file it was built from", sidecar.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenNotEmbedded(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "shorter than a header", data: []byte("plain executable")},
+		{name: "no magic", data: bytes.Repeat([]byte{0x90}, 4096)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeFile(t, t.TempDir(), "plain.exe", tt.data)
+			if _, err := Open(path); !errors.Is(err, ErrNotEmbedded) {
+				t.Errorf("Open() error = %v, want ErrNotEmbedded", err)
+			}
+		})
+	}
+}
+
+func TestVerifyChecksumDetectsCorruption(t *testing.T) {
+	dir := t.TempDir()
+	exePath := filepath.Join(dir, "out.exe")
+	err := Build(exePath, BuildOptions{
+		StubPath:    writeFile(t, dir, "stub", []byte("stub")),
+		PatchPath:   writeFile(t, dir, "in.patch", []byte("patch data")),
+		Compression: "none",
+	})
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+
+	// Flip a byte of the patch data, which starts right after the 4-byte stub
+	data, err := os.ReadFile(exePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[4] ^= 0xff
+	if err := os.WriteFile(exePath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	exe, err := Open(exePath)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer exe.Close()
+	if err := exe.VerifyChecksum(); err == nil {
+		t.Error("VerifyChecksum() accepted corrupted patch data")
+	}
+}
@@ -0,0 +1,115 @@
+// Package embedded builds and reads self-contained patch executables: an applier stub
+// followed by the patch data, an optional sidecar blob, a metadata section and a fixed-size trailer.
+//
+// Layout:
+//
+//	[applier stub][patch data][sidecar blob (optional)][metadata (v2)][128-byte header]
+//
+// The sidecar blob is the table of contents of the files that travel with the patch
+// (chunk sidecars and, optionally, the remaining parts of a multi-part patch and their chunks).
+// It is a uint32 record count followed by records of uint16 name length, name,
+// uint64 data length and data (all little-endian). Records are read in place, never copied out.
+//
+// The metadata section (format version 2) is a uint32 length followed by a JSON Metadata object.
+// Version 1 executables have no metadata section and are still read.
+package embedded
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	// Magic identifies a self-contained executable trailer
+	Magic = "CPMPATCH"
+	// HeaderSize is the size of the trailer at the end of the executable
+	HeaderSize = 128
+	// FormatVersion is the trailer version written by Build
+	FormatVersion = 2
+	// FormatVersionV1 is the original trailer without a metadata section
+	FormatVersionV1 = 1
+
+	// FlagSilent makes the executable apply the patch without prompts
+	FlagSilent byte = 0x01
+
+	// sidecarRecordMinSize is the smallest sidecar blob record: its name and data lengths (uint16, uint64)
+	sidecarRecordMinSize = 2 + 8
+)
+
+// ErrNotEmbedded is returned by Open when the file has no embedded patch
+var ErrNotEmbedded = errors.New("no embedded patch found")
+
+// Header is the fixed-size trailer at the end of a self-contained executable
+type Header struct {
+	Magic        [8]byte
+	Version      uint32
+	StubSize     uint64   // Size of the applier stub
+	DataOffset   uint64   // Offset of the patch data (always equal to StubSize)
+	DataSize     uint64   // Size of the patch data
+	Compression  [16]byte // Compression name, zero-padded
+	Checksum     [32]byte // SHA-256 of the patch data
+	Flags        byte     // Bit 0: silent mode
+	MetadataSize uint32   // Size of the metadata section including its length prefix (v2; zero in v1)
+	Reserved     [39]byte
+}
+
+// CompressionName returns the compression recorded in the header
+func (h *Header) CompressionName() string {
+	return string(bytes.TrimRight(h.Compression[:], "\x00"))
+}
+
+// Silent reports whether the executable was built in silent mode
+func (h *Header) Silent() bool {
+	return h.Flags&FlagSilent != 0
+}
+
+// encode serializes the header into its on-disk form
+func (h *Header) encode() []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, h)
+	return buf.Bytes()
+}
+
+// Metadata flags
+const (
+	MetadataSilent    uint32 = 1 << 0 // Apply without prompts
+	MetadataMultiPart uint32 = 1 << 1 // The patch has more than one part
+	MetadataAllParts  uint32 = 1 << 2 // Every part is embedded in the executable
+)
+
+// Metadata describes a self-contained executable without decoding its patch
+type Metadata struct {
+	FromVersion     string `json:"from_version"`
+	ToVersion       string `json:"to_version"`
+	StubVersion     string `json:"stub_version"`               // Version of the applier the executable was built from
+	Flags           uint32 `json:"flags"`                      // Metadata* bitfield
+	SidecarChecksum string `json:"sidecar_checksum,omitempty"` // SHA-256 of the sidecar blob
+	SignerKeyID     string `json:"signer_key_id,omitempty"`
+	SignerKey       []byte `json:"signer_key,omitempty"` // Public key of the signer, for integrity checks without --trust-key
+	Signature       []byte `json:"signature,omitempty"`  // ed25519 signature of Digest
+}
+
+// HasFlag reports whether a metadata flag is set
+func (m *Metadata) HasFlag(flag uint32) bool {
+	return m.Flags&flag != 0
+}
+
+// Digest computes the signed digest: the patch checksum followed by the metadata without its signature.
+// The sidecar blob is covered through SidecarChecksum.
+func (m *Metadata) Digest(patchChecksum [32]byte) ([]byte, error) {
+	unsigned := *m
+	unsigned.Signature = nil
+	data, err := json.Marshal(unsigned)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode metadata: %w", err)
+	}
+
+	hasher := sha256.New()
+	hasher.Write(patchChecksum[:])
+	hasher.Write(data)
+	return hasher.Sum(nil), nil
+}
@@ -0,0 +1,340 @@
+package embedded
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// Executable is an opened self-contained executable
+type Executable struct {
+	Path     string
+	Header   Header
+	Metadata *Metadata // nil for format version 1
+	file     *os.File
+	size     int64
+}
+
+// Sidecar is a file stored in the sidecar blob of an executable
+type Sidecar struct {
+	Name string
+	Data *io.SectionReader
+}
+
+// Open reads the trailer of a self-contained executable.
+// Returns ErrNotEmbedded if the file does not contain an embedded patch.
+func Open(path string) (*Executable, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	exe, err := readTrailer(path, file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return exe, nil
+}
+
+// readTrailer parses and validates the header at the end of the file
+func readTrailer(path string, file *os.File) (*Executable, error) {
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := stat.Size()
+	if size < HeaderSize {
+		return nil, ErrNotEmbedded
+	}
+
+	headerBytes := make([]byte, HeaderSize)
+	if _, err := file.ReadAt(headerBytes, size-HeaderSize); err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	var header Header
+	if err := binary.Read(bytes.NewReader(headerBytes), binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("failed to parse header: %w", err)
+	}
+	if string(bytes.TrimRight(header.Magic[:], "\x00")) != Magic {
+		return nil, ErrNotEmbedded
+	}
+	switch header.Version {
+	case FormatVersionV1:
+		// Reserved bytes in v1; never a metadata size
+		header.MetadataSize = 0
+	case FormatVersion:
+		if header.MetadataSize < 4 {
+			return nil, fmt.Errorf("embedded patch header has no metadata section")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported embedded patch format version %d (this applier supports up to %d)", header.Version, FormatVersion)
+	}
+
+	// Data must start immediately after the stub; an optional sidecar blob may follow it
+	used := header.StubSize + header.DataSize + uint64(header.MetadataSize) + HeaderSize
+	if header.DataOffset != header.StubSize || used > uint64(size) {
+		return nil, fmt.Errorf("embedded patch header is inconsistent with the file size")
+	}
+
+	exe := &Executable{Path: path, Header: header, file: file, size: size}
+	if header.MetadataSize > 0 {
+		meta, err := exe.readMetadata()
+		if err != nil {
+			return nil, err
+		}
+		exe.Metadata = meta
+	}
+	return exe, nil
+}
+
+// readMetadata parses the length-prefixed metadata section in front of the header
+func (e *Executable) readMetadata() (*Metadata, error) {
+	offset := e.size - HeaderSize - int64(e.Header.MetadataSize)
+	section := io.NewSectionReader(e.file, offset, int64(e.Header.MetadataSize))
+
+	var length uint32
+	if err := binary.Read(section, binary.LittleEndian, &length); err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	if length != e.Header.MetadataSize-4 {
+		return nil, fmt.Errorf("metadata length %d does not match the header (%d)", length, e.Header.MetadataSize-4)
+	}
+
+	var meta Metadata
+	if err := json.NewDecoder(section).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+	return &meta, nil
+}
+
+// Close closes the executable file
+func (e *Executable) Close() error {
+	return e.file.Close()
+}
+
+// PatchData returns a reader over the embedded patch data without copying it
+func (e *Executable) PatchData() *io.SectionReader {
+	return io.NewSectionReader(e.file, int64(e.Header.DataOffset), int64(e.Header.DataSize))
+}
+
+// VerifyChecksum hashes the embedded patch data and compares it with the header checksum
+func (e *Executable) VerifyChecksum() error {
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, e.PatchData()); err != nil {
+		return fmt.Errorf("failed to read embedded patch: %w", err)
+	}
+	return e.checkSum(hasher)
+}
+
+// LoadPatch parses the embedded patch straight from the executable, verifying the checksum as it streams
+func (e *Executable) LoadPatch() (*utils.Patch, error) {
+	hasher := sha256.New()
+	data := io.TeeReader(e.PatchData(), hasher)
+
+	patch, parseErr := utils.LoadPatchFromReader(data)
+
+	// The decoder may stop before the end of the data; hash the rest so the checksum covers everything
+	if _, err := io.Copy(io.Discard, data); err != nil {
+		return nil, fmt.Errorf("failed to read embedded patch: %w", err)
+	}
+	if err := e.checkSum(hasher); err != nil {
+		return nil, err
+	}
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return patch, nil
+}
+
+// checkSum compares a finished hash of the patch data with the header checksum
+func (e *Executable) checkSum(hasher hash.Hash) error {
+	if actual := hasher.Sum(nil); !bytes.Equal(actual, e.Header.Checksum[:]) {
+		return fmt.Errorf("embedded patch checksum mismatch (expected %x, got %x): the executable is corrupted",
+			e.Header.Checksum[:8], actual[:8])
+	}
+	return nil
+}
+
+// Sidecars returns the files stored in the sidecar blob (nil if there is none)
+func (e *Executable) Sidecars() ([]Sidecar, error) {
+	blob := e.sidecarBlob()
+	if blob == nil {
+		return nil, nil
+	}
+	_, offset, length := blob.Outer()
+
+	var count uint32
+	if err := binary.Read(blob, binary.LittleEndian, &count); err != nil {
+		return nil, fmt.Errorf("failed to read sidecar blob: %w", err)
+	}
+
+	// Bound the count by the blob so a damaged count cannot force a huge allocation
+	if uint64(count) > uint64(length-4)/sidecarRecordMinSize {
+		return nil, fmt.Errorf("sidecar blob lists %d files but holds at most %d", count, (length-4)/sidecarRecordMinSize)
+	}
+
+	sidecars := make([]Sidecar, 0, count)
+	pos := int64(4)
+	for i := uint32(0); i < count; i++ {
+		var nameLen uint16
+		if err := binary.Read(blob, binary.LittleEndian, &nameLen); err != nil {
+			return nil, fmt.Errorf("failed to read sidecar %d: %w", i+1, err)
+		}
+		name := make([]byte, nameLen)
+		if _, err := io.ReadFull(blob, name); err != nil {
+			return nil, fmt.Errorf("failed to read sidecar %d: %w", i+1, err)
+		}
+		var dataLen uint64
+		if err := binary.Read(blob, binary.LittleEndian, &dataLen); err != nil {
+			return nil, fmt.Errorf("failed to read sidecar %d: %w", i+1, err)
+		}
+		pos += 2 + int64(nameLen) + 8
+		// Compared before converting, so a length with the high bit set cannot turn negative
+		if pos > length || dataLen > uint64(length-pos) {
+			return nil, fmt.Errorf("sidecar %s extends past the end of the blob", name)
+		}
+
+		sidecars = append(sidecars, Sidecar{
+			Name: string(name),
+			Data: io.NewSectionReader(e.file, offset+pos, int64(dataLen)),
+		})
+		pos += int64(dataLen)
+		if _, err := blob.Seek(pos, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to read sidecar blob: %w", err)
+		}
+	}
+	return sidecars, nil
+}
+
+// sidecarBlob returns a reader over the sidecar blob, or nil if there is none
+func (e *Executable) sidecarBlob() *io.SectionReader {
+	offset := int64(e.Header.DataOffset + e.Header.DataSize)
+	length := e.size - HeaderSize - int64(e.Header.MetadataSize) - offset
+	if length <= 0 {
+		return nil
+	}
+	return io.NewSectionReader(e.file, offset, length)
+}
+
+// VerifySidecars hashes the sidecar blob and compares it with the checksum in the metadata.
+// Version 1 executables record no sidecar checksum, so there is nothing to compare.
+func (e *Executable) VerifySidecars() error {
+	if e.Metadata == nil {
+		return nil
+	}
+
+	actual := ""
+	if blob := e.sidecarBlob(); blob != nil {
+		hasher := sha256.New()
+		if _, err := io.Copy(hasher, blob); err != nil {
+			return fmt.Errorf("failed to read sidecar blob: %w", err)
+		}
+		actual = fmt.Sprintf("%x", hasher.Sum(nil))
+	}
+	if actual != e.Metadata.SidecarChecksum {
+		return fmt.Errorf("embedded parts checksum mismatch: the executable is corrupted")
+	}
+	return nil
+}
+
+// IsSigned reports whether the metadata carries a signature
+func (e *Executable) IsSigned() bool {
+	return e.Metadata != nil && len(e.Metadata.Signature) > 0
+}
+
+// PinnedKey returns the public key the executable was signed with, or nil if none is recorded.
+// It lets the signature be checked for integrity without --trust-key; since the executable carries
+// the key itself, a signature by it says nothing about who built the executable.
+func (e *Executable) PinnedKey() ed25519.PublicKey {
+	if !e.IsSigned() || len(e.Metadata.SignerKey) != ed25519.PublicKeySize {
+		return nil
+	}
+	key := ed25519.PublicKey(e.Metadata.SignerKey)
+	if utils.KeyID(key) != e.Metadata.SignerKeyID {
+		return nil
+	}
+	return key
+}
+
+// VerifySignature checks the metadata signature against trusted public keys.
+// Returns the ID of the key that verified the signature.
+func (e *Executable) VerifySignature(trustedKeys []ed25519.PublicKey) (string, error) {
+	if !e.IsSigned() {
+		return "", fmt.Errorf("executable is not signed")
+	}
+	if len(trustedKeys) == 0 {
+		return "", fmt.Errorf("no trusted keys configured")
+	}
+
+	digest, err := e.Metadata.Digest(e.Header.Checksum)
+	if err != nil {
+		return "", err
+	}
+	for _, key := range trustedKeys {
+		if ed25519.Verify(key, digest, e.Metadata.Signature) {
+			return utils.KeyID(key), nil
+		}
+	}
+	return "", fmt.Errorf("signature does not match any trusted key (signed by %s)", e.Metadata.SignerKeyID)
+}
+
+// Extract writes the patch data to dir/patchName and every sidecar file to dir under its own name.
+// The patch checksum is verified while copying. Returns the paths written.
+func (e *Executable) Extract(dir, patchName string) ([]string, error) {
+	sidecars, err := e.Sidecars()
+	if err != nil {
+		return nil, err
+	}
+	if err := utils.EnsureDir(dir); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	hasher := sha256.New()
+	patchPath := filepath.Join(dir, patchName)
+	if err := extractFile(patchPath, io.TeeReader(e.PatchData(), hasher)); err != nil {
+		return nil, err
+	}
+	if err := e.checkSum(hasher); err != nil {
+		os.Remove(patchPath)
+		return nil, err
+	}
+
+	written := []string{patchPath}
+	for _, sc := range sidecars {
+		// Names come from the file; never let them escape the output directory
+		name := filepath.Base(sc.Name)
+		if name != sc.Name || name == "." || name == ".." {
+			return written, fmt.Errorf("refusing to extract sidecar with unsafe name %q", sc.Name)
+		}
+		path := filepath.Join(dir, name)
+		if err := extractFile(path, sc.Data); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// extractFile streams data into a new file
+func extractFile(path string, data io.Reader) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := io.Copy(out, data); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return out.Close()
+}
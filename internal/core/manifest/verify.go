@@ -0,0 +1,141 @@
+package manifest
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/scanner"
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// Verification severity levels, ordered from least to most severe
+const (
+	SeverityOK      = 0 // Install matches the manifest
+	SeverityMinor   = 1 // Only extra files or permission differences
+	SeverityDamaged = 2 // Files are missing or have the wrong content
+)
+
+// FileMismatch describes a file whose content differs from the manifest
+type FileMismatch struct {
+	Path     string `json:"path"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual,omitempty"`
+	Error    string `json:"error,omitempty"` // Set if the file could not be hashed
+}
+
+// ModeMismatch describes a file whose executable flag differs from the manifest
+type ModeMismatch struct {
+	Path               string `json:"path"`
+	ExpectedExecutable bool   `json:"expected_executable"`
+	ActualExecutable   bool   `json:"actual_executable"`
+}
+
+// VerifyReport is the result of checking an install directory against a manifest
+type VerifyReport struct {
+	Version     string         `json:"version"`
+	Directory   string         `json:"directory"`
+	Checked     int            `json:"checked"`
+	Missing     []string       `json:"missing"`
+	Mismatched  []FileMismatch `json:"mismatched"`
+	Extra       []string       `json:"extra"`
+	ModeDiffers []ModeMismatch `json:"mode_differs"`
+}
+
+// Severity returns the most severe problem found in the report
+func (r *VerifyReport) Severity() int {
+	if len(r.Missing) > 0 || len(r.Mismatched) > 0 {
+		return SeverityDamaged
+	}
+	if len(r.Extra) > 0 || len(r.ModeDiffers) > 0 {
+		return SeverityMinor
+	}
+	return SeverityOK
+}
+
+// VerifyInstall checks an install directory against a manifest, hashing files with the given number of workers.
+// Unlike VerifyManifest it also reports files not listed in the manifest and executable-flag differences.
+func (m *Manager) VerifyInstall(manifest *utils.Manifest, basePath string, workers int) (*VerifyReport, error) {
+	scan := scanner.NewScanner(basePath)
+	if err := scan.ValidatePath(); err != nil {
+		return nil, err
+	}
+
+	files, _, err := scan.ListPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{
+		Version:     manifest.Version,
+		Directory:   basePath,
+		Checked:     len(manifest.Files),
+		Missing:     []string{},
+		Mismatched:  []FileMismatch{},
+		Extra:       []string{},
+		ModeDiffers: []ModeMismatch{},
+	}
+
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		present[file] = true
+	}
+
+	expected := make(map[string]bool, len(manifest.Files))
+	var paths []string
+	var entries []utils.FileEntry
+	for _, file := range manifest.Files {
+		expected[file.Path] = true
+		if !present[file.Path] {
+			report.Missing = append(report.Missing, file.Path)
+			continue
+		}
+		paths = append(paths, filepath.Join(basePath, filepath.FromSlash(file.Path)))
+		entries = append(entries, file)
+	}
+
+	for _, file := range files {
+		if !expected[file] {
+			report.Extra = append(report.Extra, file)
+		}
+	}
+
+	for i, result := range utils.CalculateFileChecksumsParallel(paths, workers) {
+		entry := entries[i]
+		if result.Err != nil {
+			report.Mismatched = append(report.Mismatched, FileMismatch{
+				Path:     entry.Path,
+				Expected: entry.Checksum,
+				Error:    fmt.Sprintf("failed to calculate checksum: %v", result.Err),
+			})
+			continue
+		}
+		if result.Checksum != entry.Checksum {
+			report.Mismatched = append(report.Mismatched, FileMismatch{
+				Path:     entry.Path,
+				Expected: entry.Checksum,
+				Actual:   result.Checksum,
+			})
+			continue
+		}
+
+		// Windows has no executable bit, so the flag is only meaningful elsewhere
+		if runtime.GOOS != "windows" {
+			if actual := utils.IsExecutable(result.Path); actual != entry.IsExecutable {
+				report.ModeDiffers = append(report.ModeDiffers, ModeMismatch{
+					Path:               entry.Path,
+					ExpectedExecutable: entry.IsExecutable,
+					ActualExecutable:   actual,
+				})
+			}
+		}
+	}
+
+	sort.Strings(report.Missing)
+	sort.Strings(report.Extra)
+	sort.Slice(report.Mismatched, func(i, j int) bool { return report.Mismatched[i].Path < report.Mismatched[j].Path })
+	sort.Slice(report.ModeDiffers, func(i, j int) bool { return report.ModeDiffers[i].Path < report.ModeDiffers[j].Path })
+
+	return report, nil
+}
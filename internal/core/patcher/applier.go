@@ -1,21 +1,61 @@
 package patcher
 
 import (
+	"crypto/ed25519"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/manifest"
 	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
 )
 
 // Applier handles patch application
 type Applier struct {
-	patchFilePath string // Stores the patch file path during application for large file streaming
+	patchFilePath string              // Stores the patch file path during application for large file streaming
+	workerThreads int                 // Number of worker threads for hashing and applying operations
+	allowHooks    bool                // Run patch hooks even if the patch is not signed by a trusted key
+	trustedKeys   []ed25519.PublicKey // Public keys accepted when verifying patch signatures
+	logWriter     io.Writer           // Receives hook output in addition to the console (nil = console only)
+	runPatchHooks bool                // Whether hooks are enabled for the patch currently being applied
+	verifyTree    bool                // Verify the whole resulting tree against the patch's target manifest
 }
 
 // NewApplier creates a new patch applier
 func NewApplier() *Applier {
-	return &Applier{}
+	return &Applier{
+		workerThreads: 1, // Default to single-threaded
+	}
+}
+
+// SetWorkerThreads sets the number of worker threads used for verification and operation application
+func (a *Applier) SetWorkerThreads(threads int) {
+	if threads < 1 {
+		threads = 1
+	}
+	a.workerThreads = threads
+}
+
+// SetAllowHooks allows patch hooks to run even if the patch is unsigned
+func (a *Applier) SetAllowHooks(allow bool) {
+	a.allowHooks = allow
+}
+
+// AddTrustedKey adds a public key used to verify patch signatures
+func (a *Applier) AddTrustedKey(key ed25519.PublicKey) {
+	a.trustedKeys = append(a.trustedKeys, key)
+}
+
+// SetLogWriter sets a writer that receives hook output in addition to the console
+func (a *Applier) SetLogWriter(w io.Writer) {
+	a.logWriter = w
+}
+
+// SetVerifyTree enables full-tree verification against the patch's embedded target manifest after applying
+func (a *Applier) SetVerifyTree(enabled bool) {
+	a.verifyTree = enabled
 }
 
 // ApplyPatch applies a patch to a target directory
@@ -28,17 +68,48 @@ func (a *Applier) ApplyPatchWithPath(patch *utils.Patch, targetDir string, patch
 	// Store patch file path for large file streaming
 	a.patchFilePath = patchFilePath
 
-	fmt.Printf("Applying patch from %s to %s...\n", patch.FromVersion, patch.ToVersion)
+	if patch.FullInstall {
+		fmt.Printf("Installing version %s...\n", patch.ToVersion)
+
+		// A full install creates its directory, but never installs over existing files
+		if err := PrepareInstallDir(targetDir); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("Applying patch from %s to %s...\n", patch.FromVersion, patch.ToVersion)
+	}
 
 	// Verify target directory exists
 	if !utils.FileExists(targetDir) {
 		return fmt.Errorf("target directory does not exist: %s", targetDir)
 	}
 
-	// Pre-patch verification
-	if verifyBefore {
-		fmt.Println("Verifying current version...")
-		if err := a.verifyKeyFile(targetDir, patch.FromKeyFile); err != nil {
+	if a.verifyTree && patch.TargetManifest == nil {
+		return fmt.Errorf("full-tree verification requested but the patch has no target manifest (generate it with --embed-manifest)")
+	}
+
+	// Hold the install lock for the whole apply so no other apply, rollback or repair can interleave
+	lock, err := AcquireLock(targetDir, "apply")
+	if err != nil {
+		return err
+	}
+	defer releaseLock(lock)
+
+	// Decide whether hooks may run before anything else happens
+	runHooks, err := a.authorizeHooks(patch)
+	if err != nil {
+		return err
+	}
+	a.runPatchHooks = runHooks
+
+	if err := a.runHooks(patch, utils.HookPhasePreVerify, targetDir); err != nil {
+		return err
+	}
+
+	// Pre-patch verification (a full install has no source version to verify)
+	if verifyBefore && !patch.FullInstall {
+		fmt.Printf("Verifying current version (%s)...\n", VerificationSummary(patch))
+		if err := a.verifyIdentity(targetDir, patch.FromKeyFile, patch.FromIdentity); err != nil {
 			return fmt.Errorf("key file verification failed: %w", err)
 		}
 
@@ -48,6 +119,18 @@ func (a *Applier) ApplyPatchWithPath(patch *utils.Patch, targetDir string, patch
 		fmt.Println("Pre-patch verification successful")
 	}
 
+	// Preflight: make sure there is enough space and every touched path is writable
+	// so the apply doesn't fail halfway with ENOSPC or EACCES
+	fmt.Println("Running preflight checks...")
+	report, err := a.Preflight(patch, targetDir, createBackup)
+	if err != nil {
+		return fmt.Errorf("preflight check failed: %w", err)
+	}
+	fmt.Print(report.Format())
+	if !report.OK() {
+		return fmt.Errorf("preflight check failed: %s", strings.Join(report.Problems, "; "))
+	}
+
 	// Create backup AFTER verification passes but BEFORE applying operations
 	if createBackup {
 		fmt.Println("\nCreating backup...")
@@ -59,37 +142,46 @@ func (a *Applier) ApplyPatchWithPath(patch *utils.Patch, targetDir string, patch
 		fmt.Println("Note: Backup will be preserved after patching for manual rollback")
 	}
 
-	// Apply operations
-	fmt.Printf("Applying %d operations...\n", len(patch.Operations))
-	for i, op := range patch.Operations {
-		if err := a.applyOperation(targetDir, op); err != nil {
+	// Nothing has been changed yet, so a failing pre-apply hook needs no rollback
+	if err := a.runHooks(patch, utils.HookPhasePreApply, targetDir); err != nil {
+		return err
+	}
+
+	// Apply operations in stages; operations within a stage are independent and run concurrently
+	stages := planOperationStages(patch.Operations)
+	if a.workerThreads > 1 {
+		fmt.Printf("Applying %d operations in %d stages using %d workers...\n", len(patch.Operations), len(stages), a.workerThreads)
+	} else {
+		fmt.Printf("Applying %d operations...\n", len(patch.Operations))
+	}
+	for _, stage := range stages {
+		if i, err := a.applyOperationStage(targetDir, patch.Operations, stage); err != nil {
 			// Operation failed - automatically restore from backup if it was created
+			// Every operation in the failed stage may have started, so roll back through the end of the stage
 			if createBackup {
-				fmt.Printf("\nOperation %d failed, automatically restoring from backup...\n", i)
-				backupDir := filepath.Join(targetDir, "backup.cyberpatcher")
-				if restoreErr := a.restoreMirrorBackup(backupDir, targetDir, patch.Operations[:i+1]); restoreErr != nil {
-					fmt.Printf("Warning: Failed to restore backup: %v\n", restoreErr)
-				} else {
-					fmt.Println("Backup restored successfully")
-				}
+				a.rollback(patch, targetDir, patch.Operations[:stage.end], fmt.Sprintf("Operation %d failed", i))
 			}
 			return fmt.Errorf("failed to apply operation %d: %w", i, err)
 		}
 	}
 
+	// Patch operations carry no permissions, so a full install takes them from the target manifest
+	if patch.FullInstall {
+		if err := applyManifestModes(targetDir, patch.TargetManifest); err != nil {
+			if createBackup {
+				a.rollback(patch, targetDir, patch.Operations, "Setting file permissions failed")
+			}
+			return err
+		}
+	}
+
 	// Post-patch verification
 	if verifyAfter {
 		fmt.Println("Verifying patched version...")
-		if err := a.verifyKeyFile(targetDir, patch.ToKeyFile); err != nil {
+		if err := a.verifyIdentity(targetDir, patch.ToKeyFile, patch.ToIdentity); err != nil {
 			// Post-verification failed - automatically restore from backup if it was created
 			if createBackup {
-				fmt.Println("\nPost-verification failed, automatically restoring from backup...")
-				backupDir := filepath.Join(targetDir, "backup.cyberpatcher")
-				if restoreErr := a.restoreMirrorBackup(backupDir, targetDir, patch.Operations); restoreErr != nil {
-					fmt.Printf("Warning: Failed to restore backup: %v\n", restoreErr)
-				} else {
-					fmt.Println("Backup restored successfully")
-				}
+				a.rollback(patch, targetDir, patch.Operations, "Post-verification failed")
 			}
 			return fmt.Errorf("post-patch key file verification failed: %w", err)
 		}
@@ -97,19 +189,30 @@ func (a *Applier) ApplyPatchWithPath(patch *utils.Patch, targetDir string, patch
 		if err := a.verifyPatchedFiles(targetDir, patch.Operations); err != nil {
 			// Post-verification failed - automatically restore from backup if it was created
 			if createBackup {
-				fmt.Println("\nPost-verification failed, automatically restoring from backup...")
-				backupDir := filepath.Join(targetDir, "backup.cyberpatcher")
-				if restoreErr := a.restoreMirrorBackup(backupDir, targetDir, patch.Operations); restoreErr != nil {
-					fmt.Printf("Warning: Failed to restore backup: %v\n", restoreErr)
-				} else {
-					fmt.Println("Backup restored successfully")
-				}
+				a.rollback(patch, targetDir, patch.Operations, "Post-verification failed")
 			}
 			return fmt.Errorf("post-patch verification failed: %w", err)
 		}
 		fmt.Println("Post-patch verification successful")
 	}
 
+	// Full-tree verification catches corrupted files the patch didn't touch and stray files
+	if a.verifyTree {
+		if err := a.verifyFullTree(patch.TargetManifest, targetDir); err != nil {
+			if createBackup {
+				a.rollback(patch, targetDir, patch.Operations, "Full-tree verification failed")
+			}
+			return err
+		}
+	}
+
+	if err := a.runHooks(patch, utils.HookPhasePostApply, targetDir); err != nil {
+		if createBackup {
+			a.rollback(patch, targetDir, patch.Operations, "Post-apply hook failed")
+		}
+		return err
+	}
+
 	// Keep backup after successful patching for manual rollback if needed
 	if createBackup {
 		backupDir := filepath.Join(targetDir, "backup.cyberpatcher")
@@ -121,6 +224,75 @@ func (a *Applier) ApplyPatchWithPath(patch *utils.Patch, targetDir string, patch
 	return nil
 }
 
+// rollback restores the backup for the given operations and runs on-rollback hooks
+func (a *Applier) rollback(patch *utils.Patch, targetDir string, operations []utils.PatchOperation, reason string) {
+	fmt.Printf("\n%s, automatically restoring from backup...\n", reason)
+	backupDir := filepath.Join(targetDir, "backup.cyberpatcher")
+	if err := a.restoreMirrorBackup(backupDir, targetDir, operations); err != nil {
+		fmt.Printf("Warning: Failed to restore backup: %v\n", err)
+	} else {
+		fmt.Println("Backup restored successfully")
+	}
+
+	// The apply has already failed; on-rollback hook failures are only reported
+	if err := a.runHooks(patch, utils.HookPhaseOnRollback, targetDir); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+}
+
+// RollbackPatch restores an install to the patch's source version using the backup left by a previous apply
+func (a *Applier) RollbackPatch(patch *utils.Patch, targetDir string) error {
+	if !utils.FileExists(targetDir) {
+		return fmt.Errorf("target directory does not exist: %s", targetDir)
+	}
+
+	lock, err := AcquireLock(targetDir, "rollback")
+	if err != nil {
+		return err
+	}
+	defer releaseLock(lock)
+
+	backupDir := filepath.Join(targetDir, utils.BackupDirName)
+	if !utils.FileExists(backupDir) {
+		return fmt.Errorf("no backup found at %s", backupDir)
+	}
+
+	// Only roll back an install that is actually at the patch's target version
+	if err := a.verifyIdentity(targetDir, patch.ToKeyFile, patch.ToIdentity); err != nil {
+		if !patch.FullInstall && a.verifyIdentity(targetDir, patch.FromKeyFile, patch.FromIdentity) == nil {
+			return fmt.Errorf("install is already at version %s, nothing to roll back", patch.FromVersion)
+		}
+		return fmt.Errorf("install is not at version %s: %w", patch.ToVersion, err)
+	}
+
+	if patch.FullInstall {
+		fmt.Printf("Rolling back the install of %s...\n", patch.ToVersion)
+	} else {
+		fmt.Printf("Rolling back from %s to %s...\n", patch.ToVersion, patch.FromVersion)
+	}
+	if err := a.restoreMirrorBackup(backupDir, targetDir, patch.Operations); err != nil {
+		return fmt.Errorf("failed to restore backup: %w", err)
+	}
+
+	// Rolling back a full install removes it; there is no source version left to verify
+	if patch.FullInstall {
+		fmt.Printf("Rollback successful: version %s removed\n", patch.ToVersion)
+		return nil
+	}
+	if err := a.verifyIdentity(targetDir, patch.FromKeyFile, patch.FromIdentity); err != nil {
+		return fmt.Errorf("rollback verification failed: %w", err)
+	}
+	fmt.Printf("Rollback successful: install restored to %s\n", patch.FromVersion)
+	return nil
+}
+
+// releaseLock releases an install lock, reporting (but not failing on) errors
+func releaseLock(lock *InstallLock) {
+	if err := lock.Release(); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+}
+
 // applyOperation applies a single patch operation
 func (a *Applier) applyOperation(targetDir string, op utils.PatchOperation) error {
 	targetPath := filepath.Join(targetDir, op.FilePath)
@@ -141,8 +313,21 @@ func (a *Applier) applyOperation(targetDir string, op utils.PatchOperation) erro
 	}
 }
 
+// checkPayload verifies the file data of an operation against its NewChecksum before anything is
+// written. The signature covers NewChecksum, so this also binds the data to a signed patch.
+func checkPayload(op utils.PatchOperation) error {
+	if utils.CalculateDataChecksum(op.NewFile) != op.NewChecksum {
+		return fmt.Errorf("file data does not match its checksum (patch is corrupted or was tampered with)")
+	}
+	return nil
+}
+
 // applyAdd adds a new file
 func (a *Applier) applyAdd(targetPath string, op utils.PatchOperation) error {
+	if err := checkPayload(op); err != nil {
+		return err
+	}
+
 	// Ensure directory exists
 	if err := utils.EnsureDir(filepath.Dir(targetPath)); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
@@ -218,6 +403,9 @@ func (a *Applier) applyModify(targetPath string, op utils.PatchOperation) error
 	if len(op.NewFile) == 0 {
 		return fmt.Errorf("no new file data provided")
 	}
+	if err := checkPayload(op); err != nil {
+		return err
+	}
 
 	newData := op.NewFile
 	resultSize := int64(len(newData))
@@ -338,53 +526,34 @@ func (a *Applier) applyDeleteDir(targetPath string) error {
 	return nil
 }
 
-// verifyKeyFile verifies the key file matches expected hash
-func (a *Applier) verifyKeyFile(targetDir string, keyFile utils.KeyFileInfo) error {
-	keyFilePath := filepath.Join(targetDir, keyFile.Path)
-
-	if !utils.FileExists(keyFilePath) {
-		return fmt.Errorf("key file not found: %s", keyFile.Path)
+// VerificationSummary describes which source files the patch verifies before applying
+func VerificationSummary(patch *utils.Patch) string {
+	if patch.FullInstall {
+		return "none, full install into an empty directory"
 	}
-
-	match, err := utils.VerifyFileChecksum(keyFilePath, keyFile.Checksum)
-	if err != nil {
-		return fmt.Errorf("failed to verify key file checksum: %w", err)
-	} else if !match {
-		currentChecksum, _ := utils.CalculateFileChecksum(keyFilePath)
-		return fmt.Errorf("key file checksum mismatch: expected %s, got %s",
-			keyFile.Checksum[:16], currentChecksum[:16])
+	v := patch.Verification
+	if v == nil || v.Level == utils.VerificationFull {
+		return "full"
 	}
-
-	return nil
+	if v.Level == utils.VerificationSampled {
+		return fmt.Sprintf("sampled %d%%, %d of %d source files", v.SamplePercent, len(patch.RequiredFiles), v.SourceFiles)
+	}
+	return fmt.Sprintf("%s, %d of %d source files", v.Level, len(patch.RequiredFiles), v.SourceFiles)
 }
 
 // verifyRequiredFiles verifies all required files exist with correct checksums
 func (a *Applier) verifyRequiredFiles(targetDir string, required []utils.FileRequirement) error {
-	mismatches := make([]string, 0)
-
+	paths := make([]string, 0, len(required))
+	expected := make([]string, 0, len(required))
 	for _, req := range required {
 		if !req.IsRequired {
 			continue
 		}
-
-		filePath := filepath.Join(targetDir, req.Path)
-
-		if !utils.FileExists(filePath) {
-			mismatches = append(mismatches, fmt.Sprintf("%s: file not found", req.Path))
-			continue
-		}
-
-		match, err := utils.VerifyFileChecksum(filePath, req.Checksum)
-		if err != nil {
-			mismatches = append(mismatches, fmt.Sprintf("%s: failed to verify checksum: %v", req.Path, err))
-			continue
-		} else if !match {
-			currentChecksum, _ := utils.CalculateFileChecksum(filePath)
-			mismatches = append(mismatches, fmt.Sprintf("%s: checksum mismatch (expected %s, got %s)",
-				req.Path, req.Checksum[:16], currentChecksum[:16]))
-		}
+		paths = append(paths, req.Path)
+		expected = append(expected, req.Checksum)
 	}
 
+	mismatches := a.verifyChecksums(targetDir, paths, expected, "")
 	if len(mismatches) > 0 {
 		return fmt.Errorf("found %d mismatches:\n%v", len(mismatches), mismatches)
 	}
@@ -394,35 +563,56 @@ func (a *Applier) verifyRequiredFiles(targetDir string, required []utils.FileReq
 
 // verifyPatchedFiles verifies all modified files have correct checksums
 func (a *Applier) verifyPatchedFiles(targetDir string, operations []utils.PatchOperation) error {
-	mismatches := make([]string, 0)
-
+	paths := make([]string, 0, len(operations))
+	expected := make([]string, 0, len(operations))
 	for _, op := range operations {
 		if op.Type == utils.OpDelete || op.Type == utils.OpDeleteDir || op.Type == utils.OpAddDir {
 			continue
 		}
+		paths = append(paths, op.FilePath)
+		expected = append(expected, op.NewChecksum)
+	}
 
-		filePath := filepath.Join(targetDir, op.FilePath)
+	mismatches := a.verifyChecksums(targetDir, paths, expected, "after patching")
+	if len(mismatches) > 0 {
+		return fmt.Errorf("found %d mismatches:\n%v", len(mismatches), mismatches)
+	}
 
-		if !utils.FileExists(filePath) {
-			mismatches = append(mismatches, fmt.Sprintf("%s: file not found after patching", op.FilePath))
-			continue
-		}
+	return nil
+}
 
-		expectedChecksum := op.NewChecksum
-		match, err := utils.VerifyFileChecksum(filePath, expectedChecksum)
-		if err != nil {
-			mismatches = append(mismatches, fmt.Sprintf("%s: failed to verify checksum: %v", op.FilePath, err))
-		} else if !match {
-			currentChecksum, _ := utils.CalculateFileChecksum(filePath)
-			mismatches = append(mismatches, fmt.Sprintf("%s: checksum mismatch (expected %s, got %s)",
-				op.FilePath, expectedChecksum[:16], currentChecksum[:16]))
-		}
+// verifyFullTree compares the whole target directory with the target manifest.
+// Missing, mismatched and extra files fail the verification; executable flag differences are only reported.
+func (a *Applier) verifyFullTree(target *utils.Manifest, targetDir string) error {
+	fmt.Printf("Verifying full tree against the %s manifest (%d files)...\n", target.Version, len(target.Files))
+	report, err := manifest.NewManager().VerifyInstall(target, targetDir, a.workerThreads)
+	if err != nil {
+		return fmt.Errorf("full-tree verification failed: %w", err)
 	}
 
-	if len(mismatches) > 0 {
-		return fmt.Errorf("found %d mismatches:\n%v", len(mismatches), mismatches)
+	for _, m := range report.ModeDiffers {
+		fmt.Printf("  Warning: executable flag differs for %s (expected %t)\n", m.Path, m.ExpectedExecutable)
+	}
+
+	var problems []string
+	for _, path := range report.Missing {
+		problems = append(problems, fmt.Sprintf("%s: missing", path))
+	}
+	for _, m := range report.Mismatched {
+		problems = append(problems, fmt.Sprintf("%s: checksum mismatch", m.Path))
+	}
+	for _, path := range report.Extra {
+		problems = append(problems, fmt.Sprintf("%s: not part of version %s", path, target.Version))
+	}
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Printf("  %s\n", problem)
+		}
+		return fmt.Errorf("full-tree verification failed: %d problems (%d missing, %d mismatched, %d extra)",
+			len(problems), len(report.Missing), len(report.Mismatched), len(report.Extra))
 	}
 
+	fmt.Println("Full-tree verification successful")
 	return nil
 }
 
@@ -0,0 +1,83 @@
+package patcher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// brandingFile is the on-disk format accepted by the generator's --branding option
+type brandingFile struct {
+	ProductName    string `json:"product_name"`
+	Publisher      string `json:"publisher"`
+	WelcomeText    string `json:"welcome_text"`
+	EULA           string `json:"eula"`
+	EULAFile       string `json:"eula_file"` // Read the EULA from a text file (relative to the branding file)
+	SupportURL     string `json:"support_url"`
+	InstallDirHint string `json:"install_dir_hint"`
+}
+
+// LoadBrandingFile reads and validates product branding from a JSON file
+func LoadBrandingFile(path string) (*utils.Branding, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read branding file: %w", err)
+	}
+
+	var file brandingFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse branding file: %w", err)
+	}
+
+	branding := &utils.Branding{
+		ProductName:    strings.TrimSpace(file.ProductName),
+		Publisher:      strings.TrimSpace(file.Publisher),
+		WelcomeText:    file.WelcomeText,
+		EULA:           file.EULA,
+		SupportURL:     strings.TrimSpace(file.SupportURL),
+		InstallDirHint: strings.TrimSpace(file.InstallDirHint),
+	}
+
+	if file.EULAFile != "" {
+		if file.EULA != "" {
+			return nil, fmt.Errorf("branding file: set either eula or eula_file, not both")
+		}
+		eulaPath := file.EULAFile
+		if !filepath.IsAbs(eulaPath) {
+			eulaPath = filepath.Join(filepath.Dir(path), eulaPath)
+		}
+		eula, err := os.ReadFile(eulaPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read EULA file: %w", err)
+		}
+		branding.EULA = string(eula)
+	}
+	branding.EULA = strings.TrimSpace(branding.EULA)
+
+	if err := ValidateBranding(branding); err != nil {
+		return nil, err
+	}
+	return branding, nil
+}
+
+// ValidateBranding checks that branding has a product name and a usable support URL
+func ValidateBranding(branding *utils.Branding) error {
+	if branding.ProductName == "" {
+		return fmt.Errorf("branding: product_name is required")
+	}
+	if branding.SupportURL != "" {
+		u, err := url.Parse(branding.SupportURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("branding: support_url must be an http(s) URL, got %q", branding.SupportURL)
+		}
+	}
+	return nil
+}
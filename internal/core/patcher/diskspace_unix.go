@@ -0,0 +1,33 @@
+//go:build unix
+
+package patcher
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// diskFreeBytes returns the number of bytes available to unprivileged users on the filesystem containing path
+func diskFreeBytes(path string) (int64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return -1, fmt.Errorf("statfs %s: %w", path, err)
+	}
+	return int64(stat.Bavail) * int64(stat.Bsize), nil
+}
+
+// sameFilesystem reports whether two existing paths live on the same device
+func sameFilesystem(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	statA, okA := infoA.Sys().(*syscall.Stat_t)
+	statB, okB := infoB.Sys().(*syscall.Stat_t)
+	if !okA || !okB {
+		return false
+	}
+	return statA.Dev == statB.Dev
+}
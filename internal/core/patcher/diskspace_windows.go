@@ -0,0 +1,46 @@
+//go:build windows
+
+package patcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32                = syscall.NewLazyDLL("kernel32.dll")
+	procGetDiskFreeSpaceExW = kernel32.NewProc("GetDiskFreeSpaceExW")
+)
+
+// diskFreeBytes returns the number of bytes available to the current user on the volume containing path
+func diskFreeBytes(path string) (int64, error) {
+	pathPtr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return -1, err
+	}
+
+	var freeBytesAvailable uint64
+	ret, _, callErr := procGetDiskFreeSpaceExW.Call(
+		uintptr(unsafe.Pointer(pathPtr)),
+		uintptr(unsafe.Pointer(&freeBytesAvailable)),
+		0,
+		0,
+	)
+	if ret == 0 {
+		return -1, fmt.Errorf("GetDiskFreeSpaceEx %s: %w", path, callErr)
+	}
+	return int64(freeBytesAvailable), nil
+}
+
+// sameFilesystem reports whether two paths live on the same volume
+func sameFilesystem(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return strings.EqualFold(filepath.VolumeName(absA), filepath.VolumeName(absB))
+}
@@ -0,0 +1,412 @@
+package patcher
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/archive"
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// DefaultEstimateSample is the most file data EstimatePatch compresses to measure compression
+const DefaultEstimateSample = 64 * 1024 * 1024
+
+// estimateFileSample is the most data sampled from the start of a single file
+const estimateFileSample = 1024 * 1024
+
+// EstimateOptions controls how a patch is estimated
+type EstimateOptions struct {
+	MaxPartSize int64 // Split limit, as for SplitPatchIntoParts (0 = utils.DefaultMaxPartSize)
+	ChunkSize   int64 // Parts larger than this are cut into chunks, as by SaveMultiPartPatch (0 = none)
+	SampleSize  int64 // Most file data compressed to measure compression (0 = DefaultEstimateSample)
+	Depth       int   // Directory levels in the per-directory contributions (0 = 1)
+}
+
+// PatchEstimate is the predicted size of a patch that was not generated
+type PatchEstimate struct {
+	FromVersion  string // Empty for full-install patches
+	ToVersion    string
+	Added        int
+	Modified     int
+	Deleted      int
+	DataSize     int64               // Uncompressed size of the added and modified files
+	SampledSize  int64               // File data compressed to measure compression
+	MetadataSize int64               // Compressed size of everything but the file data
+	Size         int64               // Predicted size of all files the patch is written to
+	PeakSize     int64               // Most disk space used while the patch is written
+	Parts        []PartEstimate      // Parts of a multi-part patch (nil = not split)
+	Directories  []DirectoryEstimate // Contributions of each directory, largest first
+}
+
+// PartEstimate is the predicted size of one part of a multi-part patch
+type PartEstimate struct {
+	Operations int
+	DataSize   int64 // Uncompressed file data in the part
+	Size       int64 // Predicted size of the part
+	Chunks     int   // Chunk files the part is cut into (0 = not chunked)
+	FileSize   int64 // Size of the part file itself: the stub of a chunked part 1, 0 for other chunked parts
+}
+
+// DirectoryEstimate is the contribution of one directory to a patch
+type DirectoryEstimate struct {
+	Path     string // Directory, "." for files at the root
+	Files    int    // Added and modified files
+	DataSize int64  // Uncompressed size of the files
+	Size     int64  // Predicted size of the files in the patch
+}
+
+// EstimatePatch predicts the size of the patch between two versions without generating it. The
+// manifests are compared as for GeneratePatch; the patch metadata is encoded and compressed
+// without file data, and a sample of each directory's changed files is compressed to predict
+// the size of the rest. Split into parts and chunks follows SplitPatchIntoParts and
+// SaveMultiPartPatch.
+func (g *Generator) EstimatePatch(fromVersion, toVersion *utils.Version, options *utils.PatchOptions, estimate EstimateOptions) (*PatchEstimate, error) {
+	return g.estimatePatch(fromVersion, toVersion, options, estimate, nil)
+}
+
+// EstimateFullPatch predicts the size of the full-install patch of a version, like EstimatePatch
+func (g *Generator) EstimateFullPatch(toVersion *utils.Version, options *utils.PatchOptions, estimate EstimateOptions) (*PatchEstimate, error) {
+	empty, fullOptions := fullInstallSource(options)
+	return g.estimatePatch(empty, toVersion, fullOptions, estimate, func(patch *utils.Patch) error {
+		return makeFullInstall(patch, toVersion, options)
+	})
+}
+
+// estimatePatch estimates a patch; finish, if set, completes the patch metadata before it is measured
+func (g *Generator) estimatePatch(fromVersion, toVersion *utils.Version, options *utils.PatchOptions, estimate EstimateOptions, finish func(*utils.Patch) error) (*PatchEstimate, error) {
+	if err := ValidateVerification(options); err != nil {
+		return nil, err
+	}
+
+	added, modified, deleted := g.manifestManager.CompareManifests(fromVersion.Manifest, toVersion.Manifest)
+	addedDirs, deletedDirs := g.compareDirectories(fromVersion.Manifest.Directories, toVersion.Manifest.Directories)
+
+	// The patch is built as GeneratePatch builds it, but without file data
+	patch, err := newPatch(fromVersion, toVersion, modified, deleted, options)
+	if err != nil {
+		return nil, err
+	}
+	if finish != nil {
+		if err := finish(patch); err != nil {
+			return nil, err
+		}
+	}
+	sourceChecksums := make(map[string]string, len(fromVersion.Manifest.Files))
+	for _, file := range fromVersion.Manifest.Files {
+		sourceChecksums[file.Path] = file.Checksum
+	}
+	for _, dir := range addedDirs {
+		patch.Operations = append(patch.Operations, utils.PatchOperation{Type: utils.OpAddDir, FilePath: dir})
+	}
+	for _, file := range deleted {
+		patch.Operations = append(patch.Operations, utils.PatchOperation{Type: utils.OpDelete, FilePath: file.Path, OldChecksum: file.Checksum})
+	}
+	for _, dir := range deletedDirs {
+		patch.Operations = append(patch.Operations, utils.PatchOperation{Type: utils.OpDeleteDir, FilePath: dir})
+	}
+	for _, file := range added {
+		patch.Operations = append(patch.Operations, utils.PatchOperation{Type: utils.OpAdd, FilePath: file.Path, NewChecksum: file.Checksum, Size: file.Size})
+	}
+	for _, file := range modified {
+		patch.Operations = append(patch.Operations, utils.PatchOperation{
+			Type:        utils.OpModify,
+			FilePath:    file.Path,
+			OldChecksum: sourceChecksums[file.Path],
+			NewChecksum: file.Checksum,
+			Size:        file.Size,
+		})
+	}
+	patch.Header = utils.PatchHeader{FormatVersion: 1, Compression: options.Compression}
+
+	result := &PatchEstimate{
+		FromVersion: patch.FromVersion,
+		ToVersion:   patch.ToVersion,
+		Added:       len(added),
+		Modified:    len(modified),
+		Deleted:     len(deleted),
+	}
+
+	// Group the changed files by directory and measure how each group compresses
+	depth := estimate.Depth
+	if depth < 1 {
+		depth = 1
+	}
+	groups := make(map[string][]utils.FileEntry)
+	for _, file := range append(append([]utils.FileEntry{}, added...), modified...) {
+		dir := estimateDir(file.Path, depth)
+		groups[dir] = append(groups[dir], file)
+		result.DataSize += file.Size
+	}
+	ratios, sampled, err := sampleCompression(toVersion, groups, result.DataSize, estimate.SampleSize, options)
+	if err != nil {
+		return nil, err
+	}
+	result.SampledSize = sampled
+
+	var dataSize float64
+	for dir, files := range groups {
+		contribution := DirectoryEstimate{Path: dir, Files: len(files)}
+		for _, file := range files {
+			contribution.DataSize += file.Size
+		}
+		contribution.Size = int64(float64(contribution.DataSize) * ratios[dir])
+		dataSize += float64(contribution.DataSize) * ratios[dir]
+		result.Directories = append(result.Directories, contribution)
+	}
+	sort.Slice(result.Directories, func(i, j int) bool {
+		a, b := result.Directories[i], result.Directories[j]
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.Path < b.Path
+	})
+
+	if result.MetadataSize, err = utils.EncodedPatchSize(patch, options.Compression, options.CompressionLevel); err != nil {
+		return nil, fmt.Errorf("failed to measure patch metadata: %w", err)
+	}
+	result.Size = result.MetadataSize + int64(dataSize)
+	result.PeakSize = result.Size
+
+	// The generator splits when the operation sizes plus the file data exceed the part size
+	maxPartSize := estimate.MaxPartSize
+	if maxPartSize <= 0 {
+		maxPartSize = utils.DefaultMaxPartSize
+	}
+	if 2*result.DataSize <= maxPartSize {
+		return result, nil
+	}
+	if err := estimateParts(result, patch, ratios, depth, maxPartSize, estimate.ChunkSize, options); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// estimateParts predicts the parts and chunks a split patch is written to. Every part repeats
+// the patch metadata without operations, part 1 also carries the target manifest, and each
+// operation adds its share of the operation metadata and its predicted file data.
+func estimateParts(result *PatchEstimate, patch *utils.Patch, ratios map[string]float64, depth int, maxPartSize, chunkSize int64, options *utils.PatchOptions) error {
+	bare := *patch
+	bare.Operations = nil
+	bare.TargetManifest = nil
+	common, err := utils.EncodedPatchSize(&bare, options.Compression, options.CompressionLevel)
+	if err != nil {
+		return fmt.Errorf("failed to measure patch metadata: %w", err)
+	}
+	bare.TargetManifest = patch.TargetManifest
+	withManifest, err := utils.EncodedPatchSize(&bare, options.Compression, options.CompressionLevel)
+	if err != nil {
+		return fmt.Errorf("failed to measure patch metadata: %w", err)
+	}
+	operationSize := float64(result.MetadataSize-withManifest) / float64(len(patch.Operations))
+
+	// Same packing as SplitPatchIntoParts: operations in the order they are applied
+	var parts []PartEstimate
+	var sizes []float64
+	for _, op := range patch.Operations {
+		n := len(parts) - 1
+		if n < 0 || (parts[n].DataSize+op.Size > maxPartSize && parts[n].Operations > 0) {
+			parts = append(parts, PartEstimate{})
+			sizes = append(sizes, float64(common))
+			n++
+		}
+		parts[n].Operations++
+		parts[n].DataSize += op.Size
+		sizes[n] += operationSize + float64(op.Size)*ratios[estimateDir(op.FilePath, depth)]
+	}
+	sizes[0] += float64(withManifest - common)
+
+	// Parts larger than the chunk size are cut into chunks; part 1 is then replaced by a stub
+	// without file data. Each part is written in full before it is chunked.
+	result.Size = 0
+	var largestChunked int64
+	for i := range parts {
+		part := &parts[i]
+		part.Size = int64(sizes[i])
+		part.FileSize = part.Size
+		if chunkSize > 0 && part.Size > chunkSize {
+			part.Chunks = int((part.Size + chunkSize - 1) / chunkSize)
+			part.FileSize = 0
+			if i == 0 {
+				part.FileSize = withManifest + int64(operationSize*float64(part.Operations))
+			}
+			largestChunked = max(largestChunked, part.Size)
+		}
+		result.Size += part.Size
+		if part.Chunks > 0 {
+			result.Size += part.FileSize
+		}
+	}
+	result.Parts = parts
+	result.PeakSize = result.Size + largestChunked
+	return nil
+}
+
+// Files returns the number of files the patch is written to: the patch, or its parts, chunks and
+// chunk sidecars
+func (e *PatchEstimate) Files() int {
+	if e.Parts == nil {
+		return 1
+	}
+	files := 0
+	for i, part := range e.Parts {
+		switch {
+		case part.Chunks == 0:
+			files++
+		case i == 0:
+			files += part.Chunks + 2 // Stub part 01 and the chunk sidecar
+		default:
+			files += part.Chunks + 1
+		}
+	}
+	return files
+}
+
+// FirstFileSize returns the size of the patch file, or of part 01 of a multi-part patch
+func (e *PatchEstimate) FirstFileSize() int64 {
+	if e.Parts == nil {
+		return e.Size
+	}
+	return e.Parts[0].FileSize
+}
+
+// Ratio returns the predicted size of the file data in the patch relative to its uncompressed size
+func (e *PatchEstimate) Ratio() float64 {
+	var size int64
+	for _, dir := range e.Directories {
+		size += dir.Size
+	}
+	if e.DataSize == 0 {
+		return 0
+	}
+	return float64(size) / float64(e.DataSize)
+}
+
+// estimateDir returns the directory a file counts towards, at most depth levels deep
+func estimateDir(filePath string, depth int) string {
+	dir := path.Dir(filePath)
+	if dir == "." {
+		return dir
+	}
+	if parts := strings.Split(dir, "/"); len(parts) > depth {
+		return strings.Join(parts[:depth], "/")
+	}
+	return dir
+}
+
+// sampleCompression measures how the changed files of each directory compress. Every directory
+// gets a share of the sample size by its data size, and at least one file; files are picked by a
+// hash of their path, so repeated estimates sample the same files. Samples are base64-encoded
+// like file data in a patch before they are compressed. Returns the ratio of compressed to
+// uncompressed size of each directory and the amount of data sampled.
+func sampleCompression(ver *utils.Version, groups map[string][]utils.FileEntry, dataSize, sampleSize int64, options *utils.PatchOptions) (map[string]float64, int64, error) {
+	if sampleSize <= 0 {
+		sampleSize = DefaultEstimateSample
+	}
+
+	picked := make(map[string][]string)
+	var paths []string
+	for dir, files := range groups {
+		var groupSize int64
+		for _, file := range files {
+			groupSize += file.Size
+		}
+		if groupSize == 0 {
+			continue
+		}
+		share := int64(float64(sampleSize) * float64(groupSize) / float64(dataSize))
+
+		ordered := make([]utils.FileEntry, len(files))
+		copy(ordered, files)
+		sort.Slice(ordered, func(i, j int) bool {
+			return sampleOrder(ordered[i].Path) < sampleOrder(ordered[j].Path)
+		})
+		var taken int64
+		for _, file := range ordered {
+			if file.Size == 0 {
+				continue
+			}
+			if taken > 0 && taken >= share {
+				break
+			}
+			picked[dir] = append(picked[dir], file.Path)
+			paths = append(paths, file.Path)
+			taken += min(file.Size, estimateFileSample)
+		}
+	}
+
+	samples, err := readSamples(ver, paths, estimateFileSample)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	compression := options.Compression
+	if compression == "" {
+		compression = "none"
+	}
+	ratios := make(map[string]float64, len(groups))
+	var sampled int64
+	for dir, files := range picked {
+		var encoded bytes.Buffer
+		var raw int64
+		for _, file := range files {
+			encoder := base64.NewEncoder(base64.StdEncoding, &encoded)
+			encoder.Write(samples[file])
+			encoder.Close()
+			raw += int64(len(samples[file]))
+		}
+		if raw == 0 {
+			continue
+		}
+		size, err := utils.CompressedSize(&encoded, compression, options.CompressionLevel)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to compress samples of %s: %w", dir, err)
+		}
+		ratios[dir] = float64(size) / float64(raw)
+		sampled += raw
+	}
+	return ratios, sampled, nil
+}
+
+// sampleOrder orders files for sampling by a hash of their path
+func sampleOrder(filePath string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(filePath))
+	return h.Sum32()
+}
+
+// readSamples reads at most limit bytes from the start of each of the files of a version
+func readSamples(ver *utils.Version, paths []string, limit int64) (map[string][]byte, error) {
+	if len(paths) == 0 {
+		return map[string][]byte{}, nil
+	}
+	if archive.IsArchive(ver.Location) {
+		samples, err := archive.ReadFilePrefixes(ver.Location, paths, limit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to sample files: %w", err)
+		}
+		return samples, nil
+	}
+
+	samples := make(map[string][]byte, len(paths))
+	for _, p := range paths {
+		file, err := os.Open(filepath.Join(ver.Location, filepath.FromSlash(p)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to sample %s: %w", p, err)
+		}
+		data, err := io.ReadAll(io.LimitReader(file, limit))
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to sample %s: %w", p, err)
+		}
+		samples[p] = data
+	}
+	return samples, nil
+}
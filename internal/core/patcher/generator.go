@@ -2,12 +2,14 @@ package patcher
 
 import (
 	"fmt"
+	"hash/fnv"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/archive"
 	"github.com/cyberofficial/cyberpatchmaker/internal/core/manifest"
 	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
 )
@@ -28,6 +30,10 @@ func NewGenerator() *Generator {
 func (g *Generator) GeneratePatch(fromVersion, toVersion *utils.Version, options *utils.PatchOptions) (*utils.Patch, error) {
 	fmt.Printf("Generating patch from %s to %s...\n", fromVersion.Number, toVersion.Number)
 
+	if err := ValidateVerification(options); err != nil {
+		return nil, err
+	}
+
 	// Compare manifests
 	added, modified, deleted := g.manifestManager.CompareManifests(fromVersion.Manifest, toVersion.Manifest)
 
@@ -38,23 +44,9 @@ func (g *Generator) GeneratePatch(fromVersion, toVersion *utils.Version, options
 		len(added), len(modified), len(deleted), len(addedDirs), len(deletedDirs))
 
 	// Create patch
-	patch := &utils.Patch{
-		FromVersion:   fromVersion.Number,
-		ToVersion:     toVersion.Number,
-		FromKeyFile:   fromVersion.KeyFile,
-		ToKeyFile:     toVersion.KeyFile,
-		RequiredFiles: make([]utils.FileRequirement, 0),
-		Operations:    make([]utils.PatchOperation, 0),
-	}
-
-	// Add required files (all files from source version)
-	for _, file := range fromVersion.Manifest.Files {
-		patch.RequiredFiles = append(patch.RequiredFiles, utils.FileRequirement{
-			Path:       file.Path,
-			Checksum:   file.Checksum,
-			Size:       file.Size,
-			IsRequired: true,
-		})
+	patch, err := newPatch(fromVersion, toVersion, modified, deleted, options)
+	if err != nil {
+		return nil, err
 	}
 
 	// Process added directories first (before adding files to them)
@@ -95,16 +87,19 @@ func (g *Generator) GeneratePatch(fromVersion, toVersion *utils.Version, options
 		fmt.Printf("  Delete directory: %s\n", dir)
 	}
 
+	readNewFile, err := newFileReader(toVersion, added, modified)
+	if err != nil {
+		return nil, err
+	}
+
 	// Process added files
 	totalAdded := len(added)
 	if totalAdded > 0 {
 		fmt.Printf("Processing %d added files...\n", totalAdded)
 	}
 	for _, file := range added {
-		fullPath := filepath.Join(toVersion.Location, file.Path)
-
 		// Read file directly (no streaming for large files)
-		fileData, err := os.ReadFile(fullPath)
+		fileData, err := readNewFile(file.Path)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read new file %s: %w", file.Path, err)
 		}
@@ -149,11 +144,8 @@ func (g *Generator) GeneratePatch(fromVersion, toVersion *utils.Version, options
 		// Use full file replacement for all modified files (no streaming)
 		fmt.Printf("  Processing modified file: %s\n", file.Path)
 
-		// Get file path
-		newPath := filepath.Join(toVersion.Location, file.Path)
-
 		// Read the new file data directly
-		newFileData, err := os.ReadFile(newPath)
+		newFileData, err := readNewFile(file.Path)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read new file %s: %w", file.Path, err)
 		}
@@ -185,6 +177,96 @@ func (g *Generator) GeneratePatch(fromVersion, toVersion *utils.Version, options
 	return patch, nil
 }
 
+// newPatch creates a patch between two versions without operations: versions, key files,
+// identities, hooks, branding and the source files to verify
+func newPatch(fromVersion, toVersion *utils.Version, modified, deleted []utils.FileEntry, options *utils.PatchOptions) (*utils.Patch, error) {
+	patch := &utils.Patch{
+		FromVersion:   fromVersion.Number,
+		ToVersion:     toVersion.Number,
+		FromKeyFile:   fromVersion.KeyFile,
+		ToKeyFile:     toVersion.KeyFile,
+		RequiredFiles: make([]utils.FileRequirement, 0),
+		Operations:    make([]utils.PatchOperation, 0),
+		Hooks:         options.Hooks,
+		Branding:      options.Branding,
+		SearchRoots:   options.SearchRoots,
+	}
+
+	// Record the composite identity of both versions, if requested
+	var err error
+	if patch.FromIdentity, err = BuildIdentity(fromVersion, options); err != nil {
+		return nil, fmt.Errorf("source version identity: %w", err)
+	}
+	if patch.ToIdentity, err = BuildIdentity(toVersion, options); err != nil {
+		return nil, fmt.Errorf("target version identity: %w", err)
+	}
+
+	// Embed the full target manifest so the applier can repair and verify the whole tree
+	if options.EmbedManifest {
+		patch.TargetManifest = toVersion.Manifest
+	}
+
+	// Add required files according to the verification level
+	patch.Verification = &utils.Verification{
+		Level:       options.VerificationLevel,
+		SourceFiles: len(fromVersion.Manifest.Files),
+	}
+	if patch.Verification.Level == "" {
+		patch.Verification.Level = utils.VerificationFull
+	}
+	if patch.Verification.Level == utils.VerificationSampled {
+		patch.Verification.SamplePercent = options.SamplePercent
+	}
+	patch.RequiredFiles = selectRequiredFiles(fromVersion, modified, deleted, patch.Verification)
+	if patch.Verification.Level != utils.VerificationFull {
+		fmt.Printf("Verification level %s: %d of %d source files required\n",
+			patch.Verification.Level, len(patch.RequiredFiles), len(fromVersion.Manifest.Files))
+	}
+	return patch, nil
+}
+
+// GenerateFullPatch generates a full-install patch that installs toVersion into an empty directory.
+// Every file is added, and the target manifest is always embedded so the install can be verified
+// and repaired like a patched one.
+func (g *Generator) GenerateFullPatch(toVersion *utils.Version, options *utils.PatchOptions) (*utils.Patch, error) {
+	empty, fullOptions := fullInstallSource(options)
+	patch, err := g.GeneratePatch(empty, toVersion, fullOptions)
+	if err != nil {
+		return nil, err
+	}
+	if err := makeFullInstall(patch, toVersion, options); err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+// fullInstallSource returns the empty source version of full-install patches and the options to
+// generate from it. The empty version has no identity; only the target's is recorded.
+func fullInstallSource(options *utils.PatchOptions) (*utils.Version, *utils.PatchOptions) {
+	empty := &utils.Version{Manifest: &utils.Manifest{}}
+	fullOptions := *options
+	fullOptions.IdentityFiles = nil
+	fullOptions.IdentitySample = 0
+	return empty, &fullOptions
+}
+
+// makeFullInstall turns a patch generated from the empty version into a full-install patch
+func makeFullInstall(patch *utils.Patch, toVersion *utils.Version, options *utils.PatchOptions) error {
+	var err error
+	if patch.ToIdentity, err = BuildIdentity(toVersion, options); err != nil {
+		return fmt.Errorf("target version identity: %w", err)
+	}
+
+	// There is no source version to verify
+	patch.FullInstall = true
+	patch.FromVersion = ""
+	patch.FromKeyFile = utils.KeyFileInfo{}
+	patch.RequiredFiles = make([]utils.FileRequirement, 0)
+	patch.Verification = nil
+	patch.TargetManifest = toVersion.Manifest
+	return nil
+}
+
 // CalculatePatchSize calculates the total size of patch operations
 func (g *Generator) CalculatePatchSize(patch *utils.Patch) int64 {
 	var totalSize int64
@@ -200,6 +282,37 @@ func (g *Generator) CalculatePatchSize(patch *utils.Patch) int64 {
 	return totalSize
 }
 
+// newFileReader returns a function that reads files of the target version by relative path. For a
+// version in an archive, the added and modified files are read up front in a single pass over the
+// archive, so nothing is extracted to disk.
+func newFileReader(toVersion *utils.Version, added, modified []utils.FileEntry) (func(path string) ([]byte, error), error) {
+	if !archive.IsArchive(toVersion.Location) {
+		return func(path string) ([]byte, error) {
+			return os.ReadFile(filepath.Join(toVersion.Location, path))
+		}, nil
+	}
+
+	var paths []string
+	for _, file := range append(append([]utils.FileEntry{}, added...), modified...) {
+		paths = append(paths, file.Path)
+	}
+	contents := map[string][]byte{}
+	if len(paths) > 0 {
+		fmt.Printf("Reading %d new files from %s...\n", len(paths), filepath.Base(toVersion.Location))
+		var err error
+		if contents, err = archive.ReadFiles(toVersion.Location, paths); err != nil {
+			return nil, fmt.Errorf("failed to read new files: %w", err)
+		}
+	}
+	return func(path string) ([]byte, error) {
+		data, ok := contents[path]
+		if !ok {
+			return nil, fmt.Errorf("%s was not read from the archive", path)
+		}
+		return data, nil
+	}, nil
+}
+
 // compareDirectories compares two directory lists and returns added and deleted directories
 func (g *Generator) compareDirectories(sourceDirs, targetDirs []string) (added, deleted []string) {
 	// Create maps for efficient lookup
@@ -233,15 +346,18 @@ func (g *Generator) compareDirectories(sourceDirs, targetDirs []string) (added,
 
 // ValidatePatch validates a patch before saving
 func (g *Generator) ValidatePatch(patch *utils.Patch) error {
-	if patch.FromVersion == "" {
+	if patch.FromVersion == "" && !patch.FullInstall {
 		return fmt.Errorf("source version is empty")
 	}
 	if patch.ToVersion == "" {
 		return fmt.Errorf("target version is empty")
 	}
-	if patch.FromKeyFile.Checksum == "" {
+	if patch.FromKeyFile.Checksum == "" && !patch.FullInstall {
 		return fmt.Errorf("source key file checksum is empty")
 	}
+	if patch.FullInstall && patch.TargetManifest == nil {
+		return fmt.Errorf("full-install patch has no target manifest")
+	}
 	if patch.ToKeyFile.Checksum == "" {
 		return fmt.Errorf("target key file checksum is empty")
 	}
@@ -292,3 +408,55 @@ func (g *Generator) ValidatePatch(patch *utils.Patch) error {
 
 	return nil
 }
+
+// ValidateVerification checks the verification level and sample percent in the patch options
+func ValidateVerification(options *utils.PatchOptions) error {
+	switch options.VerificationLevel {
+	case "", utils.VerificationFull, utils.VerificationTouched:
+		return nil
+	case utils.VerificationSampled:
+		if options.SamplePercent < 1 || options.SamplePercent > 99 {
+			return fmt.Errorf("sample percent must be between 1 and 99, got %d", options.SamplePercent)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown verification level %q (use full, touched or sampled)", options.VerificationLevel)
+}
+
+// selectRequiredFiles picks the source files the applier must verify before patching.
+// Touched files (modified, deleted and the key file) are always required; the full level requires
+// every file and the sampled level adds a deterministic sample of the untouched ones.
+func selectRequiredFiles(fromVersion *utils.Version, modified, deleted []utils.FileEntry, verification *utils.Verification) []utils.FileRequirement {
+	touched := make(map[string]bool, len(modified)+len(deleted)+1)
+	for _, file := range modified {
+		touched[file.Path] = true
+	}
+	for _, file := range deleted {
+		touched[file.Path] = true
+	}
+	touched[fromVersion.KeyFile.Path] = true
+
+	required := make([]utils.FileRequirement, 0)
+	for _, file := range fromVersion.Manifest.Files {
+		include := verification.Level == utils.VerificationFull || touched[file.Path] ||
+			(verification.Level == utils.VerificationSampled && inSample(file.Path, verification.SamplePercent))
+		if !include {
+			continue
+		}
+		required = append(required, utils.FileRequirement{
+			Path:       file.Path,
+			Checksum:   file.Checksum,
+			Size:       file.Size,
+			IsRequired: true,
+		})
+	}
+	return required
+}
+
+// inSample reports whether a path falls in the given percentage of files.
+// Selection depends only on the path, so regenerating a patch picks the same files.
+func inSample(path string, percent int) bool {
+	h := fnv.New32a()
+	h.Write([]byte(path))
+	return int(h.Sum32()%100) < percent
+}
@@ -0,0 +1,120 @@
+package patcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// maxHistoryEntries caps the number of installs remembered in the history file
+const maxHistoryEntries = 50
+
+// InstallRecord is an install directory the applier has patched successfully
+type InstallRecord struct {
+	Path      string    `json:"path"`
+	Version   string    `json:"version"`
+	KeyFile   string    `json:"key_file"`
+	Product   string    `json:"product,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// InstallHistory is the list of installs the applier has patched, most recent first
+type InstallHistory struct {
+	Installs []InstallRecord `json:"installs"`
+	path     string
+}
+
+// LoadInstallHistory reads the history file at path. A missing file is an empty history.
+func LoadInstallHistory(path string) (*InstallHistory, error) {
+	history := &InstallHistory{path: path}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	if err != nil {
+		return history, fmt.Errorf("failed to read install history: %w", err)
+	}
+	if err := json.Unmarshal(data, history); err != nil {
+		return history, fmt.Errorf("failed to parse install history %s: %w", path, err)
+	}
+	return history, nil
+}
+
+// Paths returns the recorded install directories, most recent first
+func (h *InstallHistory) Paths() []string {
+	paths := make([]string, len(h.Installs))
+	for i, install := range h.Installs {
+		paths[i] = install.Path
+	}
+	return paths
+}
+
+// Record remembers that dir was updated by patch, replacing any earlier record for dir
+func (h *InstallHistory) Record(dir string, patch *utils.Patch) {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+
+	record := InstallRecord{
+		Path:      dir,
+		Version:   patch.ToVersion,
+		KeyFile:   patch.ToKeyFile.Path,
+		UpdatedAt: time.Now().UTC(),
+	}
+	if patch.Branding != nil {
+		record.Product = patch.Branding.ProductName
+	}
+
+	installs := []InstallRecord{record}
+	for _, install := range h.Installs {
+		if install.Path != dir {
+			installs = append(installs, install)
+		}
+	}
+	sort.SliceStable(installs, func(i, j int) bool {
+		return installs[i].UpdatedAt.After(installs[j].UpdatedAt)
+	})
+	if len(installs) > maxHistoryEntries {
+		installs = installs[:maxHistoryEntries]
+	}
+	h.Installs = installs
+}
+
+// Save writes the history file, replacing it atomically
+func (h *InstallHistory) Save() error {
+	if err := utils.EnsureDir(filepath.Dir(h.path)); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	data, err := json.MarshalIndent(h, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal install history: %w", err)
+	}
+
+	tmpPath := h.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write install history: %w", err)
+	}
+	if err := os.Rename(tmpPath, h.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write install history: %w", err)
+	}
+	return nil
+}
+
+// RecordInstall adds dir to the install history at historyPath after a successful patch.
+// The history only helps find installs later, so failures are reported but not fatal.
+func RecordInstall(historyPath, dir string, patch *utils.Patch) {
+	history, err := LoadInstallHistory(historyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v (starting a new history)\n", err)
+	}
+	history.Record(dir, patch)
+	if err := history.Save(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update install history: %v\n", err)
+	}
+}
@@ -0,0 +1,219 @@
+package patcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// hooksFile is the on-disk format accepted by the generator's --hooks option
+type hooksFile struct {
+	Hooks []utils.Hook `json:"hooks"`
+}
+
+// LoadHooksFile reads and validates hook definitions from a JSON file
+func LoadHooksFile(path string) ([]utils.Hook, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hooks file: %w", err)
+	}
+
+	var file hooksFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to parse hooks file: %w", err)
+	}
+
+	if err := ValidateHooks(file.Hooks); err != nil {
+		return nil, err
+	}
+	return file.Hooks, nil
+}
+
+// ValidateHooks checks that every hook has a known phase and a command
+func ValidateHooks(hooks []utils.Hook) error {
+	for i, hook := range hooks {
+		switch hook.Phase {
+		case utils.HookPhasePreVerify, utils.HookPhasePreApply, utils.HookPhasePostApply, utils.HookPhaseOnRollback:
+		default:
+			return fmt.Errorf("hook %d: unknown phase %q (expected %s, %s, %s or %s)", i+1, hook.Phase,
+				utils.HookPhasePreVerify, utils.HookPhasePreApply, utils.HookPhasePostApply, utils.HookPhaseOnRollback)
+		}
+		if hook.Command == "" {
+			return fmt.Errorf("hook %d (%s): command is required", i+1, hook.Phase)
+		}
+		if hook.TimeoutSeconds < 0 {
+			return fmt.Errorf("hook %d (%s): timeout cannot be negative", i+1, hook.Phase)
+		}
+	}
+	return nil
+}
+
+// authorizeHooks verifies the patch signature (when trusted keys are configured) and decides whether
+// the patch's hooks may run. Hooks run if the user explicitly allowed them or the signature verifies.
+// Once trusted keys are configured, unsigned patches and invalid signatures are rejected outright.
+func (a *Applier) authorizeHooks(patch *utils.Patch) (bool, error) {
+	signatureOK := false
+	if len(a.trustedKeys) > 0 {
+		keyID, err := utils.VerifyPatchSignature(patch, a.trustedKeys)
+		if err != nil {
+			return false, fmt.Errorf("patch signature verification failed: %w", err)
+		}
+		fmt.Printf("Patch signature verified (key %s)\n", keyID)
+		signatureOK = true
+	}
+
+	if len(patch.Hooks) == 0 {
+		return false, nil
+	}
+
+	if a.allowHooks || signatureOK {
+		return true, nil
+	}
+
+	fmt.Printf("Warning: patch declares %d hook(s) but they will NOT run\n", len(patch.Hooks))
+	if utils.IsSigned(patch) {
+		fmt.Println("  The patch is signed, but no trusted key was provided to verify it (use --trust-key)")
+	} else {
+		fmt.Println("  The patch is not signed; use --allow-hooks to run its hooks anyway")
+	}
+	return false, nil
+}
+
+// runHooks runs every hook declared for a phase in declaration order.
+// A failing hook with AbortOnFailure returns an error; other failures are reported and ignored.
+func (a *Applier) runHooks(patch *utils.Patch, phase, targetDir string) error {
+	if !a.runPatchHooks {
+		return nil
+	}
+
+	for _, hook := range patch.Hooks {
+		if hook.Phase != phase {
+			continue
+		}
+
+		err := a.runHook(patch, hook, targetDir)
+		if err == nil {
+			continue
+		}
+		if hook.AbortOnFailure {
+			return fmt.Errorf("%s hook %q failed: %w", phase, hook.Command, err)
+		}
+		a.logf("Warning: %s hook %q failed (continuing): %v\n", phase, hook.Command, err)
+	}
+	return nil
+}
+
+// runHook executes a single hook with patch information in its environment,
+// streaming its output to the console and the apply log
+func (a *Applier) runHook(patch *utils.Patch, hook utils.Hook, targetDir string) error {
+	workingDir := targetDir
+	if hook.WorkingDir != "" {
+		workingDir = hook.WorkingDir
+		if !filepath.IsAbs(workingDir) {
+			workingDir = filepath.Join(targetDir, workingDir)
+		}
+	}
+
+	ctx := context.Background()
+	if hook.TimeoutSeconds > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(hook.TimeoutSeconds)*time.Second)
+		defer cancel()
+	}
+
+	absTarget, err := filepath.Abs(targetDir)
+	if err != nil {
+		absTarget = targetDir
+	}
+
+	cmd := exec.CommandContext(ctx, hook.Command, hook.Args...)
+	cmd.Dir = workingDir
+	cmd.Env = append(os.Environ(),
+		"CPM_PHASE="+hook.Phase,
+		"CPM_FROM_VERSION="+patch.FromVersion,
+		"CPM_TO_VERSION="+patch.ToVersion,
+		"CPM_TARGET_DIR="+absTarget,
+		"CPM_PATCH_FILE="+a.patchFilePath,
+	)
+	// Don't hang on child processes that keep the output pipes open after a timeout
+	cmd.WaitDelay = 5 * time.Second
+
+	output := &prefixWriter{out: a.hookOutput(), prefix: "  | "}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	a.logf("Running %s hook: %s\n", hook.Phase, formatHookCommand(hook))
+	err = cmd.Run()
+	output.Flush()
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %d seconds", hook.TimeoutSeconds)
+	}
+	if err != nil {
+		return err
+	}
+	a.logf("✓ %s hook completed\n", hook.Phase)
+	return nil
+}
+
+// hookOutput returns the writer that receives hook output: the console plus the apply log, if any
+func (a *Applier) hookOutput() io.Writer {
+	if a.logWriter != nil {
+		return io.MultiWriter(os.Stdout, a.logWriter)
+	}
+	return os.Stdout
+}
+
+// logf prints a message to the console and the apply log, if any
+func (a *Applier) logf(format string, args ...interface{}) {
+	fmt.Fprintf(a.hookOutput(), format, args...)
+}
+
+// formatHookCommand renders a hook's command line for display
+func formatHookCommand(hook utils.Hook) string {
+	command := hook.Command
+	for _, arg := range hook.Args {
+		command += " " + arg
+	}
+	return command
+}
+
+// prefixWriter prefixes every output line so hook output stands out in the console and log
+type prefixWriter struct {
+	out    io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		idx := bytes.IndexByte(w.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		if _, err := fmt.Fprintf(w.out, "%s%s\n", w.prefix, w.buf[:idx]); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[idx+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes any trailing partial line
+func (w *prefixWriter) Flush() {
+	if len(w.buf) > 0 {
+		fmt.Fprintf(w.out, "%s%s\n", w.prefix, w.buf)
+		w.buf = nil
+	}
+}
@@ -0,0 +1,264 @@
+package patcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// Kinds of identity checks
+const (
+	IdentityKeyFile     = "key file"
+	IdentityFile        = "identity file"
+	IdentityFingerprint = "fingerprint"
+)
+
+// IdentityCheck is the outcome of one check identifying the version of a directory
+type IdentityCheck struct {
+	Kind    string // One of the Identity* constants
+	Path    string // File checked, or a description of the fingerprint
+	Problem string // Why the check failed (empty = passed)
+	Missing bool   // True if the checked file does not exist
+}
+
+// IdentityReport lists the checks made to identify the version of a directory
+type IdentityReport struct {
+	Checks []IdentityCheck
+}
+
+// BuildIdentity returns the identity recorded for a version besides its key file, from the identity
+// files and fingerprint sample in the options. Returns nil if neither is set.
+func BuildIdentity(ver *utils.Version, options *utils.PatchOptions) (*utils.VersionIdentity, error) {
+	if len(options.IdentityFiles) == 0 && options.IdentitySample == 0 {
+		return nil, nil
+	}
+	if options.IdentitySample < 0 || options.IdentitySample > 100 {
+		return nil, fmt.Errorf("identity sample must be between 1 and 100 percent, got %d", options.IdentitySample)
+	}
+
+	files := make(map[string]utils.FileEntry, len(ver.Manifest.Files))
+	for _, file := range ver.Manifest.Files {
+		files[file.Path] = file
+	}
+
+	identity := &utils.VersionIdentity{}
+	for _, name := range options.IdentityFiles {
+		name = filepath.ToSlash(name)
+		if name == ver.KeyFile.Path {
+			continue
+		}
+		file, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("identity file %s is not in version %s", name, ver.Number)
+		}
+		identity.Files = append(identity.Files, utils.KeyFileInfo{Path: file.Path, Checksum: file.Checksum, Size: file.Size})
+	}
+
+	if options.IdentitySample > 0 {
+		identity.Fingerprint = buildFingerprint(ver, options.IdentitySample)
+	}
+	return identity, nil
+}
+
+// buildFingerprint samples the files of a version by path, so regenerating a patch picks the same
+// files. The key file is left out, since it is checked on its own; at least one file is sampled
+// when the version has any other file.
+func buildFingerprint(ver *utils.Version, percent int) *utils.Fingerprint {
+	checksums := make(map[string]string, len(ver.Manifest.Files))
+	var paths, others []string
+	for _, file := range ver.Manifest.Files {
+		if file.Path == ver.KeyFile.Path {
+			continue
+		}
+		checksums[file.Path] = file.Checksum
+		others = append(others, file.Path)
+		if inSample(file.Path, percent) {
+			paths = append(paths, file.Path)
+		}
+	}
+	sort.Strings(others)
+	if len(paths) == 0 && len(others) > 0 {
+		paths = others[:1]
+	}
+	sort.Strings(paths)
+
+	return &utils.Fingerprint{
+		SamplePercent: percent,
+		Paths:         paths,
+		Checksum:      utils.FingerprintChecksum(paths, checksums),
+	}
+}
+
+// CheckIdentity checks whether targetDir holds the version identified by keyFile and identity.
+// Every check is made, even after one fails, so the report can tell which parts of the identity
+// match. A key file path may be absolute, for a key file that was moved out of the install.
+func CheckIdentity(targetDir string, keyFile utils.KeyFileInfo, identity *utils.VersionIdentity) *IdentityReport {
+	report := &IdentityReport{}
+	report.Checks = append(report.Checks, checkIdentityFile(targetDir, IdentityKeyFile, keyFile))
+	if identity == nil {
+		return report
+	}
+	for _, file := range identity.Files {
+		report.Checks = append(report.Checks, checkIdentityFile(targetDir, IdentityFile, file))
+	}
+	if identity.Fingerprint != nil {
+		report.Checks = append(report.Checks, checkFingerprint(targetDir, identity.Fingerprint))
+	}
+	return report
+}
+
+// checkIdentityFile checks that a file exists with the expected checksum
+func checkIdentityFile(targetDir, kind string, file utils.KeyFileInfo) IdentityCheck {
+	check := IdentityCheck{Kind: kind, Path: file.Path}
+	path := identityPath(targetDir, file.Path)
+
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		check.Problem = "not found"
+		check.Missing = true
+		return check
+	}
+	checksum, err := utils.CalculateFileChecksum(path)
+	if err != nil {
+		check.Problem = fmt.Sprintf("could not be read: %v", err)
+		return check
+	}
+	if checksum != file.Checksum {
+		check.Problem = fmt.Sprintf("checksum mismatch: expected %s, got %s", ShortChecksum(file.Checksum), ShortChecksum(checksum))
+	}
+	return check
+}
+
+// checkFingerprint recomputes a fingerprint from the sampled files in targetDir
+func checkFingerprint(targetDir string, fingerprint *utils.Fingerprint) IdentityCheck {
+	check := IdentityCheck{Kind: IdentityFingerprint, Path: fmt.Sprintf("%d sampled files", len(fingerprint.Paths))}
+
+	checksums := make(map[string]string, len(fingerprint.Paths))
+	missing := 0
+	for _, path := range fingerprint.Paths {
+		checksum, err := utils.CalculateFileChecksum(identityPath(targetDir, path))
+		if err != nil {
+			missing++
+			continue
+		}
+		checksums[path] = checksum
+	}
+	switch {
+	case missing == len(fingerprint.Paths) && missing > 0:
+		check.Problem = "none of the sampled files were found"
+		check.Missing = true
+	case missing > 0:
+		check.Problem = fmt.Sprintf("%d of the sampled files are missing", missing)
+	case utils.FingerprintChecksum(fingerprint.Paths, checksums) != fingerprint.Checksum:
+		check.Problem = "the sampled files differ from the version's"
+	}
+	return check
+}
+
+// identityPath resolves the path of a checked file inside targetDir
+func identityPath(targetDir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(targetDir, filepath.FromSlash(path))
+}
+
+// OK reports whether every check passed
+func (r *IdentityReport) OK() bool {
+	return len(r.Failed()) == 0
+}
+
+// Failed returns the checks that failed
+func (r *IdentityReport) Failed() []IdentityCheck {
+	var failed []IdentityCheck
+	for _, check := range r.Checks {
+		if check.Problem != "" {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
+
+// Format renders the report as one line per check
+func (r *IdentityReport) Format() string {
+	var b strings.Builder
+	for _, check := range r.Checks {
+		label := strings.ToUpper(check.Kind[:1]) + check.Kind[1:]
+		if check.Problem == "" {
+			fmt.Fprintf(&b, "✓ %s verified: %s\n", label, check.Path)
+		} else {
+			fmt.Fprintf(&b, "✗ %s %s: %s\n", label, check.Path, check.Problem)
+		}
+	}
+	return b.String()
+}
+
+// Explain describes what the failed checks mean, or returns "" if every check passed
+// or the identity is the key file alone
+func (r *IdentityReport) Explain() string {
+	failed := r.Failed()
+	if len(failed) == 0 || len(r.Checks) == 1 {
+		return ""
+	}
+	keyFile := r.Checks[0]
+	switch {
+	case keyFile.Problem != "" && len(failed) == 1:
+		if keyFile.Missing {
+			return "The other identity checks match, so this looks like the right version with its key file renamed or removed; use --key-file if it was renamed."
+		}
+		return "The other identity checks match, so only the key file was changed; restore it before patching."
+	case keyFile.Problem == "":
+		return "The key file matches, but other files of the version do not: this is probably a different build with the same key file, or a modified install."
+	case len(failed) == len(r.Checks):
+		return "None of the identity checks match: this directory is not the expected version."
+	}
+	return "Several identity checks fail: this directory is not the expected version, or it was modified."
+}
+
+// Err returns nil if every check passed. A failed key-file-only identity gives the key file
+// error alone; otherwise every failed check is listed with an explanation.
+func (r *IdentityReport) Err() error {
+	failed := r.Failed()
+	if len(failed) == 0 {
+		return nil
+	}
+	if len(r.Checks) == 1 {
+		if failed[0].Missing {
+			return fmt.Errorf("key file not found: %s", failed[0].Path)
+		}
+		return fmt.Errorf("key file %s", failed[0].Problem)
+	}
+
+	problems := make([]string, 0, len(failed))
+	for _, check := range failed {
+		problems = append(problems, fmt.Sprintf("%s %s: %s", check.Kind, check.Path, check.Problem))
+	}
+	return fmt.Errorf("%d of %d identity checks failed (%s). %s",
+		len(failed), len(r.Checks), strings.Join(problems, "; "), r.Explain())
+}
+
+// IdentitySummary describes how a version is identified, e.g. "key file program.exe, 2 identity
+// files, fingerprint of 14 sampled files"
+func IdentitySummary(keyFile utils.KeyFileInfo, identity *utils.VersionIdentity) string {
+	parts := []string{"key file " + keyFile.Path}
+	if identity != nil {
+		if n := len(identity.Files); n == 1 {
+			parts = append(parts, "identity file "+identity.Files[0].Path)
+		} else if n > 1 {
+			parts = append(parts, fmt.Sprintf("%d identity files", n))
+		}
+		if identity.Fingerprint != nil {
+			parts = append(parts, fmt.Sprintf("fingerprint of %d sampled files", len(identity.Fingerprint.Paths)))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// verifyIdentity verifies that targetDir holds the version identified by keyFile and identity
+func (a *Applier) verifyIdentity(targetDir string, keyFile utils.KeyFileInfo, identity *utils.VersionIdentity) error {
+	return CheckIdentity(targetDir, keyFile, identity).Err()
+}
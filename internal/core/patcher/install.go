@@ -0,0 +1,92 @@
+package patcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// PrepareInstallDir creates the directory a full-install patch is applied to, or checks that an
+// existing one is empty. Only the applier's own bookkeeping (backup directory and lock file) may
+// already be there, so a failed install can be retried.
+func PrepareInstallDir(targetDir string) error {
+	info, err := os.Stat(targetDir)
+	if os.IsNotExist(err) {
+		if err := utils.EnsureDir(targetDir); err != nil {
+			return fmt.Errorf("failed to create install directory: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check install directory: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("install directory is a file: %s", targetDir)
+	}
+
+	existing, err := InstallDirContents(targetDir)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return fmt.Errorf("install directory is not empty (%s): a full install only goes into an empty directory; apply an update patch to an existing install",
+			strings.Join(existing, ", "))
+	}
+	return nil
+}
+
+// InstallDirContents lists the entries of a directory that would block a full install, at most
+// five of them. A missing directory has none.
+func InstallDirContents(targetDir string) ([]string, error) {
+	entries, err := os.ReadDir(targetDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read install directory: %w", err)
+	}
+
+	const maxListed = 5
+	var existing []string
+	for _, entry := range entries {
+		if entry.Name() == utils.BackupDirName || entry.Name() == utils.LockFileName {
+			continue
+		}
+		if len(existing) == maxListed {
+			existing = append(existing, "...")
+			break
+		}
+		existing = append(existing, entry.Name())
+	}
+	return existing, nil
+}
+
+// DefaultInstallDir returns where a full install goes when the user does not choose a directory:
+// the first of the patch's search roots that is set on this machine, or a folder named after the
+// product in the working directory.
+func DefaultInstallDir(patch *utils.Patch, workingDir string) string {
+	name := "install-" + patch.ToVersion
+	if patch.Branding != nil && patch.Branding.ProductName != "" {
+		name = patch.Branding.ProductName
+	}
+	for _, root := range patch.SearchRoots {
+		if expanded, ok := ExpandSearchRoot(root); ok {
+			return filepath.Join(expanded, name)
+		}
+	}
+	return filepath.Join(workingDir, name)
+}
+
+// applyManifestModes sets the permissions of every file of a fresh install to those in the manifest
+func applyManifestModes(targetDir string, m *utils.Manifest) error {
+	for _, entry := range m.Files {
+		path := filepath.Join(targetDir, filepath.FromSlash(entry.Path))
+		if err := os.Chmod(path, manifestFileMode(entry)); err != nil {
+			return fmt.Errorf("failed to set permissions of %s: %w", entry.Path, err)
+		}
+	}
+	return nil
+}
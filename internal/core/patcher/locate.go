@@ -0,0 +1,164 @@
+package patcher
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// searchRootDepth is how many directory levels below a search root are checked for the install
+const searchRootDepth = 2
+
+// Where a located install was found
+const (
+	SourceWorkingDir = "current directory"
+	SourceExeDir     = "updater location"
+	SourceSearchRoot = "search root"
+	SourceHistory    = "previous update"
+)
+
+// windowsEnvPattern matches Windows-style %VAR% references
+var windowsEnvPattern = regexp.MustCompile(`%([A-Za-z_][A-Za-z0-9_()]*)%`)
+
+// InstallMatch is a directory whose key file matches a patch's source version
+type InstallMatch struct {
+	Dir    string
+	Source string // One of the Source* constants
+}
+
+// LocateOptions lists the places searched besides the patch's own search roots
+type LocateOptions struct {
+	WorkingDir string   // Directory the applier was started in
+	ExeDir     string   // Directory of the applier executable; it and its parents are checked
+	History    []string // Install directories from the install history
+}
+
+// LocateInstalls finds directories whose key file matches the patch's source version.
+// Candidates are checked in order: working directory, the executable's directory and its parents,
+// the patch's search roots (and up to two levels below them), then the install history.
+func LocateInstalls(patch *utils.Patch, opts LocateOptions) []InstallMatch {
+	var matches []InstallMatch
+	seen := make(map[string]bool)
+
+	check := func(dir, source string) {
+		if dir == "" {
+			return
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		key := filepath.Clean(dir)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		if keyFileMatches(dir, patch.FromKeyFile) && CheckIdentity(dir, patch.FromKeyFile, patch.FromIdentity).OK() {
+			matches = append(matches, InstallMatch{Dir: dir, Source: source})
+		}
+	}
+
+	check(opts.WorkingDir, SourceWorkingDir)
+
+	if opts.ExeDir != "" {
+		dir := filepath.Clean(opts.ExeDir)
+		for {
+			check(dir, SourceExeDir)
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+
+	for _, root := range patch.SearchRoots {
+		expanded, ok := ExpandSearchRoot(root)
+		if !ok {
+			continue
+		}
+		for _, dir := range dirsBelow(expanded, searchRootDepth) {
+			check(dir, SourceSearchRoot)
+		}
+	}
+
+	for _, dir := range opts.History {
+		check(dir, SourceHistory)
+	}
+	return matches
+}
+
+// keyFileMatches reports whether dir contains the key file with the expected size and checksum
+func keyFileMatches(dir string, keyFile utils.KeyFileInfo) bool {
+	path := filepath.Join(dir, filepath.FromSlash(keyFile.Path))
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	// Size is a cheap check before hashing
+	if keyFile.Size > 0 && info.Size() != keyFile.Size {
+		return false
+	}
+	checksum, err := utils.CalculateFileChecksum(path)
+	return err == nil && checksum == keyFile.Checksum
+}
+
+// dirsBelow returns root and its subdirectories down to depth levels, skipping unreadable directories
+func dirsBelow(root string, depth int) []string {
+	info, err := os.Stat(root)
+	if err != nil || !info.IsDir() {
+		return nil
+	}
+
+	dirs := []string{root}
+	level := []string{root}
+	for d := 0; d < depth; d++ {
+		var next []string
+		for _, dir := range level {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				continue
+			}
+			for _, entry := range entries {
+				if entry.IsDir() {
+					next = append(next, filepath.Join(dir, entry.Name()))
+				}
+			}
+		}
+		dirs = append(dirs, next...)
+		level = next
+	}
+	return dirs
+}
+
+// ExpandSearchRoot expands ~ and environment variables ($VAR, ${VAR} and %VAR%) in a search root.
+// Returns false if the root refers to a variable that is not set on this machine.
+func ExpandSearchRoot(root string) (string, bool) {
+	ok := true
+	lookup := func(name string) string {
+		value, found := os.LookupEnv(name)
+		if !found || value == "" {
+			ok = false
+		}
+		return value
+	}
+
+	root = windowsEnvPattern.ReplaceAllStringFunc(root, func(ref string) string {
+		return lookup(ref[1 : len(ref)-1])
+	})
+	root = os.Expand(root, lookup)
+
+	if root == "~" || strings.HasPrefix(root, "~/") || strings.HasPrefix(root, `~\`) {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", false
+		}
+		root = filepath.Join(home, root[1:])
+	}
+	if !ok || strings.TrimSpace(root) == "" {
+		return "", false
+	}
+	return filepath.Clean(root), true
+}
@@ -0,0 +1,152 @@
+package patcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// lockAttempts bounds the retries when the lock file is removed by its previous owner while we wait for it
+const lockAttempts = 3
+
+// errLockHeld is returned by lockFile when another process holds the OS lock on the file
+var errLockHeld = errors.New("lock is held by another process")
+
+// LockInfo identifies the process holding an install lock
+type LockInfo struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	StartTime time.Time `json:"start_time"`
+	Operation string    `json:"operation"` // "apply", "rollback", "repair"
+}
+
+// InstallLock is an exclusive lock on an install directory.
+// The lock file is locked with an OS file lock for as long as it is held, so the lock of a
+// process that dies is released by the operating system; the file only records who holds it.
+type InstallLock struct {
+	path string
+	info LockInfo
+	file *os.File
+}
+
+// LockHeldError is returned when another live process holds the install lock
+type LockHeldError struct {
+	Path string
+	Info LockInfo
+}
+
+func (e *LockHeldError) Error() string {
+	if e.Info.PID == 0 {
+		return fmt.Sprintf("install is locked by another process (lock file %s)", e.Path)
+	}
+	return fmt.Sprintf("install is locked by another %s operation (PID %d on %s, started %s)",
+		e.Info.Operation, e.Info.PID, e.Info.Host, e.Info.StartTime.Format("2006-01-02 15:04:05"))
+}
+
+// AcquireLock takes the exclusive lock for targetDir.
+// A lock file left behind by a process that died is no longer locked and is taken over.
+func AcquireLock(targetDir, operation string) (*InstallLock, error) {
+	host, _ := os.Hostname()
+	lock := &InstallLock{
+		path: filepath.Join(targetDir, utils.LockFileName),
+		info: LockInfo{
+			PID:       os.Getpid(),
+			Host:      host,
+			StartTime: time.Now(),
+			Operation: operation,
+		},
+	}
+
+	for attempt := 0; attempt < lockAttempts; attempt++ {
+		file, err := os.OpenFile(lock.path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+		if err := lockFile(file); err != nil {
+			file.Close()
+			if errors.Is(err, errLockHeld) {
+				return nil, &LockHeldError{Path: lock.path, Info: readLockInfo(lock.path)}
+			}
+			return nil, fmt.Errorf("failed to lock %s: %w", lock.path, err)
+		}
+
+		// The previous owner removes the file on release; a lock on a file that is gone protects nothing
+		if current, err := os.Stat(lock.path); err != nil || !sameFile(file, current) {
+			file.Close()
+			continue
+		}
+
+		lock.file = file
+		if err := lock.writeInfo(); err != nil {
+			lock.Release()
+			return nil, err
+		}
+		return lock, nil
+	}
+
+	return nil, &LockHeldError{Path: lock.path}
+}
+
+// writeInfo replaces the contents of the locked file with the owner of this lock,
+// reporting a previous owner that died without releasing it
+func (l *InstallLock) writeInfo() error {
+	var previous LockInfo
+	if data, err := os.ReadFile(l.path); err == nil && json.Unmarshal(data, &previous) == nil && previous.PID != 0 {
+		fmt.Printf("Taking over stale lock left by PID %d (%s operation started %s)\n",
+			previous.PID, previous.Operation, previous.StartTime.Format("2006-01-02 15:04:05"))
+	}
+
+	data, err := json.MarshalIndent(l.info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode lock file: %w", err)
+	}
+	if err := l.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	if _, err := l.file.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return nil
+}
+
+// Release removes the lock file and releases the OS lock
+func (l *InstallLock) Release() error {
+	if l.file == nil {
+		return nil
+	}
+
+	// Remove while still locked so nobody can lock the old file in between. Windows refuses to remove
+	// an open file, so there it is removed after closing; if another process opened it by then, that
+	// process holds it now and the file stays.
+	removed := os.Remove(l.path) == nil
+	err := l.file.Close()
+	l.file = nil
+	if !removed {
+		os.Remove(l.path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to release lock file: %w", err)
+	}
+	return nil
+}
+
+// sameFile reports whether an open file is the file described by info
+func sameFile(file *os.File, info os.FileInfo) bool {
+	stat, err := file.Stat()
+	return err == nil && os.SameFile(stat, info)
+}
+
+// readLockInfo reads the owner recorded in a lock file; the zero LockInfo if it cannot be read
+// (for example while the owner is still writing it)
+func readLockInfo(path string) LockInfo {
+	var info LockInfo
+	if data, err := os.ReadFile(path); err == nil {
+		json.Unmarshal(data, &info)
+	}
+	return info
+}
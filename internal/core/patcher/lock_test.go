@@ -0,0 +1,115 @@
+package patcher
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+func TestAcquireLock(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing *LockInfo // Lock file left in the directory before acquiring (nil = none)
+	}{
+		{name: "no lock file"},
+		{name: "lock file left by a dead process", existing: &LockInfo{PID: 999999, Host: "old-host", Operation: "apply"}},
+		{name: "empty lock file", existing: &LockInfo{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			lockPath := filepath.Join(dir, utils.LockFileName)
+			if tt.existing != nil {
+				data, _ := json.Marshal(tt.existing)
+				if err := os.WriteFile(lockPath, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			lock, err := AcquireLock(dir, "apply")
+			if err != nil {
+				t.Fatalf("AcquireLock() error = %v", err)
+			}
+			if info := readLockInfo(lockPath); info.PID != os.Getpid() || info.Operation != "apply" {
+				t.Errorf("lock file records %+v, want this process", info)
+			}
+
+			if err := lock.Release(); err != nil {
+				t.Fatalf("Release() error = %v", err)
+			}
+			if utils.FileExists(lockPath) {
+				t.Error("lock file still exists after Release()")
+			}
+		})
+	}
+}
+
+func TestAcquireLockHeld(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := AcquireLock(dir, "rollback")
+	if err != nil {
+		t.Fatalf("AcquireLock() error = %v", err)
+	}
+	defer lock.Release()
+
+	_, err = AcquireLock(dir, "apply")
+	var held *LockHeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("second AcquireLock() error = %v, want LockHeldError", err)
+	}
+	if held.Info.PID != os.Getpid() || held.Info.Operation != "rollback" {
+		t.Errorf("LockHeldError reports %+v, want the first holder", held.Info)
+	}
+}
+
+func TestAcquireLockContention(t *testing.T) {
+	dir := t.TempDir()
+
+	const workers = 20
+	const rounds = 20
+	var holders, maxHolders atomic.Int32
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := 0; r < rounds; r++ {
+				lock, err := AcquireLock(dir, "apply")
+				var held *LockHeldError
+				if errors.As(err, &held) {
+					continue
+				}
+				if err != nil {
+					t.Errorf("AcquireLock() error = %v", err)
+					return
+				}
+
+				n := holders.Add(1)
+				for {
+					m := maxHolders.Load()
+					if n <= m || maxHolders.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				holders.Add(-1)
+
+				if err := lock.Release(); err != nil {
+					t.Errorf("Release() error = %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := maxHolders.Load(); got != 1 {
+		t.Errorf("at most %d holders at once, want 1", got)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build unix
+
+package patcher
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile takes a non-blocking exclusive flock on an open file.
+// Returns errLockHeld if another process holds it; closing the file releases it.
+func lockFile(file *os.File) error {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return errLockHeld
+	}
+	return err
+}
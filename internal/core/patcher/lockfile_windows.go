@@ -0,0 +1,34 @@
+//go:build windows
+
+package patcher
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	lockfileFailImmediately = 0x1
+	lockfileExclusiveLock   = 0x2
+	errorLockViolation      = syscall.Errno(33)
+)
+
+var procLockFileEx = syscall.NewLazyDLL("kernel32.dll").NewProc("LockFileEx")
+
+// lockFile takes a non-blocking exclusive lock on an open file.
+// Returns errLockHeld if another process holds it; closing the file releases it.
+// Windows locks are mandatory for the locked range, so a byte far beyond the contents is locked
+// to keep the owner information readable.
+func lockFile(file *os.File) error {
+	overlapped := syscall.Overlapped{OffsetHigh: 0x7fffffff}
+	r, _, err := procLockFileEx.Call(file.Fd(), lockfileExclusiveLock|lockfileFailImmediately, 0, 1, 0,
+		uintptr(unsafe.Pointer(&overlapped)))
+	if r != 0 {
+		return nil
+	}
+	if err == errorLockViolation {
+		return errLockHeld
+	}
+	return err
+}
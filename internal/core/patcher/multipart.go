@@ -4,9 +4,9 @@ import (
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
 
 	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
@@ -25,29 +25,13 @@ func (g *Generator) SplitPatchIntoParts(patch *utils.Patch, maxPartSize int64) (
 	fmt.Printf("\nPatch size (%d bytes) exceeds limit (%d bytes), splitting into multiple parts...\n",
 		totalSize, maxPartSize)
 
-	// Sort operations by size (smallest first, but key file operations first)
-	sortedOps := make([]utils.PatchOperation, len(patch.Operations))
-	copy(sortedOps, patch.Operations)
-
-	sort.Slice(sortedOps, func(i, j int) bool {
-		// Prioritize key file operations (must be in part 1)
-		iIsKeyFile := sortedOps[i].FilePath == patch.ToKeyFile.Path
-		jIsKeyFile := sortedOps[j].FilePath == patch.ToKeyFile.Path
-
-		if iIsKeyFile != jIsKeyFile {
-			return iIsKeyFile // Key file comes first
-		}
-
-		// Then sort by size (smallest first)
-		return sortedOps[i].Size < sortedOps[j].Size
-	})
-
-	// Split operations into parts
+	// Split operations into parts in the order they are applied, so the merged parts apply
+	// (and verify against the signature) exactly like the unsplit patch
 	var parts []*utils.Patch
 	var currentPart *utils.Patch
 	var currentSize int64
 
-	for _, op := range sortedOps {
+	for _, op := range patch.Operations {
 		opSize := op.Size
 		if op.NewFile != nil {
 			opSize = int64(len(op.NewFile))
@@ -79,6 +63,13 @@ func (g *Generator) SplitPatchIntoParts(patch *utils.Patch, maxPartSize int64) (
 				RequiredFiles: patch.RequiredFiles,
 				Operations:    make([]utils.PatchOperation, 0),
 				SimpleMode:    patch.SimpleMode,
+				Hooks:         patch.Hooks,
+				Verification:  patch.Verification,
+				Branding:      patch.Branding,
+				SearchRoots:   patch.SearchRoots,
+				FullInstall:   patch.FullInstall,
+				FromIdentity:  patch.FromIdentity,
+				ToIdentity:    patch.ToIdentity,
 			}
 			currentSize = 0
 		}
@@ -93,6 +84,11 @@ func (g *Generator) SplitPatchIntoParts(patch *utils.Patch, maxPartSize int64) (
 		parts = append(parts, currentPart)
 	}
 
+	// The target manifest can be large; only part 1 carries it
+	if len(parts) > 0 {
+		parts[0].TargetManifest = patch.TargetManifest
+	}
+
 	// Update multi-part metadata
 	totalParts := len(parts)
 	for i, part := range parts {
@@ -118,10 +114,52 @@ func (g *Generator) SplitPatchIntoParts(patch *utils.Patch, maxPartSize int64) (
 	return parts, nil
 }
 
-// SaveMultiPartPatch saves a multi-part patch to disk
-func (g *Generator) SaveMultiPartPatch(parts []*utils.Patch, basePath string, compression string, chunkSize int64, level int) error {
+// MultiPartFiles lists the files a multi-part patch was saved to
+type MultiPartFiles struct {
+	Part01   string   // Part 01 with the multi-part info (a stub without file data if part 1 was chunked)
+	Parts    []string // Files holding the other parts and chunks: <base>.NN.patch and <base>.partN.M.patch
+	Sidecars []string // Chunk sidecars: <base>.partN.chunks.json
+}
+
+// ListMultiPartFiles lists the files of the saved multi-part patch whose part 01 is part01Path, as
+// its metadata records them: every part up to its part count, read from a single file or from the
+// chunks its sidecar lists, like the loader reads them. Other files next to it, such as parts left
+// by an earlier build, are not listed.
+func ListMultiPartFiles(part01Path string) (*MultiPartFiles, error) {
+	part1, err := utils.LoadPatchInfo(part01Path)
+	if err != nil {
+		return nil, err
+	}
+	if part1.MultiPart == nil || !part1.MultiPart.IsMultiPart {
+		return nil, fmt.Errorf("%s is not part 1 of a multi-part patch", part01Path)
+	}
+
+	dir := filepath.Dir(part01Path)
+	source := NewDirPartSource(dir, strings.TrimSuffix(filepath.Base(part01Path), ".01.patch"))
+	files := &MultiPartFiles{Part01: part01Path}
+	for n := 1; n <= part1.MultiPart.TotalParts; n++ {
+		chunks, chunked, err := source.chunks(n)
+		if err != nil {
+			return nil, err
+		}
+		if !chunked {
+			if n > 1 {
+				files.Parts = append(files.Parts, filepath.Join(dir, source.partName(n)))
+			}
+			continue
+		}
+		files.Sidecars = append(files.Sidecars, filepath.Join(dir, source.sidecarName(n)))
+		for _, chunk := range chunks {
+			files.Parts = append(files.Parts, filepath.Join(dir, chunk.FileName))
+		}
+	}
+	return files, nil
+}
+
+// SaveMultiPartPatch saves a multi-part patch to disk and returns the files it wrote
+func (g *Generator) SaveMultiPartPatch(parts []*utils.Patch, basePath string, compression string, chunkSize int64, level int) (*MultiPartFiles, error) {
 	if len(parts) == 0 {
-		return fmt.Errorf("no parts to save")
+		return nil, fmt.Errorf("no parts to save")
 	}
 
 	// Extract base filename and directory
@@ -131,6 +169,7 @@ func (g *Generator) SaveMultiPartPatch(parts []*utils.Patch, basePath string, co
 
 	// First, save all parts to their final locations (without PartHashes yet)
 	partPaths := make([]string, len(parts))
+	files := &MultiPartFiles{}
 	for i, part := range parts {
 		partFile := fmt.Sprintf("%s.%02d.patch", baseFile, i+1)
 		partPath := filepath.Join(baseDir, partFile)
@@ -142,7 +181,7 @@ func (g *Generator) SaveMultiPartPatch(parts []*utils.Patch, basePath string, co
 		}
 
 		if err := utils.SavePatch(part, partPath, compression, level); err != nil {
-			return fmt.Errorf("failed to save part %d: %w", i+1, err)
+			return nil, fmt.Errorf("failed to save part %d: %w", i+1, err)
 		}
 	}
 
@@ -163,7 +202,7 @@ func (g *Generator) SaveMultiPartPatch(parts []*utils.Patch, basePath string, co
 	for i, partPath := range partPaths {
 		data, err := os.ReadFile(partPath)
 		if err != nil {
-			return fmt.Errorf("failed to read saved part %d for hashing: %w", i+1, err)
+			return nil, fmt.Errorf("failed to read saved part %d for hashing: %w", i+1, err)
 		}
 
 		// Compute overall checksum for the part
@@ -200,8 +239,9 @@ func (g *Generator) SaveMultiPartPatch(parts []*utils.Patch, basePath string, co
 				chunkPath := filepath.Join(baseDir, chunkFileName)
 
 				if err := os.WriteFile(chunkPath, chunkData, 0644); err != nil {
-					return fmt.Errorf("failed to write chunk file %s: %w", chunkPath, err)
+					return nil, fmt.Errorf("failed to write chunk file %s: %w", chunkPath, err)
 				}
+				files.Parts = append(files.Parts, chunkPath)
 
 				// Compute chunk checksum
 				chash := sha256.Sum256(chunkData)
@@ -216,7 +256,7 @@ func (g *Generator) SaveMultiPartPatch(parts []*utils.Patch, basePath string, co
 
 			// Remove original large part file to avoid confusion (we will reconstruct when loading)
 			if err := os.Remove(partPath); err != nil {
-				return fmt.Errorf("failed to remove original part after chunking: %w", err)
+				return nil, fmt.Errorf("failed to remove original part after chunking: %w", err)
 			}
 
 			// Mark this part as chunked
@@ -234,17 +274,25 @@ func (g *Generator) SaveMultiPartPatch(parts []*utils.Patch, basePath string, co
 			out := chunkOut{PartNumber: i + 1, Chunks: chunks}
 			jb, err := json.MarshalIndent(out, "", "  ")
 			if err != nil {
-				return fmt.Errorf("failed to marshal chunk sidecar: %w", err)
+				return nil, fmt.Errorf("failed to marshal chunk sidecar: %w", err)
 			}
 			if err := os.WriteFile(sidecarPath, jb, 0644); err != nil {
-				return fmt.Errorf("failed to write chunk sidecar: %w", err)
+				return nil, fmt.Errorf("failed to write chunk sidecar: %w", err)
 			}
+			files.Sidecars = append(files.Sidecars, sidecarPath)
 
 			// Also record chunk sidecar filename in a simple header area: we will set a PartHash.Size to the combined size (already set) and rely on sidecar for reconstruction
 			// The part file on disk was removed; reconstruction will use sidecar + chunk files
 		}
 	}
 
+	files.Part01 = partPaths[0]
+	for i := 1; i < len(partPaths); i++ {
+		if !chunked[i] {
+			files.Parts = append(files.Parts, partPaths[i])
+		}
+	}
+
 	// Attach final PartHashes to part 1 and save it again.
 	// If part 1 was chunked, write a small stub part 01 that contains only header/multi-part metadata
 	parts[0].MultiPart.PartHashes = partHashes
@@ -266,10 +314,13 @@ func (g *Generator) SaveMultiPartPatch(parts []*utils.Patch, basePath string, co
 			}
 		}
 		stub.Operations = stubOps
+		stubInfo := *stub.MultiPart
+		stubInfo.Stub = true
+		stub.MultiPart = &stubInfo
 
 		// Save stub part 01
 		if err := utils.SavePatch(&stub, partPaths[0], compression, level); err != nil {
-			return fmt.Errorf("failed to save stubbed part 1: %w", err)
+			return nil, fmt.Errorf("failed to save stubbed part 1: %w", err)
 		}
 		// Update reported size for part 1 to the stub size
 		finalData, err := os.ReadFile(partPaths[0])
@@ -279,7 +330,7 @@ func (g *Generator) SaveMultiPartPatch(parts []*utils.Patch, basePath string, co
 	} else {
 		// No stub needed; save full part 01 (with PartHashes filled)
 		if err := utils.SavePatch(parts[0], partPaths[0], compression, level); err != nil {
-			return fmt.Errorf("failed to save updated part 1: %w", err)
+			return nil, fmt.Errorf("failed to save updated part 1: %w", err)
 		}
 
 		// Update sizes if part1 file changed
@@ -297,7 +348,7 @@ func (g *Generator) SaveMultiPartPatch(parts []*utils.Patch, basePath string, co
 		fmt.Printf("  Part %d: %d bytes\n", i+1, ph.Size)
 	}
 
-	return nil
+	return files, nil
 }
 
 // LoadMultiPartPatch loads all parts of a multi-part patch
@@ -308,6 +359,15 @@ func LoadMultiPartPatch(part1Path string) (*utils.Patch, error) {
 		return nil, fmt.Errorf("failed to load part 1: %w", err)
 	}
 
+	// Extract base path
+	baseFile := strings.TrimSuffix(filepath.Base(part1Path), ".01.patch")
+
+	return LoadMultiPartPatchFrom(part1, NewDirPartSource(filepath.Dir(part1Path), baseFile))
+}
+
+// LoadMultiPartPatchFrom loads the remaining parts of a multi-part patch from source and merges them with part 1.
+// Each part is streamed through the decoder while its hash is computed, so no part is buffered or copied to disk.
+func LoadMultiPartPatchFrom(part1 *utils.Patch, source PartSource) (*utils.Patch, error) {
 	// Check if it's multi-part
 	if part1.MultiPart == nil || !part1.MultiPart.IsMultiPart {
 		// Single-part patch
@@ -321,132 +381,53 @@ func LoadMultiPartPatch(part1Path string) (*utils.Patch, error) {
 		return nil, fmt.Errorf("part 1 missing hash information for all parts")
 	}
 
-	// Extract base path
-	baseDir := filepath.Dir(part1Path)
-	baseFile := filepath.Base(part1Path)
-	baseFile = strings.TrimSuffix(baseFile, ".01.patch")
-
-	// Load and verify all parts
+	// Load and verify all parts. A stub part 1 has no file data; the full part 1 is in its chunks.
 	var allOperations []utils.PatchOperation
-	allOperations = append(allOperations, part1.Operations...)
+	if part1.MultiPart.Stub {
+		fmt.Println("Loading part 1")
+		full, err := loadPart(source, 1, part1.MultiPart.PartHashes[0].Checksum)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println("  ✓ Part 1 hash verified")
+		allOperations = append(allOperations, full.Operations...)
+	} else {
+		allOperations = append(allOperations, part1.Operations...)
+	}
 
 	for i := 2; i <= part1.MultiPart.TotalParts; i++ {
-		partFile := fmt.Sprintf("%s.%02d.patch", baseFile, i)
-		partPath := filepath.Join(baseDir, partFile)
+		fmt.Printf("Loading part %d\n", i)
 
-		fmt.Printf("Loading part %d: %s\n", i, partFile)
-
-		// Check for chunk sidecar for this part: <baseFile>.part<partNum>.chunks.json
-		sidecarName := fmt.Sprintf("%s.part%d.chunks.json", baseFile, i)
-		sidecarPath := filepath.Join(baseDir, sidecarName)
-
-		var toLoadPath string
-
-		if utils.FileExists(sidecarPath) {
-			// Reconstruct full part from chunks listed in sidecar
-			sideData, err := os.ReadFile(sidecarPath)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read chunk sidecar for part %d: %w", i, err)
-			}
-
-			var parsed struct {
-				PartNumber int               `json:"part_number"`
-				Chunks     []utils.PartChunk `json:"chunks"`
-			}
-			if err := json.Unmarshal(sideData, &parsed); err != nil {
-				return nil, fmt.Errorf("failed to parse chunk sidecar for part %d: %w", i, err)
-			}
-
-			// Create temporary file to reassemble
-			tmp, err := os.CreateTemp("", "cpm_part_reconstruct_*.patch")
-			if err != nil {
-				return nil, fmt.Errorf("failed to create temp file for part %d reconstruction: %w", i, err)
-			}
-			defer func() { tmp.Close(); os.Remove(tmp.Name()) }()
-
-			// Write chunks in order
-			for _, chunk := range parsed.Chunks {
-				chunkPath := filepath.Join(baseDir, chunk.FileName)
-				cdata, err := os.ReadFile(chunkPath)
-				if err != nil {
-					return nil, fmt.Errorf("failed to read chunk %s for part %d: %w", chunk.FileName, i, err)
-				}
-				// verify chunk checksum
-				ch := sha256.Sum256(cdata)
-				if fmt.Sprintf("%x", ch) != chunk.Checksum {
-					return nil, fmt.Errorf("chunk %s checksum mismatch for part %d", chunk.FileName, i)
-				}
-				if _, err := tmp.Write(cdata); err != nil {
-					return nil, fmt.Errorf("failed to write chunk data to temp for part %d: %w", i, err)
-				}
-			}
-
-			// Ensure flush
-			if err := tmp.Sync(); err != nil {
-				return nil, fmt.Errorf("failed to sync temp file for part %d: %w", i, err)
-			}
-
-			// Compute overall hash of reconstructed file and compare to expected
-			if _, err := tmp.Seek(0, 0); err != nil {
-				return nil, fmt.Errorf("failed to rewind temp file for part %d: %w", i, err)
-			}
-			reconData, err := os.ReadFile(tmp.Name())
-			if err != nil {
-				return nil, fmt.Errorf("failed to read reconstructed temp for part %d: %w", i, err)
-			}
-			rhash := sha256.Sum256(reconData)
-			expectedHash := part1.MultiPart.PartHashes[i-1].Checksum
-			if fmt.Sprintf("%x", rhash) != expectedHash {
-				return nil, fmt.Errorf("reconstructed part %d hash mismatch: expected %s, got %s", i, expectedHash[:16]+"...", fmt.Sprintf("%x", rhash)[:16]+"...")
-			}
-
-			fmt.Printf("  ✓ Reconstructed Part %d hash verified\n", i)
-
-			toLoadPath = tmp.Name()
-		} else {
-			// No sidecar; load the part file directly
-			// Read part file
-			partData, err := os.ReadFile(partPath)
-			if err != nil {
-				return nil, fmt.Errorf("failed to read part %d: %w", i, err)
-			}
-
-			// Verify hash
-			hash := sha256.Sum256(partData)
-			expectedHash := part1.MultiPart.PartHashes[i-1].Checksum
-			actualHash := fmt.Sprintf("%x", hash)
-
-			if actualHash != expectedHash {
-				return nil, fmt.Errorf("part %d hash mismatch: expected %s, got %s",
-					i, expectedHash[:16]+"...", actualHash[:16]+"...")
-			}
-
-			fmt.Printf("  ✓ Part %d hash verified\n", i)
-
-			toLoadPath = partPath
-		}
-
-		// Load part (either reconstructed temp or the file on disk)
-		part, err := utils.LoadPatch(toLoadPath)
+		part, err := loadPart(source, i, part1.MultiPart.PartHashes[i-1].Checksum)
 		if err != nil {
-			return nil, fmt.Errorf("failed to load part %d: %w", i, err)
+			return nil, err
 		}
 
+		fmt.Printf("  ✓ Part %d hash verified\n", i)
+
 		// Merge operations
 		allOperations = append(allOperations, part.Operations...)
 	}
 
 	// Create combined patch
 	combinedPatch := &utils.Patch{
-		Header:        part1.Header,
-		FromVersion:   part1.FromVersion,
-		ToVersion:     part1.ToVersion,
-		FromKeyFile:   part1.FromKeyFile,
-		ToKeyFile:     part1.ToKeyFile,
-		RequiredFiles: part1.RequiredFiles,
-		Operations:    allOperations,
-		SimpleMode:    part1.SimpleMode,
-		MultiPart:     part1.MultiPart, // Keep multi-part info for reference
+		Header:         part1.Header,
+		FromVersion:    part1.FromVersion,
+		ToVersion:      part1.ToVersion,
+		FromKeyFile:    part1.FromKeyFile,
+		ToKeyFile:      part1.ToKeyFile,
+		RequiredFiles:  part1.RequiredFiles,
+		Operations:     allOperations,
+		SimpleMode:     part1.SimpleMode,
+		Hooks:          part1.Hooks,
+		TargetManifest: part1.TargetManifest,
+		Verification:   part1.Verification,
+		Branding:       part1.Branding,
+		SearchRoots:    part1.SearchRoots,
+		FullInstall:    part1.FullInstall,
+		FromIdentity:   part1.FromIdentity,
+		ToIdentity:     part1.ToIdentity,
+		MultiPart:      part1.MultiPart, // Keep multi-part info for reference
 	}
 
 	fmt.Printf("✓ Loaded %d total operations from %d parts\n",
@@ -454,3 +435,33 @@ func LoadMultiPartPatch(part1Path string) (*utils.Patch, error) {
 
 	return combinedPatch, nil
 }
+
+// loadPart decodes part n from source and checks the hash of its stored data
+func loadPart(source PartSource, n int, expectedHash string) (*utils.Patch, error) {
+	reader, err := source.OpenPart(n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read part %d: %w", n, err)
+	}
+	defer reader.Close()
+
+	hasher := sha256.New()
+	data := io.TeeReader(reader, hasher)
+
+	part, loadErr := utils.LoadPatchFromReader(data)
+
+	// Hash whatever the decoder did not consume so the checksum covers the whole part
+	if _, err := io.Copy(io.Discard, data); err != nil {
+		return nil, fmt.Errorf("failed to read part %d: %w", n, err)
+	}
+
+	// A corrupted part usually also fails to decode; report the hash mismatch as the cause
+	actualHash := fmt.Sprintf("%x", hasher.Sum(nil))
+	if actualHash != expectedHash {
+		return nil, fmt.Errorf("part %d hash mismatch: expected %s, got %s",
+			n, ShortChecksum(expectedHash)+"...", ShortChecksum(actualHash)+"...")
+	}
+	if loadErr != nil {
+		return nil, fmt.Errorf("failed to load part %d: %w", n, loadErr)
+	}
+	return part, nil
+}
@@ -0,0 +1,198 @@
+package patcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// operationStage is a contiguous run of operations that can safely be applied concurrently
+type operationStage struct {
+	start int // Index of the first operation in the stage
+	end   int // Index one past the last operation in the stage
+}
+
+// operationClass groups operation types that may run side by side.
+// Operations of different classes never share a stage, so the generator's ordering
+// (directories created before files, files deleted before their directories) is kept.
+func operationClass(opType utils.OperationType) int {
+	switch opType {
+	case utils.OpAddDir:
+		return 0
+	case utils.OpDelete:
+		return 1
+	case utils.OpDeleteDir:
+		return 2
+	default: // OpAdd, OpModify
+		return 3
+	}
+}
+
+// stagePathKey normalizes an operation path for conflict detection.
+// Paths are compared case-insensitively so the plan is safe on Windows file systems.
+func stagePathKey(path string) string {
+	return strings.ToLower(filepath.ToSlash(filepath.Clean(path)))
+}
+
+// planOperationStages splits the operation list into stages of independent operations.
+// A new stage starts whenever the operation class changes or an operation touches a path
+// that is equal to, inside, or a parent of a path already used in the current stage.
+func planOperationStages(operations []utils.PatchOperation) []operationStage {
+	var stages []operationStage
+	if len(operations) == 0 {
+		return stages
+	}
+
+	start := 0
+	class := operationClass(operations[0].Type)
+	paths := make(map[string]bool)
+	ancestors := make(map[string]bool)
+
+	for i, op := range operations {
+		key := stagePathKey(op.FilePath)
+		opClass := operationClass(op.Type)
+
+		if i > start && (opClass != class || pathConflicts(key, paths, ancestors)) {
+			stages = append(stages, operationStage{start: start, end: i})
+			start = i
+			class = opClass
+			paths = make(map[string]bool)
+			ancestors = make(map[string]bool)
+		}
+
+		paths[key] = true
+		for parent := parentKey(key); parent != ""; parent = parentKey(parent) {
+			ancestors[parent] = true
+		}
+	}
+
+	stages = append(stages, operationStage{start: start, end: len(operations)})
+	return stages
+}
+
+// pathConflicts reports whether key overlaps any path already claimed by the stage
+func pathConflicts(key string, paths, ancestors map[string]bool) bool {
+	// Same path, or key is a parent of a claimed path
+	if paths[key] || ancestors[key] {
+		return true
+	}
+	// A claimed path is a parent of key
+	for parent := parentKey(key); parent != ""; parent = parentKey(parent) {
+		if paths[parent] {
+			return true
+		}
+	}
+	return false
+}
+
+// parentKey returns the parent of a normalized path key, or "" at the root
+func parentKey(key string) string {
+	idx := strings.LastIndex(key, "/")
+	if idx <= 0 {
+		return ""
+	}
+	return key[:idx]
+}
+
+// applyOperationStage applies all operations in a stage using the worker pool.
+// On failure it returns the index of the first failed operation in patch order.
+func (a *Applier) applyOperationStage(targetDir string, operations []utils.PatchOperation, stage operationStage) (int, error) {
+	count := stage.end - stage.start
+	workers := a.workerThreads
+	if workers > count {
+		workers = count
+	}
+
+	// Sequential path for single-threaded mode and single-operation stages
+	if workers <= 1 {
+		for i := stage.start; i < stage.end; i++ {
+			if err := a.applyOperation(targetDir, operations[i]); err != nil {
+				return i, err
+			}
+		}
+		return -1, nil
+	}
+
+	errs := make([]error, count)
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	jobs := make(chan int, count)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				// Stop picking up new work once any operation has failed
+				if failed.Load() {
+					continue
+				}
+				if err := a.applyOperation(targetDir, operations[idx]); err != nil {
+					errs[idx-stage.start] = err
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+
+	for i := stage.start; i < stage.end; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return stage.start + i, err
+		}
+	}
+	return -1, nil
+}
+
+// verifyChecksums hashes the given files in parallel and reports mismatches against expected checksums.
+// label is appended to "file not found" messages to give context (e.g. "after patching").
+func (a *Applier) verifyChecksums(targetDir string, relPaths, expected []string, label string) []string {
+	mismatches := make([]string, 0)
+
+	// Only hash files that exist; missing files are reported directly
+	existing := make([]string, 0, len(relPaths))
+	existingIdx := make([]int, 0, len(relPaths))
+	for i, relPath := range relPaths {
+		fullPath := filepath.Join(targetDir, relPath)
+		if !utils.FileExists(fullPath) {
+			if label != "" {
+				mismatches = append(mismatches, fmt.Sprintf("%s: file not found %s", relPath, label))
+			} else {
+				mismatches = append(mismatches, fmt.Sprintf("%s: file not found", relPath))
+			}
+			continue
+		}
+		existing = append(existing, fullPath)
+		existingIdx = append(existingIdx, i)
+	}
+
+	results := utils.CalculateFileChecksumsParallel(existing, a.workerThreads)
+	for j, result := range results {
+		i := existingIdx[j]
+		if result.Err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("%s: failed to verify checksum: %v", relPaths[i], result.Err))
+		} else if result.Checksum != expected[i] {
+			mismatches = append(mismatches, fmt.Sprintf("%s: checksum mismatch (expected %s, got %s)",
+				relPaths[i], ShortChecksum(expected[i]), ShortChecksum(result.Checksum)))
+		}
+	}
+
+	return mismatches
+}
+
+// ShortChecksum shortens a checksum for messages (safe for checksums shorter than 16 characters)
+func ShortChecksum(checksum string) string {
+	if len(checksum) > 16 {
+		return checksum[:16]
+	}
+	return checksum
+}
@@ -0,0 +1,94 @@
+package patcher
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+func TestPlanOperationStages(t *testing.T) {
+	op := func(opType utils.OperationType, path string) utils.PatchOperation {
+		return utils.PatchOperation{Type: opType, FilePath: path}
+	}
+
+	tests := []struct {
+		name       string
+		operations []utils.PatchOperation
+		want       []operationStage
+	}{
+		{
+			name: "no operations",
+			want: nil,
+		},
+		{
+			name: "independent files share a stage",
+			operations: []utils.PatchOperation{
+				op(utils.OpAdd, "a.txt"),
+				op(utils.OpModify, "b.txt"),
+				op(utils.OpAdd, "dir/c.txt"),
+			},
+			want: []operationStage{{0, 3}},
+		},
+		{
+			name: "class change starts a stage",
+			operations: []utils.PatchOperation{
+				op(utils.OpAddDir, "new"),
+				op(utils.OpAdd, "new/a.txt"),
+				op(utils.OpDelete, "old/b.txt"),
+				op(utils.OpDeleteDir, "old"),
+			},
+			want: []operationStage{{0, 1}, {1, 2}, {2, 3}, {3, 4}},
+		},
+		{
+			name: "same path starts a stage",
+			operations: []utils.PatchOperation{
+				op(utils.OpAdd, "a.txt"),
+				op(utils.OpModify, "a.txt"),
+			},
+			want: []operationStage{{0, 1}, {1, 2}},
+		},
+		{
+			name: "paths differing only in case conflict",
+			operations: []utils.PatchOperation{
+				op(utils.OpAdd, "Data/File.txt"),
+				op(utils.OpAdd, "data/file.txt"),
+			},
+			want: []operationStage{{0, 1}, {1, 2}},
+		},
+		{
+			name: "parent after child conflicts",
+			operations: []utils.PatchOperation{
+				op(utils.OpDeleteDir, "a/b"),
+				op(utils.OpDeleteDir, "a"),
+			},
+			want: []operationStage{{0, 1}, {1, 2}},
+		},
+		{
+			name: "child after parent conflicts",
+			operations: []utils.PatchOperation{
+				op(utils.OpAddDir, "a"),
+				op(utils.OpAddDir, "a/b"),
+				op(utils.OpAddDir, "c"),
+			},
+			want: []operationStage{{0, 1}, {1, 3}},
+		},
+		{
+			name: "shared prefix is not a parent",
+			operations: []utils.PatchOperation{
+				op(utils.OpAdd, "app/data.bin"),
+				op(utils.OpAdd, "app/data.bin.bak"),
+			},
+			want: []operationStage{{0, 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := planOperationStages(tt.operations)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("planOperationStages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
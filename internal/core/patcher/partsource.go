@@ -0,0 +1,177 @@
+package patcher
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// PartSource provides the raw data of parts 2..N of a multi-part patch
+type PartSource interface {
+	// OpenPart opens the stored data of part n for streaming
+	OpenPart(n int) (io.ReadCloser, error)
+}
+
+// DirPartSource reads parts stored in a directory, either as <base>.NN.patch files
+// or as chunk files listed in a <base>.partN.chunks.json sidecar.
+// Files added with AddFile (e.g. embedded in an executable) are used in place of files on disk.
+type DirPartSource struct {
+	dir      string
+	baseName string
+	files    map[string]*io.SectionReader
+}
+
+// NewDirPartSource creates a part source for parts named after baseName in dir
+func NewDirPartSource(dir, baseName string) *DirPartSource {
+	return &DirPartSource{
+		dir:      dir,
+		baseName: baseName,
+		files:    make(map[string]*io.SectionReader),
+	}
+}
+
+// AddFile supplies a part, chunk or sidecar file by name; it takes precedence over a file on disk
+func (s *DirPartSource) AddFile(name string, data *io.SectionReader) {
+	s.files[name] = data
+}
+
+// exists reports whether a file is available from AddFile or on disk
+func (s *DirPartSource) exists(name string) bool {
+	if _, ok := s.files[name]; ok {
+		return true
+	}
+	return utils.FileExists(filepath.Join(s.dir, name))
+}
+
+// open opens a file supplied with AddFile, or the file on disk
+func (s *DirPartSource) open(name string) (io.ReadCloser, error) {
+	if data, ok := s.files[name]; ok {
+		// Each open reads from the start, independently of earlier reads
+		return io.NopCloser(io.NewSectionReader(data, 0, data.Size())), nil
+	}
+	return os.Open(filepath.Join(s.dir, name))
+}
+
+// OpenPart opens part n, streaming its chunks in order if the part was chunked
+func (s *DirPartSource) OpenPart(n int) (io.ReadCloser, error) {
+	chunks, chunked, err := s.chunks(n)
+	if err != nil {
+		return nil, err
+	}
+	if !chunked {
+		// No sidecar; the part is a single file
+		return s.open(s.partName(n))
+	}
+
+	// Fail early if a chunk is missing rather than partway through decoding
+	for _, chunk := range chunks {
+		if !s.exists(chunk.FileName) {
+			return nil, fmt.Errorf("missing chunk file for part %d: %s", n, filepath.Join(s.dir, chunk.FileName))
+		}
+	}
+
+	return &chunkReader{source: s, part: n, chunks: chunks}, nil
+}
+
+// partName returns the file name of part n when it is stored as a single file
+func (s *DirPartSource) partName(n int) string {
+	return fmt.Sprintf("%s.%02d.patch", s.baseName, n)
+}
+
+// sidecarName returns the file name of the chunk sidecar of part n
+func (s *DirPartSource) sidecarName(n int) string {
+	return fmt.Sprintf("%s.part%d.chunks.json", s.baseName, n)
+}
+
+// chunks returns the chunks listed in the sidecar of part n, and false if the part has no sidecar
+func (s *DirPartSource) chunks(n int) ([]utils.PartChunk, bool, error) {
+	sidecarName := s.sidecarName(n)
+	if !s.exists(sidecarName) {
+		return nil, false, nil
+	}
+
+	sidecar, err := s.open(sidecarName)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read chunk sidecar for part %d: %w", n, err)
+	}
+	sideData, err := io.ReadAll(sidecar)
+	sidecar.Close()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read chunk sidecar for part %d: %w", n, err)
+	}
+
+	var parsed struct {
+		PartNumber int               `json:"part_number"`
+		Chunks     []utils.PartChunk `json:"chunks"`
+	}
+	if err := json.Unmarshal(sideData, &parsed); err != nil {
+		return nil, false, fmt.Errorf("failed to parse chunk sidecar for part %d: %w", n, err)
+	}
+	return parsed.Chunks, true, nil
+}
+
+// chunkReader streams the chunk files of a part in order, verifying each chunk's checksum as it finishes
+type chunkReader struct {
+	source  *DirPartSource
+	part    int
+	chunks  []utils.PartChunk
+	current io.ReadCloser
+	hasher  hash.Hash
+}
+
+// Read implements io.Reader
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.chunks) == 0 {
+				return 0, io.EOF
+			}
+			file, err := r.source.open(r.chunks[0].FileName)
+			if err != nil {
+				return 0, fmt.Errorf("failed to read chunk %s for part %d: %w", r.chunks[0].FileName, r.part, err)
+			}
+			r.current = file
+			r.hasher = sha256.New()
+		}
+
+		n, err := r.current.Read(p)
+		r.hasher.Write(p[:n])
+		if err == io.EOF {
+			if verr := r.finishChunk(); verr != nil {
+				return n, verr
+			}
+			if n == 0 {
+				continue
+			}
+			return n, nil
+		}
+		return n, err
+	}
+}
+
+// finishChunk closes the current chunk and checks its checksum
+func (r *chunkReader) finishChunk() error {
+	chunk := r.chunks[0]
+	r.current.Close()
+	r.current = nil
+	r.chunks = r.chunks[1:]
+
+	if fmt.Sprintf("%x", r.hasher.Sum(nil)) != chunk.Checksum {
+		return fmt.Errorf("chunk %s checksum mismatch for part %d", chunk.FileName, r.part)
+	}
+	return nil
+}
+
+// Close implements io.Closer
+func (r *chunkReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package patcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// PreflightReport summarizes the disk space and permission checks run before a patch is applied
+type PreflightReport struct {
+	TargetDir       string   // Directory being patched
+	BackupDir       string   // Backup location (empty if backup is disabled)
+	NewFileBytes    int64    // Bytes written for added files
+	GrowthBytes     int64    // Net growth of modified files (new size minus old size, when positive)
+	TempFileBytes   int64    // Largest temporary file needed while replacing a large file
+	BackupBytes     int64    // Pre-images copied into the backup (modified/deleted files and directories)
+	PeakTargetBytes int64    // Peak extra space needed on the target filesystem
+	PeakBackupBytes int64    // Peak extra space needed on the backup filesystem (0 if shared with target)
+	TargetFree      int64    // Free space on the target filesystem (-1 if unknown)
+	BackupFree      int64    // Free space on the backup filesystem (-1 if unknown or shared with target)
+	SameFilesystem  bool     // True if target and backup share a filesystem
+	CheckedPaths    int      // Number of paths checked for write access
+	Unwritable      []string // Paths (files or directories) that cannot be written
+	Problems        []string // Reasons the patch cannot be applied safely
+}
+
+// OK returns true if no preflight problems were found
+func (r *PreflightReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Format renders the report as human-readable text
+func (r *PreflightReport) Format() string {
+	var b strings.Builder
+
+	b.WriteString("Preflight report:\n")
+	fmt.Fprintf(&b, "  New files:          %s\n", utils.FormatBytes(r.NewFileBytes))
+	fmt.Fprintf(&b, "  Modified growth:    %s\n", utils.FormatBytes(r.GrowthBytes))
+	fmt.Fprintf(&b, "  Temp files (peak):  %s\n", utils.FormatBytes(r.TempFileBytes))
+	if r.BackupDir != "" {
+		fmt.Fprintf(&b, "  Backup pre-images:  %s\n", utils.FormatBytes(r.BackupBytes))
+	} else {
+		b.WriteString("  Backup pre-images:  (backup disabled)\n")
+	}
+	fmt.Fprintf(&b, "  Peak space needed:  %s on target (free: %s)\n", utils.FormatBytes(r.PeakTargetBytes), formatFreeBytes(r.TargetFree))
+	if r.BackupDir != "" && !r.SameFilesystem {
+		fmt.Fprintf(&b, "  Backup space:       %s on backup filesystem (free: %s)\n", utils.FormatBytes(r.PeakBackupBytes), formatFreeBytes(r.BackupFree))
+	}
+	fmt.Fprintf(&b, "  Write access:       %d paths checked, %d not writable\n", r.CheckedPaths, len(r.Unwritable))
+
+	const maxListed = 10
+	for i, path := range r.Unwritable {
+		if i >= maxListed {
+			fmt.Fprintf(&b, "    ... and %d more\n", len(r.Unwritable)-maxListed)
+			break
+		}
+		fmt.Fprintf(&b, "    ✗ %s\n", path)
+	}
+
+	if r.OK() {
+		b.WriteString("✓ Preflight checks passed\n")
+	} else {
+		for _, problem := range r.Problems {
+			fmt.Fprintf(&b, "✗ %s\n", problem)
+		}
+	}
+
+	return b.String()
+}
+
+// Preflight computes the peak extra disk space an apply needs and checks that every touched path is writable.
+// It does not modify the target directory (beyond creating and removing probe files).
+func (a *Applier) Preflight(patch *utils.Patch, targetDir string, createBackup bool) (*PreflightReport, error) {
+	// A full install creates its directory, so it is measured on the nearest existing parent
+	if !utils.FileExists(targetDir) && !patch.FullInstall {
+		return nil, fmt.Errorf("target directory does not exist: %s", targetDir)
+	}
+
+	report := &PreflightReport{
+		TargetDir:  targetDir,
+		TargetFree: -1,
+		BackupFree: -1,
+	}
+	if createBackup {
+		report.BackupDir = filepath.Join(targetDir, "backup.cyberpatcher")
+	}
+
+	checker := newWriteChecker()
+	// Files already counted into BackupBytes. The backup mirrors the target, so a deleted directory is
+	// copied over the files of it that were backed up on their own (and over its deleted subdirectories).
+	backedUp := make(map[string]bool)
+
+	for _, op := range patch.Operations {
+		targetPath := filepath.Join(targetDir, op.FilePath)
+		newSize := int64(len(op.NewFile))
+		if newSize == 0 {
+			newSize = op.Size
+		}
+
+		switch op.Type {
+		case utils.OpAdd:
+			report.NewFileBytes += newSize
+			checker.checkDir(filepath.Dir(targetPath))
+
+		case utils.OpModify:
+			var oldSize int64
+			if info, err := os.Stat(targetPath); err == nil {
+				oldSize = info.Size()
+				checker.checkFile(targetPath)
+			}
+			if newSize > oldSize {
+				report.GrowthBytes += newSize - oldSize
+			}
+			// Large results are written to a temp file next to the target before the rename
+			if newSize > utils.LargeFileThreshold && newSize > report.TempFileBytes {
+				report.TempFileBytes = newSize
+			}
+			if createBackup && !backedUp[targetPath] {
+				report.BackupBytes += oldSize
+				backedUp[targetPath] = true
+			}
+			checker.checkDir(filepath.Dir(targetPath))
+
+		case utils.OpDelete:
+			if info, err := os.Stat(targetPath); err == nil && createBackup && !backedUp[targetPath] {
+				report.BackupBytes += info.Size()
+				backedUp[targetPath] = true
+			}
+			checker.checkDir(filepath.Dir(targetPath))
+
+		case utils.OpAddDir:
+			checker.checkDir(targetPath)
+
+		case utils.OpDeleteDir:
+			if createBackup && utils.FileExists(targetPath) {
+				dirSize, err := directorySize(targetPath, backedUp)
+				if err != nil {
+					return nil, fmt.Errorf("failed to measure directory %s: %w", op.FilePath, err)
+				}
+				report.BackupBytes += dirSize
+			}
+			checker.checkDir(filepath.Dir(targetPath))
+		}
+	}
+
+	if createBackup {
+		checker.checkDir(report.BackupDir)
+	}
+
+	report.CheckedPaths = checker.checked
+	report.Unwritable = checker.unwritable
+	if len(report.Unwritable) > 0 {
+		report.Problems = append(report.Problems, fmt.Sprintf("%d path(s) are not writable", len(report.Unwritable)))
+	}
+
+	// Compare peak requirements against free space on each filesystem
+	report.PeakTargetBytes = report.NewFileBytes + report.GrowthBytes + report.TempFileBytes
+	report.SameFilesystem = true
+	targetProbe := nearestExistingDir(targetDir)
+	if createBackup {
+		backupProbe := nearestExistingDir(report.BackupDir)
+		report.SameFilesystem = sameFilesystem(targetProbe, backupProbe)
+		if report.SameFilesystem {
+			report.PeakTargetBytes += report.BackupBytes
+		} else {
+			report.PeakBackupBytes = report.BackupBytes
+			if free, err := diskFreeBytes(backupProbe); err == nil {
+				report.BackupFree = free
+			}
+		}
+	}
+
+	if free, err := diskFreeBytes(targetProbe); err == nil {
+		report.TargetFree = free
+	}
+
+	if report.TargetFree >= 0 && report.PeakTargetBytes > report.TargetFree {
+		report.Problems = append(report.Problems, fmt.Sprintf("insufficient disk space on target: need %s, %s free",
+			utils.FormatBytes(report.PeakTargetBytes), utils.FormatBytes(report.TargetFree)))
+	}
+	if report.BackupFree >= 0 && report.PeakBackupBytes > report.BackupFree {
+		report.Problems = append(report.Problems, fmt.Sprintf("insufficient disk space for backup: need %s, %s free",
+			utils.FormatBytes(report.PeakBackupBytes), utils.FormatBytes(report.BackupFree)))
+	}
+
+	return report, nil
+}
+
+// writeChecker probes write access, caching results per path
+type writeChecker struct {
+	results    map[string]bool
+	checked    int
+	unwritable []string
+}
+
+func newWriteChecker() *writeChecker {
+	return &writeChecker{results: make(map[string]bool)}
+}
+
+// checkDir verifies that files can be created in dir, or in its nearest existing ancestor if dir does not exist yet
+func (w *writeChecker) checkDir(dir string) {
+	probeDir := nearestExistingDir(dir)
+	if _, seen := w.results[probeDir]; seen {
+		return
+	}
+	w.checked++
+
+	probe, err := os.CreateTemp(probeDir, ".cpm_preflight_*")
+	if err != nil {
+		w.results[probeDir] = false
+		w.unwritable = append(w.unwritable, probeDir+string(os.PathSeparator))
+		return
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+	w.results[probeDir] = true
+}
+
+// checkFile verifies that an existing file can be opened for writing
+func (w *writeChecker) checkFile(path string) {
+	if _, seen := w.results[path]; seen {
+		return
+	}
+	w.checked++
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		w.results[path] = false
+		w.unwritable = append(w.unwritable, path)
+		return
+	}
+	file.Close()
+	w.results[path] = true
+}
+
+// nearestExistingDir walks up from path until it finds a directory that exists
+func nearestExistingDir(path string) string {
+	current := path
+	for {
+		if info, err := os.Stat(current); err == nil && info.IsDir() {
+			return current
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return current
+		}
+		current = parent
+	}
+}
+
+// directorySize returns the combined size of the files under dir that are not in counted,
+// adding them to counted
+func directorySize(dir string, counted map[string]bool) (int64, error) {
+	var total int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !counted[path] {
+			total += info.Size()
+			counted[path] = true
+		}
+		return nil
+	})
+	return total, err
+}
+
+// formatFreeBytes formats free space, handling unknown values
+func formatFreeBytes(bytes int64) string {
+	if bytes < 0 {
+		return "unknown"
+	}
+	return utils.FormatBytes(bytes)
+}
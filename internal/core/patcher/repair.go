@@ -0,0 +1,172 @@
+package patcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// RepairResult summarizes a repair run against a patch's target manifest
+type RepairResult struct {
+	Checked      int      // Files compared against the manifest
+	Missing      []string // Files that did not exist
+	Mismatched   []string // Files whose checksum did not match the manifest
+	Repaired     []string // Files rewritten from payload data (or that would be, in a dry run)
+	Unrepairable []string // Damaged files the patch carries no payload for
+	DirsCreated  int      // Missing directories recreated
+}
+
+// Damaged returns the number of missing or mismatched files found
+func (r *RepairResult) Damaged() int {
+	return len(r.Missing) + len(r.Mismatched)
+}
+
+// Repair compares an install against the patch's embedded target manifest and rewrites
+// missing or mismatched files using the file data carried by the patch.
+// Files the patch has no data for are reported in RepairResult.Unrepairable.
+func (a *Applier) Repair(patch *utils.Patch, targetDir string, dryRun bool) (*RepairResult, error) {
+	manifest := patch.TargetManifest
+	if manifest == nil {
+		return nil, fmt.Errorf("patch does not contain a target manifest (generate it with --embed-manifest)")
+	}
+	if !utils.FileExists(targetDir) {
+		return nil, fmt.Errorf("target directory does not exist: %s", targetDir)
+	}
+
+	if !dryRun {
+		lock, err := AcquireLock(targetDir, "repair")
+		if err != nil {
+			return nil, err
+		}
+		defer releaseLock(lock)
+	}
+
+	// An install still at the source version needs the patch applied, not repaired
+	if !patch.FullInstall && a.verifyIdentity(targetDir, patch.FromKeyFile, patch.FromIdentity) == nil && a.verifyIdentity(targetDir, patch.ToKeyFile, patch.ToIdentity) != nil {
+		return nil, fmt.Errorf("install is at version %s; apply the patch instead of repairing", patch.FromVersion)
+	}
+
+	// Payload available for each target path
+	payload := make(map[string]utils.PatchOperation)
+	for _, op := range patch.Operations {
+		if (op.Type == utils.OpAdd || op.Type == utils.OpModify) && len(op.NewFile) > 0 {
+			payload[op.FilePath] = op
+		}
+	}
+
+	result := &RepairResult{Checked: len(manifest.Files)}
+
+	// Recreate missing directories first so repaired files have somewhere to go
+	for _, dir := range manifest.Directories {
+		dirPath := filepath.Join(targetDir, dir)
+		if utils.FileExists(dirPath) {
+			continue
+		}
+		result.DirsCreated++
+		if !dryRun {
+			if err := utils.EnsureDir(dirPath); err != nil {
+				return result, fmt.Errorf("failed to create directory %s: %w", dir, err)
+			}
+		}
+	}
+
+	// Hash every existing manifest file in parallel
+	fmt.Printf("Checking %d files against the %s manifest...\n", len(manifest.Files), manifest.Version)
+	damaged := make([]utils.FileEntry, 0)
+	existing := make([]string, 0, len(manifest.Files))
+	existingEntries := make([]utils.FileEntry, 0, len(manifest.Files))
+	for _, entry := range manifest.Files {
+		fullPath := filepath.Join(targetDir, entry.Path)
+		if !utils.FileExists(fullPath) {
+			result.Missing = append(result.Missing, entry.Path)
+			damaged = append(damaged, entry)
+			continue
+		}
+		existing = append(existing, fullPath)
+		existingEntries = append(existingEntries, entry)
+	}
+	for i, res := range utils.CalculateFileChecksumsParallel(existing, a.workerThreads) {
+		entry := existingEntries[i]
+		if res.Err != nil || res.Checksum != entry.Checksum {
+			result.Mismatched = append(result.Mismatched, entry.Path)
+			damaged = append(damaged, entry)
+		}
+	}
+
+	for _, entry := range damaged {
+		var data []byte
+		if entry.Size > 0 {
+			op, ok := payload[entry.Path]
+			if !ok || op.NewChecksum != entry.Checksum {
+				result.Unrepairable = append(result.Unrepairable, entry.Path)
+				continue
+			}
+			data = op.NewFile
+		}
+
+		result.Repaired = append(result.Repaired, entry.Path)
+		if dryRun {
+			continue
+		}
+
+		if err := writeRepairedFile(filepath.Join(targetDir, entry.Path), data, entry); err != nil {
+			return result, fmt.Errorf("failed to repair %s: %w", entry.Path, err)
+		}
+		fmt.Printf("  Repaired: %s\n", entry.Path)
+	}
+
+	return result, nil
+}
+
+// writeRepairedFile atomically replaces a file with data. The written data is verified against the
+// manifest entry before the rename, so a corrupted payload never replaces the file.
+func writeRepairedFile(targetPath string, data []byte, entry utils.FileEntry) error {
+	if err := utils.EnsureDir(filepath.Dir(targetPath)); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Write next to the target so the rename stays on one filesystem
+	tmp, err := os.CreateTemp(filepath.Dir(targetPath), ".cpm_repair_*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write data: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+
+	match, err := utils.VerifyFileChecksum(tmpPath, entry.Checksum)
+	if err != nil {
+		return fmt.Errorf("failed to verify checksum: %w", err)
+	} else if !match {
+		return fmt.Errorf("repair data does not match the manifest checksum (patch is corrupted or was tampered with)")
+	}
+
+	if err := os.Chmod(tmpPath, manifestFileMode(entry)); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, targetPath); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+	return nil
+}
+
+// manifestFileMode returns the permissions a file is written with according to its manifest entry
+func manifestFileMode(entry utils.FileEntry) os.FileMode {
+	if entry.Mode != 0 {
+		return os.FileMode(entry.Mode) & os.ModePerm
+	}
+	if entry.IsExecutable {
+		return 0755
+	}
+	return 0644
+}
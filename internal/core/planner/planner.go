@@ -0,0 +1,285 @@
+// Package planner decides which patches to publish for a version history. Patching every older
+// version straight to the newest makes storage grow quadratically with the number of releases;
+// the planner instead picks a smaller set of incremental and cumulative patches so that every
+// version still reaches the newest within a maximum number of patches.
+package planner
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// SizeFunc returns the size of the patch from one version to a newer one
+type SizeFunc func(from, to string) int64
+
+// Options limits the planned patch set
+type Options struct {
+	MaxChain int   // Most patches any version applies to reach the newest version (0 = no limit)
+	Budget   int64 // Most bytes all patches may take together (0 = no limit)
+}
+
+// Patch is one patch in a plan
+type Patch struct {
+	From string
+	To   string
+	Size int64
+	Span int // Number of releases the patch advances: 1 for incremental patches, more for cumulative ones
+}
+
+// Plan is the set of patches to publish for a version history
+type Plan struct {
+	Versions    []string // Versions oldest first; the last one is the newest
+	Patches     []Patch  // Planned patches, sorted by source version, then target version
+	MinimumSize int64    // Size of the patch set meeting the chain limit, before the budget adds patches
+	TotalSize   int64    // Size of all planned patches, including cumulative patches added within the budget
+}
+
+// PlanPatches plans the patches for versions, which must be sorted oldest first. Every version
+// gets a chain of at most opts.MaxChain patches to the newest version. The plan first takes a
+// small patch set that meets the chain limit (the smallest one without a limit); if that exceeds
+// opts.Budget an error is returned.
+// Budget left over is spent on direct patches to the newest version, newest source first, since
+// recent versions have the most installs.
+func PlanPatches(versions []string, size SizeFunc, opts Options) (*Plan, error) {
+	if opts.MaxChain < 0 {
+		return nil, fmt.Errorf("maximum chain length must be 0 or more")
+	}
+	if opts.Budget < 0 {
+		return nil, fmt.Errorf("storage budget must be 0 or more")
+	}
+
+	plan := &Plan{Versions: versions}
+	newest := len(versions) - 1
+	if newest < 1 {
+		return plan, nil
+	}
+
+	// sizes[i][j] is the size of the patch from versions[i] to versions[j], for i < j
+	sizes := make([][]int64, newest)
+	for i := range sizes {
+		sizes[i] = make([]int64, newest+1)
+		for j := i + 1; j <= newest; j++ {
+			sizes[i][j] = size(versions[i], versions[j])
+		}
+	}
+
+	// Every version but the newest gets one patch to a newer version (its parent); the parents
+	// form a tree rooted at the newest version, and a version's depth is the length of its chain
+	var parents []int
+	if opts.MaxChain == 0 || opts.MaxChain >= newest {
+		parents = cheapestParents(sizes, newest)
+	} else {
+		parents = layeredParents(sizes, newest, opts.MaxChain)
+	}
+
+	var patches [][2]int
+	for i, parent := range parents {
+		patches = append(patches, [2]int{i, parent})
+		plan.MinimumSize += sizes[i][parent]
+	}
+	plan.TotalSize = plan.MinimumSize
+
+	if opts.Budget > 0 {
+		if plan.MinimumSize > opts.Budget {
+			limit := "no chain limit"
+			if opts.MaxChain > 0 {
+				limit = fmt.Sprintf("chains of at most %d patches", opts.MaxChain)
+			}
+			return nil, fmt.Errorf("the planned patch set with %s takes %s, over the %s storage budget (raise the budget or the maximum chain length)",
+				limit, utils.FormatBytes(plan.MinimumSize), utils.FormatBytes(opts.Budget))
+		}
+		for i := newest - 1; i >= 0; i-- {
+			if parents[i] == newest || plan.TotalSize+sizes[i][newest] > opts.Budget {
+				continue
+			}
+			patches = append(patches, [2]int{i, newest})
+			plan.TotalSize += sizes[i][newest]
+		}
+	}
+
+	sort.Slice(patches, func(a, b int) bool {
+		if patches[a][0] != patches[b][0] {
+			return patches[a][0] < patches[b][0]
+		}
+		return patches[a][1] < patches[b][1]
+	})
+	for _, p := range patches {
+		plan.Patches = append(plan.Patches, Patch{
+			From: versions[p[0]],
+			To:   versions[p[1]],
+			Size: sizes[p[0]][p[1]],
+			Span: p[1] - p[0],
+		})
+	}
+	return plan, nil
+}
+
+// Chain returns the patches an install of version from applies to reach the newest version:
+// like patch-update, it takes the chain with the smallest total size, then the fewest patches.
+// Returns nil for the newest version and for versions not in the plan.
+func (p *Plan) Chain(from string) []Patch {
+	position := make(map[string]int, len(p.Versions))
+	for i, v := range p.Versions {
+		position[v] = i
+	}
+	start, ok := position[from]
+	newest := len(p.Versions) - 1
+	if !ok || start == newest {
+		return nil
+	}
+
+	// Patches only go to newer versions, so chains are found newest version first
+	type route struct {
+		size  int64
+		hops  int
+		patch int // Index of the first patch in p.Patches (-1 = none)
+	}
+	routes := make([]route, len(p.Versions))
+	for i := range routes {
+		routes[i] = route{size: math.MaxInt64, patch: -1}
+	}
+	routes[newest] = route{}
+	for i := newest - 1; i >= start; i-- {
+		for n, patch := range p.Patches {
+			if patch.From != p.Versions[i] {
+				continue
+			}
+			next := routes[position[patch.To]]
+			if next.size == math.MaxInt64 {
+				continue
+			}
+			candidate := route{size: next.size + patch.Size, hops: next.hops + 1, patch: n}
+			if candidate.size < routes[i].size || candidate.size == routes[i].size && candidate.hops < routes[i].hops {
+				routes[i] = candidate
+			}
+		}
+	}
+
+	var chain []Patch
+	for i := start; i != newest && routes[i].patch >= 0; {
+		patch := p.Patches[routes[i].patch]
+		chain = append(chain, patch)
+		i = position[patch.To]
+	}
+	return chain
+}
+
+// LongestChain returns the largest number of patches any version applies to reach the newest version
+func (p *Plan) LongestChain() int {
+	longest := 0
+	for _, v := range p.Versions {
+		if n := len(p.Chain(v)); n > longest {
+			longest = n
+		}
+	}
+	return longest
+}
+
+// cheapestParents gives every version its smallest patch to any newer version. Without a chain
+// limit this is the smallest patch set: each version needs one patch, and any choice reaches the newest.
+func cheapestParents(sizes [][]int64, newest int) []int {
+	parents := make([]int, newest)
+	for i := range parents {
+		parents[i] = cheapest(sizes[i], i+1, newest+1)
+	}
+	return parents
+}
+
+// layeredParents plans a small patch set with chains of at most maxChain patches, for versions
+// grouped into layers: layer 0 is the newest version, layer d holds versions with chains of at
+// most d patches, and each layer is a contiguous range of versions older than the layer before
+// it. Each version patches to its cheapest version in any newer layer. The layer boundaries are
+// chosen by dynamic programming; afterwards every version may switch to a cheaper parent that
+// does not lengthen its chain. The smallest patch set can give an older version a shorter chain
+// than a newer one, which layers cannot express, so the result is not always the smallest.
+func layeredParents(sizes [][]int64, newest, maxChain int) []int {
+	const unreachable = math.MaxInt64
+
+	// reach[i][b] is the smallest patch from versions[i] to any version from b on, for i < b
+	reach := make([][]int64, newest)
+	for i := range reach {
+		reach[i] = make([]int64, newest+1)
+		reach[i][newest] = sizes[i][newest]
+		for b := newest - 1; b > i; b-- {
+			reach[i][b] = min(reach[i][b+1], sizes[i][b])
+		}
+	}
+
+	// best[b][d] is the smallest size of the patches for versions [0, b) when layer d starts at
+	// version b; first[b][d] is where layer d+1 starts in that solution
+	best := make([][]int64, newest+1)
+	first := make([][]int, newest+1)
+	for b := 0; b <= newest; b++ {
+		best[b] = make([]int64, maxChain+1)
+		first[b] = make([]int, maxChain+1)
+		if b == 0 {
+			continue // No older versions left
+		}
+		for d := 0; d <= maxChain; d++ {
+			best[b][d] = unreachable
+			if d == maxChain {
+				continue // Older versions would exceed the chain limit
+			}
+			// Layer d+1 is versions [a, b), each patching to its cheapest version from b on
+			var layer int64
+			for a := b - 1; a >= 0; a-- {
+				layer += reach[a][b]
+				if rest := best[a][d+1]; rest != unreachable && layer+rest < best[b][d] {
+					best[b][d] = layer + rest
+					first[b][d] = a
+				}
+			}
+		}
+	}
+
+	// Walk the layers from the newest version down, assigning parents
+	parents := make([]int, newest)
+	for b, d := newest, 0; b > 0; b, d = first[b][d], d+1 {
+		for i := first[b][d]; i < b; i++ {
+			parents[i] = cheapest(sizes[i], b, newest+1)
+		}
+	}
+
+	// Switching to a cheaper parent whose chain is no longer keeps every chain within the limit
+	depths := make([]int, newest+1)
+	for i := newest - 1; i >= 0; i-- {
+		depths[i] = depths[parents[i]] + 1
+		for j := i + 1; j <= newest; j++ {
+			if depths[j] < depths[i] && sizes[i][j] <= sizes[i][parents[i]] {
+				parents[i] = j
+			}
+		}
+		depths[i] = depths[parents[i]] + 1
+	}
+	return parents
+}
+
+// cheapest returns the version in [from, to) with the smallest patch size, preferring newer versions on ties
+func cheapest(sizes []int64, from, to int) int {
+	best := from
+	for j := from + 1; j < to; j++ {
+		if sizes[j] <= sizes[best] {
+			best = j
+		}
+	}
+	return best
+}
+
+// EstimateSize estimates the size of the patch between two manifests: the uncompressed size of the
+// added and modified files, which the patch stores in full. Compression makes real patches smaller.
+func EstimateSize(from, to *utils.Manifest) int64 {
+	checksums := make(map[string]string, len(from.Files))
+	for _, file := range from.Files {
+		checksums[file.Path] = file.Checksum
+	}
+	var size int64
+	for _, file := range to.Files {
+		if checksum, ok := checksums[file.Path]; !ok || checksum != file.Checksum {
+			size += file.Size
+		}
+	}
+	return size
+}
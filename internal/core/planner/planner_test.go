@@ -0,0 +1,332 @@
+package planner
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// linearSize makes each patch cost base plus perRelease for every release it advances
+func linearSize(versions []string, base, perRelease int64) SizeFunc {
+	position := make(map[string]int, len(versions))
+	for i, v := range versions {
+		position[v] = i
+	}
+	return func(from, to string) int64 {
+		return base + perRelease*int64(position[to]-position[from])
+	}
+}
+
+// tableSize looks patch sizes up in a map keyed by "from>to"
+func tableSize(sizes map[string]int64) SizeFunc {
+	return func(from, to string) int64 {
+		return sizes[from+">"+to]
+	}
+}
+
+// patchNames lists a plan's patches as "from>to"
+func patchNames(plan *Plan) []string {
+	var names []string
+	for _, p := range plan.Patches {
+		names = append(names, p.From+">"+p.To)
+	}
+	return names
+}
+
+func TestPlanPatches(t *testing.T) {
+	five := []string{"1", "2", "3", "4", "5"}
+
+	tests := []struct {
+		name      string
+		versions  []string
+		size      SizeFunc
+		opts      Options
+		want      []string
+		wantMin   int64
+		wantTotal int64
+		wantErr   bool
+	}{
+		{
+			name:     "single version",
+			versions: []string{"1"},
+			size:     linearSize(five, 0, 1),
+		},
+		{
+			name:      "incremental patches are cheapest without a chain limit",
+			versions:  five,
+			size:      linearSize(five, 0, 10),
+			want:      []string{"1>2", "2>3", "3>4", "4>5"},
+			wantMin:   40,
+			wantTotal: 40,
+		},
+		{
+			name:      "chain limit of one patches straight to the newest",
+			versions:  five,
+			size:      linearSize(five, 0, 10),
+			opts:      Options{MaxChain: 1},
+			want:      []string{"1>5", "2>5", "3>5", "4>5"},
+			wantMin:   100,
+			wantTotal: 100,
+		},
+		{
+			name:      "chain limit of two",
+			versions:  five,
+			size:      linearSize(five, 100, 1),
+			opts:      Options{MaxChain: 2},
+			want:      []string{"1>3", "2>3", "3>5", "4>5"},
+			wantMin:   406,
+			wantTotal: 406,
+		},
+		{
+			name:      "chain limit at least the history length is no limit",
+			versions:  five,
+			size:      linearSize(five, 0, 10),
+			opts:      Options{MaxChain: 4},
+			want:      []string{"1>2", "2>3", "3>4", "4>5"},
+			wantMin:   40,
+			wantTotal: 40,
+		},
+		{
+			name:      "budget adds direct patches newest source first",
+			versions:  five,
+			size:      linearSize(five, 0, 10),
+			opts:      Options{Budget: 100},
+			want:      []string{"1>2", "2>3", "2>5", "3>4", "3>5", "4>5"},
+			wantMin:   40,
+			wantTotal: 90,
+		},
+		{
+			name:     "cumulative patch cheaper than incremental ones",
+			versions: []string{"1", "2", "3"},
+			size: tableSize(map[string]int64{
+				"1>2": 50, "1>3": 20, "2>3": 50,
+			}),
+			want:      []string{"1>3", "2>3"},
+			wantMin:   70,
+			wantTotal: 70,
+		},
+		{
+			name:     "budget below the minimum",
+			versions: five,
+			size:     linearSize(five, 0, 10),
+			opts:     Options{MaxChain: 1, Budget: 99},
+			wantErr:  true,
+		},
+		{
+			name:     "negative chain limit",
+			versions: five,
+			size:     linearSize(five, 0, 10),
+			opts:     Options{MaxChain: -1},
+			wantErr:  true,
+		},
+		{
+			name:     "negative budget",
+			versions: five,
+			size:     linearSize(five, 0, 10),
+			opts:     Options{Budget: -1},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := PlanPatches(tt.versions, tt.size, tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PlanPatches() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := patchNames(plan); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("patches = %v, want %v", got, tt.want)
+			}
+			if plan.MinimumSize != tt.wantMin || plan.TotalSize != tt.wantTotal {
+				t.Errorf("MinimumSize, TotalSize = %d, %d, want %d, %d", plan.MinimumSize, plan.TotalSize, tt.wantMin, tt.wantTotal)
+			}
+		})
+	}
+}
+
+// smallestPatchSet tries every choice of parent for every version and returns the smallest
+// total size whose chains are all at most maxChain patches long
+func smallestPatchSet(sizes [][]int64, maxChain int) int64 {
+	newest := len(sizes)
+	parents := make([]int, newest)
+	best := int64(math.MaxInt64)
+
+	var try func(i int)
+	try = func(i int) {
+		if i == newest {
+			var total int64
+			for v, parent := range parents {
+				depth := 0
+				for n := v; n != newest; n = parents[n] {
+					depth++
+				}
+				if depth > maxChain {
+					return
+				}
+				total += sizes[v][parent]
+			}
+			best = min(best, total)
+			return
+		}
+		for parent := i + 1; parent <= newest; parent++ {
+			parents[i] = parent
+			try(i + 1)
+		}
+	}
+	try(0)
+	return best
+}
+
+// smallestLayeredSet tries every way to group versions into contiguous layers of at most maxChain
+// and returns the smallest total size when each version patches to its cheapest version in a newer layer
+func smallestLayeredSet(sizes [][]int64, maxChain int) int64 {
+	newest := len(sizes)
+	layers := make([]int, newest+1) // layers[newest] is 0
+	best := int64(math.MaxInt64)
+
+	var try func(i, limit int)
+	try = func(i, limit int) {
+		if i < 0 {
+			var total int64
+			for v := 0; v < newest; v++ {
+				cheapest := int64(math.MaxInt64)
+				for j := v + 1; j <= newest; j++ {
+					if layers[j] < layers[v] {
+						cheapest = min(cheapest, sizes[v][j])
+					}
+				}
+				total += cheapest
+			}
+			best = min(best, total)
+			return
+		}
+		// Older versions are in the same layer or a later one
+		for layer := limit; layer <= maxChain; layer++ {
+			layers[i] = layer
+			try(i-1, layer)
+		}
+	}
+	try(newest-1, 1)
+	return best
+}
+
+func TestPlanPatchesChainLimit(t *testing.T) {
+	// Deterministic pseudo-random sizes, so the test checks the same histories every run
+	seed := uint32(1)
+	random := func() int64 {
+		seed = seed*1664525 + 1013904223
+		return int64(seed>>16) % 100
+	}
+
+	for round := 0; round < 200; round++ {
+		count := 3 + round%4
+		versions := make([]string, count)
+		for i := range versions {
+			versions[i] = fmt.Sprintf("%d.0", i)
+		}
+
+		// Alternate between unrelated sizes and sizes that grow with the releases a patch spans
+		sizes := make([][]int64, count-1)
+		table := make(map[string]int64)
+		changes := make([]int64, count)
+		for i := range changes {
+			changes[i] = 1 + random()
+		}
+		for i := range sizes {
+			sizes[i] = make([]int64, count)
+			for j := i + 1; j < count; j++ {
+				if round%2 == 0 {
+					sizes[i][j] = 1 + random()
+				} else {
+					sizes[i][j] = sizes[i][j-1] + changes[j-1]
+				}
+				table[versions[i]+">"+versions[j]] = sizes[i][j]
+			}
+		}
+
+		for maxChain := 1; maxChain < count; maxChain++ {
+			plan, err := PlanPatches(versions, tableSize(table), Options{MaxChain: maxChain})
+			if err != nil {
+				t.Fatalf("round %d: PlanPatches() error = %v", round, err)
+			}
+			if longest := plan.LongestChain(); longest > maxChain {
+				t.Errorf("round %d, chain limit %d: LongestChain() = %d", round, maxChain, longest)
+			}
+
+			smallest := smallestPatchSet(sizes, maxChain)
+			layered := smallestLayeredSet(sizes, maxChain)
+			if plan.MinimumSize < smallest || plan.MinimumSize > layered {
+				t.Errorf("round %d, chain limit %d: MinimumSize = %d, want between %d and %d", round, maxChain, plan.MinimumSize, smallest, layered)
+			}
+			if maxChain == count-1 && plan.MinimumSize != smallest {
+				t.Errorf("round %d without a chain limit: MinimumSize = %d, want %d", round, plan.MinimumSize, smallest)
+			}
+		}
+	}
+}
+
+func TestChain(t *testing.T) {
+	plan := &Plan{
+		Versions: []string{"1", "2", "3", "4"},
+		Patches: []Patch{
+			{From: "1", To: "2", Size: 10},
+			{From: "1", To: "4", Size: 30},
+			{From: "2", To: "3", Size: 10},
+			{From: "2", To: "4", Size: 25},
+			{From: "3", To: "4", Size: 10},
+		},
+	}
+
+	tests := []struct {
+		from string
+		want []string
+	}{
+		{from: "1", want: []string{"1>4"}},        // 30 in one patch beats 1>2>3>4, also 30, by fewer patches
+		{from: "2", want: []string{"2>3", "3>4"}}, // 20 beats the direct 25
+		{from: "3", want: []string{"3>4"}},
+		{from: "4"},
+		{from: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from, func(t *testing.T) {
+			var got []string
+			for _, p := range plan.Chain(tt.from) {
+				got = append(got, p.From+">"+p.To)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Chain(%q) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+
+	if got := plan.LongestChain(); got != 2 {
+		t.Errorf("LongestChain() = %d, want 2", got)
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	from := &utils.Manifest{Files: []utils.FileEntry{
+		{Path: "same.txt", Checksum: "a", Size: 100},
+		{Path: "changed.txt", Checksum: "b", Size: 200},
+		{Path: "removed.txt", Checksum: "c", Size: 300},
+	}}
+	to := &utils.Manifest{Files: []utils.FileEntry{
+		{Path: "same.txt", Checksum: "a", Size: 100},
+		{Path: "changed.txt", Checksum: "b2", Size: 250},
+		{Path: "added.txt", Checksum: "d", Size: 40},
+	}}
+
+	if got := EstimateSize(from, to); got != 290 {
+		t.Errorf("EstimateSize() = %d, want 290", got)
+	}
+	if got := EstimateSize(to, to); got != 0 {
+		t.Errorf("EstimateSize() of identical manifests = %d, want 0", got)
+	}
+}
@@ -2,6 +2,7 @@ package scanner
 
 import (
 	"bufio"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -35,7 +36,12 @@ func (ip *IgnorePatterns) LoadFromFile(rootPath string) error {
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
+	return ip.LoadFromReader(file)
+}
+
+// LoadFromReader loads ignore patterns in .cyberignore format, e.g. from a .cyberignore file inside an archive
+func (ip *IgnorePatterns) LoadFromReader(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
 
 	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
@@ -32,8 +31,8 @@ func (s *Scanner) ScanDirectoryParallel(workers int) ([]utils.FileEntry, []strin
 
 		relPath = filepath.ToSlash(relPath)
 
-		// Skip backup.cyberpatcher directory
-		if relPath == "backup.cyberpatcher" || strings.HasPrefix(relPath, "backup.cyberpatcher/") {
+		// Skip the backup directory and lock file created by the applier
+		if IsPatcherPath(relPath) {
 			if info.IsDir() {
 				return filepath.SkipDir
 			}
@@ -146,8 +145,8 @@ func (s *Scanner) ScanDirectoryParallelWithProgress(workers int, progressCallbac
 
 		relPath = filepath.ToSlash(relPath)
 
-		// Skip backup.cyberpatcher directory
-		if relPath == "backup.cyberpatcher" || strings.HasPrefix(relPath, "backup.cyberpatcher/") {
+		// Skip the backup directory and lock file created by the applier
+		if IsPatcherPath(relPath) {
 			if info.IsDir() {
 				return filepath.SkipDir
 			}
@@ -15,6 +15,13 @@ type Scanner struct {
 	ignorePatterns *IgnorePatterns
 }
 
+// IsPatcherPath reports whether a slash-separated relative path belongs to the applier's
+// own bookkeeping (backup directory, lock file or package info) rather than the application
+func IsPatcherPath(relPath string) bool {
+	return relPath == utils.BackupDirName || strings.HasPrefix(relPath, utils.BackupDirName+"/") ||
+		relPath == utils.LockFileName || relPath == utils.PackageInfoFileName
+}
+
 // NewScanner creates a new scanner for the given root path
 func NewScanner(rootPath string) *Scanner {
 	ignorePatterns := NewIgnorePatterns()
@@ -57,8 +64,8 @@ func (s *Scanner) ScanDirectory() ([]utils.FileEntry, []string, error) {
 		// Convert to forward slashes for consistency
 		relPath = filepath.ToSlash(relPath)
 
-		// Skip backup.cyberpatcher directory and all its contents
-		if relPath == "backup.cyberpatcher" || strings.HasPrefix(relPath, "backup.cyberpatcher/") {
+		// Skip the backup directory and lock file created by the applier
+		if IsPatcherPath(relPath) {
 			if info.IsDir() {
 				return filepath.SkipDir
 			}
@@ -115,8 +122,8 @@ func (s *Scanner) ScanDirectoryWithProgress(progressCallback func(current, total
 		}
 		relPath, _ := filepath.Rel(s.rootPath, path)
 		relPath = filepath.ToSlash(relPath)
-		// Skip backup.cyberpatcher directory
-		if relPath == "backup.cyberpatcher" || strings.HasPrefix(relPath, "backup.cyberpatcher/") {
+		// Skip the backup directory and lock file created by the applier
+		if IsPatcherPath(relPath) {
 			if info.IsDir() {
 				return filepath.SkipDir
 			}
@@ -156,8 +163,8 @@ func (s *Scanner) ScanDirectoryWithProgress(progressCallback func(current, total
 
 		relPath = filepath.ToSlash(relPath)
 
-		// Skip backup.cyberpatcher directory and all its contents
-		if relPath == "backup.cyberpatcher" || strings.HasPrefix(relPath, "backup.cyberpatcher/") {
+		// Skip the backup directory and lock file created by the applier
+		if IsPatcherPath(relPath) {
 			if info.IsDir() {
 				return filepath.SkipDir
 			}
@@ -206,6 +213,53 @@ func (s *Scanner) ScanDirectoryWithProgress(progressCallback func(current, total
 	return files, directories, nil
 }
 
+// ListPaths walks the directory tree and returns relative file and directory paths without hashing.
+// The same exclusions as ScanDirectory apply.
+func (s *Scanner) ListPaths() ([]string, []string, error) {
+	var files []string
+	var directories []string
+
+	err := filepath.Walk(s.rootPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fmt.Errorf("error accessing path %s: %w", path, err)
+		}
+
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path for %s: %w", path, err)
+		}
+
+		relPath, err := filepath.Rel(s.rootPath, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+		if relPath == "." {
+			return nil
+		}
+		relPath = filepath.ToSlash(relPath)
+
+		if IsPatcherPath(relPath) || s.ignorePatterns.ShouldIgnoreWithAbsPath(relPath, absPath) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			directories = append(directories, relPath)
+		} else {
+			files = append(files, relPath)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scan directory: %w", err)
+	}
+
+	return files, directories, nil
+}
+
 // FindFile searches for a file by relative path
 func (s *Scanner) FindFile(relPath string) (utils.FileEntry, error) {
 	fullPath := filepath.Join(s.rootPath, filepath.FromSlash(relPath))
@@ -0,0 +1,333 @@
+// Package serve serves a patch output directory over HTTP for patch-update and other clients.
+// Files are served with Range support and a strong ETag made from their SHA-256.
+package serve
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/catalog"
+)
+
+// Options configures a Handler
+type Options struct {
+	ListDirs  bool      // Show directory listings (off by default)
+	Username  string    // Basic auth user name ("" = no authentication)
+	Password  string    // Basic auth password
+	Realm     string    // Basic auth realm shown by browsers
+	AccessLog io.Writer // Receives one line per request (nil = no access log)
+}
+
+// Handler serves the files of one directory
+type Handler struct {
+	root  string
+	opts  Options
+	etags *etagCache
+	logMu sync.Mutex
+}
+
+// NewHandler creates a handler serving the files in root
+func NewHandler(root string, opts Options) (*Handler, error) {
+	abs, err := filepath.Abs(root)
+	if err == nil {
+		abs, err = filepath.EvalSymlinks(abs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open patch directory: %w", err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open patch directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", root)
+	}
+	if opts.Realm == "" {
+		opts.Realm = "CyberPatchMaker"
+	}
+	return &Handler{root: abs, opts: opts, etags: newETagCache()}, nil
+}
+
+// Prehash computes the ETags of every file in the directory so the first requests don't wait
+// for large files to be hashed. Returns the number of files hashed.
+func (h *Handler) Prehash() (int, error) {
+	count := 0
+	err := filepath.WalkDir(h.root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != h.root && hidden(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if hidden(d.Name()) || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if _, err := h.etags.get(p, info); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// ServeHTTP implements http.Handler
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	user := h.serve(rec, r)
+	h.logAccess(r, rec, user, time.Since(start))
+}
+
+// serve handles a request and returns the authenticated user name, if any
+func (h *Handler) serve(w http.ResponseWriter, r *http.Request) string {
+	user := ""
+	if h.opts.Username != "" {
+		var ok bool
+		if user, ok = h.authenticate(r); !ok {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", h.opts.Realm))
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return ""
+		}
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return user
+	}
+
+	// Clean the URL path and refuse hidden files and directories (e.g. the .data scan cache)
+	urlPath := path.Clean("/" + r.URL.Path)
+	for _, segment := range strings.Split(urlPath, "/") {
+		if hidden(segment) {
+			http.NotFound(w, r)
+			return user
+		}
+	}
+	fsPath := filepath.Join(h.root, filepath.FromSlash(urlPath))
+
+	info, err := os.Stat(fsPath)
+	if err != nil || !h.inside(fsPath) {
+		http.NotFound(w, r)
+		return user
+	}
+	if info.IsDir() {
+		if !h.opts.ListDirs {
+			http.NotFound(w, r)
+			return user
+		}
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.Redirect(w, r, path.Base(urlPath)+"/", http.StatusMovedPermanently)
+			return user
+		}
+		h.listDir(w, fsPath, urlPath)
+		return user
+	}
+	if !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return user
+	}
+
+	h.serveFile(w, r, fsPath, info)
+	return user
+}
+
+// serveFile serves a regular file with Range support and a SHA-256 ETag
+func (h *Handler) serveFile(w http.ResponseWriter, r *http.Request, fsPath string, info os.FileInfo) {
+	etag, err := h.etags.get(fsPath, info)
+	if err != nil {
+		http.Error(w, "failed to read file", http.StatusInternalServerError)
+		return
+	}
+
+	f, err := os.Open(fsPath)
+	if err != nil {
+		http.Error(w, "failed to read file", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	// ServeContent uses the ETag for If-None-Match, If-Match and If-Range, so a client resuming
+	// a download of a file that has since been replaced gets the whole new file instead of a range
+	w.Header().Set("ETag", etag)
+	if filepath.Base(fsPath) == catalog.FileName {
+		// The index changes with every release; clients must revalidate it
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	http.ServeContent(w, r, filepath.Base(fsPath), info.ModTime(), f)
+}
+
+// inside reports whether fsPath, with symlinks resolved, is within the served directory
+func (h *Handler) inside(fsPath string) bool {
+	real, err := filepath.EvalSymlinks(fsPath)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(h.root, real)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// authenticate checks the request's basic auth credentials in constant time
+func (h *Handler) authenticate(r *http.Request) (string, bool) {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return "", false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(user), []byte(h.opts.Username)) == 1
+	passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(h.opts.Password)) == 1
+	return user, userOK && passOK
+}
+
+// listDir writes a plain HTML listing of a directory, without hidden entries
+func (h *Handler) listDir(w http.ResponseWriter, fsPath, urlPath string) {
+	entries, err := os.ReadDir(fsPath)
+	if err != nil {
+		http.Error(w, "failed to read directory", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<title>%s</title>\n<h1>%s</h1>\n<pre>\n", html.EscapeString(urlPath), html.EscapeString(urlPath))
+	for _, entry := range entries {
+		if hidden(entry.Name()) {
+			continue
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			name += "/"
+		}
+		fmt.Fprintf(w, "<a href=\"%s\">%s</a>\n", html.EscapeString((&url.URL{Path: name}).String()), html.EscapeString(name))
+	}
+	fmt.Fprint(w, "</pre>\n")
+}
+
+// logAccess writes a Common Log Format line extended with the Range header and duration
+func (h *Handler) logAccess(r *http.Request, rec *statusRecorder, user string, elapsed time.Duration) {
+	if h.opts.AccessLog == nil {
+		return
+	}
+	if user == "" {
+		user = "-"
+	}
+	rangeHeader := r.Header.Get("Range")
+	if rangeHeader == "" {
+		rangeHeader = "-"
+	}
+	h.logMu.Lock()
+	defer h.logMu.Unlock()
+	fmt.Fprintf(h.opts.AccessLog, "%s - %s [%s] %q %d %d %q %q %.3fs\n",
+		remoteHost(r.RemoteAddr), user, time.Now().Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method+" "+r.URL.RequestURI()+" "+r.Proto, rec.status, rec.written,
+		rangeHeader, r.UserAgent(), elapsed.Seconds())
+}
+
+// hidden reports whether a path segment names a hidden file, which is never served
+func hidden(name string) bool {
+	return strings.HasPrefix(name, ".") && name != "." && name != ""
+}
+
+// remoteHost strips the port from a remote address
+func remoteHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// statusRecorder records the status code and body size of a response for the access log
+type statusRecorder struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+// WriteHeader implements http.ResponseWriter
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(p)
+	r.written += int64(n)
+	return n, err
+}
+
+// etagCache holds the SHA-256 ETag of each file, recomputed when its size or modification time changes
+type etagCache struct {
+	mu      sync.Mutex
+	entries map[string]*etagEntry
+}
+
+// etagEntry is the ETag of one version of a file
+type etagEntry struct {
+	size    int64
+	modTime time.Time
+	once    sync.Once
+	etag    string
+	err     error
+}
+
+// newETagCache creates an empty cache
+func newETagCache() *etagCache {
+	return &etagCache{entries: make(map[string]*etagEntry)}
+}
+
+// get returns the ETag of a file, hashing it if it is new or has changed.
+// Concurrent requests for the same file wait for a single hash.
+func (c *etagCache) get(fsPath string, info os.FileInfo) (string, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[fsPath]
+	if !ok || entry.size != info.Size() || !entry.modTime.Equal(info.ModTime()) {
+		entry = &etagEntry{size: info.Size(), modTime: info.ModTime()}
+		c.entries[fsPath] = entry
+	}
+	c.mu.Unlock()
+
+	entry.once.Do(func() {
+		f, err := os.Open(fsPath)
+		if err != nil {
+			entry.err = err
+			return
+		}
+		defer f.Close()
+		hasher := sha256.New()
+		if _, err := io.Copy(hasher, f); err != nil {
+			entry.err = err
+			return
+		}
+		entry.etag = fmt.Sprintf("\"%x\"", hasher.Sum(nil))
+	})
+	if entry.err != nil {
+		// Don't cache failures; the file may be readable next time
+		c.mu.Lock()
+		if c.entries[fsPath] == entry {
+			delete(c.entries, fsPath)
+		}
+		c.mu.Unlock()
+	}
+	return entry.etag, entry.err
+}
@@ -0,0 +1,202 @@
+// Package update fetches an update index over HTTP, identifies a local install, plans the
+// patch chain to a target version and downloads the patch files with SHA-256 verification.
+// It only needs a static file server hosting a patch output directory and its index.json.
+package update
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/catalog"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/download"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/version"
+)
+
+// maxIndexSize limits how much of index.json is read, so a wrong URL can't exhaust memory
+const maxIndexSize = 64 << 20
+
+// responseTimeout is how long to wait for a server to start answering a request.
+// There is no overall timeout, since large patch files can take hours to download.
+const responseTimeout = 30 * time.Second
+
+// Client downloads an update index and the patch files it lists from a base URL
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	userAgent  string
+	downloader *download.Downloader
+}
+
+// NewClient creates a client for the patch directory at baseURL, using NewHTTPClient
+func NewClient(baseURL string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid update URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid update URL %q: only http and https are supported", u.Redacted())
+	}
+	// File names in the index are relative to the directory holding index.json
+	if !strings.HasSuffix(u.Path, "/") {
+		if path.Base(u.Path) == catalog.FileName {
+			u.Path = strings.TrimSuffix(u.Path, catalog.FileName)
+		} else {
+			u.Path += "/"
+		}
+	}
+
+	httpClient := NewHTTPClient()
+	downloader := download.NewDownloader(httpClient)
+	downloader.SetUserAgent(UserAgent())
+	return &Client{
+		baseURL:    u,
+		httpClient: httpClient,
+		userAgent:  UserAgent(),
+		downloader: downloader,
+	}, nil
+}
+
+// NewHTTPClient creates the HTTP client used for updates. Proxies are taken from the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func NewHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	transport.ResponseHeaderTimeout = responseTimeout
+	return &http.Client{Transport: transport}
+}
+
+// UserAgent returns the User-Agent header sent by the updater
+func UserAgent() string {
+	return "CyberPatchMaker-Updater/" + version.GetVersion()
+}
+
+// SetHTTPClient replaces the HTTP client, e.g. to use custom TLS settings
+func (c *Client) SetHTTPClient(httpClient *http.Client) {
+	c.httpClient = httpClient
+	c.downloader.SetHTTPClient(httpClient)
+}
+
+// Downloader returns the downloader used for patch files, to set retries, parallelism and progress
+func (c *Client) Downloader() *download.Downloader {
+	return c.downloader
+}
+
+// BaseURL returns the URL of the directory holding index.json, with any password redacted
+func (c *Client) BaseURL() string {
+	return c.baseURL.Redacted()
+}
+
+// resolve returns the URL of a file named relative to the index
+func (c *Client) resolve(name string) (*url.URL, error) {
+	if err := checkName(name); err != nil {
+		return nil, err
+	}
+	ref := &url.URL{Path: name}
+	return c.baseURL.ResolveReference(ref), nil
+}
+
+// get sends a GET request and returns the response if the status is 200 OK
+func (c *Client) get(ctx context.Context, u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for %s: %w", u.Redacted(), err)
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", u.Redacted(), err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download %s: %s", u.Redacted(), resp.Status)
+	}
+	return resp, nil
+}
+
+// FetchIndex downloads and parses index.json
+func (c *Client) FetchIndex(ctx context.Context) (*catalog.Index, error) {
+	u, err := c.resolve(catalog.FileName)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.get(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxIndexSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", u, err)
+	}
+	if len(data) > maxIndexSize {
+		return nil, fmt.Errorf("update index at %s is larger than %d MB", u, maxIndexSize>>20)
+	}
+	return catalog.Parse(data)
+}
+
+// DownloadPatch fetches every part and chunk of a patch into destDir, keeping their relative names.
+// Files are downloaded in parallel and resumed if an earlier download was interrupted; each one is
+// checked against the size and SHA-256 in the index. Returns the path of the file to load the patch
+// from (part 1 of a multi-part patch).
+func (c *Client) DownloadPatch(ctx context.Context, entry catalog.PatchEntry, destDir string) (string, error) {
+	files := PatchFiles(entry)
+	if len(files) == 0 || entry.Parts[0].File == nil {
+		return "", fmt.Errorf("patch %s → %s has no files in the update index", entry.From, entry.To)
+	}
+
+	reqs := make([]download.Request, 0, len(files))
+	for _, file := range files {
+		u, err := c.resolve(file.Name)
+		if err != nil {
+			return "", err
+		}
+		reqs = append(reqs, download.Request{
+			URL:    u.String(),
+			Path:   filepath.Join(destDir, filepath.FromSlash(file.Name)),
+			Name:   file.Name,
+			Size:   file.Size,
+			SHA256: file.SHA256,
+		})
+	}
+	if err := c.downloader.FetchAll(ctx, reqs); err != nil {
+		return "", err
+	}
+	return filepath.Join(destDir, filepath.FromSlash(entry.Parts[0].File.Name)), nil
+}
+
+// PatchFiles lists the files to download for a patch: each part's file, chunk list and chunks
+func PatchFiles(entry catalog.PatchEntry) []catalog.File {
+	var files []catalog.File
+	for _, part := range entry.Parts {
+		if part.File != nil {
+			files = append(files, *part.File)
+		}
+		if part.ChunkList != nil {
+			files = append(files, *part.ChunkList)
+		}
+		files = append(files, part.Chunks...)
+	}
+	return files
+}
+
+// checkName rejects file names that would leave the patch directory
+func checkName(name string) error {
+	if name == "" || strings.Contains(name, `\`) || path.IsAbs(name) || filepath.IsAbs(name) ||
+		strings.Contains(name, ":") {
+		return fmt.Errorf("update index lists an invalid file name %q", name)
+	}
+	clean := path.Clean(name)
+	if clean != name || clean == ".." || strings.HasPrefix(clean, "../") {
+		return fmt.Errorf("update index lists an invalid file name %q", name)
+	}
+	return nil
+}
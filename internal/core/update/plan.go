@@ -0,0 +1,163 @@
+package update
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/catalog"
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/version"
+	"github.com/cyberofficial/cyberpatchmaker/pkg/utils"
+)
+
+// IdentifyInstall returns the version in the index whose key file matches the install in dir.
+// keyFile, relative to dir, overrides the key file path recorded for each version (for renamed key files).
+func IdentifyInstall(index *catalog.Index, dir, keyFile string) (*catalog.VersionEntry, error) {
+	var matches []*catalog.VersionEntry
+	checksums := make(map[string]string) // Key file path → checksum, so shared key files are hashed once
+
+	for n := range index.Versions {
+		entry := &index.Versions[n]
+		path := entry.KeyFile.Path
+		if keyFile != "" {
+			path = keyFile
+		}
+		path = filepath.Join(dir, filepath.FromSlash(path))
+
+		checksum, hashed := checksums[path]
+		if !hashed {
+			if utils.FileExists(path) {
+				checksum, _ = utils.CalculateFileChecksum(path)
+			}
+			checksums[path] = checksum
+		}
+		if checksum != "" && checksum == entry.KeyFile.SHA256 {
+			matches = append(matches, entry)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		var keyFiles []string
+		for path := range checksums {
+			keyFiles = append(keyFiles, path)
+		}
+		sort.Strings(keyFiles)
+		return nil, fmt.Errorf("no version in the update index matches the install in %s (checked %s)",
+			dir, strings.Join(keyFiles, ", "))
+	case 1:
+		return matches[0], nil
+	}
+
+	var names []string
+	for _, match := range matches {
+		names = append(names, match.Version)
+	}
+	return nil, fmt.Errorf("the install in %s matches several versions (%s); choose one with --from",
+		dir, strings.Join(names, ", "))
+}
+
+// LatestVersion returns the newest version that some patch leads to.
+// Pre-release versions are skipped unless includePreRelease is set.
+func LatestVersion(index *catalog.Index, includePreRelease bool) (string, error) {
+	var latest *version.Number
+	for _, entry := range index.Patches {
+		v, err := version.Parse(entry.To)
+		if err != nil || (v.IsPreRelease() && !includePreRelease) {
+			continue
+		}
+		if latest == nil || v.Compare(*latest) > 0 {
+			latest = &v
+		}
+	}
+	if latest == nil {
+		return "", fmt.Errorf("the update index lists no patches to a release version")
+	}
+	return latest.String(), nil
+}
+
+// chainCost orders candidate chains: the smallest download first, then the fewest patches
+type chainCost struct {
+	size  int64
+	hops  int
+	valid bool
+}
+
+// less reports whether c is a better chain than other
+func (c chainCost) less(other chainCost) bool {
+	if !other.valid {
+		return c.valid
+	}
+	if c.size != other.size {
+		return c.size < other.size
+	}
+	return c.hops < other.hops
+}
+
+// PlanChain returns the patches that take an install from one version to another, in the order to
+// apply them. The chain with the smallest total download is chosen, and among those the one with
+// the fewest patches. Only upgrade patches are used to move forward and only downgrade patches to move back.
+// Returns an empty chain if from and to are the same version.
+func PlanChain(index *catalog.Index, from, to string) ([]catalog.PatchEntry, error) {
+	if from == to {
+		return nil, nil
+	}
+	downgrade := false
+	if c, err := version.Compare(from, to); err == nil {
+		downgrade = c > 0
+	}
+
+	// Dijkstra's algorithm over versions; indexes are small, so a linear scan for the next version is enough
+	cost := map[string]chainCost{from: {valid: true}}
+	via := make(map[string]int) // Version → index of the patch that reaches it
+	done := make(map[string]bool)
+	for {
+		current := ""
+		for v, c := range cost {
+			if !done[v] && (current == "" || c.less(cost[current]) || (!cost[current].less(c) && v < current)) {
+				current = v
+			}
+		}
+		if current == "" || current == to {
+			break
+		}
+		done[current] = true
+
+		for n, entry := range index.Patches {
+			if entry.From != current || entry.Downgrade != downgrade || done[entry.To] {
+				continue
+			}
+			next := chainCost{size: cost[current].size + entry.TotalSize, hops: cost[current].hops + 1, valid: true}
+			if next.less(cost[entry.To]) {
+				cost[entry.To] = next
+				via[entry.To] = n
+			}
+		}
+	}
+
+	if _, ok := cost[to]; !ok {
+		direction := "upgrade"
+		if downgrade {
+			direction = "downgrade"
+		}
+		return nil, fmt.Errorf("the update index has no %s path from %s to %s", direction, from, to)
+	}
+
+	var chain []catalog.PatchEntry
+	for v := to; v != from; {
+		entry := index.Patches[via[v]]
+		chain = append([]catalog.PatchEntry{entry}, chain...)
+		v = entry.From
+	}
+	return chain, nil
+}
+
+// ChainSize returns the total download size of a chain
+func ChainSize(chain []catalog.PatchEntry) int64 {
+	var total int64
+	for _, entry := range chain {
+		total += entry.TotalSize
+	}
+	return total
+}
@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/cyberofficial/cyberpatchmaker/internal/core/archive"
 	"github.com/cyberofficial/cyberpatchmaker/internal/core/cache"
 	"github.com/cyberofficial/cyberpatchmaker/internal/core/manifest"
 	"github.com/cyberofficial/cyberpatchmaker/internal/core/scanner"
@@ -90,29 +91,41 @@ func (m *Manager) RegisterVersion(versionNumber, location, keyFilePath string) (
 			fmt.Printf("Loading cached scan for version %s...\n", versionNumber)
 			cachedVersion, err := m.scanCache.LoadScan(versionNumber, location)
 			if err == nil {
-				// Verify key file still matches
-				keyFilePath := cachedVersion.KeyFile.Path
-				fullKeyPath := filepath.Join(location, keyFilePath)
-				if utils.FileExists(fullKeyPath) {
-					match, _ := utils.VerifyFileChecksum(fullKeyPath, cachedVersion.KeyFile.Checksum)
-					if match {
-						// Cache is valid, use it
-						m.registry.Versions[versionNumber] = cachedVersion
-						fmt.Printf("✓ Loaded from cache: %d files, %d directories\n",
-							len(cachedVersion.Manifest.Files), len(cachedVersion.Manifest.Directories))
-						fmt.Printf("Version %s registered: %d files, %d directories\n",
-							versionNumber, len(cachedVersion.Manifest.Files), len(cachedVersion.Manifest.Directories))
-						fmt.Printf("Preparing to compare versions...\nThis may take a while, program will build and gather the files to hash\n")
-						return cachedVersion, nil
-					}
+				if cachedScanValid(location, cachedVersion) {
+					// Cache is valid, use it
+					m.registry.Versions[versionNumber] = cachedVersion
+					fmt.Printf("✓ Loaded from cache: %d files, %d directories\n",
+						len(cachedVersion.Manifest.Files), len(cachedVersion.Manifest.Directories))
+					fmt.Printf("Version %s registered: %d files, %d directories\n",
+						versionNumber, len(cachedVersion.Manifest.Files), len(cachedVersion.Manifest.Directories))
+					fmt.Printf("Preparing to compare versions...\nThis may take a while, program will build and gather the files to hash\n")
+					return cachedVersion, nil
+				}
+				if archive.IsArchive(location) {
+					fmt.Printf("Cache invalid (archive changed), rescanning...\n")
+				} else {
+					fmt.Printf("Cache invalid (key file changed), rescanning...\n")
 				}
-				fmt.Printf("Cache invalid (key file changed), rescanning...\n")
 			} else {
 				fmt.Printf("Failed to load cache: %v, rescanning...\n", err)
 			}
 		}
 	}
 
+	// Archives are scanned member by member, without extracting them
+	if archive.IsArchive(location) {
+		// Taken before the scan, so an archive replaced during the scan is rescanned next time
+		archiveInfo, err := os.Stat(location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read version archive: %w", err)
+		}
+		files, directories, keyFileEntry, err := scanArchive(versionNumber, location, keyFilePath)
+		if err != nil {
+			return nil, err
+		}
+		return m.addVersion(versionNumber, location, keyFilePath, keyFileEntry, files, directories, archiveInfo)
+	}
+
 	// Scan the directory
 	scan := scanner.NewScanner(location)
 	if err := scan.ValidatePath(); err != nil {
@@ -180,6 +193,12 @@ func (m *Manager) RegisterVersion(versionNumber, location, keyFilePath string) (
 		return nil, fmt.Errorf("key file not found: %w", err)
 	}
 
+	return m.addVersion(versionNumber, location, keyFilePath, keyFileEntry, files, directories, nil)
+}
+
+// addVersion creates the manifest of a scanned version, registers the version and caches the scan.
+// archiveInfo describes the archive a version was scanned from (nil for a directory). The caller holds m.mu.
+func (m *Manager) addVersion(versionNumber, location, keyFilePath string, keyFileEntry utils.FileEntry, files []utils.FileEntry, directories []string, archiveInfo os.FileInfo) (*utils.Version, error) {
 	keyFileInfo := utils.KeyFileInfo{
 		Path:     keyFilePath,
 		Checksum: keyFileEntry.Checksum,
@@ -201,6 +220,10 @@ func (m *Manager) RegisterVersion(versionNumber, location, keyFilePath string) (
 		RegisteredAt: time.Now(),
 		LastScanned:  time.Now(),
 	}
+	if archiveInfo != nil {
+		version.ArchiveSize = archiveInfo.Size()
+		version.ArchiveModTime = archiveInfo.ModTime()
+	}
 
 	m.registry.Versions[versionNumber] = version
 
@@ -220,6 +243,48 @@ func (m *Manager) RegisterVersion(versionNumber, location, keyFilePath string) (
 	return version, nil
 }
 
+// scanArchive scans the version in an archive and finds its key file among the archive's files
+func scanArchive(versionNumber, location, keyFilePath string) ([]utils.FileEntry, []string, utils.FileEntry, error) {
+	fmt.Printf("Scanning version %s in archive %s...\n", versionNumber, location)
+
+	startTime := time.Now()
+	files, directories, err := archive.Scan(location, func(files int, bytes int64) {
+		fmt.Printf("\rScanning: %d files, %s | Elapsed: %s     ", files, utils.FormatBytes(bytes), formatDuration(time.Since(startTime).Seconds()))
+	})
+	fmt.Println() // New line after progress
+	if err != nil {
+		return nil, nil, utils.FileEntry{}, fmt.Errorf("failed to scan version archive: %w", err)
+	}
+
+	keyFilePath = filepath.ToSlash(keyFilePath)
+	for _, file := range files {
+		if file.Path == keyFilePath {
+			return files, directories, file, nil
+		}
+	}
+	return nil, nil, utils.FileEntry{}, fmt.Errorf("key file not found: %s is not in %s", keyFilePath, location)
+}
+
+// cachedScanValid reports whether a cached scan still describes the version at location. For a
+// directory, the key file must be unchanged; an archive is replaced as a whole, so its scan is
+// valid while the archive has the size and modification time it had when it was scanned. (An
+// archive copied in with its original time preserved can be older than the scan, so comparing
+// with the scan time is not enough.)
+func cachedScanValid(location string, cached *utils.Version) bool {
+	if archive.IsArchive(location) {
+		info, err := os.Stat(location)
+		return err == nil && cached.ArchiveSize > 0 && info.Size() == cached.ArchiveSize &&
+			info.ModTime().Equal(cached.ArchiveModTime)
+	}
+
+	fullKeyPath := filepath.Join(location, cached.KeyFile.Path)
+	if !utils.FileExists(fullKeyPath) {
+		return false
+	}
+	match, _ := utils.VerifyFileChecksum(fullKeyPath, cached.KeyFile.Checksum)
+	return match
+}
+
 // UnregisterVersion removes a version from the registry
 func (m *Manager) UnregisterVersion(versionNumber string) error {
 	m.mu.Lock()
@@ -0,0 +1,282 @@
+package version
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxComponents is the most numeric components a version number may have (1.2.3.4)
+const maxComponents = 4
+
+// Number is a parsed version number: semantic versions (1.2.3, 1.2.3-beta.1+build.5)
+// and game-style versions with one to four numeric components (1.2, 1.2.3.4).
+// An optional leading "v" is accepted.
+type Number struct {
+	Original   string   // The string that was parsed
+	Components []int    // Numeric components; missing components compare as 0
+	PreRelease []string // Dot-separated pre-release identifiers (empty for releases)
+	Build      string   // Build metadata after "+" (ignored when comparing)
+}
+
+// Parse parses a version number
+func Parse(s string) (Number, error) {
+	n := Number{Original: s}
+	rest := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(s), "v"), "V")
+	if rest == "" {
+		return n, fmt.Errorf("invalid version %q: empty", s)
+	}
+
+	if i := strings.IndexByte(rest, '+'); i >= 0 {
+		n.Build = rest[i+1:]
+		rest = rest[:i]
+		if n.Build == "" {
+			return n, fmt.Errorf("invalid version %q: empty build metadata", s)
+		}
+	}
+	if i := strings.IndexByte(rest, '-'); i >= 0 {
+		pre := rest[i+1:]
+		rest = rest[:i]
+		if pre == "" {
+			return n, fmt.Errorf("invalid version %q: empty pre-release", s)
+		}
+		n.PreRelease = strings.Split(pre, ".")
+		for _, id := range n.PreRelease {
+			if id == "" || strings.TrimLeft(id, "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ-") != "" {
+				return n, fmt.Errorf("invalid version %q: bad pre-release identifier %q", s, id)
+			}
+		}
+	}
+
+	parts := strings.Split(rest, ".")
+	if len(parts) > maxComponents {
+		return n, fmt.Errorf("invalid version %q: more than %d numeric components", s, maxComponents)
+	}
+	for _, part := range parts {
+		value, err := strconv.Atoi(part)
+		if err != nil || value < 0 {
+			return n, fmt.Errorf("invalid version %q: %q is not a number", s, part)
+		}
+		n.Components = append(n.Components, value)
+	}
+	return n, nil
+}
+
+// String returns the version as it was written
+func (n Number) String() string {
+	return n.Original
+}
+
+// IsPreRelease reports whether the version has a pre-release suffix
+func (n Number) IsPreRelease() bool {
+	return len(n.PreRelease) > 0
+}
+
+// Compare returns -1, 0 or 1 as n is older than, equal to or newer than other.
+// Pre-releases are older than the release they precede; build metadata is ignored.
+func (n Number) Compare(other Number) int {
+	for i := 0; i < maxComponents; i++ {
+		if c := compareInts(component(n.Components, i), component(other.Components, i)); c != 0 {
+			return c
+		}
+	}
+
+	switch {
+	case !n.IsPreRelease() && !other.IsPreRelease():
+		return 0
+	case !n.IsPreRelease():
+		return 1
+	case !other.IsPreRelease():
+		return -1
+	}
+
+	for i := 0; i < len(n.PreRelease) && i < len(other.PreRelease); i++ {
+		if c := comparePreRelease(n.PreRelease[i], other.PreRelease[i]); c != 0 {
+			return c
+		}
+	}
+	return compareInts(len(n.PreRelease), len(other.PreRelease))
+}
+
+// component returns the i-th numeric component, or 0 if there are fewer
+func component(components []int, i int) int {
+	if i < len(components) {
+		return components[i]
+	}
+	return 0
+}
+
+// comparePreRelease compares pre-release identifiers as semantic versioning does:
+// numeric identifiers numerically and before alphanumeric ones, which compare as text
+func comparePreRelease(a, b string) int {
+	aNum, aErr := strconv.Atoi(a)
+	bNum, bErr := strconv.Atoi(b)
+	switch {
+	case aErr == nil && bErr == nil:
+		return compareInts(aNum, bNum)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// compareInts returns -1, 0 or 1 as a is less than, equal to or greater than b
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Compare parses and compares two version strings.
+// Returns an error if either one is not a version number.
+func Compare(a, b string) (int, error) {
+	va, err := Parse(a)
+	if err != nil {
+		return 0, err
+	}
+	vb, err := Parse(b)
+	if err != nil {
+		return 0, err
+	}
+	return va.Compare(vb), nil
+}
+
+// SortNames parses version strings and sorts them oldest first.
+// Names that are not version numbers are returned separately, in their original order.
+func SortNames(names []string) ([]Number, []string) {
+	var versions []Number
+	var invalid []string
+	for _, name := range names {
+		v, err := Parse(name)
+		if err != nil {
+			invalid = append(invalid, name)
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Compare(versions[j]) < 0
+	})
+	return versions, invalid
+}
+
+// constraintTerm is a single comparison such as ">=1.4.0"
+type constraintTerm struct {
+	op      string
+	version Number
+}
+
+// Constraint is a set of comparisons a version must all satisfy, e.g. ">=1.4.0, <2.0.0"
+type Constraint struct {
+	text  string
+	terms []constraintTerm
+}
+
+// constraintOps lists the supported operators, longest first so ">=" is not read as ">"
+var constraintOps = []string{">=", "<=", "!=", "==", ">", "<", "="}
+
+// ParseConstraint parses comparisons separated by commas or spaces.
+// Supported operators: =, ==, !=, >, >=, <, <=. A bare version means "=".
+func ParseConstraint(s string) (*Constraint, error) {
+	c := &Constraint{text: strings.TrimSpace(s)}
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		op := "="
+		for _, candidate := range constraintOps {
+			if strings.HasPrefix(field, candidate) {
+				op = candidate
+				field = field[len(candidate):]
+				break
+			}
+		}
+		// Allow a space between the operator and the version (">= 1.4.0")
+		if field == "" && i+1 < len(fields) {
+			i++
+			field = fields[i]
+		}
+		v, err := Parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version range %q: %w", s, err)
+		}
+		if op == "==" {
+			op = "="
+		}
+		c.terms = append(c.terms, constraintTerm{op: op, version: v})
+	}
+
+	if len(c.terms) == 0 {
+		return nil, fmt.Errorf("invalid version range %q: no comparisons", s)
+	}
+	return c, nil
+}
+
+// String returns the constraint as it was written
+func (c *Constraint) String() string {
+	return c.text
+}
+
+// Check reports whether v satisfies every comparison
+func (c *Constraint) Check(v Number) bool {
+	for _, term := range c.terms {
+		cmp := v.Compare(term.version)
+		var ok bool
+		switch term.op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter selects which versions to use, e.g. as sources for batch patch generation
+type Filter struct {
+	Constraint     *Constraint // Only versions satisfying the constraint (nil = any)
+	SkipPreRelease bool        // Leave out pre-release versions
+	Last           int         // Only the newest N versions that pass the other checks (0 = all)
+}
+
+// IsEmpty reports whether the filter selects every version
+func (f Filter) IsEmpty() bool {
+	return f.Constraint == nil && !f.SkipPreRelease && f.Last <= 0
+}
+
+// Apply returns the versions that pass the filter, keeping their order.
+// versions must be sorted oldest first for Last to select the newest ones.
+func (f Filter) Apply(versions []Number) []Number {
+	var selected []Number
+	for _, v := range versions {
+		if f.SkipPreRelease && v.IsPreRelease() {
+			continue
+		}
+		if f.Constraint != nil && !f.Constraint.Check(v) {
+			continue
+		}
+		selected = append(selected, v)
+	}
+	if f.Last > 0 && len(selected) > f.Last {
+		selected = selected[len(selected)-f.Last:]
+	}
+	return selected
+}
@@ -0,0 +1,185 @@
+package version
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input      string
+		components []int
+		preRelease []string
+		build      string
+		wantErr    bool
+	}{
+		{input: "1.2.3", components: []int{1, 2, 3}},
+		{input: "v1.2", components: []int{1, 2}},
+		{input: "V7", components: []int{7}},
+		{input: "1.2.3.4", components: []int{1, 2, 3, 4}},
+		{input: " 1.0.0 ", components: []int{1, 0, 0}},
+		{input: "1.2.3-beta.1", components: []int{1, 2, 3}, preRelease: []string{"beta", "1"}},
+		{input: "1.2.3+build.5", components: []int{1, 2, 3}, build: "build.5"},
+		{input: "1.2.3-rc-1+exp", components: []int{1, 2, 3}, preRelease: []string{"rc-1"}, build: "exp"},
+		{input: "", wantErr: true},
+		{input: "v", wantErr: true},
+		{input: "1.2.3.4.5", wantErr: true},
+		{input: "1..2", wantErr: true},
+		{input: "1.x", wantErr: true},
+		{input: "1.-2", wantErr: true},
+		{input: "1.2-", wantErr: true},
+		{input: "1.2+", wantErr: true},
+		{input: "1.2-beta..1", wantErr: true},
+		{input: "1.2-beta_1", wantErr: true},
+		{input: "release", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %t", tt.input, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got.Components, tt.components) || !reflect.DeepEqual(got.PreRelease, tt.preRelease) || got.Build != tt.build {
+				t.Errorf("Parse(%q) = %v %v %q, want %v %v %q", tt.input, got.Components, got.PreRelease, got.Build, tt.components, tt.preRelease, tt.build)
+			}
+			if got.String() != tt.input {
+				t.Errorf("String() = %q, want %q", got.String(), tt.input)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0", "1.0.0.0", 0},
+		{"v1.2.3", "1.2.3", 0},
+		{"1.2.3+a", "1.2.3+b", 0},
+		{"1.9.0", "1.10.0", -1},
+		{"2.0", "1.99.99", 1},
+		{"1.2.3", "1.2.3.1", -1},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0", "1.0.0-rc.1", 1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta.2", "1.0.0-beta.11", -1},
+		{"1.0.0-beta", "1.0.0-alpha", 1},
+		{"1.0.0-rc.1", "0.9.9", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.a+"_vs_"+tt.b, func(t *testing.T) {
+			got, err := Compare(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("Compare() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Compare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+			}
+			if reverse, _ := Compare(tt.b, tt.a); reverse != -tt.want {
+				t.Errorf("Compare(%q, %q) = %d, want %d", tt.b, tt.a, reverse, -tt.want)
+			}
+		})
+	}
+
+	if _, err := Compare("1.0", "latest"); err == nil {
+		t.Error("Compare() accepted a name that is not a version number")
+	}
+}
+
+func TestSortNames(t *testing.T) {
+	versions, invalid := SortNames([]string{"1.10.0", "latest", "1.2.0", "1.2.0-rc.1", "v1.9", "backup", "1.2"})
+
+	var names []string
+	for _, v := range versions {
+		names = append(names, v.String())
+	}
+	// 1.2.0 and 1.2 are equal, so they keep their input order
+	if want := []string{"1.2.0-rc.1", "1.2.0", "1.2", "v1.9", "1.10.0"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("sorted versions = %v, want %v", names, want)
+	}
+	if want := []string{"latest", "backup"}; !reflect.DeepEqual(invalid, want) {
+		t.Errorf("invalid names = %v, want %v", invalid, want)
+	}
+}
+
+func TestConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		accept     []string
+		reject     []string
+		wantErr    bool
+	}{
+		{constraint: "1.2.0", accept: []string{"1.2", "1.2.0+x"}, reject: []string{"1.2.1"}},
+		{constraint: "==1.2.0", accept: []string{"1.2.0"}, reject: []string{"1.3.0"}},
+		{constraint: "!=1.2.0", accept: []string{"1.2.1"}, reject: []string{"1.2.0"}},
+		{constraint: ">=1.4.0, <2.0.0", accept: []string{"1.4.0", "1.99", "2.0.0-rc.1"}, reject: []string{"1.3.9", "2.0.0"}},
+		{constraint: ">= 1.4.0 < 2.0.0", accept: []string{"1.5.0"}, reject: []string{"2.1.0"}},
+		{constraint: ">1.0 <=1.2", accept: []string{"1.1", "1.2.0"}, reject: []string{"1.0", "1.2.1"}},
+		{constraint: "", wantErr: true},
+		{constraint: ">=", wantErr: true},
+		{constraint: ">=1.0, <two", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.constraint, func(t *testing.T) {
+			c, err := ParseConstraint(tt.constraint)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseConstraint(%q) error = %v, wantErr %t", tt.constraint, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			for _, s := range tt.accept {
+				if v, _ := Parse(s); !c.Check(v) {
+					t.Errorf("%q rejects %s", tt.constraint, s)
+				}
+			}
+			for _, s := range tt.reject {
+				if v, _ := Parse(s); c.Check(v) {
+					t.Errorf("%q accepts %s", tt.constraint, s)
+				}
+			}
+		})
+	}
+}
+
+func TestFilterApply(t *testing.T) {
+	versions, _ := SortNames([]string{"1.0.0", "1.1.0-beta", "1.1.0", "1.2.0", "2.0.0-rc.1", "2.0.0"})
+	below2, _ := ParseConstraint("<2.0.0")
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{name: "empty filter", filter: Filter{}, want: []string{"1.0.0", "1.1.0-beta", "1.1.0", "1.2.0", "2.0.0-rc.1", "2.0.0"}},
+		{name: "skip pre-releases", filter: Filter{SkipPreRelease: true}, want: []string{"1.0.0", "1.1.0", "1.2.0", "2.0.0"}},
+		{name: "constraint", filter: Filter{Constraint: below2}, want: []string{"1.0.0", "1.1.0-beta", "1.1.0", "1.2.0", "2.0.0-rc.1"}},
+		{name: "last", filter: Filter{Last: 2}, want: []string{"2.0.0-rc.1", "2.0.0"}},
+		{name: "last after the other checks", filter: Filter{Constraint: below2, SkipPreRelease: true, Last: 2}, want: []string{"1.1.0", "1.2.0"}},
+		{name: "last larger than the list", filter: Filter{Last: 10}, want: []string{"1.0.0", "1.1.0-beta", "1.1.0", "1.2.0", "2.0.0-rc.1", "2.0.0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range tt.filter.Apply(versions) {
+				got = append(got, v.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %v, want %v", got, tt.want)
+			}
+			if empty := tt.filter.IsEmpty(); empty != (tt.name == "empty filter") {
+				t.Errorf("IsEmpty() = %t", empty)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 )
 
 // CalculateFileChecksum computes the SHA-256 hash of a file
@@ -44,3 +45,56 @@ func VerifyFileChecksum(filePath string, expectedChecksum string) (bool, error)
 	}
 	return actualChecksum == expectedChecksum, nil
 }
+
+// FingerprintChecksum computes the checksum of an identity fingerprint: the SHA-256 of each path
+// and its checksum, one pair per line, in the order given
+func FingerprintChecksum(paths []string, checksums map[string]string) string {
+	hash := sha256.New()
+	for _, path := range paths {
+		fmt.Fprintf(hash, "%s\x00%s\n", path, checksums[path])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// ChecksumResult holds the outcome of hashing a single file in parallel
+type ChecksumResult struct {
+	Path     string // File path that was hashed
+	Checksum string // SHA-256 hash (empty if Err is set)
+	Err      error  // Error encountered while hashing, if any
+}
+
+// CalculateFileChecksumsParallel computes SHA-256 hashes for many files using a bounded worker pool.
+// Results are returned in the same order as the input paths.
+func CalculateFileChecksumsParallel(paths []string, workers int) []ChecksumResult {
+	results := make([]ChecksumResult, len(paths))
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+
+	jobs := make(chan int, len(paths))
+	var wg sync.WaitGroup
+
+	// Start workers
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				checksum, err := CalculateFileChecksum(paths[idx])
+				results[idx] = ChecksumResult{Path: paths[idx], Checksum: checksum, Err: err}
+			}
+		}()
+	}
+
+	// Send jobs
+	for idx := range paths {
+		jobs <- idx
+	}
+	close(jobs)
+
+	wg.Wait()
+	return results
+}
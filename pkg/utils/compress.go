@@ -171,6 +171,24 @@ func CompressDataStreaming(src io.Reader, dst io.Writer, algorithm string, level
 	}
 }
 
+// CompressedSize returns the size of src compressed with the algorithm and level, without
+// keeping the compressed data
+func CompressedSize(src io.Reader, algorithm string, level int) (int64, error) {
+	var counter byteCounter
+	if err := CompressDataStreaming(src, &counter, algorithm, level); err != nil {
+		return 0, err
+	}
+	return int64(counter), nil
+}
+
+// byteCounter is a writer that counts the bytes written to it
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
+
 // DecompressDataStreaming decompresses data using streaming
 func DecompressDataStreaming(src io.Reader, dst io.Writer, algorithm string) error {
 	switch algorithm {
@@ -78,6 +78,32 @@ func SavePatch(patch *Patch, filename string, compression string, level int) err
 	return nil
 }
 
+// EncodedPatchSize returns the number of bytes SavePatch would write for the patch, without
+// writing a file
+func EncodedPatchSize(patch *Patch, compression string, level int) (int64, error) {
+	jsonReader, jsonWriter := io.Pipe()
+	defer jsonReader.Close()
+
+	encodeErr := make(chan error, 1)
+	go func() {
+		err := encodePatchStreaming(patch, jsonWriter)
+		jsonWriter.CloseWithError(err)
+		encodeErr <- err
+	}()
+
+	if compression == "" {
+		compression = "none"
+	}
+	size, err := CompressedSize(jsonReader, compression, level)
+	if err != nil {
+		return 0, err
+	}
+	if err := <-encodeErr; err != nil {
+		return 0, fmt.Errorf("failed to encode patch: %w", err)
+	}
+	return size, nil
+}
+
 // LoadPatch loads a patch from a file using streaming decompression.
 func LoadPatch(filename string) (*Patch, error) {
 	file, err := os.Open(filename)
@@ -89,13 +115,89 @@ func LoadPatch(filename string) (*Patch, error) {
 	return loadPatchStreaming(file)
 }
 
+// LoadPatchFromReader loads a patch from any reader (e.g. a section of a self-contained executable)
+// using streaming decompression.
+func LoadPatchFromReader(reader io.Reader) (*Patch, error) {
+	return loadPatchStreaming(reader)
+}
+
+// LoadPatchInfo loads everything but the operations from a patch file. The operations and their
+// file data come last, so only the start of the file is decompressed.
+func LoadPatchInfo(filename string) (*Patch, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open patch file: %w", err)
+	}
+	defer file.Close()
+
+	patchReader, stop, err := decompressPatchStreaming(file)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	decoder := json.NewDecoder(bufio.NewReaderSize(patchReader, 64*1024))
+	decoder.UseNumber()
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("failed to parse patch: not a patch file")
+	}
+
+	// Collect the fields before the operations, then decode them like a whole patch
+	fields := make(map[string]json.RawMessage)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse patch: %w", err)
+		}
+		name, _ := token.(string)
+		if name == "Operations" {
+			break
+		}
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, fmt.Errorf("failed to parse patch: %w", err)
+		}
+		fields[name] = value
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse patch: %w", err)
+	}
+	var patch Patch
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, fmt.Errorf("failed to parse patch: %w", err)
+	}
+	return &patch, nil
+}
+
 // loadPatchStreaming parses patch data using streaming and magic-byte detection.
 func loadPatchStreaming(reader io.Reader) (*Patch, error) {
+	patchReader, stop, err := decompressPatchStreaming(reader)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	var patch Patch
+	bufReader := bufio.NewReaderSize(patchReader, 64*1024) // 64KB buffer is sufficient for streaming JSON
+	decoder := json.NewDecoder(bufReader)
+	decoder.UseNumber()
+	if err := decoder.Decode(&patch); err != nil {
+		return nil, fmt.Errorf("failed to parse patch: %w", err)
+	}
+
+	return &patch, nil
+}
+
+// decompressPatchStreaming detects the compression of patch data from its magic bytes and returns a
+// reader over the decompressed JSON. stop ends the decompression if the JSON is not read to the end.
+func decompressPatchStreaming(reader io.Reader) (io.Reader, func(), error) {
 	// Read first 4 bytes to detect compression format without consuming the stream
 	magic := make([]byte, 4)
 	n, err := io.ReadFull(reader, magic)
 	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
-		return nil, fmt.Errorf("failed to read patch header: %w", err)
+		return nil, nil, fmt.Errorf("failed to read patch header: %w", err)
 	}
 	magic = magic[:n]
 
@@ -105,41 +207,21 @@ func loadPatchStreaming(reader io.Reader) (*Patch, error) {
 	// Reconstruct full reader with magic bytes prepended
 	fullReader := io.MultiReader(bytes.NewReader(magic), reader)
 
-	var patchReader io.Reader
 	switch algo {
 	case "none":
-		patchReader = fullReader
-	case "zstd":
+		return fullReader, func() {}, nil
+	case "zstd", "gzip":
 		pr, pw := io.Pipe()
 		go func() {
 			defer pw.Close()
-			if err := DecompressDataStreaming(fullReader, pw, "zstd"); err != nil {
+			if err := DecompressDataStreaming(fullReader, pw, algo); err != nil {
 				pw.CloseWithError(err)
 			}
 		}()
-		patchReader = pr
-	case "gzip":
-		pr, pw := io.Pipe()
-		go func() {
-			defer pw.Close()
-			if err := DecompressDataStreaming(fullReader, pw, "gzip"); err != nil {
-				pw.CloseWithError(err)
-			}
-		}()
-		patchReader = pr
-	default:
-		return nil, fmt.Errorf("unsupported compression format")
-	}
-
-	var patch Patch
-	bufReader := bufio.NewReaderSize(patchReader, 64*1024) // 64KB buffer is sufficient for streaming JSON
-	decoder := json.NewDecoder(bufReader)
-	decoder.UseNumber()
-	if err := decoder.Decode(&patch); err != nil {
-		return nil, fmt.Errorf("failed to parse patch: %w", err)
+		// Closing the read end makes the decompressor's next write fail, which ends it
+		return pr, func() { pr.Close() }, nil
 	}
-
-	return &patch, nil
+	return nil, nil, fmt.Errorf("unsupported compression format")
 }
 
 // encodePatchStreaming writes the patch as JSON in a streaming fashion to avoid memory exhaustion
@@ -177,6 +259,30 @@ func encodePatchStreaming(patch *Patch, writer io.Writer) error {
 	if err := encodeField(bufWriter, "SimpleMode", patch.SimpleMode, true); err != nil {
 		return err
 	}
+	if err := encodeField(bufWriter, "Hooks", patch.Hooks, true); err != nil {
+		return err
+	}
+	if err := encodeField(bufWriter, "TargetManifest", patch.TargetManifest, true); err != nil {
+		return err
+	}
+	if err := encodeField(bufWriter, "Verification", patch.Verification, true); err != nil {
+		return err
+	}
+	if err := encodeField(bufWriter, "Branding", patch.Branding, true); err != nil {
+		return err
+	}
+	if err := encodeField(bufWriter, "SearchRoots", patch.SearchRoots, true); err != nil {
+		return err
+	}
+	if err := encodeField(bufWriter, "FullInstall", patch.FullInstall, true); err != nil {
+		return err
+	}
+	if err := encodeField(bufWriter, "FromIdentity", patch.FromIdentity, true); err != nil {
+		return err
+	}
+	if err := encodeField(bufWriter, "ToIdentity", patch.ToIdentity, true); err != nil {
+		return err
+	}
 
 	// Encode multi-part info if present
 	if patch.MultiPart != nil {
@@ -193,7 +299,8 @@ func encodePatchStreaming(patch *Patch, writer io.Writer) error {
 		}
 	}
 
-	// Encode operations array manually to stream large data
+	// Encode operations array manually to stream large data. Operations come last so that
+	// LoadPatchInfo can stop before the file data.
 	if _, err := bufWriter.WriteString(`  "Operations": [`); err != nil {
 		return err
 	}
@@ -0,0 +1,281 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// signedOperation is the part of a patch operation covered by the signature.
+// File data is covered indirectly through NewChecksum, which the applier checks before writing it.
+type signedOperation struct {
+	Type        OperationType
+	FilePath    string
+	OldChecksum string
+	NewChecksum string
+}
+
+// signedContent is the canonical form of the patch metadata that gets signed.
+// It excludes anything that differs between a single-part patch and its split parts
+// (header checksum/size, multi-part info and stub sizes). Operations are kept in the order they
+// are applied, which splitting preserves, so a reordered patch does not verify.
+type signedContent struct {
+	FormatVersion int
+	FromVersion   string
+	ToVersion     string
+	FromKeyFile   KeyFileInfo
+	ToKeyFile     KeyFileInfo
+	RequiredFiles []FileRequirement
+	Operations    []signedOperation
+	SimpleMode    bool
+	Hooks         []Hook
+	Target        *signedManifest
+	Verification  *Verification
+	Branding      *Branding
+	SearchRoots   []string
+	FullInstall   bool             `json:",omitempty"` // Omitted when false so signatures of earlier patches stay valid
+	FromIdentity  *VersionIdentity `json:",omitempty"`
+	ToIdentity    *VersionIdentity `json:",omitempty"`
+}
+
+// signedPackage is the canonical form of full-install package info that gets signed (timestamps excluded)
+type signedPackage struct {
+	FormatVersion int
+	Version       string
+	KeyFile       KeyFileInfo
+	Target        *signedManifest
+	Branding      *Branding
+	Hooks         []Hook
+	SearchRoots   []string
+}
+
+// signedManifest is the part of the embedded target manifest covered by the signature (timestamps excluded)
+type signedManifest struct {
+	Checksum    string
+	Files       []FileRequirement
+	Directories []string
+}
+
+// PatchDigest computes the SHA-256 digest of a patch's signable metadata
+func PatchDigest(patch *Patch) ([]byte, error) {
+	content := signedContent{
+		FormatVersion: patch.Header.FormatVersion,
+		FromVersion:   patch.FromVersion,
+		ToVersion:     patch.ToVersion,
+		FromKeyFile:   patch.FromKeyFile,
+		ToKeyFile:     patch.ToKeyFile,
+		RequiredFiles: append([]FileRequirement(nil), patch.RequiredFiles...),
+		Operations:    make([]signedOperation, 0, len(patch.Operations)),
+		SimpleMode:    patch.SimpleMode,
+		Hooks:         patch.Hooks,
+		Verification:  patch.Verification,
+		Branding:      patch.Branding,
+		SearchRoots:   patch.SearchRoots,
+		FullInstall:   patch.FullInstall,
+		FromIdentity:  patch.FromIdentity,
+		ToIdentity:    patch.ToIdentity,
+	}
+	if m := patch.TargetManifest; m != nil {
+		content.Target = newSignedManifest(m)
+	}
+
+	// Required files are only checked, never applied, so their order does not matter
+	sort.Slice(content.RequiredFiles, func(i, j int) bool {
+		return content.RequiredFiles[i].Path < content.RequiredFiles[j].Path
+	})
+	for _, op := range patch.Operations {
+		content.Operations = append(content.Operations, signedOperation{
+			Type:        op.Type,
+			FilePath:    op.FilePath,
+			OldChecksum: op.OldChecksum,
+			NewChecksum: op.NewChecksum,
+		})
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode patch metadata: %w", err)
+	}
+	digest := sha256.Sum256(data)
+	return digest[:], nil
+}
+
+// newSignedManifest returns the signed form of a manifest
+func newSignedManifest(m *Manifest) *signedManifest {
+	signed := &signedManifest{
+		Checksum:    m.Checksum,
+		Files:       make([]FileRequirement, 0, len(m.Files)),
+		Directories: m.Directories,
+	}
+	for _, file := range m.Files {
+		signed.Files = append(signed.Files, FileRequirement{
+			Path:     file.Path,
+			Checksum: file.Checksum,
+			Size:     file.Size,
+		})
+	}
+	return signed
+}
+
+// SignPatch signs the patch metadata and stores the signature in the patch header
+func SignPatch(patch *Patch, privateKey ed25519.PrivateKey) error {
+	digest, err := PatchDigest(patch)
+	if err != nil {
+		return err
+	}
+	patch.Header.Signature = ed25519.Sign(privateKey, digest)
+	patch.Header.SignerKeyID = KeyID(privateKey.Public().(ed25519.PublicKey))
+	return nil
+}
+
+// IsSigned returns true if the patch carries a signature
+func IsSigned(patch *Patch) bool {
+	return len(patch.Header.Signature) > 0
+}
+
+// VerifyPatchSignature checks the patch signature against a set of trusted public keys.
+// Returns the ID of the key that verified the signature.
+func VerifyPatchSignature(patch *Patch, trustedKeys []ed25519.PublicKey) (string, error) {
+	if !IsSigned(patch) {
+		return "", fmt.Errorf("patch is not signed")
+	}
+	if len(trustedKeys) == 0 {
+		return "", fmt.Errorf("no trusted keys configured")
+	}
+
+	digest, err := PatchDigest(patch)
+	if err != nil {
+		return "", err
+	}
+
+	for _, key := range trustedKeys {
+		if ed25519.Verify(key, digest, patch.Header.Signature) {
+			return KeyID(key), nil
+		}
+	}
+
+	if patch.Header.SignerKeyID != "" {
+		return "", fmt.Errorf("signature does not match any trusted key (signed by %s)", patch.Header.SignerKeyID)
+	}
+	return "", fmt.Errorf("signature does not match any trusted key")
+}
+
+// PackageDigest computes the SHA-256 digest of the signable part of full-install package info
+func PackageDigest(info *PackageInfo) ([]byte, error) {
+	content := signedPackage{
+		FormatVersion: info.FormatVersion,
+		Version:       info.Version,
+		KeyFile:       info.KeyFile,
+		Branding:      info.Branding,
+		Hooks:         info.Hooks,
+		SearchRoots:   info.SearchRoots,
+	}
+	if info.Manifest != nil {
+		content.Target = newSignedManifest(info.Manifest)
+	}
+
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode package info: %w", err)
+	}
+	digest := sha256.Sum256(data)
+	return digest[:], nil
+}
+
+// SignPackage signs full-install package info
+func SignPackage(info *PackageInfo, privateKey ed25519.PrivateKey) error {
+	digest, err := PackageDigest(info)
+	if err != nil {
+		return err
+	}
+	info.Signature = ed25519.Sign(privateKey, digest)
+	info.SignerKeyID = KeyID(privateKey.Public().(ed25519.PublicKey))
+	return nil
+}
+
+// VerifyPackageSignature checks the signature of full-install package info against a set of trusted public keys.
+// Returns the ID of the key that verified the signature.
+func VerifyPackageSignature(info *PackageInfo, trustedKeys []ed25519.PublicKey) (string, error) {
+	if len(info.Signature) == 0 {
+		return "", fmt.Errorf("package is not signed")
+	}
+	if len(trustedKeys) == 0 {
+		return "", fmt.Errorf("no trusted keys configured")
+	}
+
+	digest, err := PackageDigest(info)
+	if err != nil {
+		return "", err
+	}
+	for _, key := range trustedKeys {
+		if ed25519.Verify(key, digest, info.Signature) {
+			return KeyID(key), nil
+		}
+	}
+	if info.SignerKeyID != "" {
+		return "", fmt.Errorf("signature does not match any trusted key (signed by %s)", info.SignerKeyID)
+	}
+	return "", fmt.Errorf("signature does not match any trusted key")
+}
+
+// KeyID returns a short identifier for a public key (first 16 hex chars of its SHA-256)
+func KeyID(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// GenerateSigningKeyPair creates a new ed25519 key pair and writes it as hex to privatePath and publicPath
+func GenerateSigningKeyPair(privatePath, publicPath string) (string, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate key pair: %w", err)
+	}
+
+	if err := os.WriteFile(privatePath, []byte(hex.EncodeToString(privateKey.Seed())+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to write private key: %w", err)
+	}
+	if err := os.WriteFile(publicPath, []byte(hex.EncodeToString(publicKey)+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("failed to write public key: %w", err)
+	}
+
+	return KeyID(publicKey), nil
+}
+
+// LoadSigningKey reads a hex-encoded ed25519 private key (seed) from a file
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := readHexKey(path, ed25519.SeedSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load signing key: %w", err)
+	}
+	return ed25519.NewKeyFromSeed(data), nil
+}
+
+// LoadPublicKey reads a hex-encoded ed25519 public key from a file
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	data, err := readHexKey(path, ed25519.PublicKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load public key: %w", err)
+	}
+	return ed25519.PublicKey(data), nil
+}
+
+// readHexKey reads a hex-encoded key of the expected length from a file
+func readHexKey(path string, size int) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := hex.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: invalid hex encoding: %w", path, err)
+	}
+	if len(data) != size {
+		return nil, fmt.Errorf("%s: expected %d bytes, got %d", path, size, len(data))
+	}
+	return data, nil
+}
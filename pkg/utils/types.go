@@ -12,6 +12,9 @@ type Version struct {
 	Manifest     *Manifest   // Complete file manifest
 	RegisteredAt time.Time   // When version was registered
 	LastScanned  time.Time   // When manifest was last updated
+
+	ArchiveSize    int64     // Size of the version archive when it was scanned (0 for directories)
+	ArchiveModTime time.Time // Modification time of the version archive when it was scanned
 }
 
 // KeyFileInfo identifies the main executable for version verification
@@ -40,21 +43,91 @@ type FileEntry struct {
 	Checksum     string    // SHA-256 hash
 	ModTime      time.Time // Modification time
 	IsExecutable bool      // Executable flag
+	Mode         uint32    // Unix permission bits (0o777) of an archive member (0 = not recorded)
+	LinkTarget   string    // Symlink target as stored, or hard link target from the version root, of an archive member; the entry holds the target's contents
 }
 
 // Patch represents a delta between two versions
 type Patch struct {
-	Header        PatchHeader       // Patch metadata
-	FromVersion   string            // Source version number
-	ToVersion     string            // Target version number
-	FromKeyFile   KeyFileInfo       // Source key file verification
-	ToKeyFile     KeyFileInfo       // Target key file verification
-	RequiredFiles []FileRequirement // Files that MUST exist with exact hashes
-	Operations    []PatchOperation  // List of changes to apply
-	SimpleMode    bool              // If true, show simplified UI for end users (minimal options, no advanced settings)
-	MultiPart     *MultiPartInfo    // Multi-part patch information (nil if single-part)
+	Header         PatchHeader       // Patch metadata
+	FromVersion    string            // Source version number
+	ToVersion      string            // Target version number
+	FromKeyFile    KeyFileInfo       // Source key file verification
+	ToKeyFile      KeyFileInfo       // Target key file verification
+	RequiredFiles  []FileRequirement // Files that MUST exist with exact hashes
+	Operations     []PatchOperation  // List of changes to apply
+	SimpleMode     bool              // If true, show simplified UI for end users (minimal options, no advanced settings)
+	MultiPart      *MultiPartInfo    // Multi-part patch information (nil if single-part)
+	Hooks          []Hook            // Scripts run by the applier at defined phases (only if signed or explicitly allowed)
+	TargetManifest *Manifest         // Complete manifest of the target version (optional; only in part 1 of multi-part patches)
+	Verification   *Verification     // How RequiredFiles were selected (nil = full, for patches from older generators)
+	Branding       *Branding         // How the applier presents the patch to end users (nil = default CyberPatchMaker UI)
+	SearchRoots    []string          // Directories self-contained appliers search for the install (may contain environment variables)
+	FullInstall    bool              // Installs the target version into an empty directory (no source version or key file)
+	FromIdentity   *VersionIdentity  // Checks identifying the source version besides its key file (nil = key file only)
+	ToIdentity     *VersionIdentity  // Checks identifying the target version besides its key file (nil = key file only)
+}
+
+// VersionIdentity identifies a version by more than its key file, for products whose key file is
+// shared between builds or may be renamed. Every check must match, together with the key file.
+type VersionIdentity struct {
+	Files       []KeyFileInfo // Further files that must match exactly
+	Fingerprint *Fingerprint  // Digest over a sample of the version's files (nil = none)
+}
+
+// Fingerprint is a digest over a deterministic sample of a version's files
+type Fingerprint struct {
+	SamplePercent int      // Percentage of files sampled at generation time
+	Paths         []string // Sampled files, sorted
+	Checksum      string   // SHA-256 over the sampled paths and their checksums (see FingerprintChecksum)
+}
+
+// Branding customizes the applier's simple and interactive modes for a product
+type Branding struct {
+	ProductName    string // Shown in banners instead of "CyberPatchMaker"
+	Publisher      string // Company or author shown under the product name
+	WelcomeText    string // Shown before the patch details
+	EULA           string // License text the user must accept before the patch is applied (empty = none)
+	SupportURL     string // Shown in banners and when patching fails
+	InstallDirHint string // Where the product is usually installed, shown when asking for the target directory
+}
+
+// VerificationLevel controls which source files a patch requires to match before it is applied
+type VerificationLevel string
+
+// Verification levels
+const (
+	VerificationFull    VerificationLevel = "full"    // Every source file
+	VerificationTouched VerificationLevel = "touched" // Files being modified or deleted, plus the key file
+	VerificationSampled VerificationLevel = "sampled" // Touched files plus a deterministic sample of the rest
+)
+
+// Verification records how a patch's RequiredFiles were selected from the source version.
+// Source files left out of RequiredFiles are not recorded at all, so they are not checked before patching.
+type Verification struct {
+	Level         VerificationLevel // Verification level used at generation time
+	SamplePercent int               // Percentage of untouched files included (sampled level only)
+	SourceFiles   int               // Total number of files in the source version
+}
+
+// Hook describes a command the applier runs at a defined phase of patch application
+type Hook struct {
+	Phase          string   // One of the HookPhase* constants
+	Command        string   // Executable to run (looked up in PATH, or relative to WorkingDir)
+	Args           []string // Command arguments
+	WorkingDir     string   // Working directory (relative to the target directory; empty = target directory)
+	TimeoutSeconds int      // Maximum run time in seconds (0 = no timeout)
+	AbortOnFailure bool     // If true, a failing hook aborts the apply (and rolls back after operations started)
 }
 
+// Hook phases, in the order they run during patch application
+const (
+	HookPhasePreVerify  = "pre-verify"  // Before pre-patch verification
+	HookPhasePreApply   = "pre-apply"   // After backup, before any operation is applied
+	HookPhasePostApply  = "post-apply"  // After operations and post-patch verification succeeded
+	HookPhaseOnRollback = "on-rollback" // After an automatic rollback restored the backup
+)
+
 // MultiPartInfo contains metadata for multi-part patches
 type MultiPartInfo struct {
 	IsMultiPart bool       // True if this is a multi-part patch
@@ -62,6 +135,7 @@ type MultiPartInfo struct {
 	TotalParts  int        // Total number of parts
 	PartHashes  []PartHash // Hashes of all parts for verification (only in part 1)
 	MaxPartSize int64      // Maximum size per part (default 4GB)
+	Stub        bool       // True if this part 1 carries no file data; the full part 1 is stored in chunks
 }
 
 // PartHash stores hash information for a patch part
@@ -125,6 +199,29 @@ const (
 	DefaultMaxPartSize = 4 * 1024 * 1024 * 1024 // 4 GB
 )
 
+// Names of files and directories the applier creates inside an install
+const (
+	BackupDirName       = "backup.cyberpatcher"        // Mirror backup of files changed by the last patch
+	LockFileName        = ".cyberpatcher.lock"         // Advisory lock held while an install is being modified
+	PackageInfoFileName = ".cyberpatcher-package.json" // Manifest, branding and signature of a full-install package
+)
+
+// PackageInfo describes a full-install package (a zip or tar.zst of a whole version). It is stored
+// in the package as PackageInfoFileName and carries the same manifest, branding and signature a
+// full-install patch does.
+type PackageInfo struct {
+	FormatVersion int         // Package info format version
+	Version       string      // Version in the package
+	KeyFile       KeyFileInfo // Key file of the version
+	Manifest      *Manifest   // Complete manifest of the version
+	Branding      *Branding   // Product branding (nil = none)
+	Hooks         []Hook      // Hook scripts of the matching full-install patch, for installers that run them
+	SearchRoots   []string    // Where the product is usually installed
+	CreatedAt     time.Time   // Creation timestamp
+	Signature     []byte      // Digital signature (optional)
+	SignerKeyID   string      // ID of the key that produced Signature
+}
+
 // PatchHeader contains patch-level information
 type PatchHeader struct {
 	FormatVersion int       // Patch format version
@@ -133,15 +230,25 @@ type PatchHeader struct {
 	PatchSize     int64     // Compressed patch size
 	Checksum      string    // Patch file checksum
 	Signature     []byte    // Digital signature (optional)
+	SignerKeyID   string    // ID of the key that produced Signature (first 16 hex chars of SHA-256 of the public key)
 }
 
 // PatchOptions configures patch generation
 type PatchOptions struct {
 	Compression       string // "zstd", "gzip", "none"
 	CompressionLevel  int    // 1-4 for zstd, 1-3 for gzip
-	GenerateSignature bool   // Create digital signature (future)
+	GenerateSignature bool   // Patch will be signed after generation
 	ParallelWorkers   int    // Number of parallel workers
 	SkipIdentical     bool   // Skip binary-identical files
+	Hooks             []Hook // Hook scripts to embed in the patch
+	EmbedManifest     bool   // Embed the complete target manifest (enables repair and full-tree verification)
+
+	VerificationLevel VerificationLevel // Which source files become RequiredFiles (empty = full)
+	SamplePercent     int               // Percentage of untouched files to require at the sampled level
+	Branding          *Branding         // Product branding to embed in the patch
+	SearchRoots       []string          // Install search roots to embed in the patch
+	IdentityFiles     []string          // Files identifying each version besides its key file
+	IdentitySample    int               // Percentage of files in each version's identity fingerprint (0 = no fingerprint)
 }
 
 // Config stores application configuration
@@ -155,6 +262,7 @@ type Config struct {
 	PreservePerms      bool                // Preserve file permissions
 	VerifySignatures   bool                // Verify patch signatures
 	SigningKeyPath     string              // Path to signing key
+	KeyFileCandidates  []string            // Key file names or glob patterns tried when none is given (empty = built-in list)
 }
 
 // VersionRegistry tracks all registered versions